@@ -0,0 +1,81 @@
+// Standalone executable that compares two osbuild manifests and prints
+// the pipelines, stages, packages and sources that differ.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/osbuild/images/pkg/osbuild/manifestdiff"
+)
+
+// readManifest reads a manifest file, the manifests that are generated
+// by cmd/gen-manifests wrap the manifest in an object with additional
+// information about the build
+func readManifest(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var wrapped struct {
+		Manifest json.RawMessage `json:"manifest"`
+	}
+	if err := json.Unmarshal(data, &wrapped); err != nil {
+		return nil, fmt.Errorf("cannot parse %q: %w", path, err)
+	}
+	if len(wrapped.Manifest) > 0 {
+		return wrapped.Manifest, nil
+	}
+	return data, nil
+}
+
+func run() (bool, error) {
+	var asJSON, exitCode bool
+	flag.BoolVar(&asJSON, "json", false, "print the difference as json")
+	flag.BoolVar(&exitCode, "exit-code", false, "exit with 1 if the manifests differ")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <old manifest> <new manifest>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	oldManifest, err := readManifest(flag.Arg(0))
+	if err != nil {
+		return false, err
+	}
+	newManifest, err := readManifest(flag.Arg(1))
+	if err != nil {
+		return false, err
+	}
+	diff, err := manifestdiff.Compare(oldManifest, newManifest)
+	if err != nil {
+		return false, err
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(diff)
+	} else {
+		err = diff.WriteText(os.Stdout)
+	}
+	return exitCode && !diff.Empty(), err
+}
+
+func main() {
+	differ, err := run()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(2)
+	}
+	if differ {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/osbuild/images/pkg/distro/defs"
+	"github.com/osbuild/images/pkg/platform"
+)
+
+func explain(w io.Writer, searchPaths []fs.FS, distroName, archName, imgTypeName string, asJSON bool) error {
+	d, err := defs.NewDistroYAML(distroName, searchPaths...)
+	if err != nil {
+		return err
+	}
+	if d == nil {
+		return fmt.Errorf("cannot find distro %q", distroName)
+	}
+	expl, err := d.Explain(imgTypeName, archName)
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(expl)
+	}
+
+	valueStr := func(v any) string {
+		if s, ok := v.(string); ok {
+			return s
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(b)
+	}
+	printValues := func(values []defs.ExplainedValue) {
+		for _, v := range values {
+			fmt.Fprintf(w, "  %s: %s\n    (from %s)\n", v.Key, valueStr(v.Value), v.Source)
+		}
+	}
+
+	fmt.Fprintf(w, "%s %s %s\n", expl.Distro, expl.Arch, expl.ImageType)
+	if len(expl.ExtendsChain) > 0 {
+		fmt.Fprintf(w, "extends: %s\n", strings.Join(expl.ExtendsChain, " -> "))
+	}
+	if expl.Platform != nil {
+		fmt.Fprintf(w, "\nplatform (from %s):\n", expl.PlatformSource)
+		for _, v := range platformValues(expl.Platform) {
+			fmt.Fprintf(w, "  %s: %s\n", v.key, valueStr(v.value))
+		}
+	}
+
+	fmt.Fprintf(w, "\nimage_config:\n")
+	printValues(expl.ImageConfig)
+	if len(expl.InstallerConfig) > 0 {
+		fmt.Fprintf(w, "\ninstaller_config:\n")
+		printValues(expl.InstallerConfig)
+	}
+
+	pkgSetNames := make([]string, 0, len(expl.PackageSets))
+	for name := range expl.PackageSets {
+		pkgSetNames = append(pkgSetNames, name)
+	}
+	sort.Strings(pkgSetNames)
+	for _, name := range pkgSetNames {
+		fmt.Fprintf(w, "\npackage_sets.%s:\n", name)
+		for _, v := range expl.PackageSets[name] {
+			sign := "+"
+			if v.Key == "exclude" {
+				sign = "-"
+			}
+			fmt.Fprintf(w, "  %s%s (from %s)\n", sign, valueStr(v.Value), v.Source)
+		}
+	}
+
+	if expl.PartitionTable != nil {
+		b, err := json.MarshalIndent(expl.PartitionTable, "  ", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "\npartition_table (from %s):\n  %s\n", expl.PartitionTableSource, b)
+	}
+
+	return nil
+}
+
+type platformValue struct {
+	key   string
+	value any
+}
+
+// platformValues returns the set values of the platform with the
+// enums as their names
+func platformValues(pl *platform.Data) []platformValue {
+	var res []platformValue
+	add := func(key string, value any, isSet bool) {
+		if isSet {
+			res = append(res, platformValue{key, value})
+		}
+	}
+	add("arch", pl.Arch.String(), true)
+	add("image_format", pl.ImageFormat.String(), pl.ImageFormat != platform.FORMAT_UNSET)
+	add("qcow2_compat", pl.QCOW2Compat, pl.QCOW2Compat != "")
+	add("bios_platform", pl.BIOSPlatform, pl.BIOSPlatform != "")
+	add("uefi_vendor", pl.UEFIVendor, pl.UEFIVendor != "")
+	add("zipl_support", pl.ZiplSupport, pl.ZiplSupport)
+	add("packages", pl.Packages, len(pl.Packages) > 0)
+	add("build_packages", pl.BuildPackages, len(pl.BuildPackages) > 0)
+	add("boot_files", pl.BootFiles, len(pl.BootFiles) > 0)
+	add("bootloader", pl.Bootloader.String(), pl.Bootloader != platform.BOOTLOADER_NONE)
+	add("fips_menu", pl.FIPSMenu, pl.FIPSMenu)
+	return res
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplainGolden(t *testing.T) {
+	for _, tc := range []struct {
+		imgType string
+		asJSON  bool
+		golden  string
+	}{
+		{"base-qcow2", false, "explain-base-qcow2.golden"},
+		{"hardened-qcow2", false, "explain-hardened-qcow2.golden"},
+		{"hardened-qcow2", true, "explain-hardened-qcow2.json.golden"},
+	} {
+		t.Run(tc.golden, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := explain(&buf, testSearchPaths(), "test-distro-1", "x86_64", tc.imgType, tc.asJSON)
+			require.NoError(t, err)
+			assertGolden(t, tc.golden, buf.Bytes())
+		})
+	}
+}
+
+func TestExplainErrors(t *testing.T) {
+	var buf bytes.Buffer
+	err := explain(&buf, testSearchPaths(), "no-such-distro-1", "x86_64", "base-qcow2", false)
+	assert.EqualError(t, err, `cannot find distro "no-such-distro-1"`)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"path/filepath"
+	"slices"
+	"sort"
+	"text/template"
+
+	"github.com/osbuild/images/internal/cmdutil"
+	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/disk"
+	"github.com/osbuild/images/pkg/disk/partition"
+	"github.com/osbuild/images/pkg/distro"
+	"github.com/osbuild/images/pkg/distro/defs"
+	"github.com/osbuild/images/pkg/distro/generic"
+	"github.com/osbuild/images/pkg/platform"
+)
+
+type lintIssue struct {
+	Distro    string
+	Arch      string
+	ImageType string
+	Msg       string
+}
+
+func (i lintIssue) String() string {
+	where := i.Distro
+	if i.ImageType != "" {
+		where += "/" + i.ImageType
+	}
+	if i.Arch != "" {
+		where += "/" + i.Arch
+	}
+	return fmt.Sprintf("%s: %s", where, i.Msg)
+}
+
+type linter struct {
+	issues []lintIssue
+}
+
+func (l *linter) addf(d *defs.DistroYAML, archName, imgTypeName, format string, a ...any) {
+	l.issues = append(l.issues, lintIssue{
+		Distro:    d.ID.String(),
+		Arch:      archName,
+		ImageType: imgTypeName,
+		Msg:       fmt.Sprintf(format, a...),
+	})
+}
+
+// lint loads all given distros and checks all their image types for
+// all architectures.
+func lint(searchPaths []fs.FS, distroGlobs, archGlobs cmdutil.MultiValue) ([]lintIssue, error) {
+	allDistros, err := defs.Distros(searchPaths...)
+	if err != nil {
+		return nil, err
+	}
+	distroNames, invalid := distroGlobs.ResolveArgValues(allDistros)
+	if len(invalid) > 0 {
+		return nil, fmt.Errorf("invalid distro names: %v", invalid)
+	}
+
+	var l linter
+	for _, distroName := range distroNames {
+		d, err := defs.NewDistroYAML(distroName, searchPaths...)
+		if err != nil {
+			l.issues = append(l.issues, lintIssue{Distro: distroName, Msg: fmt.Sprintf("cannot load: %v", err)})
+			continue
+		}
+		if d == nil {
+			l.issues = append(l.issues, lintIssue{Distro: distroName, Msg: "cannot find distro"})
+			continue
+		}
+		l.lintDistro(d, archGlobs)
+	}
+	return l.issues, nil
+}
+
+func (l *linter) lintDistro(d *defs.DistroYAML, archGlobs cmdutil.MultiValue) {
+	for _, overlap := range d.ConditionOverlaps() {
+		l.addf(d, overlap.Arch, overlap.ImageType, "%s conditions %q overlap on keys %q", overlap.Section, overlap.Conditions, overlap.Keys)
+	}
+
+	imgTypeNames := make([]string, 0, len(d.ImageTypes()))
+	for name := range d.ImageTypes() {
+		imgTypeNames = append(imgTypeNames, name)
+	}
+	sort.Strings(imgTypeNames)
+	for _, name := range imgTypeNames {
+		it := d.ImageTypes()[name]
+		for mnt := range it.RequiredPartitionSizes {
+			if !filepath.IsAbs(mnt) {
+				l.addf(d, "", name, "required_partition_sizes key %q is not an absolute path", mnt)
+			}
+		}
+
+		platforms, err := it.PlatformsFor(d.ID)
+		if err != nil {
+			l.addf(d, "", name, "%v", err)
+			continue
+		}
+		// image types without platforms are never instantiated (e.g.
+		// when they only provide package sets for other tools)
+		if len(platforms) > 0 && !slices.Contains(generic.ImageFuncNames(), it.Image) {
+			l.addf(d, "", name, "unknown image_func %q (supported: %v)", it.Image, generic.ImageFuncNames())
+		}
+		seen := make(map[arch.Arch]bool)
+		var archNames []string
+		for _, pl := range platforms {
+			if pl.Arch == arch.ARCH_UNSET {
+				l.addf(d, "", name, "platform without arch")
+				continue
+			}
+			if seen[pl.Arch] {
+				l.addf(d, pl.Arch.String(), name, "duplicated platform")
+				continue
+			}
+			seen[pl.Arch] = true
+			archNames = append(archNames, pl.Arch.String())
+		}
+		archNames, _ = archGlobs.ResolveArgValues(archNames)
+
+		for _, archName := range archNames {
+			idx := slices.IndexFunc(platforms, func(pl platform.Data) bool { return pl.Arch.String() == archName })
+			l.lintImageType(d, &it, &platforms[idx])
+		}
+	}
+}
+
+func (l *linter) lintImageType(d *defs.DistroYAML, it *defs.ImageTypeYAML, pl *platform.Data) {
+	archName := pl.Arch.String()
+	add := func(format string, a ...any) {
+		l.addf(d, archName, it.Name(), format, a...)
+	}
+
+	if it.Bootable && platform.BootModeFor(pl) == platform.BOOT_NONE {
+		add("bootable image type without bios_platform, uefi_vendor or zipl_support")
+	}
+
+	// templates that are only expanded when a manifest is generated
+	if it.BootISO {
+		if err := checkISOLabelTmpl(d, archName, it.ISOLabel); err != nil {
+			add("invalid iso_label_tmpl: %v", err)
+		}
+	}
+
+	for key, pkgSet := range it.PackageSets(d.ID, archName) {
+		for _, pkg := range pkgSet.Include {
+			if slices.Contains(pkgSet.Exclude, pkg) {
+				add("package %q is both included and excluded in package set %q", pkg, key)
+			}
+		}
+	}
+
+	if it.PartitionTables == nil {
+		if it.Image == "disk" {
+			add("disk image type without partition table")
+		}
+		return
+	}
+	pt, err := it.PartitionTable(d.ID, archName)
+	if err != nil {
+		add("%v", err)
+		return
+	}
+	if pt == nil {
+		add("partition table override without a table for this arch")
+		return
+	}
+	lintPartitionTable(d, it, pl, pt, add)
+}
+
+func lintPartitionTable(d *defs.DistroYAML, it *defs.ImageTypeYAML, pl *platform.Data, pt *disk.PartitionTable, add func(format string, a ...any)) {
+	mountpoints := make(map[string]bool)
+	err := pt.ForEachMountable(func(mnt disk.Mountable, _ []disk.Entity) error {
+		if mountpoints[mnt.GetMountpoint()] {
+			add("duplicated mountpoint %q in partition table", mnt.GetMountpoint())
+		}
+		mountpoints[mnt.GetMountpoint()] = true
+		return nil
+	})
+	if err != nil {
+		add("cannot iterate partition table: %v", err)
+	}
+
+	// this is what happens when a manifest is generated, it will
+	// apply the required sizes and do the final layout
+	rng := rand.New(rand.NewSource(0)) // nolint:gosec
+	final, err := disk.NewPartitionTable(pt, nil, it.DefaultSize, partition.DefaultPartitioningMode, pl.Arch, it.RequiredPartitionSizes, d.DefaultFSType.String(), rng)
+	if err != nil {
+		add("cannot create partition table: %v", err)
+		return
+	}
+	if final.FindMountable("/") == nil && it.Image == "disk" {
+		add("partition table without a root filesystem")
+	}
+	for mnt, size := range it.RequiredPartitionSizes {
+		if final.FindMountable(mnt) == nil {
+			continue
+		}
+		mntSize, err := final.GetMountpointSize(mnt)
+		if err != nil {
+			add("cannot get size of required mountpoint %q: %v", mnt, err)
+			continue
+		}
+		if mntSize < size {
+			add("mountpoint %q is smaller (%v) than the required size %v", mnt, mntSize, size)
+		}
+	}
+	if final.Size < final.HeaderSize() {
+		add("partition table size %v is smaller than its header", final.Size)
+	}
+}
+
+func checkISOLabelTmpl(d *defs.DistroYAML, archName, isoLabel string) error {
+	templ, err := template.New("iso-label").Parse(d.ISOLabelTmpl)
+	if err != nil {
+		return err
+	}
+	// keep in sync with generic.distribution.getISOLabelFunc
+	type inputs struct {
+		Distro   *distro.ID
+		Product  string
+		Arch     string
+		ISOLabel string
+	}
+	var buf bytes.Buffer
+	return templ.Execute(&buf, inputs{
+		Distro:   &d.ID,
+		Product:  d.Product,
+		Arch:     archName,
+		ISOLabel: isoLabel,
+	})
+}
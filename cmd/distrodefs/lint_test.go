@@ -0,0 +1,26 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintGolden(t *testing.T) {
+	issues, err := lint(testSearchPaths(), nil, nil)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	for _, issue := range issues {
+		fmt.Fprintln(&buf, issue)
+	}
+	assertGolden(t, "lint.golden", buf.Bytes())
+}
+
+func TestLintInvalidDistro(t *testing.T) {
+	_, err := lint(testSearchPaths(), []string{"no-such-distro"}, nil)
+	assert.EqualError(t, err, `invalid distro names: [no-such-distro]`)
+}
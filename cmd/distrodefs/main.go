@@ -0,0 +1,102 @@
+// Standalone executable to check and explain the YAML distro
+// definitions under data/distrodefs.
+//
+// Usage:
+//
+//	distrodefs lint [-distros ...] [-arches ...] [-layers ...]
+//	distrodefs explain [-layers ...] [-json] <distro> <arch> <imgtype>
+//	distrodefs schema [-output <dir>]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/osbuild/images/data/distrodefs"
+	"github.com/osbuild/images/internal/cmdutil"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s lint|explain|schema [options]\n", os.Args[0])
+}
+
+// searchPathsFor returns the embedded distro definitions with the
+// given extra layers on top
+func searchPathsFor(layers cmdutil.MultiValue) []fs.FS {
+	searchPaths := []fs.FS{distrodefs.Data}
+	for _, layer := range layers {
+		searchPaths = append(searchPaths, os.DirFS(layer))
+	}
+	return searchPaths
+}
+
+func run(args []string) error {
+	if len(args) < 1 {
+		usage()
+		os.Exit(1)
+	}
+
+	var layers cmdutil.MultiValue
+	switch args[0] {
+	case "lint":
+		var distros, arches cmdutil.MultiValue
+		flags := flag.NewFlagSet("lint", flag.ExitOnError)
+		flags.Var(&distros, "distros", "comma-separated list of distributions (globs supported)")
+		flags.Var(&arches, "arches", "comma-separated list of architectures (globs supported)")
+		flags.Var(&layers, "layers", "comma-separated list of directories with extra distro definitions")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		issues, err := lint(searchPathsFor(layers), distros, arches)
+		if err != nil {
+			return err
+		}
+		for _, issue := range issues {
+			fmt.Println(issue)
+		}
+		if len(issues) > 0 {
+			return fmt.Errorf("found %v issues", len(issues))
+		}
+		return nil
+	case "explain":
+		var asJSON bool
+		flags := flag.NewFlagSet("explain", flag.ExitOnError)
+		flags.Var(&layers, "layers", "comma-separated list of directories with extra distro definitions")
+		flags.BoolVar(&asJSON, "json", false, "print the explanation as json")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if flags.NArg() != 3 {
+			return fmt.Errorf("usage: %s explain [options] <distro> <arch> <imgtype>", os.Args[0])
+		}
+		return explain(os.Stdout, searchPathsFor(layers), flags.Arg(0), flags.Arg(1), flags.Arg(2), asJSON)
+	case "schema":
+		var output string
+		flags := flag.NewFlagSet("schema", flag.ExitOnError)
+		flags.StringVar(&output, "output", "data/distrodefs/schemas", "directory to write the JSON schemas to")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		written, err := writeSchemas(output)
+		if err != nil {
+			return err
+		}
+		for _, p := range written {
+			fmt.Println(p)
+		}
+		return nil
+	default:
+		usage()
+		return fmt.Errorf("unknown command %q, expected one of: %s", args[0], strings.Join([]string{"lint", "explain", "schema"}, ","))
+	}
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+func testSearchPaths() []fs.FS {
+	return []fs.FS{os.DirFS("testdata/defs")}
+}
+
+// assertGolden compares the output with the given file in testdata,
+// run the tests with "-update" to regenerate the files
+func assertGolden(t *testing.T, name string, output []byte) {
+	t.Helper()
+
+	p := filepath.Join("testdata", name)
+	if *update {
+		require.NoError(t, os.WriteFile(p, output, 0644))
+	}
+	expected, err := os.ReadFile(p)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(output))
+}
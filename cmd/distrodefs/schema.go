@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/osbuild/images/pkg/distro/defs"
+)
+
+// writeSchemas writes the JSON Schemas for the distro definitions
+// into the given directory
+func writeSchemas(dir string) ([]string, error) {
+	files, err := defs.JSONSchemaFiles()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	var written []string
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, content, 0644); err != nil {
+			return nil, err
+		}
+		written = append(written, p)
+	}
+	sort.Strings(written)
+	return written, nil
+}
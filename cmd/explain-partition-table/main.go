@@ -0,0 +1,184 @@
+// Standalone executable that explains the partition table of an image
+// type: the resolved layout with offsets and sizes and why each entity
+// has the size it has.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/osbuild/blueprint/pkg/blueprint"
+	"github.com/osbuild/images/internal/buildconfig"
+	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/datasizes"
+	"github.com/osbuild/images/pkg/disk"
+	"github.com/osbuild/images/pkg/disk/partition"
+	"github.com/osbuild/images/pkg/distro"
+	"github.com/osbuild/images/pkg/distrofactory"
+)
+
+// formatSize returns the size in the largest binary unit that
+// represents it without a fraction, e.g. "1 GiB" or "1536 MiB".
+func formatSize(size uint64) string {
+	for _, unit := range []struct {
+		name string
+		size uint64
+	}{
+		{"TiB", datasizes.TiB},
+		{"GiB", datasizes.GiB},
+		{"MiB", datasizes.MiB},
+		{"KiB", datasizes.KiB},
+	} {
+		if size >= unit.size && size%unit.size == 0 {
+			return fmt.Sprintf("%d %s", size/unit.size, unit.name)
+		}
+	}
+	return fmt.Sprintf("%d B", size)
+}
+
+func formatReasons(reasons []disk.SizeReason) string {
+	var res []string
+	for _, reason := range reasons {
+		s := string(reason.Source)
+		if reason.Detail != "" {
+			s += fmt.Sprintf(" (%s)", reason.Detail)
+		}
+		switch reason.Source {
+		case disk.SizeSourceAlignment, disk.SizeSourceGrow:
+			s += " +" + formatSize(reason.Size)
+		default:
+			s += " " + formatSize(reason.Size)
+		}
+		if reason.Driving {
+			s += " *"
+		}
+		res = append(res, s)
+	}
+	return strings.Join(res, ", ")
+}
+
+func printTable(w io.Writer, report *disk.PartitionTableReport) error {
+	fmt.Fprintf(w, "partition table: %s, %s (%d sectors of %d bytes)", report.Type, formatSize(report.Size), report.Sectors, report.SectorSize)
+	if report.RequestedSize > 0 {
+		fmt.Fprintf(w, ", requested %s", formatSize(report.RequestedSize))
+	}
+	if report.Mode != "" {
+		fmt.Fprintf(w, ", partitioning mode %s", report.Mode)
+	}
+	if report.DPS {
+		fmt.Fprint(w, ", discoverable partitions")
+	}
+	fmt.Fprintf(w, "\nheader: %s", formatSize(report.HeaderSize))
+	if report.StartOffset > 0 {
+		fmt.Fprintf(w, ", start offset %s", formatSize(report.StartOffset))
+	}
+	fmt.Fprint(w, "\n\n")
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATH\tKIND\tMOUNTPOINT\tFSTYPE\tSTART\tSECTORS\tSIZE\tMETADATA\tALIGN\tAUTO\tREASONS (* = driving)")
+	for _, ent := range report.Entities {
+		var start, sectors, size, metadata, align, auto string
+		if ent.Kind == "partition" {
+			start = fmt.Sprintf("%d", ent.StartSector)
+			sectors = fmt.Sprintf("%d", ent.SizeSectors)
+		}
+		if ent.Size > 0 {
+			size = formatSize(ent.Size)
+		}
+		if ent.MetadataSize > 0 {
+			metadata = formatSize(ent.MetadataSize)
+		}
+		if ent.Alignment > 0 {
+			align = formatSize(ent.Alignment)
+		}
+		if ent.AutoCreated {
+			auto = "yes"
+		}
+		fmt.Fprintf(tw, "%s%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			strings.Repeat("  ", ent.Depth), ent.Path, ent.Kind, ent.Mountpoint, ent.FSType,
+			start, sectors, size, metadata, align, auto, formatReasons(ent.Reasons))
+	}
+	return tw.Flush()
+}
+
+func run() error {
+	var distroName, archName, imgTypeName, configFile, size, mode string
+	var asJSON bool
+	flag.StringVar(&distroName, "distro", "", "distribution (required)")
+	flag.StringVar(&archName, "arch", arch.Current().String(), "architecture")
+	flag.StringVar(&imgTypeName, "type", "", "image type name (required)")
+	flag.StringVar(&configFile, "config", "", "build config file with the blueprint and image options")
+	flag.StringVar(&size, "size", "", "image size, e.g. \"10 GiB\" (overrides the size of the config)")
+	flag.StringVar(&mode, "partitioning-mode", "", "partitioning mode (overrides the mode of the config)")
+	flag.BoolVar(&asJSON, "json", false, "print the report as json")
+	flag.Parse()
+
+	if distroName == "" || imgTypeName == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	bp := &blueprint.Blueprint{}
+	var options distro.ImageOptions
+	if configFile != "" {
+		config, err := buildconfig.New(configFile, nil)
+		if err != nil {
+			return err
+		}
+		if config.Blueprint != nil {
+			bp = config.Blueprint
+		}
+		options = config.Options
+	}
+	if size != "" {
+		var err error
+		options.Size, err = datasizes.Parse(size)
+		if err != nil {
+			return fmt.Errorf("invalid size %q: %w", size, err)
+		}
+	}
+	if mode != "" {
+		options.PartitioningMode = partition.PartitioningMode(mode)
+	}
+
+	distribution := distrofactory.NewDefault().GetDistro(distroName)
+	if distribution == nil {
+		return fmt.Errorf("invalid or unsupported distribution: %q", distroName)
+	}
+	distroArch, err := distribution.GetArch(archName)
+	if err != nil {
+		return fmt.Errorf("invalid arch name %q for distro %q: %w", archName, distroName, err)
+	}
+	imgType, err := distroArch.GetImageType(imgTypeName)
+	if err != nil {
+		return fmt.Errorf("invalid image type %q for distro %q and arch %q: %w", imgTypeName, distroName, archName, err)
+	}
+	explainer, ok := imgType.(distro.PartitionTableExplainer)
+	if !ok {
+		return fmt.Errorf("image type %q cannot explain its partition table", imgTypeName)
+	}
+
+	report, err := explainer.ExplainPartitionTable(bp, options)
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	return printTable(os.Stdout, report)
+}
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -4,13 +4,18 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 
 	"github.com/gobwas/glob"
+	"github.com/osbuild/images/pkg/distro"
+	"github.com/osbuild/images/pkg/distro/defs"
 	"github.com/osbuild/images/pkg/distrofactory"
+	"github.com/osbuild/images/pkg/reporegistry"
 	testrepos "github.com/osbuild/images/test/data/repositories"
 )
 
@@ -55,6 +60,9 @@ type config struct {
 	Distro    string `json:"distro"`
 	Arch      string `json:"arch"`
 	ImageType string `json:"image-type"`
+	NoRepos   bool   `json:"no-repos,omitempty"`
+
+	Deprecated *distro.Deprecation `json:"deprecated,omitempty"`
 }
 
 func jsonPrint(configs []config) {
@@ -67,20 +75,42 @@ func jsonPrint(configs []config) {
 }
 
 func main() {
-	var arches, distros, imgTypes multiValue
-	var json bool
+	var arches, distros, imgTypes, extraImageTypes multiValue
+	var json, withoutRepos bool
 	flag.Var(&arches, "arches", "comma-separated list of architectures (globs supported)")
 	flag.Var(&distros, "distros", "comma-separated list of distributions (globs supported)")
 	flag.Var(&imgTypes, "types", "comma-separated list of image types (globs supported)")
+	flag.Var(&extraImageTypes, "extra-image-types", "comma-separated list of YAML files with user defined image types")
 	flag.BoolVar(&json, "json", false, "print configs as json")
+	flag.BoolVar(&withoutRepos, "without-repos", false, "include all known distributions, even the ones without test repositories")
 	flag.Parse()
 
 	testedRepoRegistry, err := testrepos.New()
 	if err != nil {
 		panic(fmt.Sprintf("failed to create repo registry with tested distros: %v", err))
 	}
-	distroFac := distrofactory.NewDefault()
-	distros, invalidDistros := resolveArgValues(distros, testedRepoRegistry.ListDistros())
+	var extras []*defs.ExtraImageTypes
+	for _, p := range extraImageTypes {
+		extra, err := defs.LoadExtraImageTypes(p)
+		if err != nil {
+			panic(fmt.Sprintf("failed to load extra image types: %v", err))
+		}
+		extras = append(extras, extra)
+	}
+	distroFac := distrofactory.NewDefaultWithExtraImageTypes(extras)
+	allDistros := testedRepoRegistry.ListDistros()
+	if withoutRepos {
+		knownDistros, err := distroFac.ListDistros()
+		if err != nil {
+			panic(fmt.Sprintf("failed to list known distros: %v", err))
+		}
+		for _, name := range knownDistros {
+			if !slices.Contains(allDistros, name) {
+				allDistros = append(allDistros, name)
+			}
+		}
+	}
+	distros, invalidDistros := resolveArgValues(distros, allDistros)
 	if len(invalidDistros) > 0 {
 		fmt.Fprintf(os.Stderr, "WARNING: invalid distro names: [%s]\n", strings.Join(invalidDistros, ","))
 	}
@@ -116,9 +146,18 @@ func main() {
 				}
 
 				c := config{
-					Distro:    distroName,
-					Arch:      archName,
-					ImageType: imgType.Name(),
+					Distro:     distroName,
+					Arch:       archName,
+					ImageType:  imgType.Name(),
+					Deprecated: imgType.Deprecation(),
+				}
+				if withoutRepos {
+					_, err := testedRepoRegistry.ReposByImageTypeName(distroName, archName, imgTypeName)
+					if errors.Is(err, reporegistry.ErrNoRepoFound) {
+						c.NoRepos = true
+					} else if err != nil {
+						panic(fmt.Sprintf("cannot get repos for %q %q %q: %v", distroName, archName, imgTypeName, err))
+					}
 				}
 
 				configs = append(configs, c)
@@ -131,6 +170,17 @@ func main() {
 		jsonPrint(configs)
 	} else {
 		for _, c := range configs {
+			var notes []string
+			if c.NoRepos {
+				notes = append(notes, "no repos")
+			}
+			if c.Deprecated != nil {
+				notes = append(notes, "deprecated")
+			}
+			if len(notes) > 0 {
+				fmt.Printf("%s %s %s (%s)\n", c.Distro, c.Arch, c.ImageType, strings.Join(notes, ", "))
+				continue
+			}
 			fmt.Printf("%s %s %s\n", c.Distro, c.Arch, c.ImageType)
 		}
 	}
@@ -0,0 +1,8 @@
+// Package stageschemas contains a snapshot of the schemas of osbuild
+// stages, see README.md.
+package stageschemas
+
+import "embed"
+
+//go:embed *.meta.json
+var Data embed.FS
@@ -0,0 +1,114 @@
+package jsonschema
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ecmaWhitespace is the set of characters that "\s" matches in a
+// ECMA-262 regular expression, go only matches ASCII whitespace.
+const ecmaWhitespace = `\t\n\v\f\r \x{a0}\x{1680}\x{2000}-\x{200a}\x{2028}\x{2029}\x{202f}\x{205f}\x{3000}\x{feff}`
+
+// ecmaLineTerminators are the characters that "." does not match in
+// a ECMA-262 regular expression, go only excludes "\n".
+const ecmaLineTerminators = `\n\r\x{2028}\x{2029}`
+
+// CompilePattern compiles the "pattern" of a schema. JSON schema
+// patterns use the ECMA-262 syntax while go uses RE2, the supported
+// subset is:
+//
+//   - everything that has the same meaning in both syntaxes, i.e.
+//     literals, groups (including named groups), alternations,
+//     quantifiers, anchors, character classes and the "\d", "\D",
+//     "\w", "\W", "\b" and "\B" escapes (all ASCII only in both)
+//   - "\s", "\S" and "." are rewritten to the ECMA-262 sets (which
+//     include the unicode whitespace and line terminators)
+//   - "\uXXXX" and "\0" are rewritten to the go syntax
+//
+// Patterns that use lookarounds, backreferences, "\cX" control
+// escapes, negated sets inside a class ("[\S]") or the empty classes
+// "[]" and "[^]" return an error. ECMA-262 patterns without the "u"
+// flag work on UTF-16 code units, the go regexp always works on
+// runes, this is not an issue for the patterns of the schemas.
+func CompilePattern(pattern string) (*regexp.Regexp, error) {
+	translated, err := translatePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return regexp.Compile(translated)
+}
+
+func translatePattern(pattern string) (string, error) {
+	var out strings.Builder
+	inClass := false
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '\\':
+			if i+1 >= len(pattern) {
+				return "", fmt.Errorf("trailing backslash")
+			}
+			i++
+			esc := pattern[i]
+			switch {
+			case esc == 's':
+				if inClass {
+					out.WriteString(ecmaWhitespace)
+				} else {
+					out.WriteString("[" + ecmaWhitespace + "]")
+				}
+			case esc == 'S':
+				if inClass {
+					return "", fmt.Errorf(`"\S" inside a character class`)
+				}
+				out.WriteString("[^" + ecmaWhitespace + "]")
+			case esc == 'u':
+				if i+4 >= len(pattern) {
+					return "", fmt.Errorf(`incomplete "\u" escape`)
+				}
+				hex := pattern[i+1 : i+5]
+				if strings.Trim(hex, "0123456789abcdefABCDEF") != "" {
+					return "", fmt.Errorf(`invalid "\u" escape %q`, hex)
+				}
+				out.WriteString(`\x{` + hex + `}`)
+				i += 4
+			case esc == '0':
+				out.WriteString(`\x00`)
+			case esc >= '1' && esc <= '9', esc == 'k':
+				return "", fmt.Errorf("backreferences are not supported")
+			case esc == 'c':
+				return "", fmt.Errorf(`"\c" control escapes are not supported`)
+			default:
+				out.WriteByte('\\')
+				out.WriteByte(esc)
+			}
+		case inClass:
+			if c == ']' {
+				inClass = false
+			}
+			out.WriteByte(c)
+		case c == '[':
+			rest := pattern[i+1:]
+			if strings.HasPrefix(rest, "]") || strings.HasPrefix(rest, "^]") {
+				return "", fmt.Errorf("empty character classes are not supported")
+			}
+			inClass = true
+			out.WriteByte(c)
+			// a leading "^" negates the class, it must not be
+			// taken as the end of a "[^]" class later
+			if strings.HasPrefix(rest, "^") {
+				out.WriteByte('^')
+				i++
+			}
+		case c == '.':
+			out.WriteString("[^" + ecmaLineTerminators + "]")
+		case c == '(' && (strings.HasPrefix(pattern[i:], "(?=") || strings.HasPrefix(pattern[i:], "(?!") ||
+			strings.HasPrefix(pattern[i:], "(?<=") || strings.HasPrefix(pattern[i:], "(?<!")):
+			return "", fmt.Errorf("lookarounds are not supported")
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.String(), nil
+}
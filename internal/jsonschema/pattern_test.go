@@ -0,0 +1,65 @@
+package jsonschema_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/jsonschema"
+)
+
+func TestCompilePattern(t *testing.T) {
+	for _, tc := range []struct {
+		pattern  string
+		matches  []string
+		nomatchs []string
+	}{
+		{`^[0-9]+(\.[0-9]+)*$`, []string{"1", "1.2.3"}, []string{"1.", "a"}},
+		{`^\d{2}\D$`, []string{"12a"}, []string{"123", "\u0661\u0662a"}},
+		{`^[\w.-]{1,250}\.conf$`, []string{"foo-1.conf"}, []string{"föo.conf", ".conf"}},
+		{`^\s*[0-9]+\s*$`, []string{" 1 ", "\v1\u00a0", "\ufeff1"}, []string{"1 a"}},
+		{`^[\s,]+$`, []string{", \u2028"}, []string{"a"}},
+		{`^\S+$`, []string{"abc"}, []string{"a\u3000b"}},
+		{`^a.b$`, []string{"a-b", "aéb"}, []string{"a\nb", "a\rb", "a\u2029b"}},
+		{`^[.]$`, []string{"."}, []string{"a"}},
+		{`^é\0?$`, []string{"é", "é\x00"}, []string{"e"}},
+		{`^[^\]]+$`, []string{"abc"}, []string{"a]"}},
+		{`^(?<major>[0-9]+)\/x$`, []string{"10/x"}, []string{"x/x"}},
+		{`^a$`, []string{"a"}, []string{"a\n"}},
+	} {
+		re, err := jsonschema.CompilePattern(tc.pattern)
+		require.NoError(t, err, tc.pattern)
+		for _, s := range tc.matches {
+			assert.True(t, re.MatchString(s), "%q should match %q", tc.pattern, s)
+		}
+		for _, s := range tc.nomatchs {
+			assert.False(t, re.MatchString(s), "%q should not match %q", tc.pattern, s)
+		}
+	}
+}
+
+func TestCompilePatternUnsupported(t *testing.T) {
+	for _, tc := range []struct {
+		pattern     string
+		expectedErr string
+	}{
+		{`^(?!root$)[a-z]+$`, "lookarounds are not supported"},
+		{`^(?=a)`, "lookarounds are not supported"},
+		{`(?<=a)b`, "lookarounds are not supported"},
+		{`(?<!a)b`, "lookarounds are not supported"},
+		{`^(a)\1$`, "backreferences are not supported"},
+		{`^(?<x>a)\k<x>$`, "backreferences are not supported"},
+		{`^\cJ$`, `"\c" control escapes are not supported`},
+		{`^[\S]$`, `"\S" inside a character class`},
+		{`^[]a]$`, "empty character classes are not supported"},
+		{`^[^]$`, "empty character classes are not supported"},
+		{`^\u00$`, `incomplete "\u" escape`},
+		{`^\uzzzz$`, `invalid "\u" escape "zzzz"`},
+		{`a\`, "trailing backslash"},
+		{`a{2,1}`, "error parsing regexp: invalid repeat count: `{2,1}`"},
+	} {
+		_, err := jsonschema.CompilePattern(tc.pattern)
+		assert.EqualError(t, err, tc.expectedErr, tc.pattern)
+	}
+}
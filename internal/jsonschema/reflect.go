@@ -0,0 +1,277 @@
+package jsonschema
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+)
+
+// TypeHook returns the schema for a type that the reflector cannot
+// reflect on its own (e.g. because it has a custom unmarshaler).
+type TypeHook func(r *Reflector, t reflect.Type) (*Schema, error)
+
+// Reflector generates JSON Schemas for go types.
+//
+// Struct fields are named after the struct tag given in Tag and
+// structs do not allow additional properties (this matches strict
+// decoding). Types with custom unmarshalers need a TypeHook, the
+// reflector returns an error for any such type without a hook so
+// that the schema never silently diverges from the decoder.
+type Reflector struct {
+	// Tag is the struct tag used to name the properties, either
+	// "yaml" (the default) or "json"
+	Tag string
+	// Hooks maps types to their schema generator
+	Hooks map[reflect.Type]TypeHook
+
+	defs    map[string]*Schema
+	defKeys map[defKey]string
+}
+
+type defKey struct {
+	t   reflect.Type
+	tag string
+}
+
+var (
+	yamlObsoleteUnmarshaler = reflect.TypeOf((*interface {
+		UnmarshalYAML(unmarshal func(any) error) error
+	})(nil)).Elem()
+	jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+func (r *Reflector) tag() string {
+	if r.Tag == "" {
+		return "yaml"
+	}
+	return r.Tag
+}
+
+// Reflect returns the schema for the given value, all named struct
+// types are put into "$defs".
+func (r *Reflector) Reflect(v any) (*Schema, error) {
+	r.defs = make(map[string]*Schema)
+	r.defKeys = make(map[defKey]string)
+
+	s, err := r.ReflectType(reflect.TypeOf(v))
+	if err != nil {
+		return nil, err
+	}
+	s.Schema = Draft
+	if len(r.defs) > 0 {
+		s.Defs = r.defs
+	}
+	return s, nil
+}
+
+// WithTag runs fn with a different struct tag, this is useful for
+// types that are decoded via a different decoder (e.g. YAML that is
+// converted to JSON).
+func (r *Reflector) WithTag(tag string, fn func() (*Schema, error)) (*Schema, error) {
+	prev := r.Tag
+	r.Tag = tag
+	defer func() { r.Tag = prev }()
+	return fn()
+}
+
+// ReflectType returns the schema for the given type. It can be used
+// by hooks to reflect nested types.
+func (r *Reflector) ReflectType(t reflect.Type) (*Schema, error) {
+	if hook, ok := r.Hooks[t]; ok {
+		return r.define(t, func() (*Schema, error) { return hook(r, t) })
+	}
+	if t.Kind() == reflect.Pointer {
+		return r.ReflectType(t.Elem())
+	}
+	if err := r.checkUnmarshaler(t); err != nil {
+		return nil, err
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0.0
+		return &Schema{Type: "integer", Minimum: &zero}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}, nil
+	case reflect.String:
+		return &Schema{Type: "string"}, nil
+	case reflect.Interface:
+		return &Schema{}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := r.ReflectType(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		return r.reflectMap(t)
+	case reflect.Struct:
+		return r.define(t, func() (*Schema, error) { return r.ReflectStruct(t) })
+	}
+	return nil, fmt.Errorf("cannot generate schema for %v (kind %v)", t, t.Kind())
+}
+
+// checkUnmarshaler ensures that types with custom unmarshalers have
+// a hook
+func (r *Reflector) checkUnmarshaler(t reflect.Type) error {
+	pt := reflect.PointerTo(t)
+	custom := pt.Implements(textUnmarshaler)
+	switch r.tag() {
+	case "yaml":
+		custom = custom || pt.Implements(yamlObsoleteUnmarshaler)
+	case "json":
+		custom = custom || pt.Implements(jsonUnmarshaler)
+	}
+	if custom {
+		return fmt.Errorf("cannot generate schema for %v: custom unmarshaler without a hook", t)
+	}
+	return nil
+}
+
+func (r *Reflector) reflectMap(t reflect.Type) (*Schema, error) {
+	values, err := r.ReflectType(t.Elem())
+	if err != nil {
+		return nil, err
+	}
+	s := &Schema{Type: "object", AdditionalProperties: values}
+	if t.Key().Kind() != reflect.String || t.Key().PkgPath() != "" {
+		keys, err := r.ReflectType(t.Key())
+		if err != nil {
+			return nil, err
+		}
+		if keys.Type == "integer" {
+			// keys are always strings in JSON
+			keys = &Schema{Type: "string", Pattern: `^[0-9]+$`}
+		}
+		if keys.Type != "string" || keys.Pattern != "" || keys.Ref != "" || keys.Enum != nil {
+			s.PropertyNames = keys
+		}
+	}
+	return s, nil
+}
+
+// define puts named types into "$defs" and returns a reference
+func (r *Reflector) define(t reflect.Type, gen func() (*Schema, error)) (*Schema, error) {
+	if t.Name() == "" {
+		return gen()
+	}
+	key := defKey{t, r.tag()}
+	if name, ok := r.defKeys[key]; ok {
+		return Ref(name), nil
+	}
+	name := path.Base(t.PkgPath()) + "." + t.Name()
+	for _, used := range r.defKeys {
+		if used == name {
+			name += "." + r.tag()
+			break
+		}
+	}
+	r.defKeys[key] = name
+	// set a placeholder first so that recursive types work
+	r.defs[name] = &Schema{}
+	s, err := gen()
+	if err != nil {
+		return nil, err
+	}
+	r.defs[name] = s
+	return Ref(name), nil
+}
+
+// FieldName returns the property name of the given struct field or
+// "" if the field is not (de)serialized. The second return value is
+// true if the field is inlined into the parent.
+func (r *Reflector) FieldName(f reflect.StructField) (string, bool) {
+	tag, hasTag := f.Tag.Lookup(r.tag())
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "-" && opts == "" {
+		return "", false
+	}
+	inline := false
+	for _, opt := range strings.Split(opts, ",") {
+		if opt == "inline" {
+			inline = true
+		}
+	}
+	switch r.tag() {
+	case "json":
+		// json inlines embedded structs without a name
+		if f.Anonymous && name == "" {
+			return "", true
+		}
+	case "yaml":
+		if inline {
+			return "", true
+		}
+	}
+	if !f.IsExported() {
+		return "", false
+	}
+	if name == "" || !hasTag {
+		if r.tag() == "yaml" {
+			return strings.ToLower(f.Name), false
+		}
+		return f.Name, false
+	}
+	return name, false
+}
+
+// StructProperties returns the properties of the given struct type.
+func (r *Reflector) StructProperties(t reflect.Type) (map[string]*Schema, error) {
+	props := make(map[string]*Schema)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, inline := r.FieldName(f)
+		if inline {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Map {
+				// inline maps collect all other keys
+				continue
+			}
+			inlined, err := r.StructProperties(ft)
+			if err != nil {
+				return nil, err
+			}
+			for k, v := range inlined {
+				props[k] = v
+			}
+			continue
+		}
+		if name == "" {
+			continue
+		}
+		fs, err := r.ReflectType(f.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%v.%v: %w", t, f.Name, err)
+		}
+		props[name] = fs
+	}
+	return props, nil
+}
+
+// ReflectStruct returns the object schema for the given struct type
+// (without checking for a custom unmarshaler of the type itself).
+func (r *Reflector) ReflectStruct(t reflect.Type) (*Schema, error) {
+	props, err := r.StructProperties(t)
+	if err != nil {
+		return nil, err
+	}
+	s := &Schema{
+		Type:                 "object",
+		AdditionalProperties: false,
+	}
+	if len(props) > 0 {
+		s.Properties = props
+	}
+	return s, nil
+}
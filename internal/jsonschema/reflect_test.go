@@ -0,0 +1,117 @@
+package jsonschema_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/jsonschema"
+)
+
+type testInner struct {
+	Name string `yaml:"name" json:"json_name"`
+}
+
+type testEmbedded struct {
+	Embedded string `yaml:"embedded"`
+}
+
+type testOuter struct {
+	testEmbedded `yaml:",inline"`
+
+	Count    uint              `yaml:"count"`
+	Ratio    float64           `yaml:"ratio,omitempty"`
+	Enabled  *bool             `yaml:"enabled"`
+	Untagged string            ``
+	Inner    testInner         `yaml:"inner"`
+	Inners   []testInner       `yaml:"inners"`
+	Labels   map[string]string `yaml:"labels"`
+	Ignored  string            `yaml:"-"`
+	Any      any               `yaml:"any"`
+	private  string
+}
+
+type testEnum int
+
+func (e *testEnum) UnmarshalYAML(unmarshal func(any) error) error {
+	return nil
+}
+
+type testWithEnum struct {
+	Enum testEnum `yaml:"enum"`
+}
+
+func TestReflect(t *testing.T) {
+	r := &jsonschema.Reflector{}
+	s, err := r.Reflect(testOuter{})
+	require.NoError(t, err)
+
+	b, err := json.Marshal(s)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "$ref": "#/$defs/jsonschema_test.testOuter",
+  "$defs": {
+    "jsonschema_test.testInner": {
+      "type": "object",
+      "properties": {"name": {"type": "string"}},
+      "additionalProperties": false
+    },
+    "jsonschema_test.testOuter": {
+      "type": "object",
+      "properties": {
+        "embedded": {"type": "string"},
+        "count": {"type": "integer", "minimum": 0},
+        "ratio": {"type": "number"},
+        "enabled": {"type": "boolean"},
+        "untagged": {"type": "string"},
+        "inner": {"$ref": "#/$defs/jsonschema_test.testInner"},
+        "inners": {"type": "array", "items": {"$ref": "#/$defs/jsonschema_test.testInner"}},
+        "labels": {"type": "object", "additionalProperties": {"type": "string"}},
+        "any": {}
+      },
+      "additionalProperties": false
+    }
+  }
+}`, string(b))
+}
+
+func TestReflectJSONTag(t *testing.T) {
+	r := &jsonschema.Reflector{Tag: "json"}
+	s, err := r.Reflect(testInner{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]*jsonschema.Schema{
+		"json_name": {Type: "string"},
+	}, s.Defs["jsonschema_test.testInner"].Properties)
+}
+
+func TestReflectCustomUnmarshalerNeedsHook(t *testing.T) {
+	r := &jsonschema.Reflector{}
+	_, err := r.Reflect(testWithEnum{})
+	assert.EqualError(t, err, "jsonschema_test.testWithEnum.Enum: cannot generate schema for jsonschema_test.testEnum: custom unmarshaler without a hook")
+
+	r.Hooks = map[reflect.Type]jsonschema.TypeHook{
+		reflect.TypeOf(testEnum(0)): func(r *jsonschema.Reflector, t reflect.Type) (*jsonschema.Schema, error) {
+			return &jsonschema.Schema{Type: "string", Enum: []any{"a", "b"}}, nil
+		},
+	}
+	s, err := r.Reflect(testWithEnum{})
+	require.NoError(t, err)
+	assert.Equal(t, jsonschema.Ref("jsonschema_test.testEnum"), s.Defs["jsonschema_test.testWithEnum"].Properties["enum"])
+	assert.Equal(t, &jsonschema.Schema{Type: "string", Enum: []any{"a", "b"}}, s.Defs["jsonschema_test.testEnum"])
+}
+
+func TestSchemaJSONRoundtrip(t *testing.T) {
+	r := &jsonschema.Reflector{}
+	s, err := r.Reflect(testOuter{})
+	require.NoError(t, err)
+
+	b, err := json.Marshal(s)
+	require.NoError(t, err)
+	var s2 jsonschema.Schema
+	require.NoError(t, json.Unmarshal(b, &s2))
+	assert.Equal(t, s, &s2)
+}
@@ -0,0 +1,137 @@
+// Package jsonschema contains a minimal JSON Schema (draft 2020-12)
+// generator that works via reflection on go types and a validator
+// for the subset of JSON Schema that the generator produces and that
+// the (draft 4) schemas of osbuild use. See CompilePattern() for the
+// supported subset of the "pattern" syntax.
+package jsonschema
+
+import (
+	"encoding/json"
+)
+
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema, only the keywords that are needed by the
+// generator and the validator are supported. Unknown keywords are
+// ignored.
+type Schema struct {
+	Schema      string `json:"$schema,omitempty"`
+	ID          string `json:"$id,omitempty"`
+	Ref         string `json:"$ref,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	Type string `json:"type,omitempty"`
+	// Types is set instead of Type when the schema allows multiple
+	// types, e.g. "type": ["string", "null"]
+	Types     []string `json:"-"`
+	Enum      []any    `json:"enum,omitempty"`
+	Const     any      `json:"const,omitempty"`
+	Pattern   string   `json:"pattern,omitempty"`
+	Minimum   *float64 `json:"minimum,omitempty"`
+	Maximum   *float64 `json:"maximum,omitempty"`
+	MinLength *int     `json:"minLength,omitempty"`
+	MaxLength *int     `json:"maxLength,omitempty"`
+
+	Properties        map[string]*Schema `json:"properties,omitempty"`
+	PatternProperties map[string]*Schema `json:"patternProperties,omitempty"`
+	// AdditionalProperties is either nil (allowed), a bool or
+	// a *Schema
+	AdditionalProperties any      `json:"additionalProperties,omitempty"`
+	PropertyNames        *Schema  `json:"propertyNames,omitempty"`
+	Required             []string `json:"required,omitempty"`
+
+	Items       *Schema `json:"items,omitempty"`
+	MinItems    *int    `json:"minItems,omitempty"`
+	MaxItems    *int    `json:"maxItems,omitempty"`
+	UniqueItems bool    `json:"uniqueItems,omitempty"`
+
+	AllOf []*Schema `json:"allOf,omitempty"`
+	AnyOf []*Schema `json:"anyOf,omitempty"`
+	OneOf []*Schema `json:"oneOf,omitempty"`
+	Not   *Schema   `json:"not,omitempty"`
+
+	Defs map[string]*Schema `json:"$defs,omitempty"`
+	// Definitions are the draft 4 equivalent of Defs, referenced
+	// via "#/definitions/<name>"
+	Definitions map[string]*Schema `json:"definitions,omitempty"`
+}
+
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	type alias Schema
+	var raw struct {
+		alias
+		Type                 json.RawMessage `json:"type,omitempty"`
+		AdditionalProperties json.RawMessage `json:"additionalProperties,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*s = Schema(raw.alias)
+	if len(raw.Type) > 0 {
+		if raw.Type[0] == '[' {
+			if err := json.Unmarshal(raw.Type, &s.Types); err != nil {
+				return err
+			}
+		} else if err := json.Unmarshal(raw.Type, &s.Type); err != nil {
+			return err
+		}
+	}
+	switch {
+	case len(raw.AdditionalProperties) == 0:
+	case string(raw.AdditionalProperties) == "true":
+		s.AdditionalProperties = true
+	case string(raw.AdditionalProperties) == "false":
+		s.AdditionalProperties = false
+	default:
+		var add Schema
+		if err := json.Unmarshal(raw.AdditionalProperties, &add); err != nil {
+			return err
+		}
+		s.AdditionalProperties = &add
+	}
+	return nil
+}
+
+// Ref returns a schema that references the definition with the
+// given name.
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/$defs/" + name}
+}
+
+// Walk calls fn for the schema and all its sub-schemas (depth first,
+// parent first). Definitions are not followed via their references
+// but visited as part of the "$defs".
+func (s *Schema) Walk(fn func(s *Schema)) {
+	if s == nil {
+		return
+	}
+	fn(s)
+	for _, sub := range s.Properties {
+		sub.Walk(fn)
+	}
+	for _, sub := range s.PatternProperties {
+		sub.Walk(fn)
+	}
+	if add, ok := s.AdditionalProperties.(*Schema); ok {
+		add.Walk(fn)
+	}
+	s.PropertyNames.Walk(fn)
+	s.Items.Walk(fn)
+	for _, sub := range s.AllOf {
+		sub.Walk(fn)
+	}
+	for _, sub := range s.AnyOf {
+		sub.Walk(fn)
+	}
+	for _, sub := range s.OneOf {
+		sub.Walk(fn)
+	}
+	s.Not.Walk(fn)
+	for _, sub := range s.Defs {
+		sub.Walk(fn)
+	}
+	for _, sub := range s.Definitions {
+		sub.Walk(fn)
+	}
+}
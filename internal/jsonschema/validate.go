@@ -0,0 +1,371 @@
+package jsonschema
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidationError describes a single place in the document that does
+// not match the schema.
+type ValidationError struct {
+	// Path is the location in the document, e.g. "/image_types/qcow2/filename"
+	Path string
+	Msg  string
+}
+
+func (e *ValidationError) Error() string {
+	p := e.Path
+	if p == "" {
+		p = "/"
+	}
+	return fmt.Sprintf("%s: %s", p, e.Msg)
+}
+
+// Validate validates the given document against the schema. The
+// document must be a decoded JSON or YAML document, i.e. a tree of
+// maps, slices and scalars. All errors are returned joined.
+func (s *Schema) Validate(doc any) error {
+	v := &validator{root: s, regexps: make(map[string]*regexp.Regexp)}
+	v.validate(s, doc, "")
+	return errors.Join(v.errs...)
+}
+
+type validator struct {
+	root    *Schema
+	regexps map[string]*regexp.Regexp
+	errs    []error
+}
+
+func (v *validator) errorf(path, format string, a ...any) {
+	v.errs = append(v.errs, &ValidationError{Path: path, Msg: fmt.Sprintf(format, a...)})
+}
+
+func (v *validator) regexp(pattern string) (*regexp.Regexp, error) {
+	if re, ok := v.regexps[pattern]; ok {
+		return re, nil
+	}
+	re, err := CompilePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	v.regexps[pattern] = re
+	return re, nil
+}
+
+// matches returns true if the doc is valid for the schema without
+// recording any errors
+func (v *validator) matches(s *Schema, doc any, path string) bool {
+	sub := &validator{root: v.root, regexps: v.regexps}
+	sub.validate(s, doc, path)
+	return len(sub.errs) == 0
+}
+
+func (v *validator) resolve(ref string) (*Schema, error) {
+	defs := v.root.Defs
+	name, ok := strings.CutPrefix(ref, "#/$defs/")
+	if !ok {
+		defs = v.root.Definitions
+		name, ok = strings.CutPrefix(ref, "#/definitions/")
+	}
+	if !ok {
+		return nil, fmt.Errorf("unsupported reference %q", ref)
+	}
+	s, ok := defs[name]
+	if !ok {
+		return nil, fmt.Errorf("cannot find definition %q", name)
+	}
+	return s, nil
+}
+
+func normalizeMap(doc any) (map[string]any, bool) {
+	switch m := doc.(type) {
+	case map[string]any:
+		return m, true
+	case map[any]any:
+		res := make(map[string]any, len(m))
+		for k, v := range m {
+			res[fmt.Sprintf("%v", k)] = v
+		}
+		return res, true
+	}
+	return nil, false
+}
+
+func toFloat(doc any) (float64, bool) {
+	rv := reflect.ValueOf(doc)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+func typeMatches(typ string, doc any) bool {
+	switch typ {
+	case "null":
+		return doc == nil
+	case "boolean":
+		_, ok := doc.(bool)
+		return ok
+	case "string":
+		_, ok := doc.(string)
+		return ok
+	case "number":
+		_, ok := toFloat(doc)
+		return ok
+	case "integer":
+		f, ok := toFloat(doc)
+		return ok && f == math.Trunc(f)
+	case "array":
+		_, ok := doc.([]any)
+		return ok
+	case "object":
+		_, ok := normalizeMap(doc)
+		return ok
+	}
+	return false
+}
+
+func equal(a, b any) bool {
+	fa, okA := toFloat(a)
+	fb, okB := toFloat(b)
+	if okA && okB {
+		return fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// formatValue formats a value of the document or schema for error
+// messages, strings are quoted so that e.g. the empty string is
+// visible
+func formatValue(val any) string {
+	if s, ok := val.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprintf("%v", val)
+}
+
+func formatValues(vals []any) string {
+	strs := make([]string, 0, len(vals))
+	for _, val := range vals {
+		strs = append(strs, formatValue(val))
+	}
+	return "[" + strings.Join(strs, " ") + "]"
+}
+
+func (v *validator) validate(s *Schema, doc any, path string) {
+	if s.Ref != "" {
+		ref, err := v.resolve(s.Ref)
+		if err != nil {
+			v.errorf(path, "%v", err)
+			return
+		}
+		v.validate(ref, doc, path)
+	}
+
+	if s.Type != "" && !typeMatches(s.Type, doc) {
+		v.errorf(path, "expected %s, got %T", s.Type, doc)
+		return
+	}
+	if len(s.Types) > 0 {
+		found := false
+		for _, typ := range s.Types {
+			if typeMatches(typ, doc) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.errorf(path, "expected %s, got %T", strings.Join(s.Types, " or "), doc)
+			return
+		}
+	}
+	if s.Enum != nil {
+		found := false
+		for _, e := range s.Enum {
+			if equal(e, doc) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.errorf(path, "value %s is not one of %s", formatValue(doc), formatValues(s.Enum))
+		}
+	}
+	if s.Const != nil && !equal(s.Const, doc) {
+		v.errorf(path, "value %s is not %s", formatValue(doc), formatValue(s.Const))
+	}
+	if str, ok := doc.(string); ok {
+		if s.Pattern != "" {
+			re, err := v.regexp(s.Pattern)
+			if err != nil {
+				v.errorf(path, "invalid pattern %q: %v", s.Pattern, err)
+			} else if !re.MatchString(str) {
+				v.errorf(path, "value %q does not match %q", str, s.Pattern)
+			}
+		}
+		if n := utf8.RuneCountInString(str); s.MinLength != nil && n < *s.MinLength {
+			v.errorf(path, "value %q is shorter than %d characters", str, *s.MinLength)
+		} else if s.MaxLength != nil && n > *s.MaxLength {
+			v.errorf(path, "value %q is longer than %d characters", str, *s.MaxLength)
+		}
+	}
+	if f, ok := toFloat(doc); ok {
+		if s.Minimum != nil && f < *s.Minimum {
+			v.errorf(path, "value %v is smaller than %v", doc, *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			v.errorf(path, "value %v is bigger than %v", doc, *s.Maximum)
+		}
+	}
+
+	if m, ok := normalizeMap(doc); ok {
+		v.validateObject(s, m, path)
+	}
+	if l, ok := doc.([]any); ok {
+		v.validateArray(s, l, path)
+	}
+
+	for _, sub := range s.AllOf {
+		v.validate(sub, doc, path)
+	}
+	if s.Not != nil && v.matches(s.Not, doc, path) {
+		v.errorf(path, "value matches a schema that is not allowed")
+	}
+
+	if len(s.AnyOf) > 0 {
+		found := false
+		for _, sub := range s.AnyOf {
+			if v.matches(sub, doc, path) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.anyOfFailed(s.AnyOf, doc, path)
+		}
+	}
+	if len(s.OneOf) > 0 {
+		n := 0
+		for _, sub := range s.OneOf {
+			if v.matches(sub, doc, path) {
+				n++
+			}
+		}
+		switch {
+		case n == 0:
+			// be helpful and report the errors of all variants
+			for _, sub := range s.OneOf {
+				v.validate(sub, doc, path)
+			}
+		case n > 1:
+			v.errorf(path, "value matches %d schemas, expected exactly one", n)
+		}
+	}
+}
+
+// anyOfFailed reports the errors of the only variant that has the
+// same type as the doc (if any), this gives much better errors than
+// a plain "does not match" for e.g. "string or object" schemas
+func (v *validator) anyOfFailed(variants []*Schema, doc any, path string) {
+	var candidates []*Schema
+	for _, sub := range variants {
+		typ := sub.Type
+		if sub.Ref != "" {
+			if ref, err := v.resolve(sub.Ref); err == nil {
+				typ = ref.Type
+			}
+		}
+		if typ == "" || typeMatches(typ, doc) {
+			candidates = append(candidates, sub)
+		}
+	}
+	if len(candidates) == 1 {
+		v.validate(candidates[0], doc, path)
+		return
+	}
+	v.errorf(path, "value does not match any of the allowed schemas")
+}
+
+func (v *validator) validateArray(s *Schema, l []any, path string) {
+	if s.MinItems != nil && len(l) < *s.MinItems {
+		v.errorf(path, "expected at least %d items, got %d", *s.MinItems, len(l))
+	}
+	if s.MaxItems != nil && len(l) > *s.MaxItems {
+		v.errorf(path, "expected at most %d items, got %d", *s.MaxItems, len(l))
+	}
+	if s.UniqueItems {
+	outer:
+		for i := range l {
+			for j := 0; j < i; j++ {
+				if equal(l[i], l[j]) {
+					v.errorf(fmt.Sprintf("%s/%d", path, i), "duplicate of item %d", j)
+					break outer
+				}
+			}
+		}
+	}
+	if s.Items != nil {
+		for i, item := range l {
+			v.validate(s.Items, item, fmt.Sprintf("%s/%d", path, i))
+		}
+	}
+}
+
+func (v *validator) validateObject(s *Schema, m map[string]any, path string) {
+	for _, req := range s.Required {
+		if _, ok := m[req]; !ok {
+			v.errorf(path, "missing required property %q", req)
+		}
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		val := m[k]
+		p := path + "/" + k
+		if s.PropertyNames != nil {
+			v.validate(s.PropertyNames, k, p)
+		}
+		known := false
+		if prop, ok := s.Properties[k]; ok {
+			known = true
+			v.validate(prop, val, p)
+		}
+		for pattern, prop := range s.PatternProperties {
+			re, err := v.regexp(pattern)
+			if err != nil {
+				v.errorf(path, "invalid pattern %q: %v", pattern, err)
+				continue
+			}
+			if re.MatchString(k) {
+				known = true
+				v.validate(prop, val, p)
+			}
+		}
+		if known {
+			continue
+		}
+		switch add := s.AdditionalProperties.(type) {
+		case bool:
+			if !add {
+				v.errorf(path, "unknown property %q", k)
+			}
+		case *Schema:
+			v.validate(add, val, p)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package jsonschema_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/osbuild/images/internal/jsonschema"
+)
+
+func validateYAML(t *testing.T, s *jsonschema.Schema, input string) error {
+	t.Helper()
+	var doc any
+	require.NoError(t, yaml.Unmarshal([]byte(input), &doc))
+	return s.Validate(doc)
+}
+
+func TestValidateReflected(t *testing.T) {
+	r := &jsonschema.Reflector{}
+	s, err := r.Reflect(testOuter{})
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		input       string
+		expectedErr string
+	}{
+		{`
+embedded: foo
+count: 1
+ratio: 0.5
+enabled: true
+inner: {name: bar}
+inners: [{name: a}, {name: b}]
+labels: {a: b}
+any: [1, "2"]
+`, ""},
+		{"{}", ""},
+		{"count: -1", "/count: value -1 is smaller than 0"},
+		{"count: 1.5", "/count: expected integer, got float64"},
+		{"inner: {nam: bar}", `/inner: unknown property "nam"`},
+		{"inners: [{name: 1}]", "/inners/0/name: expected string, got int"},
+		{"labels: {a: [b]}", "/labels/a: expected string, got []interface {}"},
+		{"- foo", "/: expected object, got []interface {}"},
+		{"typo: 1\ncount: x", "/count: expected integer, got string\n/: unknown property \"typo\""},
+	} {
+		err := validateYAML(t, s, tc.input)
+		if tc.expectedErr == "" {
+			assert.NoError(t, err, tc.input)
+		} else {
+			assert.EqualError(t, err, tc.expectedErr, tc.input)
+		}
+	}
+}
+
+func TestValidateKeywords(t *testing.T) {
+	zero := 0.0
+	s := &jsonschema.Schema{
+		Type: "object",
+		Properties: map[string]*jsonschema.Schema{
+			"enum":    {Enum: []any{"a", 1}},
+			"const":   {Const: "c"},
+			"pattern": {Type: "string", Pattern: "^[a-z]+$"},
+			"size": {
+				AnyOf: []*jsonschema.Schema{
+					{Type: "integer", Minimum: &zero},
+					{Type: "string", Pattern: "^[0-9]+ GiB$"},
+				},
+			},
+			"either": {
+				OneOf: []*jsonschema.Schema{
+					{Type: "string"},
+					{Type: "integer"},
+					{Type: "number"},
+				},
+			},
+			"ref": jsonschema.Ref("def"),
+		},
+		PatternProperties: map[string]*jsonschema.Schema{
+			"^x-": {Type: "boolean"},
+		},
+		PropertyNames: &jsonschema.Schema{Pattern: "^[a-z-]+$"},
+		Required:      []string{"const"},
+		Defs: map[string]*jsonschema.Schema{
+			"def": {Type: "array", Items: &jsonschema.Schema{Type: "string"}},
+		},
+		AdditionalProperties: false,
+	}
+
+	for _, tc := range []struct {
+		input       string
+		expectedErr string
+	}{
+		{"{const: c, enum: 1, pattern: abc, size: 1 GiB, either: foo, ref: [a], x-foo: true}", ""},
+		{"{const: c, size: 10}", ""},
+		{"{}", `/: missing required property "const"`},
+		{"{const: d}", `/const: value "d" is not "c"`},
+		{"{const: c, enum: b}", `/enum: value "b" is not one of ["a" 1]`},
+		{"{const: c, pattern: ABC}", `/pattern: value "ABC" does not match "^[a-z]+$"`},
+		{"{const: c, size: 1 MiB}", `/size: value "1 MiB" does not match "^[0-9]+ GiB$"`},
+		{"{const: c, size: -1}", "/size: value -1 is smaller than 0"},
+		{"{const: c, size: [1]}", "/size: value does not match any of the allowed schemas"},
+		{"{const: c, either: 1}", "/either: value matches 2 schemas, expected exactly one"},
+		{"{const: c, ref: [1]}", "/ref/0: expected string, got int"},
+		{"{const: c, x-foo: 1}", "/x-foo: expected boolean, got int"},
+		{"{const: c, Foo: 1}", "/Foo: value \"Foo\" does not match \"^[a-z-]+$\"\n/: unknown property \"Foo\""},
+	} {
+		err := validateYAML(t, s, tc.input)
+		if tc.expectedErr == "" {
+			assert.NoError(t, err, tc.input)
+		} else {
+			assert.EqualError(t, err, tc.expectedErr, tc.input)
+		}
+	}
+}
+
+func TestValidateDraft4Keywords(t *testing.T) {
+	var s jsonschema.Schema
+	require.NoError(t, json.Unmarshal([]byte(`{
+  "definitions": {
+    "name": {"type": "string", "minLength": 1, "maxLength": 4}
+  },
+  "additionalProperties": false,
+  "properties": {
+    "name": {"$ref": "#/definitions/name"},
+    "nullable": {"type": ["string", "null"]},
+    "port": {"type": "integer", "minimum": 1, "maximum": 65535},
+    "list": {"type": "array", "minItems": 1, "maxItems": 2, "uniqueItems": true},
+    "both": {"allOf": [{"type": "string"}, {"pattern": "^a"}]},
+    "notfoo": {"not": {"enum": ["foo"]}}
+  }
+}`), &s))
+
+	for _, tc := range []struct {
+		input       string
+		expectedErr string
+	}{
+		{"{name: abc, nullable: null, port: 22, list: [a, b], both: abc, notfoo: bar}", ""},
+		{"{nullable: abc}", ""},
+		{"{name: ''}", `/name: value "" is shorter than 1 characters`},
+		{"{name: abcde}", `/name: value "abcde" is longer than 4 characters`},
+		{"{nullable: 1}", "/nullable: expected string or null, got int"},
+		{"{port: 0}", "/port: value 0 is smaller than 1"},
+		{"{port: 65536}", "/port: value 65536 is bigger than 65535"},
+		{"{list: []}", "/list: expected at least 1 items, got 0"},
+		{"{list: [a, b, c]}", "/list: expected at most 2 items, got 3"},
+		{"{list: [a, a]}", "/list/1: duplicate of item 0"},
+		{"{both: bcd}", `/both: value "bcd" does not match "^a"`},
+		{"{notfoo: foo}", "/notfoo: value matches a schema that is not allowed"},
+	} {
+		err := validateYAML(t, &s, tc.input)
+		if tc.expectedErr == "" {
+			assert.NoError(t, err, tc.input)
+		} else {
+			assert.EqualError(t, err, tc.expectedErr, tc.input)
+		}
+	}
+}
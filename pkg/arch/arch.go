@@ -38,6 +38,10 @@ func (a Arch) String() string {
 	}
 }
 
+func (a Arch) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
 func (a *Arch) UnmarshalJSON(data []byte) (err error) {
 	var s string
 	if err := json.Unmarshal(data, &s); err != nil {
@@ -1,6 +1,7 @@
 package arch
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -90,3 +91,17 @@ func TestUnmarshal(t *testing.T) {
 		assert.Equal(t, tc.expected, v.Arch)
 	}
 }
+
+func TestMarshalJSON(t *testing.T) {
+	b, err := json.Marshal(struct {
+		Arch Arch `json:"arch"`
+	}{ARCH_X86_64})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"arch":"x86_64"}`, string(b))
+
+	var v struct {
+		Arch Arch `json:"arch"`
+	}
+	assert.NoError(t, json.Unmarshal(b, &v))
+	assert.Equal(t, ARCH_X86_64, v.Arch)
+}
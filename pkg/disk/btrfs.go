@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math/rand"
 	"reflect"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/osbuild/images/internal/common"
@@ -126,6 +127,21 @@ type BtrfsSubvolume struct {
 	UUID string `json:"uuid,omitempty" yaml:"uuid,omitempty"`
 }
 
+func (sv *BtrfsSubvolume) validate() error {
+	if sv.Compress != "" {
+		algo, level, _ := strings.Cut(sv.Compress, ":")
+		switch algo {
+		case "zlib", "lzo", "zstd":
+		default:
+			return fmt.Errorf("btrfs subvolume %q: unsupported compression %q", sv.Name, sv.Compress)
+		}
+		if level != "" && algo == "lzo" {
+			return fmt.Errorf("btrfs subvolume %q: compression lzo does not support levels", sv.Name)
+		}
+	}
+	return nil
+}
+
 func (sv *BtrfsSubvolume) UnmarshalJSON(data []byte) (err error) {
 	data, err = datasizes.ParseSizeInJSONMapping("size", data)
 	if err != nil {
@@ -138,7 +154,7 @@ func (sv *BtrfsSubvolume) UnmarshalJSON(data []byte) (err error) {
 		return fmt.Errorf("cannot unmarshal %q: %w", data, err)
 	}
 	*sv = BtrfsSubvolume(alias)
-	return err
+	return sv.validate()
 }
 
 func (sv *BtrfsSubvolume) UnmarshalYAML(unmarshal func(any) error) error {
@@ -156,6 +172,7 @@ func (bs *BtrfsSubvolume) Clone() Entity {
 		Mountpoint: bs.Mountpoint,
 		GroupID:    bs.GroupID,
 		Compress:   bs.Compress,
+		ReadOnly:   bs.ReadOnly,
 		UUID:       bs.UUID,
 	}
 }
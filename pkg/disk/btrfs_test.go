@@ -86,3 +86,32 @@ func TestUnmarshalSizeUnitStringBtrfsSubvolume(t *testing.T) {
 		})
 	}
 }
+
+func TestBtrfsSubvolumeUnmarshalCompress(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{"zstd level", `{"name": "data", "compress": "zstd:1"}`, ""},
+		{"lzo", `{"name": "data", "compress": "lzo"}`, ""},
+		{"bad compression", `{"name": "data", "compress": "gzip"}`, `btrfs subvolume "data": unsupported compression "gzip"`},
+		{"lzo level", `{"name": "data", "compress": "lzo:3"}`, `btrfs subvolume "data": compression lzo does not support levels`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var sv BtrfsSubvolume
+			err := json.Unmarshal([]byte(tc.input), &sv)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestBtrfsSubvolumeCloneReadOnly(t *testing.T) {
+	sv := &BtrfsSubvolume{Name: "usr", Mountpoint: "/usr", ReadOnly: true}
+	assert.Equal(t, sv, sv.Clone())
+}
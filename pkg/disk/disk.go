@@ -55,6 +55,10 @@ const (
 	PRePartitionGUID       = "9E1A2D38-C612-4316-AA26-8B49521E5A8B"
 	SwapPartitionGUID      = "0657FD6D-A4AB-43C4-84E5-0933C84B4F4F" // SD_GPT_SWAP
 	XBootLDRPartitionGUID  = "BC13C2FF-59E6-4262-A352-B275FD6F7172" // SD_GPT_XBOOTLDR
+	HomePartitionGUID      = "933AC7E1-2EB4-4F13-B844-0E14E2AEF915" // SD_GPT_HOME
+	SrvPartitionGUID       = "3B8F8425-20E0-4F3B-907F-1A25A76F98E8" // SD_GPT_SRV
+	VarPartitionGUID       = "4D21B016-B534-45C2-A9FB-5C16E091FD2D" // SD_GPT_VAR
+	TmpPartitionGUID       = "7EC6F557-3BC5-4ACA-B293-16EF5DF639D1" // SD_GPT_TMP
 
 	RootPartitionX86_64GUID  = "4F68BCE3-E8CD-4DB1-96E7-FBCAF984B709" // SD_GPT_ROOT_X86_64
 	RootPartitionAarch64GUID = "B921B045-1DF0-41C3-AF44-4C6F280D3FAE" // SD_GPT_ROOT_ARM64
@@ -114,7 +118,7 @@ func getPartitionTypeIDfor(ptType PartitionTableType, partTypeName string, archi
 		switch partTypeName {
 		case "bios":
 			return BIOSBootPartitionDOSID, nil
-		case "data", "boot", "root", "usr":
+		case "data", "boot", "root", "usr", "home", "srv", "var", "tmp":
 			return FilesystemLinuxDOSID, nil
 		case "esp":
 			return EFISystemPartitionDOSID, nil
@@ -143,6 +147,14 @@ func getPartitionTypeIDfor(ptType PartitionTableType, partTypeName string, archi
 			return PRePartitionGUID, nil
 		case "swap":
 			return SwapPartitionGUID, nil
+		case "home":
+			return HomePartitionGUID, nil
+		case "srv":
+			return SrvPartitionGUID, nil
+		case "var":
+			return VarPartitionGUID, nil
+		case "tmp":
+			return TmpPartitionGUID, nil
 		case "root":
 			switch architecture {
 			case arch.ARCH_X86_64:
@@ -193,6 +205,9 @@ const (
 	FS_EXT4
 	FS_XFS
 	FS_BTRFS
+	FS_EROFS
+	FS_SQUASHFS
+	FS_F2FS
 )
 
 func (f FSType) String() string {
@@ -207,11 +222,28 @@ func (f FSType) String() string {
 		return "xfs"
 	case FS_BTRFS:
 		return "btrfs"
+	case FS_EROFS:
+		return "erofs"
+	case FS_SQUASHFS:
+		return "squashfs"
+	case FS_F2FS:
+		return "f2fs"
 	default:
 		panic(fmt.Sprintf("unknown or unsupported filesystem type with enum value %d", f))
 	}
 }
 
+// ReadOnly returns true for filesystem types that are created from the
+// content of a tree and cannot be written to afterwards.
+func (f FSType) ReadOnly() bool {
+	switch f {
+	case FS_EROFS, FS_SQUASHFS:
+		return true
+	default:
+		return false
+	}
+}
+
 func (f *FSType) UnmarshalJSON(data []byte) error {
 	var s string
 	if err := json.Unmarshal(data, &s); err != nil {
@@ -242,6 +274,12 @@ func NewFSType(s string) (FSType, error) {
 		return FS_XFS, nil
 	case "btrfs":
 		return FS_BTRFS, nil
+	case "erofs":
+		return FS_EROFS, nil
+	case "squashfs":
+		return FS_SQUASHFS, nil
+	case "f2fs":
+		return FS_F2FS, nil
 	default:
 		return FS_NONE, fmt.Errorf("unknown or unsupported filesystem type name: %s", s)
 	}
@@ -324,6 +362,16 @@ type PayloadEntity interface {
 
 var payloadEntityMap = map[string]reflect.Type{}
 
+// PayloadTypes returns the types of the payload entities keyed by
+// their "payload_type" name as used when (un)marshaling.
+func PayloadTypes() map[string]reflect.Type {
+	types := make(map[string]reflect.Type, len(payloadEntityMap))
+	for name, t := range payloadEntityMap {
+		types[name] = t
+	}
+	return types
+}
+
 // Container is the interface for entities that can contain other entities.
 // Together with the base Entity interface this allows to model a generic
 // entity tree of theoretically arbitrary depth and width.
@@ -461,6 +509,12 @@ func NewVolIDFromRand(r *rand.Rand) string {
 	if len != 4 {
 		panic("expected four random bytes")
 	}
+	return volIDFromBytes(volid)
+}
+
+// volIDFromBytes formats the given four bytes as a volume ID for FAT
+// filesystems, see NewVolIDFromRand().
+func volIDFromBytes(volid []byte) string {
 	asHex := strings.ToUpper(hex.EncodeToString(volid))
 	return fmt.Sprintf("%s-%s", asHex[:4], asHex[4:])
 }
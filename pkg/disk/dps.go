@@ -0,0 +1,108 @@
+package disk
+
+import (
+	"fmt"
+
+	"github.com/osbuild/images/pkg/arch"
+)
+
+// The Discoverable Partitions Specification (DPS) defines partition types
+// for well-known mountpoints, which allows systemd-gpt-auto-generator(8)
+// to find and mount the partitions without any configuration. See
+// https://uapi-group.org/specifications/specs/discoverable_partitions_specification/
+
+// dpsPartitionTypeName returns the name of the partition type (see
+// getPartitionTypeIDfor()) of the Discoverable Partitions Specification
+// for the filesystem with the given mountpoint or an empty string if there
+// is none.
+func dpsPartitionTypeName(mountpoint string) string {
+	switch mountpoint {
+	case "/":
+		return "root"
+	case "/usr":
+		return "usr"
+	case "/home":
+		return "home"
+	case "/srv":
+		return "srv"
+	case "/var":
+		return "var"
+	case "/var/tmp":
+		return "tmp"
+	case "/boot":
+		return "boot"
+	case "/boot/efi", "/efi":
+		return "esp"
+	}
+	return ""
+}
+
+// dpsPayloadTypeName returns the name of the DPS partition type for the
+// payload of a partition or an empty string if there is none. Only
+// filesystems and swap areas directly on the partition or in a LUKS
+// container are discovered.
+func dpsPayloadTypeName(payload Entity) string {
+	if luks, ok := payload.(*LUKSContainer); ok {
+		payload = luks.Payload
+	}
+	switch ent := payload.(type) {
+	case *Filesystem:
+		return dpsPartitionTypeName(ent.Mountpoint)
+	case *Swap:
+		return "swap"
+	}
+	return ""
+}
+
+// applyDPS sets the partition types of the Discoverable Partitions
+// Specification on all partitions of well-known mountpoints.
+func (pt *PartitionTable) applyDPS(architecture arch.Arch) error {
+	if pt.Type != PT_GPT {
+		return fmt.Errorf("discoverable partitions require a gpt partition table, got %s", pt.Type)
+	}
+	for idx := range pt.Partitions {
+		part := &pt.Partitions[idx]
+		typeName := dpsPayloadTypeName(part.Payload)
+		if typeName == "" {
+			continue
+		}
+		partType, err := getPartitionTypeIDfor(pt.Type, typeName, architecture)
+		if err != nil {
+			return fmt.Errorf("error getting discoverable partition type for %q: %w", typeName, err)
+		}
+		part.Type = partType
+	}
+	return nil
+}
+
+// DPSAutoMounted returns true if the FSTabEntity at the end of the path
+// is found and mounted by systemd-gpt-auto-generator(8) on boot, i.e. it
+// does not need an entry in the fstab or a mount unit. This is the case
+// for /home, /srv, /var/tmp and swap partitions with their DPS partition
+// types on the disk of the root filesystem. The root filesystem, /usr,
+// /boot and the ESP are not included because they are either needed
+// before the generator runs or only discovered when booted via
+// systemd-boot, /var is not included because its partition UUID must be
+// derived from the machine ID, which is unknown when the image is built.
+func (pt *PartitionTable) DPSAutoMounted(ent FSTabEntity, path []Entity) bool {
+	if !pt.DPS || pt.isDataDisk() || len(path) < 2 {
+		return false
+	}
+	part, ok := path[len(path)-2].(*Partition)
+	if !ok {
+		return false
+	}
+	var expected string
+	switch ent.GetFSFile() {
+	case "/home":
+		expected = HomePartitionGUID
+	case "/srv":
+		expected = SrvPartitionGUID
+	case "/var/tmp":
+		expected = TmpPartitionGUID
+	}
+	if ent.GetFSType() == "swap" {
+		expected = SwapPartitionGUID
+	}
+	return expected != "" && part.Type == expected
+}
@@ -0,0 +1,72 @@
+package disk_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/osbuild/blueprint/pkg/blueprint"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/testdisk"
+	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/datasizes"
+	"github.com/osbuild/images/pkg/disk"
+	"github.com/osbuild/images/pkg/disk/partition"
+)
+
+func TestNewPartitionTableDPS(t *testing.T) {
+	rng := rand.New(rand.NewSource(0)) // nolint:gosec
+
+	basePT := testdisk.MakeFakePartitionTable("/boot/efi", "/boot", "swap", "/")
+	mountpoints := []blueprint.FilesystemCustomization{
+		{Mountpoint: "/home", MinSize: 1 * datasizes.GiB},
+		{Mountpoint: "/var/tmp", MinSize: 1 * datasizes.GiB},
+		{Mountpoint: "/opt", MinSize: 1 * datasizes.GiB},
+	}
+	pt, err := disk.NewPartitionTable(basePT, mountpoints, 0, partition.DPSPartitioningMode, arch.ARCH_AARCH64, nil, "xfs", rng)
+	require.NoError(t, err)
+	assert.True(t, pt.DPS)
+	assert.False(t, disk.GetPartitionTableFeatures(*pt).LVM)
+
+	types := map[string]string{}
+	_ = pt.ForEachFSTabEntity(func(ent disk.FSTabEntity, path []disk.Entity) error {
+		types[ent.GetFSFile()] = path[len(path)-2].(*disk.Partition).Type
+		return nil
+	})
+	assert.Equal(t, map[string]string{
+		"/":         disk.RootPartitionAarch64GUID,
+		"/boot":     disk.XBootLDRPartitionGUID,
+		"/boot/efi": disk.EFISystemPartitionGUID,
+		"/home":     disk.HomePartitionGUID,
+		"/var/tmp":  disk.TmpPartitionGUID,
+		"/opt":      disk.FilesystemDataGUID,
+		"none":      disk.SwapPartitionGUID,
+	}, types)
+
+	basePT.Type = disk.PT_DOS
+	_, err = disk.NewPartitionTable(basePT, nil, 0, partition.DPSPartitioningMode, arch.ARCH_AARCH64, nil, "xfs", rng)
+	assert.EqualError(t, err, "discoverable partitions require a gpt partition table, got dos")
+}
+
+func TestPartitionTableDPSAutoMounted(t *testing.T) {
+	pt := testdisk.MakeFakePartitionTable("/", "/home", "/var", "swap")
+	pt.Partitions[1].Type = disk.HomePartitionGUID
+	pt.Partitions[2].Type = disk.VarPartitionGUID
+	pt.Partitions[3].Type = disk.SwapPartitionGUID
+
+	collect := func() []string {
+		var autoMounted []string
+		_ = pt.ForEachFSTabEntity(func(ent disk.FSTabEntity, path []disk.Entity) error {
+			if pt.DPSAutoMounted(ent, path) {
+				autoMounted = append(autoMounted, ent.GetFSType()+":"+ent.GetFSFile())
+			}
+			return nil
+		})
+		return autoMounted
+	}
+	assert.Empty(t, collect())
+
+	pt.DPS = true
+	assert.Equal(t, []string{"ext4:/home", "swap:none"}, collect())
+}
@@ -80,11 +80,14 @@ func TestEnumPartitionTableTypeYAML(t *testing.T) {
 
 func TestEnumFSType(t *testing.T) {
 	enumMap := map[string]disk.FSType{
-		"":      disk.FS_NONE,
-		"vfat":  disk.FS_VFAT,
-		"ext4":  disk.FS_EXT4,
-		"xfs":   disk.FS_XFS,
-		"btrfs": disk.FS_BTRFS,
+		"":         disk.FS_NONE,
+		"vfat":     disk.FS_VFAT,
+		"ext4":     disk.FS_EXT4,
+		"xfs":      disk.FS_XFS,
+		"btrfs":    disk.FS_BTRFS,
+		"erofs":    disk.FS_EROFS,
+		"squashfs": disk.FS_SQUASHFS,
+		"f2fs":     disk.FS_F2FS,
 	}
 
 	assert := assert.New(t)
@@ -99,10 +102,18 @@ func TestEnumFSType(t *testing.T) {
 	}
 
 	// error test: bad value
-	badFst := disk.FSType(5)
-	assert.PanicsWithValue("unknown or unsupported filesystem type with enum value 5", func() { _ = badFst.String() })
+	badFst := disk.FSType(8)
+	assert.PanicsWithValue("unknown or unsupported filesystem type with enum value 8", func() { _ = badFst.String() })
 
 	// error test: bad name
 	_, err := disk.NewFSType("not-a-type")
 	assert.EqualError(err, "unknown or unsupported filesystem type name: not-a-type")
 }
+
+func TestFSTypeReadOnly(t *testing.T) {
+	assert.True(t, disk.FS_EROFS.ReadOnly())
+	assert.True(t, disk.FS_SQUASHFS.ReadOnly())
+	assert.False(t, disk.FS_F2FS.ReadOnly())
+	assert.False(t, disk.FS_XFS.ReadOnly())
+	assert.False(t, disk.FS_NONE.ReadOnly())
+}
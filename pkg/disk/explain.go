@@ -0,0 +1,496 @@
+package disk
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/osbuild/blueprint/pkg/blueprint"
+	"github.com/osbuild/images/pkg/disk/partition"
+)
+
+// SizeSource is the origin of a size requirement of an entity, see
+// SizeReason.
+type SizeSource string
+
+const (
+	// SizeSourceBase is the size of the entity in the base partition
+	// table of the image type
+	SizeSourceBase SizeSource = "base"
+	// SizeSourceBlueprint is the minimum size of a filesystem,
+	// partition or logical volume customization of the blueprint
+	SizeSourceBlueprint SizeSource = "blueprint"
+	// SizeSourceRequired is the sum of the required directory sizes
+	// of the image type that are on the mountpoint of the entity
+	SizeSourceRequired SizeSource = "required_sizes"
+	// SizeSourceVolumes is the space needed by the volumes of a
+	// volume container (e.g. LVM or btrfs) and its metadata
+	SizeSourceVolumes SizeSource = "volumes"
+	// SizeSourceAlignment is the extra space added to align the
+	// entity to the grain of its container
+	SizeSourceAlignment SizeSource = "alignment"
+	// SizeSourceGrow is the extra space added to the partition that
+	// is grown to fill the disk
+	SizeSourceGrow SizeSource = "grow"
+	// SizeSourceDefault is the default size of an automatically
+	// created entity, e.g. the BIOS boot partition or /boot
+	SizeSourceDefault SizeSource = "default"
+)
+
+// SizeReason is a reason for the size of an entity.
+type SizeReason struct {
+	Source SizeSource `json:"source"`
+	// Detail explains the reason, e.g. the directories of the
+	// required sizes or the mountpoint of the customization
+	Detail string `json:"detail,omitempty"`
+	// Size is the size required by the reason or, for alignment and
+	// growth, the extra space that was added (in bytes)
+	Size uint64 `json:"size"`
+	// Driving is true for the requirement that determined the
+	// minimum size of the entity
+	Driving bool `json:"driving,omitempty"`
+}
+
+// EntityReport describes an entity of a resolved partition table.
+type EntityReport struct {
+	// Path of the entity in the partition table, e.g.
+	// "p3/luks/rootvg/rootlv" for a logical volume in an encrypted
+	// volume group on the third partition
+	Path  string `json:"path"`
+	Kind  string `json:"kind"`
+	Depth int    `json:"depth"`
+
+	Mountpoint string `json:"mountpoint,omitempty"`
+	FSType     string `json:"fs_type,omitempty"`
+
+	// Start (partitions only) and Size of the entity in bytes and
+	// sectors. Entities without a size of their own (e.g.
+	// filesystems) use all of their container.
+	Start        uint64 `json:"start,omitempty"`
+	StartSector  uint64 `json:"start_sector,omitempty"`
+	Size         uint64 `json:"size,omitempty"`
+	SizeSectors  uint64 `json:"size_sectors,omitempty"`
+	MetadataSize uint64 `json:"metadata_size,omitempty"`
+	// Alignment is the grain (in bytes) that the size of the entity
+	// is aligned to
+	Alignment uint64 `json:"alignment,omitempty"`
+
+	// AutoCreated is true for entities that were neither in the base
+	// partition table nor in the customizations, e.g. a /boot
+	// partition that is needed to boot from LVM
+	AutoCreated bool         `json:"auto_created,omitempty"`
+	Reasons     []SizeReason `json:"reasons,omitempty"`
+}
+
+// PartitionTableReport describes the resolved layout of a partition
+// table and why each entity has the size it has, see
+// ExplainPartitionTable().
+type PartitionTableReport struct {
+	Type PartitionTableType         `json:"type"`
+	Mode partition.PartitioningMode `json:"partitioning_mode,omitempty"`
+	DPS  bool                       `json:"dps,omitempty"`
+
+	SectorSize uint64 `json:"sector_size"`
+	Size       uint64 `json:"size"`
+	Sectors    uint64 `json:"sectors"`
+	// RequestedSize is the image size that was asked for, the
+	// partition table is bigger if its entities do not fit
+	RequestedSize uint64 `json:"requested_size,omitempty"`
+	// HeaderSize is the space reserved for the partition table
+	// headers at the start (and for GPT at the end) of the disk
+	HeaderSize  uint64 `json:"header_size"`
+	StartOffset uint64 `json:"start_offset,omitempty"`
+
+	Entities []EntityReport `json:"entities"`
+}
+
+// ExplainOptions are the inputs that a partition table was created
+// from, see ExplainPartitionTable().
+type ExplainOptions struct {
+	// BasePartitionTable and Mountpoints are the arguments of
+	// NewPartitionTable()
+	BasePartitionTable *PartitionTable
+	Mountpoints        []blueprint.FilesystemCustomization
+
+	// Disk is the customization of NewCustomPartitionTable(), if set
+	// BasePartitionTable and Mountpoints are ignored
+	Disk *blueprint.DiskCustomization
+
+	Mode      partition.PartitioningMode
+	ImageSize uint64
+	// RequiredSizes are the required directory sizes, if nil the
+	// defaults of NewPartitionTable() are used unless Disk is set
+	RequiredSizes map[string]uint64
+}
+
+// ExplainPartitionTable returns a report of the resolved layout of the
+// partition table pt that was created from the given options with
+// NewPartitionTable() or NewCustomPartitionTable(). The report lists
+// every entity with its offset and size, the alignment and metadata
+// overhead and which requirement (base partition table, blueprint
+// customization or required directory size) drove its minimum size.
+func ExplainPartitionTable(pt *PartitionTable, options *ExplainOptions) *PartitionTableReport {
+	if options == nil {
+		options = &ExplainOptions{}
+	}
+
+	ex := newExplainer(pt, options)
+
+	report := &PartitionTableReport{
+		Type:          pt.Type,
+		Mode:          options.Mode,
+		DPS:           pt.DPS,
+		SectorSize:    pt.SectorSize,
+		Size:          pt.Size,
+		Sectors:       pt.BytesToSectors(pt.Size),
+		RequestedSize: options.ImageSize,
+		HeaderSize:    pt.AlignUp(pt.HeaderSize()),
+		StartOffset:   pt.StartOffset,
+	}
+	if report.SectorSize == 0 {
+		report.SectorSize = DefaultSectorSize
+	}
+	for idx := range pt.Partitions {
+		report.Entities = ex.explain(report.Entities, &pt.Partitions[idx], pt, fmt.Sprintf("p%d", idx+1), 0)
+	}
+	return report
+}
+
+type explainer struct {
+	pt      *PartitionTable
+	options *ExplainOptions
+
+	// requirements of the sizeable entities
+	reasons map[Entity][]SizeReason
+	// mountpoints of the customizations
+	customized map[string]bool
+	// the partition that is grown to fill the disk
+	grown *Partition
+}
+
+func newExplainer(pt *PartitionTable, options *ExplainOptions) *explainer {
+	ex := &explainer{
+		pt:         pt,
+		options:    options,
+		reasons:    make(map[Entity][]SizeReason),
+		customized: make(map[string]bool),
+	}
+
+	// the sizeable entity that holds the given mountpoint, i.e. the
+	// one that gets resized for the mountpoint
+	holder := func(mountpoint string) Entity {
+		for _, ent := range entityPath(pt, mountpoint) {
+			if _, ok := ent.(Sizeable); ok {
+				return ent
+			}
+		}
+		return nil
+	}
+	addReason := func(ent Entity, reason SizeReason) {
+		if ent != nil && reason.Size > 0 {
+			ex.reasons[ent] = append(ex.reasons[ent], reason)
+		}
+	}
+
+	if options.Disk != nil {
+		for _, part := range options.Disk.Partitions {
+			switch part.Type {
+			case "lvm":
+				for _, lv := range part.LogicalVolumes {
+					ex.customized[lv.Mountpoint] = true
+					addReason(holder(lv.Mountpoint), SizeReason{
+						Source: SizeSourceBlueprint,
+						Detail: fmt.Sprintf("logical volume %s", lv.Mountpoint),
+						Size:   lv.MinSize,
+					})
+				}
+				if len(part.LogicalVolumes) > 0 {
+					addReason(ex.partitionOf(part.LogicalVolumes[0].Mountpoint), SizeReason{
+						Source: SizeSourceBlueprint,
+						Detail: "lvm partition",
+						Size:   part.MinSize,
+					})
+				}
+			case "btrfs":
+				for _, subvol := range part.Subvolumes {
+					ex.customized[subvol.Mountpoint] = true
+				}
+				if len(part.Subvolumes) > 0 {
+					addReason(ex.partitionOf(part.Subvolumes[0].Mountpoint), SizeReason{
+						Source: SizeSourceBlueprint,
+						Detail: "btrfs partition",
+						Size:   part.MinSize,
+					})
+				}
+			default:
+				ex.customized[part.Mountpoint] = true
+				addReason(holder(part.Mountpoint), SizeReason{
+					Source: SizeSourceBlueprint,
+					Detail: fmt.Sprintf("partition %s", part.Mountpoint),
+					Size:   part.MinSize,
+				})
+			}
+		}
+	} else {
+		if basePT := options.BasePartitionTable; basePT != nil {
+			_ = pt.ForEachMountable(func(mnt Mountable, path []Entity) error {
+				mountpoint := mnt.GetMountpoint()
+				if size, err := basePT.GetMountpointSize(mountpoint); err == nil {
+					addReason(holder(mountpoint), SizeReason{
+						Source: SizeSourceBase,
+						Detail: fmt.Sprintf("%s in the base partition table", mountpoint),
+						Size:   size,
+					})
+				}
+				return nil
+			})
+		}
+		for _, mnt := range options.Mountpoints {
+			ex.customized[mnt.Mountpoint] = true
+			addReason(holder(mnt.Mountpoint), SizeReason{
+				Source: SizeSourceBlueprint,
+				Detail: fmt.Sprintf("filesystem %s", mnt.Mountpoint),
+				Size:   mnt.MinSize,
+			})
+		}
+	}
+
+	requiredSizes := options.RequiredSizes
+	if requiredSizes == nil && options.Disk == nil {
+		requiredSizes = defaultRequiredSizes()
+	}
+	// required sizes add up per mountpoint, see EnsureDirectorySizes()
+	dirs := make(map[string][]string)
+	sums := make(map[string]uint64)
+	for dir, size := range requiredSizes {
+		entPath := pt.findDirectoryEntityPath(dir)
+		if entPath == nil {
+			continue
+		}
+		mountpoint := entPath[0].(Mountable).GetMountpoint()
+		dirs[mountpoint] = append(dirs[mountpoint], dir)
+		sums[mountpoint] += size
+	}
+	for _, mountpoint := range slices.Sorted(maps.Keys(dirs)) {
+		sort.Strings(dirs[mountpoint])
+		addReason(holder(mountpoint), SizeReason{
+			Source: SizeSourceRequired,
+			Detail: strings.Join(dirs[mountpoint], ", "),
+			Size:   sums[mountpoint],
+		})
+	}
+
+	// see relayout()
+	for idx := range pt.Partitions {
+		part := &pt.Partitions[idx]
+		if len(entityPath(part, "/")) != 0 {
+			ex.grown = part
+		}
+	}
+	if ex.grown == nil && pt.isDataDisk() {
+		ex.grown = &pt.Partitions[len(pt.Partitions)-1]
+	}
+
+	return ex
+}
+
+// partitionOf returns the partition that holds the mountpoint
+func (ex *explainer) partitionOf(mountpoint string) Entity {
+	for _, ent := range entityPath(ex.pt, mountpoint) {
+		if part, ok := ent.(*Partition); ok {
+			return part
+		}
+	}
+	return nil
+}
+
+// autoCreated returns true if the entity was created by the partition
+// table generation, i.e. none of its mountpoints is in the base
+// partition table or in the customizations.
+func (ex *explainer) autoCreated(ent Entity) bool {
+	var mountpoints []string
+	_ = forEachEntity(ent, nil, func(e Entity, path []Entity) error {
+		if mnt, ok := e.(Mountable); ok {
+			mountpoints = append(mountpoints, mnt.GetMountpoint())
+		}
+		return nil
+	})
+
+	basePT := ex.options.BasePartitionTable
+	if ex.options.Disk != nil {
+		basePT = nil
+	}
+	if basePT != nil {
+		// volume containers that the partitioning mode converted
+		// the base partition table to
+		switch ent.(type) {
+		case *LVMVolumeGroup:
+			if !basePT.features().LVM {
+				return true
+			}
+		case *Btrfs:
+			if !basePT.features().Btrfs {
+				return true
+			}
+		}
+	}
+	if len(mountpoints) == 0 {
+		// entities without mountpoints (e.g. the BIOS boot
+		// partition) are never customized, they are either in the
+		// base partition table or created for the boot mode
+		return basePT == nil
+	}
+	for _, mountpoint := range mountpoints {
+		if ex.customized[mountpoint] || (basePT != nil && basePT.ContainsMountpoint(mountpoint)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (ex *explainer) explain(entities []EntityReport, ent Entity, parent Entity, name string, depth int) []EntityReport {
+	report := EntityReport{
+		Path:  name,
+		Depth: depth,
+	}
+
+	switch e := ent.(type) {
+	case *Partition:
+		report.Kind = "partition"
+		report.Start = e.Start
+		report.StartSector = ex.pt.BytesToSectors(e.Start)
+		report.SizeSectors = ex.pt.BytesToSectors(e.Size)
+	case *LVMLogicalVolume:
+		report.Kind = "logical_volume"
+	case *BtrfsSubvolume:
+		report.Kind = "subvolume"
+	case PayloadEntity:
+		report.Kind = e.EntityName()
+	default:
+		report.Kind = fmt.Sprintf("%T", ent)
+	}
+	if mnt, ok := ent.(Mountable); ok {
+		report.Mountpoint = mnt.GetMountpoint()
+		report.FSType = mnt.GetFSType()
+	}
+	if vc, ok := ent.(VolumeContainer); ok {
+		report.MetadataSize = vc.MetadataSize()
+	}
+	if ac, ok := parent.(MountpointCreator); ok {
+		if grain := ac.AlignUp(1); grain > 1 {
+			report.Alignment = grain
+		}
+	}
+	switch e := ent.(type) {
+	case Sizeable:
+		report.Size = e.GetSize()
+		report.AutoCreated = ex.autoCreated(ent)
+		report.Reasons = ex.sizeReasons(ent, report.Size, report.Alignment, report.AutoCreated)
+	case *LVMVolumeGroup, *Btrfs:
+		report.AutoCreated = ex.autoCreated(ent)
+	}
+	entities = append(entities, report)
+
+	c, ok := ent.(Container)
+	if !ok {
+		return entities
+	}
+	for idx := uint(0); idx < c.GetItemCount(); idx++ {
+		child := c.GetChild(idx)
+		entities = ex.explain(entities, child, ent, name+"/"+explainName(child, idx), depth+1)
+	}
+	return entities
+}
+
+// sizeReasons returns the reasons for the size of the sizeable
+// entity: the requirements with the driving one marked and the extra
+// space added for alignment or growth.
+func (ex *explainer) sizeReasons(ent Entity, size, alignment uint64, autoCreated bool) []SizeReason {
+	reasons := slices.Clone(ex.reasons[ent])
+
+	if part, ok := ent.(*Partition); ok {
+		if vc, ok := part.Payload.(VolumeContainer); ok {
+			reasons = append(reasons, SizeReason{
+				Source: SizeSourceVolumes,
+				Detail: fmt.Sprintf("volumes and metadata of the %s", part.Payload.EntityName()),
+				Size:   vc.minSize(0),
+			})
+		}
+	}
+	if len(reasons) == 0 && size > 0 && ent != ex.grown {
+		// entities without a mountpoint (or without customized
+		// size) keep the size they were defined or created with
+		reason := SizeReason{
+			Source: SizeSourceBase,
+			Detail: "size in the base partition table",
+			Size:   size,
+		}
+		if autoCreated {
+			reason.Source = SizeSourceDefault
+			reason.Detail = "default size"
+		}
+		reasons = append(reasons, reason)
+	}
+
+	var minSize uint64
+	driving := -1
+	for idx, reason := range reasons {
+		if reason.Size > minSize {
+			minSize = reason.Size
+			driving = idx
+		}
+	}
+	if driving >= 0 {
+		reasons[driving].Driving = true
+	}
+
+	if size <= minSize {
+		return reasons
+	}
+	aligned := minSize
+	if alignment > 1 && aligned%alignment != 0 {
+		aligned += alignment - aligned%alignment
+	}
+	if aligned > minSize {
+		reasons = append(reasons, SizeReason{
+			Source: SizeSourceAlignment,
+			Size:   min(aligned, size) - minSize,
+		})
+	}
+	if size > aligned {
+		if ent == ex.grown {
+			reasons = append(reasons, SizeReason{
+				Source: SizeSourceGrow,
+				Detail: "grown to fill the disk",
+				Size:   size - aligned,
+			})
+		} else {
+			reasons = append(reasons, SizeReason{
+				Source: SizeSourceAlignment,
+				Detail: "fit to the payload",
+				Size:   size - aligned,
+			})
+		}
+	}
+	return reasons
+}
+
+// explainName returns the name of the nth child entity in the path of
+// an EntityReport.
+func explainName(ent Entity, n uint) string {
+	switch e := ent.(type) {
+	case *LVMLogicalVolume:
+		return e.Name
+	case *LVMVolumeGroup:
+		return e.Name
+	case *BtrfsSubvolume:
+		return e.Name
+	case *Filesystem:
+		return e.Type
+	case PayloadEntity:
+		return e.EntityName()
+	}
+	return fmt.Sprintf("%d", n)
+}
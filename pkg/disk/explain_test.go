@@ -0,0 +1,149 @@
+package disk_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/blueprint/pkg/blueprint"
+	"github.com/osbuild/images/internal/testdisk"
+	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/datasizes"
+	"github.com/osbuild/images/pkg/disk"
+	"github.com/osbuild/images/pkg/disk/partition"
+	"github.com/osbuild/images/pkg/platform"
+)
+
+func findEntityReport(t *testing.T, report *disk.PartitionTableReport, path string) disk.EntityReport {
+	t.Helper()
+	for _, ent := range report.Entities {
+		if ent.Path == path {
+			return ent
+		}
+	}
+	require.Failf(t, "entity not found", "no entity %q in report", path)
+	return disk.EntityReport{}
+}
+
+func TestExplainPartitionTableLVM(t *testing.T) {
+	basePT := testdisk.TestPartitionTables()["plain-noboot"]
+	mountpoints := []blueprint.FilesystemCustomization{
+		{Mountpoint: "/var", MinSize: 5 * datasizes.GiB},
+	}
+	imageSize := uint64(4 * datasizes.GiB)
+
+	/* #nosec G404 */
+	rng := rand.New(rand.NewSource(0))
+	pt, err := disk.NewPartitionTable(&basePT, mountpoints, imageSize, partition.AutoLVMPartitioningMode, arch.ARCH_X86_64, nil, "", rng)
+	require.NoError(t, err)
+
+	report := disk.ExplainPartitionTable(pt, &disk.ExplainOptions{
+		BasePartitionTable: &basePT,
+		Mountpoints:        mountpoints,
+		Mode:               partition.AutoLVMPartitioningMode,
+		ImageSize:          imageSize,
+	})
+	assert.Equal(t, disk.PT_GPT, report.Type)
+	assert.Equal(t, pt.Size, report.Size)
+	assert.Equal(t, pt.Size/512, report.Sectors)
+	assert.Equal(t, imageSize, report.RequestedSize)
+	assert.Len(t, report.Entities, 11)
+
+	// the BIOS boot partition keeps its size
+	biosBoot := findEntityReport(t, report, "p1")
+	assert.Equal(t, "partition", biosBoot.Kind)
+	assert.Equal(t, uint64(2048), biosBoot.StartSector)
+	assert.Equal(t, uint64(2048), biosBoot.SizeSectors)
+	assert.Equal(t, disk.DefaultGrainBytes, biosBoot.Alignment)
+	assert.False(t, biosBoot.AutoCreated)
+	assert.Equal(t, []disk.SizeReason{
+		{Source: disk.SizeSourceBase, Detail: "size in the base partition table", Size: 1 * datasizes.MiB, Driving: true},
+	}, biosBoot.Reasons)
+
+	// the partition table was converted to LVM
+	vg := findEntityReport(t, report, "p3/rootvg")
+	assert.Equal(t, "lvm", vg.Kind)
+	assert.Equal(t, 1, vg.Depth)
+	assert.True(t, vg.AutoCreated)
+	assert.NotZero(t, vg.MetadataSize)
+
+	// the root LV is driven by the default required sizes
+	rootLV := findEntityReport(t, report, "p3/rootvg/rootlv")
+	assert.Equal(t, "logical_volume", rootLV.Kind)
+	assert.False(t, rootLV.AutoCreated)
+	assert.Equal(t, uint64(disk.LVMDefaultExtentSize), rootLV.Alignment)
+	assert.Equal(t, uint64(3*datasizes.GiB), rootLV.Size)
+	assert.Contains(t, rootLV.Reasons, disk.SizeReason{Source: disk.SizeSourceRequired, Detail: "/, /usr", Size: 3 * datasizes.GiB, Driving: true})
+
+	rootFS := findEntityReport(t, report, "p3/rootvg/rootlv/xfs")
+	assert.Equal(t, "/", rootFS.Mountpoint)
+	assert.Equal(t, "xfs", rootFS.FSType)
+	assert.Equal(t, 3, rootFS.Depth)
+
+	// the /var LV is driven by the blueprint
+	varLV := findEntityReport(t, report, "p3/rootvg/varlv")
+	assert.Equal(t, uint64(5*datasizes.GiB), varLV.Size)
+	assert.Equal(t, []disk.SizeReason{
+		{Source: disk.SizeSourceBlueprint, Detail: "filesystem /var", Size: 5 * datasizes.GiB, Driving: true},
+	}, varLV.Reasons)
+
+	// the LVM partition is driven by its volumes and grown to fill
+	// the disk
+	lvmPart := findEntityReport(t, report, "p3")
+	require.Len(t, lvmPart.Reasons, 2)
+	assert.Equal(t, disk.SizeSourceVolumes, lvmPart.Reasons[0].Source)
+	assert.True(t, lvmPart.Reasons[0].Driving)
+	assert.Equal(t, disk.SizeSourceGrow, lvmPart.Reasons[1].Source)
+	assert.Equal(t, lvmPart.Size, lvmPart.Reasons[0].Size+lvmPart.Reasons[1].Size)
+
+	// /boot is needed to boot from LVM
+	boot := findEntityReport(t, report, "p4")
+	assert.True(t, boot.AutoCreated)
+	assert.Equal(t, []disk.SizeReason{
+		{Source: disk.SizeSourceDefault, Detail: "default size", Size: disk.DefaultBootPartitionSize, Driving: true},
+	}, boot.Reasons)
+}
+
+func TestExplainPartitionTableCustom(t *testing.T) {
+	customizations := &blueprint.DiskCustomization{
+		Partitions: []blueprint.PartitionCustomization{
+			{
+				MinSize: 2 * datasizes.GiB,
+				FilesystemTypedCustomization: blueprint.FilesystemTypedCustomization{
+					Mountpoint: "/data",
+					FSType:     "ext4",
+				},
+			},
+		},
+	}
+	options := &disk.CustomPartitionTableOptions{
+		PartitionTableType: disk.PT_GPT,
+		BootMode:           platform.BOOT_HYBRID,
+		DefaultFSType:      disk.FS_XFS,
+		Architecture:       arch.ARCH_X86_64,
+	}
+	/* #nosec G404 */
+	rng := rand.New(rand.NewSource(0))
+	pt, err := disk.NewCustomPartitionTable(customizations, options, rng)
+	require.NoError(t, err)
+
+	report := disk.ExplainPartitionTable(pt, &disk.ExplainOptions{Disk: customizations})
+
+	var auto []string
+	for _, ent := range report.Entities {
+		if ent.AutoCreated {
+			auto = append(auto, ent.Path)
+		}
+	}
+	// the boot partitions and the root partition are created for the
+	// customization, only the /data partition is customized
+	assert.Equal(t, []string{"p1", "p2", "p4"}, auto)
+
+	data := findEntityReport(t, report, "p3")
+	assert.Equal(t, []disk.SizeReason{
+		{Source: disk.SizeSourceBlueprint, Detail: "partition /data", Size: 2 * datasizes.GiB, Driving: true},
+	}, data.Reasons)
+	assert.Equal(t, "/data", findEntityReport(t, report, "p3/ext4").Mountpoint)
+}
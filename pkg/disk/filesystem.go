@@ -1,12 +1,21 @@
 package disk
 
 import (
+	"errors"
+	"fmt"
 	"math/rand"
 	"reflect"
+	"slices"
+	"strings"
 
 	"github.com/google/uuid"
 )
 
+// ErrReadOnlyRootUnsupported is returned for partition tables with a
+// read-only filesystem (see FSType.ReadOnly()) for "/". They have no
+// filesystem UUID that the root= kernel option could refer to.
+var ErrReadOnlyRootUnsupported = errors.New("read-only filesystems cannot be used for the root filesystem")
+
 type MkfsOptionGeometry struct {
 	Heads           int `json:"heads" yaml:"heads"`
 	SectorsPerTrack int `json:"sectors_per_track" yaml:"sectors_per_track"`
@@ -104,19 +113,54 @@ func (fs *Filesystem) GetFSTabOptions() (FSTabOptions, error) {
 	if fs == nil {
 		return FSTabOptions{}, nil
 	}
+	mntOps := fs.FSTabOptions
+	if fs.ReadOnly() {
+		opts := strings.Split(mntOps, ",")
+		if slices.Contains(opts, "rw") {
+			return FSTabOptions{}, fmt.Errorf("read-only %s filesystem %q cannot be mounted with the %q option", fs.Type, fs.Mountpoint, "rw")
+		}
+		if fs.FSTabPassNo != 0 {
+			return FSTabOptions{}, fmt.Errorf("read-only %s filesystem %q cannot be checked by fsck (fstab_passno %d)", fs.Type, fs.Mountpoint, fs.FSTabPassNo)
+		}
+		switch {
+		case mntOps == "":
+			mntOps = "ro"
+		case !slices.Contains(opts, "ro"):
+			mntOps += ",ro"
+		}
+	}
 	return FSTabOptions{
-		MntOps: fs.FSTabOptions,
+		MntOps: mntOps,
 		Freq:   fs.FSTabFreq,
 		PassNo: fs.FSTabPassNo,
 	}, nil
 }
 
+// ReadOnly returns true if the filesystem is created from the content of a
+// tree and cannot be written to afterwards (see FSType.ReadOnly()).
+func (fs *Filesystem) ReadOnly() bool {
+	if fs == nil {
+		return false
+	}
+	fsType, err := NewFSType(fs.Type)
+	if err != nil {
+		return false
+	}
+	return fsType.ReadOnly()
+}
+
 func (fs *Filesystem) GenUUID(rng *rand.Rand) {
 	if fs.Type == "vfat" && fs.UUID == "" {
 		// vfat has no uuids, it has "serial numbers" (volume IDs)
 		fs.UUID = NewVolIDFromRand(rng)
 		return
 	}
+	if fs.ReadOnly() {
+		// read-only filesystems are created from a tree by
+		// osbuild without a known uuid, they are identified via
+		// their partition
+		return
+	}
 	if fs.UUID == "" {
 		fs.UUID = uuid.Must(newRandomUUIDFromReader(rng)).String()
 	}
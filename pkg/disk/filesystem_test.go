@@ -1,12 +1,16 @@
 package disk_test
 
 import (
+	"math/rand"
 	"reflect"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/osbuild/images/pkg/arch"
 	"github.com/osbuild/images/pkg/disk"
+	"github.com/osbuild/images/pkg/disk/partition"
 )
 
 func TestImplementsInterfacesCompileTimeCheckFilesystem(t *testing.T) {
@@ -29,3 +33,69 @@ func TestMkfsOptionsClone(t *testing.T) {
 	assert.False(t, reflect.ValueOf(orig.Geometry).Pointer() == reflect.ValueOf(clone.Geometry).Pointer())
 
 }
+
+func TestFilesystemReadOnlyFSTabOptions(t *testing.T) {
+	for _, tc := range []struct {
+		fs          disk.Filesystem
+		expectedOps string
+		expectedErr string
+	}{
+		{disk.Filesystem{Type: "xfs", Mountpoint: "/", FSTabOptions: "defaults"}, "defaults", ""},
+		{disk.Filesystem{Type: "erofs", Mountpoint: "/usr"}, "ro", ""},
+		{disk.Filesystem{Type: "erofs", Mountpoint: "/usr", FSTabOptions: "defaults"}, "defaults,ro", ""},
+		{disk.Filesystem{Type: "squashfs", Mountpoint: "/opt", FSTabOptions: "ro,noatime"}, "ro,noatime", ""},
+		{disk.Filesystem{Type: "erofs", Mountpoint: "/usr", FSTabOptions: "defaults,rw"}, "", `read-only erofs filesystem "/usr" cannot be mounted with the "rw" option`},
+		{disk.Filesystem{Type: "squashfs", Mountpoint: "/opt", FSTabPassNo: 2}, "", `read-only squashfs filesystem "/opt" cannot be checked by fsck (fstab_passno 2)`},
+	} {
+		opts, err := tc.fs.GetFSTabOptions()
+		if tc.expectedErr != "" {
+			assert.EqualError(t, err, tc.expectedErr)
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, tc.expectedOps, opts.MntOps)
+	}
+}
+
+func TestFilesystemReadOnlyGenUUID(t *testing.T) {
+	rng := rand.New(rand.NewSource(0)) // nolint:gosec
+
+	for _, fsType := range []string{"erofs", "squashfs"} {
+		fs := &disk.Filesystem{Type: fsType, Mountpoint: "/usr"}
+		assert.True(t, fs.ReadOnly())
+		fs.GenUUID(rng)
+		assert.Equal(t, "", fs.UUID)
+	}
+
+	fs := &disk.Filesystem{Type: "f2fs", Mountpoint: "/var"}
+	assert.False(t, fs.ReadOnly())
+	fs.GenUUID(rng)
+	assert.NotEqual(t, "", fs.UUID)
+}
+
+func TestFilesystemReadOnlyRootUnsupported(t *testing.T) {
+	pt := &disk.PartitionTable{
+		Type: disk.PT_GPT,
+		Partitions: []disk.Partition{
+			{Payload: &disk.Filesystem{Type: "erofs", Mountpoint: "/"}},
+		},
+	}
+	rng := rand.New(rand.NewSource(0)) // nolint:gosec
+	_, err := disk.NewPartitionTable(pt, nil, 0, partition.RawPartitioningMode, arch.ARCH_X86_64, nil, "", rng)
+	assert.ErrorIs(t, err, disk.ErrReadOnlyRootUnsupported)
+}
+
+func TestFilesystemBuildPackages(t *testing.T) {
+	for fsType, expected := range map[string][]string{
+		"erofs":    {"e2fsprogs", "erofs-utils"},
+		"squashfs": {"e2fsprogs", "squashfs-tools"},
+		"f2fs":     {"f2fs-tools"},
+	} {
+		pt := &disk.PartitionTable{
+			Partitions: []disk.Partition{
+				{Payload: &disk.Filesystem{Type: fsType, Mountpoint: "/usr"}},
+			},
+		}
+		assert.ElementsMatch(t, expected, pt.GetBuildPackages(), fsType)
+	}
+}
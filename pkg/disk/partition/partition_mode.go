@@ -17,6 +17,14 @@ const (
 	// BtrfsPartitioningMode creates a btrfs layout.
 	BtrfsPartitioningMode PartitioningMode = "btrfs"
 
+	// DPSPartitioningMode creates a raw layout where the partitions of
+	// well-known mountpoints get the partition types of the
+	// Discoverable Partitions Specification. It can only be selected
+	// via the ImageOptions of the API or "dps: true" in the image type
+	// definitions, the partitioning_mode customization of blueprints
+	// does not know it.
+	DPSPartitioningMode PartitioningMode = "dps"
+
 	// DefaultPartitioningMode is AutoLVMPartitioningMode and is the empty state
 	DefaultPartitioningMode PartitioningMode = ""
 )
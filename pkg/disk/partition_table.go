@@ -30,6 +30,16 @@ type PartitionTable struct {
 	ExtraPadding uint64 `json:"extra_padding,omitempty" yaml:"extra_padding,omitempty"`
 	// Starting offset of the first partition in the table (in bytes)
 	StartOffset uint64 `json:"start_offset,omitempty" yaml:"start_offset,omitempty"`
+
+	// DPS assigns the partition types of the Discoverable Partitions
+	// Specification to the partitions of well-known mountpoints (GPT
+	// only), see dps.go.
+	DPS bool `json:"dps,omitempty" yaml:"dps,omitempty"`
+
+	// UUIDNamespace, if set, makes GenerateUUIDs() derive the UUIDs
+	// of the partition table from the namespace instead of generating
+	// random ones, see uuid.go.
+	UUIDNamespace uuid.UUID `json:"-" yaml:"-"`
 }
 
 var _ = MountpointCreator(&PartitionTable{})
@@ -65,6 +75,10 @@ const DefaultBootPartitionSize = 1 * datasizes.GiB
 //     /boot, will be added to the Btrfs volume as new Btrfs subvolumes.
 //   - AutoLVM is the default mode and will convert a raw partition table to an
 //     LVM-based one if and only if new mountpoints are added.
+//   - DPS will not convert any partition to LVM or Btrfs (like Raw) and
+//     assigns the partition types of the Discoverable Partitions
+//     Specification to the partitions of well-known mountpoints (see
+//     PartitionTable.DPS).
 //
 // Directory sizes: The requiredSizes argument defines a map of minimum sizes
 // for specific directories. These indirectly control the minimum sizes of
@@ -93,9 +107,13 @@ const DefaultBootPartitionSize = 1 * datasizes.GiB
 // partition table. Logical Volumes are not grown to fill the space in the
 // Volume Group since they are trivial to grow on a live system.
 func NewPartitionTable(basePT *PartitionTable, mountpoints []blueprint.FilesystemCustomization, imageSize uint64, mode partition.PartitioningMode, architecture arch.Arch, requiredSizes map[string]uint64, defaultFs string, rng *rand.Rand) (*PartitionTable, error) {
+	if err := basePT.checkUnsupported(); err != nil {
+		return nil, err
+	}
+
 	newPT := basePT.Clone().(*PartitionTable)
 
-	if basePT.features().LVM && (mode == partition.RawPartitioningMode || mode == partition.BtrfsPartitioningMode) {
+	if basePT.features().LVM && (mode == partition.RawPartitioningMode || mode == partition.BtrfsPartitioningMode || mode == partition.DPSPartitioningMode) {
 		return nil, fmt.Errorf("%s partitioning mode set for a base partition table with LVM, this is unsupported", mode)
 	}
 
@@ -113,6 +131,9 @@ func NewPartitionTable(basePT *PartitionTable, mountpoints []blueprint.Filesyste
 		ensureLVM = true
 	case partition.RawPartitioningMode:
 		ensureLVM = false
+	case partition.DPSPartitioningMode:
+		ensureLVM = false
+		newPT.DPS = true
 	case partition.DefaultPartitioningMode, partition.AutoLVMPartitioningMode:
 		ensureLVM = len(newMountpoints) > 0
 	case partition.BtrfsPartitioningMode:
@@ -142,16 +163,19 @@ func NewPartitionTable(basePT *PartitionTable, mountpoints []blueprint.Filesyste
 
 	// If no separate requiredSizes are given then we use our defaults
 	if requiredSizes == nil {
-		requiredSizes = map[string]uint64{
-			"/":    1073741824,
-			"/usr": 2147483648,
-		}
+		requiredSizes = defaultRequiredSizes()
 	}
 
 	if len(requiredSizes) != 0 {
 		newPT.EnsureDirectorySizes(requiredSizes)
 	}
 
+	if newPT.DPS {
+		if err := newPT.applyDPS(architecture); err != nil {
+			return nil, err
+		}
+	}
+
 	// Calculate partition table offsets and sizes
 	newPT.relayout(imageSize)
 
@@ -161,6 +185,54 @@ func NewPartitionTable(basePT *PartitionTable, mountpoints []blueprint.Filesyste
 	return newPT, nil
 }
 
+// defaultRequiredSizes returns the required directory sizes that
+// NewPartitionTable() uses if none are given.
+func defaultRequiredSizes() map[string]uint64 {
+	return map[string]uint64{
+		"/":    1073741824,
+		"/usr": 2147483648,
+	}
+}
+
+// NewDataPartitionTable creates a partition table for an additional
+// (data) disk of an image from the basePT. Data disks hold no root
+// filesystem, the operating system is installed on the disk described
+// by the partition table created via NewPartitionTable() and mounts
+// the filesystems of the data disks. The last partition of a data
+// disk is grown to fill the disk.
+func NewDataPartitionTable(basePT *PartitionTable, rng *rand.Rand) (*PartitionTable, error) {
+	if len(basePT.Partitions) == 0 {
+		return nil, fmt.Errorf("data disk partition table has no partitions")
+	}
+	if basePT.FindMountable("/") != nil {
+		return nil, fmt.Errorf("data disk partition table must not contain the root filesystem")
+	}
+	if err := basePT.checkUnsupported(); err != nil {
+		return nil, err
+	}
+
+	newPT := basePT.Clone().(*PartitionTable)
+	newPT.relayout(0)
+	newPT.GenerateUUIDs(rng)
+
+	return newPT, nil
+}
+
+// checkUnsupported returns an error if the partition table has entities
+// that images cannot be built with yet.
+func (pt *PartitionTable) checkUnsupported() error {
+	if fs, ok := pt.FindMountable("/").(*Filesystem); ok && fs.ReadOnly() {
+		return ErrReadOnlyRootUnsupported
+	}
+	return nil
+}
+
+// isDataDisk returns true if the partition table describes a data disk,
+// see NewDataPartitionTable()
+func (pt *PartitionTable) isDataDisk() bool {
+	return len(pt.Partitions) > 0 && pt.FindMountable("/") == nil
+}
+
 func (pt *PartitionTable) UnmarshalJSON(data []byte) (err error) {
 	for _, field := range []string{"size", "start_offset"} {
 		data, err = datasizes.ParseSizeInJSONMapping(field, data)
@@ -195,6 +267,9 @@ func (pt *PartitionTable) Clone() Entity {
 		SectorSize:   pt.SectorSize,
 		ExtraPadding: pt.ExtraPadding,
 		StartOffset:  pt.StartOffset,
+		DPS:          pt.DPS,
+
+		UUIDNamespace: pt.UUIDNamespace,
 	}
 
 	for idx, partition := range pt.Partitions {
@@ -253,8 +328,14 @@ func (pt *PartitionTable) ContainsMountpoint(mountpoint string) bool {
 // Generate all needed UUIDs for all the partiton and filesystems
 //
 // Will not overwrite existing UUIDs and only generate UUIDs for
-// partitions if the layout is GPT.
+// partitions if the layout is GPT. If the partition table has a
+// UUIDNamespace the UUIDs are derived from it and the rng is only
+// used for entities that have no deterministic UUID.
 func (pt *PartitionTable) GenerateUUIDs(rng *rand.Rand) {
+	if pt.UUIDNamespace != uuid.Nil {
+		pt.genDeterministicUUIDs()
+	}
+
 	setuuid := func(ent Entity, path []Entity) error {
 		if ui, ok := ent.(UniqueEntity); ok {
 			ui.GenUUID(rng)
@@ -313,7 +394,8 @@ func (pt *PartitionTable) findDirectoryEntityPath(dir string) []Entity {
 
 // EnsureDirectorySizes takes a mapping of directory paths to sizes (in bytes)
 // and resizes the appropriate partitions such that they are at least the size
-// of the sum of their subdirectories plus their own sizes.
+// of the sum of their subdirectories plus their own sizes. The sizes of the
+// swap files of the partition table are added to their directories.
 // The function will panic if any of the directory paths are invalid.
 func (pt *PartitionTable) EnsureDirectorySizes(dirSizeMap map[string]uint64) {
 
@@ -485,9 +567,14 @@ func (pt *PartitionTable) relayout(size uint64) uint64 {
 	size = pt.AlignUp(size)
 
 	var rootIdx = -1
+	if pt.isDataDisk() {
+		// data disks have no root filesystem, grow their last
+		// partition instead
+		rootIdx = len(pt.Partitions) - 1
+	}
 	for idx := range pt.Partitions {
 		partition := &pt.Partitions[idx]
-		if len(entityPath(partition, "/")) != 0 {
+		if idx == rootIdx || len(entityPath(partition, "/")) != 0 {
 			// keep the root partition index to handle after all the other
 			// partitions have been moved and resized
 			rootIdx = idx
@@ -650,6 +737,17 @@ func (pt *PartitionTable) FindMountable(mountpoint string) Mountable {
 	return path[0].(Mountable)
 }
 
+// FindDirectoryMountable returns the Mountable entity that holds the given
+// directory (or file), i.e. the one with the longest mountpoint that is a
+// parent of the path. Returns nil if there is none.
+func (pt *PartitionTable) FindDirectoryMountable(dir string) Mountable {
+	path := pt.findDirectoryEntityPath(dir)
+	if len(path) == 0 {
+		return nil
+	}
+	return path[0].(Mountable)
+}
+
 func clampFSSize(mountpoint string, size uint64) uint64 {
 	// set a minimum size of 1GB for all mountpoints
 	// with the exception for '/boot' (= 500 MB)
@@ -854,14 +952,17 @@ func (pt *PartitionTable) ensureBtrfs(defaultFS string, architecture arch.Arch)
 }
 
 type partitionTableFeatures struct {
-	LVM   bool
-	Btrfs bool
-	XFS   bool
-	FAT   bool
-	EXT4  bool
-	LUKS  bool
-	Swap  bool
-	Raw   bool
+	LVM      bool
+	Btrfs    bool
+	XFS      bool
+	FAT      bool
+	EXT4     bool
+	EROFS    bool
+	Squashfs bool
+	F2FS     bool
+	LUKS     bool
+	Swap     bool
+	Raw      bool
 }
 
 // features examines all of the PartitionTable entities and returns a struct
@@ -889,6 +990,12 @@ func (pt *PartitionTable) features() partitionTableFeatures {
 				ptFeatures.XFS = true
 			case "ext4":
 				ptFeatures.EXT4 = true
+			case "erofs":
+				ptFeatures.EROFS = true
+			case "squashfs":
+				ptFeatures.Squashfs = true
+			case "f2fs":
+				ptFeatures.F2FS = true
 			}
 		case *Raw:
 			ptFeatures.Raw = true
@@ -926,9 +1033,20 @@ func (pt *PartitionTable) GetBuildPackages() []string {
 	if features.FAT {
 		packages = append(packages, "dosfstools")
 	}
-	if features.EXT4 {
+	// read-only filesystems are backed by a scratch ext4 filesystem
+	// while the disk image is created
+	if features.EXT4 || features.EROFS || features.Squashfs {
 		packages = append(packages, "e2fsprogs")
 	}
+	if features.EROFS {
+		packages = append(packages, "erofs-utils")
+	}
+	if features.Squashfs {
+		packages = append(packages, "squashfs-tools")
+	}
+	if features.F2FS {
+		packages = append(packages, "f2fs-tools")
+	}
 	if features.LUKS {
 		packages = append(packages,
 			"clevis",
@@ -1255,6 +1373,11 @@ type CustomPartitionTableOptions struct {
 	// enable automatic discovery. It has no effect and is not required when
 	// the PartitionTableType is PT_DOS.
 	Architecture arch.Arch
+
+	// UUIDNamespace, if set, is used to derive the UUIDs of the
+	// partition table deterministically instead of using the rng (see
+	// PartitionTable.UUIDNamespace).
+	UUIDNamespace uuid.UUID
 }
 
 // Returns the default filesystem type if the fstype is empty. If both are
@@ -1383,6 +1506,7 @@ func NewCustomPartitionTable(customizations *blueprint.DiskCustomization, option
 	}
 
 	pt.relayout(customizations.MinSize)
+	pt.UUIDNamespace = options.UUIDNamespace
 	pt.GenerateUUIDs(rng)
 
 	// One thing not caught by the customization validation is if a final "dos"
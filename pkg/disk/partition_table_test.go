@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
+	"strings"
 	"testing"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -112,6 +114,127 @@ func TestPartitionTable_GenerateUUIDs_VFAT(t *testing.T) {
 	assert.Equal(t, "6E4F-F95F", pt.Partitions[0].Payload.(*disk.Filesystem).UUID)
 }
 
+func makeUUIDNamespacePartitionTable(namespace uuid.UUID) *disk.PartitionTable {
+	return &disk.PartitionTable{
+		Type:          disk.PT_GPT,
+		UUIDNamespace: namespace,
+		Partitions: []disk.Partition{
+			{
+				Size:     1 * datasizes.MebiByte,
+				Bootable: true,
+				Type:     disk.BIOSBootPartitionGUID,
+			},
+			{
+				Size: 200 * datasizes.MebiByte,
+				Type: disk.EFISystemPartitionGUID,
+				Payload: &disk.Filesystem{
+					Type:       "vfat",
+					Mountpoint: "/boot/efi",
+				},
+			},
+			{
+				Size: 1 * datasizes.GibiByte,
+				Type: disk.FilesystemDataGUID,
+				Payload: &disk.Filesystem{
+					Type:       "xfs",
+					Mountpoint: "/boot",
+				},
+			},
+			{
+				Size: 2 * datasizes.GibiByte,
+				Type: disk.FilesystemDataGUID,
+				Payload: &disk.Filesystem{
+					Type:       "erofs",
+					Mountpoint: "/usr",
+				},
+			},
+			{
+				Size: 1 * datasizes.GibiByte,
+				Type: disk.SwapPartitionGUID,
+				Payload: &disk.Swap{
+					Label: "swap",
+				},
+			},
+			{
+				Size: 10 * datasizes.GibiByte,
+				Payload: &disk.LUKSContainer{
+					Payload: &disk.Btrfs{
+						Subvolumes: []disk.BtrfsSubvolume{
+							{
+								Name:       "root",
+								Mountpoint: "/",
+							},
+							{
+								Name:       "home",
+								Mountpoint: "/home",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestPartitionTable_GenerateUUIDs_Namespace(t *testing.T) {
+	namespace := uuid.NewSHA1(uuid.Nil, []byte("my-image/1.0"))
+
+	pt := makeUUIDNamespacePartitionTable(namespace)
+	/* #nosec G404 */
+	pt.GenerateUUIDs(rand.New(rand.NewSource(0)))
+
+	assert.Equal(t, uuid.NewSHA1(namespace, []byte("partition-table")).String(), pt.UUID)
+	// partitions without a mountpoint are identified by their position
+	assert.Equal(t, uuid.NewSHA1(namespace, []byte("partition:#0")).String(), pt.Partitions[0].UUID)
+	assert.Equal(t, uuid.NewSHA1(namespace, []byte("partition:/boot")).String(), pt.Partitions[2].UUID)
+	assert.Equal(t, uuid.NewSHA1(namespace, []byte("filesystem:/boot")).String(), pt.Partitions[2].Payload.(*disk.Filesystem).UUID)
+	assert.Equal(t, uuid.NewSHA1(namespace, []byte("partition:swap")).String(), pt.Partitions[4].UUID)
+	assert.Equal(t, uuid.NewSHA1(namespace, []byte("swap:swap")).String(), pt.Partitions[4].Payload.(*disk.Swap).UUID)
+
+	// vfat gets a volume ID derived from the UUID
+	espUUID := uuid.NewSHA1(namespace, []byte("filesystem:/boot/efi"))
+	assert.Equal(t, strings.ToUpper(fmt.Sprintf("%x-%x", espUUID[:2], espUUID[2:4])), pt.Partitions[1].Payload.(*disk.Filesystem).UUID)
+
+	// read-only filesystems have no UUID
+	assert.Equal(t, "", pt.Partitions[3].Payload.(*disk.Filesystem).UUID)
+
+	luks := pt.Partitions[5].Payload.(*disk.LUKSContainer)
+	assert.Equal(t, uuid.NewSHA1(namespace, []byte("luks:/")).String(), luks.UUID)
+	btrfs := luks.Payload.(*disk.Btrfs)
+	assert.Equal(t, uuid.NewSHA1(namespace, []byte("btrfs:/")).String(), btrfs.UUID)
+	for _, subvol := range btrfs.Subvolumes {
+		assert.Equal(t, btrfs.UUID, subvol.UUID)
+	}
+
+	// the rng is not used
+	other := makeUUIDNamespacePartitionTable(namespace)
+	/* #nosec G404 */
+	other.GenerateUUIDs(rand.New(rand.NewSource(1)))
+	assert.Equal(t, pt, other)
+
+	// but the namespace is
+	other = makeUUIDNamespacePartitionTable(uuid.NewSHA1(uuid.Nil, []byte("my-image/1.1")))
+	/* #nosec G404 */
+	other.GenerateUUIDs(rand.New(rand.NewSource(0)))
+	assert.NotEqual(t, pt.UUID, other.UUID)
+	assert.NotEqual(t, pt.Partitions[2].UUID, other.Partitions[2].UUID)
+	assert.NotEqual(t, luks.UUID, other.Partitions[5].Payload.(*disk.LUKSContainer).UUID)
+}
+
+func TestPartitionTable_GenerateUUIDs_NamespaceKeepsUUIDs(t *testing.T) {
+	pt := makeUUIDNamespacePartitionTable(uuid.NewSHA1(uuid.Nil, []byte("my-image/1.0")))
+	pt.UUID = "D209C89E-EA5E-4FBD-B161-B461CCE297E0"
+	pt.Partitions[0].UUID = disk.BIOSBootPartitionUUID
+	pt.Partitions[1].Payload.(*disk.Filesystem).UUID = disk.EFIFilesystemUUID
+
+	/* #nosec G404 */
+	pt.GenerateUUIDs(rand.New(rand.NewSource(0)))
+
+	assert.Equal(t, "D209C89E-EA5E-4FBD-B161-B461CCE297E0", pt.UUID)
+	assert.Equal(t, disk.BIOSBootPartitionUUID, pt.Partitions[0].UUID)
+	assert.Equal(t, disk.EFIFilesystemUUID, pt.Partitions[1].Payload.(*disk.Filesystem).UUID)
+}
+
 func TestEnsureRootFilesystem(t *testing.T) {
 	type testCase struct {
 		pt            disk.PartitionTable
@@ -3247,3 +3370,54 @@ func TestUnmarshalSizeUnitStringPartitionTable(t *testing.T) {
 		})
 	}
 }
+
+func TestNewDataPartitionTable(t *testing.T) {
+	// math/rand is good enough in this case
+	/* #nosec G404 */
+	rng := rand.New(rand.NewSource(0))
+
+	basePT := &disk.PartitionTable{
+		Size: 20 * datasizes.GiB,
+		Type: disk.PT_GPT,
+		Partitions: []disk.Partition{
+			{
+				Size: 1 * datasizes.GiB,
+				Type: disk.SwapPartitionGUID,
+				Payload: &disk.Swap{
+					FSTabOptions: "defaults",
+				},
+			},
+			{
+				Type: disk.FilesystemDataGUID,
+				Payload: &disk.Filesystem{
+					Type:       "xfs",
+					Mountpoint: "/var/lib/containers",
+				},
+			},
+		},
+	}
+	pt, err := disk.NewDataPartitionTable(basePT, rng)
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(20*datasizes.GiB), pt.Size)
+	assert.NotEmpty(t, pt.UUID)
+	assert.Empty(t, basePT.UUID)
+	// the last partition fills the disk (minus the secondary GPT header)
+	swap, data := pt.Partitions[0], pt.Partitions[1]
+	assert.Equal(t, uint64(1*datasizes.GiB), swap.Size)
+	assert.Equal(t, swap.Start+swap.Size, data.Start)
+	assert.Equal(t, pt.Size-pt.HeaderSize(), data.Start+data.Size)
+	assert.NotEmpty(t, data.Payload.(*disk.Filesystem).UUID)
+}
+
+func TestNewDataPartitionTableErrors(t *testing.T) {
+	// math/rand is good enough in this case
+	/* #nosec G404 */
+	rng := rand.New(rand.NewSource(0))
+
+	_, err := disk.NewDataPartitionTable(&disk.PartitionTable{}, rng)
+	assert.EqualError(t, err, "data disk partition table has no partitions")
+
+	_, err = disk.NewDataPartitionTable(testdisk.MakeFakePartitionTable("/", "/home"), rng)
+	assert.EqualError(t, err, "data disk partition table must not contain the root filesystem")
+}
@@ -0,0 +1,110 @@
+package disk
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// genDeterministicUUIDs sets all UUIDs of the partition table that are
+// not yet set to name based (version 5) UUIDs in the namespace of the
+// partition table (see PartitionTable.UUIDNamespace).
+//
+// The name of an entity is derived from its type and the first
+// mountpoint it contains (e.g. "partition:/boot" or "luks:/"), so
+// that the UUIDs only depend on the namespace and the layout and not
+// on the position of an entity in the partition table. Entities
+// without a mountpoint fall back to their position.
+func (pt *PartitionTable) genDeterministicUUIDs() {
+	names := make(map[string]bool)
+	newUUID := func(name string) uuid.UUID {
+		if unique, err := genUniqueString(name, names); err == nil {
+			name = unique
+		}
+		names[name] = true
+		return uuid.NewSHA1(pt.UUIDNamespace, []byte(name))
+	}
+
+	if pt.UUID == "" {
+		pt.UUID = newUUID("partition-table").String()
+	}
+
+	for idx := range pt.Partitions {
+		part := &pt.Partitions[idx]
+		anchor := uuidAnchor(part.Payload)
+		if anchor == "" {
+			anchor = fmt.Sprintf("#%d", idx)
+		}
+
+		if pt.Type == PT_GPT && part.UUID == "" {
+			part.UUID = newUUID("partition:" + anchor).String()
+		}
+
+		if part.Payload == nil {
+			continue
+		}
+		_ = forEachEntity(part.Payload, nil, func(ent Entity, path []Entity) error {
+			name := func(kind string) string {
+				if entAnchor := uuidAnchor(ent); entAnchor != "" {
+					return kind + ":" + entAnchor
+				}
+				return fmt.Sprintf("%s:%s/%d", kind, anchor, len(path))
+			}
+
+			switch e := ent.(type) {
+			case *Filesystem:
+				if e.UUID != "" || e.ReadOnly() {
+					// see Filesystem.GenUUID()
+					return nil
+				}
+				id := newUUID(name("filesystem"))
+				if e.Type == "vfat" {
+					e.UUID = volIDFromBytes(id[:4])
+				} else {
+					e.UUID = id.String()
+				}
+			case *Btrfs:
+				if e.UUID == "" {
+					e.UUID = newUUID(name("btrfs")).String()
+				}
+				for i := range e.Subvolumes {
+					e.Subvolumes[i].UUID = e.UUID
+				}
+			case *LUKSContainer:
+				if e.UUID == "" {
+					e.UUID = newUUID(name("luks")).String()
+				}
+			case *Swap:
+				if e.UUID == "" {
+					e.UUID = newUUID(name("swap")).String()
+				}
+			}
+			return nil
+		})
+	}
+}
+
+// uuidAnchor returns the name that identifies the given entity for
+// the generation of deterministic UUIDs: the first mountpoint in the
+// entity or one of its children or "swap" for swap areas. It returns
+// an empty string if there is no such name.
+func uuidAnchor(ent Entity) string {
+	if ent == nil {
+		return ""
+	}
+
+	var anchor string
+	_ = forEachEntity(ent, nil, func(e Entity, path []Entity) error {
+		if anchor != "" {
+			return nil
+		}
+		switch e := e.(type) {
+		case Mountable:
+			anchor = e.GetMountpoint()
+		case *Swap:
+			anchor = "swap"
+		}
+		return nil
+	})
+	return anchor
+}
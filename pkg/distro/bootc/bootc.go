@@ -137,6 +137,11 @@ func (d *BootcDistro) OSTreeRef() string {
 	return ""
 }
 
+func (d *BootcDistro) Lifecycle() distro.Lifecycle {
+	// the lifecycle is defined by the bootc container
+	return distro.Lifecycle{}
+}
+
 func (d *BootcDistro) ListArches() []string {
 	archs := make([]string, 0, len(d.arches))
 	for name := range d.arches {
@@ -210,6 +215,10 @@ func (t *BootcImageType) Aliases() []string {
 	return nil
 }
 
+func (t *BootcImageType) Deprecation() *distro.Deprecation {
+	return nil
+}
+
 func (t *BootcImageType) Arch() distro.Arch {
 	return t.arch
 }
@@ -51,10 +51,8 @@ func genManifest(t *testing.T, imgType distro.ImageType) string {
 	assert.NoError(t, err)
 
 	// XXX: it would be nice to return an *osbuild.Manifest here
-	// and do all of this more structed, however this is not
-	// working currently as osbuild.NewManifestsFromBytes() cannot
-	// unmarshal our manifests because of:
-	// "unexpected source name: org.osbuild.containers-storage"
+	// (via osbuild.NewManifestFromBytes()) and do all of this
+	// more structured
 	return string(manifestJson)
 }
 
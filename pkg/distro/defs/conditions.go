@@ -0,0 +1,280 @@
+package defs
+
+import (
+	"fmt"
+	"io/fs"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/osbuild/images/pkg/distro"
+)
+
+// prioritized is implemented by all conditions that can be ordered
+// via their "priority" key.
+type prioritized interface {
+	getPriority() int
+}
+
+func (c *distroImageConfigConditions) getPriority() int       { return c.Priority }
+func (c *conditionsPlatforms) getPriority() int               { return c.Priority }
+func (c *conditionsImgConf) getPriority() int                 { return c.Priority }
+func (c *conditionsInstallerConf) getPriority() int           { return c.Priority }
+func (c *pkgSetConditions) getPriority() int                  { return c.Priority }
+func (c *partitionTablesOverwriteCondition) getPriority() int { return c.Priority }
+
+// sortedConditionNames returns the names of the given conditions in
+// the order they need to be applied: ordered by ascending priority
+// and then by name. Conditions that are applied later win, i.e. the
+// condition with the highest priority wins.
+func sortedConditionNames[T prioritized](conds map[string]T) []string {
+	names := make([]string, 0, len(conds))
+	for name := range conds {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		pi, pj := conds[names[i]].getPriority(), conds[names[j]].getPriority()
+		if pi != pj {
+			return pi < pj
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// ConditionOverlap describes conditions that match at the same time
+// for a given distro/arch and that change the same keys. The result
+// of such conditions depends on their order so they should either
+// be made mutually exclusive or get an explicit priority.
+type ConditionOverlap struct {
+	Distro string
+	Arch   string
+	// ImageType is empty for the distro wide image config
+	ImageType string
+	// Section is the part of the definitions with the overlapping
+	// conditions, e.g. "image_config" or "partition_tables_override"
+	Section    string
+	Conditions []string
+	Keys       []string
+}
+
+func (o ConditionOverlap) String() string {
+	where := o.Distro
+	if o.ImageType != "" {
+		where += "/" + o.ImageType
+	}
+	if o.Arch != "" {
+		where += "/" + o.Arch
+	}
+	return fmt.Sprintf("%s: %s conditions %q overlap on keys %q", where, o.Section, o.Conditions, o.Keys)
+}
+
+// setKeys returns the YAML names of all fields of the given struct
+// (pointer) that are set.
+func setKeys(v any) []string {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	var keys []string
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Type().Field(i)
+		if !field.IsExported() || rv.Field(i).IsZero() {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		keys = append(keys, name)
+	}
+	return keys
+}
+
+// matchingConditions returns the names of the conditions that
+// match (in the order they are applied).
+func matchingConditions[T prioritized](conds map[string]T, match func(cond T) bool) []string {
+	var matching []string
+	for _, name := range sortedConditionNames(conds) {
+		if match(conds[name]) {
+			matching = append(matching, name)
+		}
+	}
+	return matching
+}
+
+// findOverlaps returns the keys that are changed by more than one of
+// the given matching conditions and the conditions that change
+// them. Conditions with a different priority do not overlap as
+// their order is well defined. The keysFor helper returns the keys
+// a condition changes.
+func findOverlaps[T prioritized](conds map[string]T, matching []string, keysFor func(cond T) []string) (overlapConds []string, keys []string) {
+	type keyPrio struct {
+		key      string
+		priority int
+	}
+	seen := make(map[keyPrio][]string)
+	for _, name := range matching {
+		for _, key := range keysFor(conds[name]) {
+			kp := keyPrio{key, conds[name].getPriority()}
+			seen[kp] = append(seen[kp], name)
+		}
+	}
+	keySet := make(map[string]bool)
+	condSet := make(map[string]bool)
+	for kp, names := range seen {
+		if len(names) < 2 {
+			continue
+		}
+		keySet[kp.key] = true
+		for _, name := range names {
+			condSet[name] = true
+		}
+	}
+	for key := range keySet {
+		keys = append(keys, key)
+	}
+	for name := range condSet {
+		overlapConds = append(overlapConds, name)
+	}
+	sort.Strings(keys)
+	sort.Strings(overlapConds)
+	return overlapConds, keys
+}
+
+// ConditionOverlaps returns all the overlapping conditions of the
+// distro wide image config and of all image types for all their
+// architectures.
+func (d *DistroYAML) ConditionOverlaps() []ConditionOverlap {
+	var overlaps []ConditionOverlap
+	add := func(arch, imgType, section string, conds, keys []string) {
+		if len(keys) == 0 {
+			return
+		}
+		overlaps = append(overlaps, ConditionOverlap{
+			Distro:     d.ID.String(),
+			Arch:       arch,
+			ImageType:  imgType,
+			Section:    section,
+			Conditions: conds,
+			Keys:       keys,
+		})
+	}
+
+	if conds := d.imageConfigConds; conds != nil {
+		matching := matchingConditions(conds, func(cond *distroImageConfigConditions) bool {
+			return cond.When.Eval(d.ID, "")
+		})
+		overlapConds, keys := findOverlaps(conds, matching, func(cond *distroImageConfigConditions) []string {
+			return setKeys(cond.ShallowMerge)
+		})
+		add("", "", "image_config", overlapConds, keys)
+	}
+
+	imgTypeNames := make([]string, 0, len(d.imageTypes))
+	for name := range d.imageTypes {
+		imgTypeNames = append(imgTypeNames, name)
+	}
+	sort.Strings(imgTypeNames)
+	for _, name := range imgTypeNames {
+		it := d.imageTypes[name]
+		for _, o := range it.conditionOverlaps(d.ID) {
+			add(o.Arch, name, o.Section, o.Conditions, o.Keys)
+		}
+	}
+
+	return overlaps
+}
+
+func (it *ImageTypeYAML) conditionOverlaps(id distro.ID) []ConditionOverlap {
+	var overlaps []ConditionOverlap
+	add := func(arch, section string, conds, keys []string) {
+		if len(keys) == 0 {
+			return
+		}
+		overlaps = append(overlaps, ConditionOverlap{
+			Arch:       arch,
+			Section:    section,
+			Conditions: conds,
+			Keys:       keys,
+		})
+	}
+
+	if it.PlatformsOverride != nil {
+		conds := it.PlatformsOverride.Conditions
+		ctx := &whenContext{id: id, imgType: it.name}
+		matching := matchingConditions(conds, func(cond *conditionsPlatforms) bool {
+			return cond.When.eval(ctx)
+		})
+		overlapConds, keys := findOverlaps(conds, matching, func(*conditionsPlatforms) []string {
+			return []string{"platforms"}
+		})
+		add("", "platforms_override", overlapConds, keys)
+	}
+
+	platforms, err := it.PlatformsFor(id)
+	if err != nil {
+		// already reported above
+		return overlaps
+	}
+	for _, pl := range platforms {
+		archName := pl.Arch.String()
+		ctx := it.whenContext(id, archName)
+
+		if it.PartitionTablesOverrides != nil {
+			conds := it.PartitionTablesOverrides.Conditions
+			matching := matchingConditions(conds, func(cond *partitionTablesOverwriteCondition) bool {
+				return cond.When.eval(ctx)
+			})
+			overlapConds, keys := findOverlaps(conds, matching, func(cond *partitionTablesOverwriteCondition) []string {
+				if _, ok := cond.Override[archName]; ok {
+					return []string{archName}
+				}
+				return nil
+			})
+			add(archName, "partition_tables_override", overlapConds, keys)
+		}
+
+		imgConds := it.ImageConfigYAML.Conditions
+		matching := matchingConditions(imgConds, func(cond *conditionsImgConf) bool {
+			return cond.When.eval(ctx)
+		})
+		overlapConds, keys := findOverlaps(imgConds, matching, func(cond *conditionsImgConf) []string {
+			return setKeys(cond.ShallowMerge)
+		})
+		add(archName, "image_config", overlapConds, keys)
+
+		installerConds := it.InstallerConfigYAML.Conditions
+		matching = matchingConditions(installerConds, func(cond *conditionsInstallerConf) bool {
+			return cond.When.eval(ctx)
+		})
+		overlapConds, keys = findOverlaps(installerConds, matching, func(cond *conditionsInstallerConf) []string {
+			return setKeys(cond.ShallowMerge)
+		})
+		add(archName, "installer_config", overlapConds, keys)
+	}
+
+	return overlaps
+}
+
+// LintConditionOverlaps loads the given distros and returns all
+// overlapping conditions for all their image types and
+// architectures. The optional searchPaths work the same way as for
+// NewDistroYAML.
+func LintConditionOverlaps(nameVers []string, searchPaths ...fs.FS) ([]ConditionOverlap, error) {
+	var overlaps []ConditionOverlap
+	for _, nameVer := range nameVers {
+		d, err := newDistroYAML(nameVer, searchPaths...)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load %q: %w", nameVer, err)
+		}
+		if d == nil {
+			return nil, fmt.Errorf("cannot find distro %q", nameVer)
+		}
+		overlaps = append(overlaps, d.ConditionOverlaps()...)
+	}
+	return overlaps, nil
+}
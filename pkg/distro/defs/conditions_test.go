@@ -0,0 +1,150 @@
+package defs_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/distro"
+	"github.com/osbuild/images/pkg/distro/defs"
+)
+
+var overlappingConditionsYAML = `
+image_types:
+  test_type:
+    platforms:
+      - arch: x86_64
+    image_config:
+      conditions:
+        "a: hostname for distro":
+          when:
+            distro_name: "test-distro"
+          shallow_merge:
+            hostname: "from-a"
+            locale: "C.UTF-8"
+        "b: hostname for arch":
+          when:
+            arch: "x86_64"
+          shallow_merge:
+            hostname: "from-b"
+`
+
+func TestConditionsAppliedInNameOrder(t *testing.T) {
+	it := makeTestImageType(t, overlappingConditionsYAML)
+
+	id := distro.ID{Name: "test-distro", MajorVersion: 1, MinorVersion: -1}
+	for i := 0; i < 10; i++ {
+		imgConfig := it.ImageConfig(id, "x86_64")
+		assert.Equal(t, common.ToPtr("from-b"), imgConfig.Hostname)
+		assert.Equal(t, common.ToPtr("C.UTF-8"), imgConfig.Locale)
+	}
+}
+
+func TestConditionsAppliedInPriorityOrder(t *testing.T) {
+	it := makeTestImageType(t, `
+image_types:
+  test_type:
+    platforms:
+      - arch: x86_64
+    partition_table:
+      x86_64: &pt
+        size: 1_000_000_000
+    partition_tables_override:
+      conditions:
+        "a: high priority":
+          priority: 10
+          when:
+            distro_name: "test-distro"
+          override:
+            x86_64:
+              size: 3_000_000_000
+        "b: low priority":
+          when:
+            arch: "x86_64"
+          override:
+            x86_64:
+              size: 2_000_000_000
+`)
+
+	id := distro.ID{Name: "test-distro", MajorVersion: 1, MinorVersion: -1}
+	for i := 0; i < 10; i++ {
+		pt, err := it.PartitionTable(id, "x86_64")
+		require.NoError(t, err)
+		assert.Equal(t, uint64(3_000_000_000), pt.Size)
+	}
+}
+
+func TestConditionOverlaps(t *testing.T) {
+	baseDir := makeFakeDistrosYAML(t, "", overlappingConditionsYAML)
+
+	overlaps, err := defs.LintConditionOverlaps([]string{"test-distro-1"}, os.DirFS(baseDir))
+	require.NoError(t, err)
+	assert.Equal(t, []defs.ConditionOverlap{
+		{
+			Distro:     "test-distro-1",
+			Arch:       "x86_64",
+			ImageType:  "test_type",
+			Section:    "image_config",
+			Conditions: []string{"a: hostname for distro", "b: hostname for arch"},
+			Keys:       []string{"hostname"},
+		},
+	}, overlaps)
+	assert.Equal(t, `test-distro-1/test_type/x86_64: image_config conditions ["a: hostname for distro" "b: hostname for arch"] overlap on keys ["hostname"]`, overlaps[0].String())
+}
+
+func TestConditionOverlapsPriorityIsNoOverlap(t *testing.T) {
+	baseDir := makeFakeDistrosYAML(t, "", `
+image_types:
+  test_type:
+    platforms:
+      - arch: x86_64
+    image_config:
+      conditions:
+        "a":
+          priority: 1
+          when:
+            distro_name: "test-distro"
+          shallow_merge:
+            hostname: "from-a"
+        "b":
+          when:
+            arch: "x86_64"
+          shallow_merge:
+            hostname: "from-b"
+`)
+
+	overlaps, err := defs.LintConditionOverlaps([]string{"test-distro-1"}, os.DirFS(baseDir))
+	require.NoError(t, err)
+	assert.Len(t, overlaps, 0)
+}
+
+func TestConditionOverlapsStrictErrors(t *testing.T) {
+	baseDir := makeFakeDistrosYAML(t, "", "strict_conditions: true\n"+overlappingConditionsYAML)
+	restore := defs.MockDataFS(baseDir)
+	defer restore()
+
+	_, err := defs.NewDistroYAML("test-distro-1")
+	assert.ErrorIs(t, err, defs.ErrOverlappingConditions)
+	assert.ErrorContains(t, err, `overlapping conditions: test-distro-1/test_type/x86_64: image_config conditions`)
+}
+
+func TestConditionOverlapsNonStrictLoads(t *testing.T) {
+	baseDir := makeFakeDistrosYAML(t, "", overlappingConditionsYAML)
+	restore := defs.MockDataFS(baseDir)
+	defer restore()
+
+	_, err := defs.NewDistroYAML("test-distro-1")
+	assert.NoError(t, err)
+}
+
+func TestConditionOverlapsDistrodefs(t *testing.T) {
+	overlaps, err := defs.LintConditionOverlaps([]string{
+		"rhel-7.9", "rhel-8.10", "rhel-9.6", "rhel-10.0",
+		"centos-9", "centos-10", "fedora-42",
+	})
+	require.NoError(t, err)
+	assert.Len(t, overlaps, 0, "%v", overlaps)
+}
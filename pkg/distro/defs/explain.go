@@ -0,0 +1,211 @@
+package defs
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/osbuild/images/pkg/disk"
+	"github.com/osbuild/images/pkg/platform"
+)
+
+// ExplainedValue is a single resolved value together with the part
+// of the definitions that it comes from.
+type ExplainedValue struct {
+	Key    string `json:"key"`
+	Value  any    `json:"value"`
+	Source string `json:"source"`
+}
+
+// Explanation contains the fully resolved definition of an image
+// type for a given distro/arch and where the values come from.
+type Explanation struct {
+	Distro    string `json:"distro"`
+	Arch      string `json:"arch"`
+	ImageType string `json:"image_type"`
+	// ExtendsChain is the list of image types that the image
+	// type inherits from (see ImageTypeYAML.ExtendsChain)
+	ExtendsChain []string `json:"extends_chain,omitempty"`
+
+	ImageConfig     []ExplainedValue `json:"image_config,omitempty"`
+	InstallerConfig []ExplainedValue `json:"installer_config,omitempty"`
+	// PackageSets maps the package set name to the packages, the
+	// key of each value is either "include" or "exclude"
+	PackageSets map[string][]ExplainedValue `json:"package_sets,omitempty"`
+
+	PartitionTable       *disk.PartitionTable `json:"partition_table,omitempty"`
+	PartitionTableSource string               `json:"partition_table_source,omitempty"`
+
+	Platform       *platform.Data `json:"platform,omitempty"`
+	PlatformSource string         `json:"platform_source,omitempty"`
+}
+
+// configLayer is a single (shallow) config that is merged on top of
+// the previous layers
+type configLayer struct {
+	source string
+	config any
+}
+
+// explainConfig returns the set values of the given final config
+// with the source of the layer that set them last.
+func explainConfig(final any, layers []configLayer) []ExplainedValue {
+	sources := make(map[string]string)
+	for _, layer := range layers {
+		for _, key := range setKeys(layer.config) {
+			sources[key] = layer.source
+		}
+	}
+
+	rv := reflect.ValueOf(final)
+	if rv.IsNil() {
+		return nil
+	}
+	rv = rv.Elem()
+	var res []ExplainedValue
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Type().Field(i)
+		fv := rv.Field(i)
+		if !field.IsExported() || fv.IsZero() {
+			continue
+		}
+		key := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if key == "" {
+			key = strings.ToLower(field.Name)
+		}
+		if fv.Kind() == reflect.Ptr {
+			fv = fv.Elem()
+		}
+		res = append(res, ExplainedValue{
+			Key:    key,
+			Value:  fv.Interface(),
+			Source: sources[key],
+		})
+	}
+	return res
+}
+
+func conditionSource(section, name string) string {
+	return fmt.Sprintf("%s condition %q", section, name)
+}
+
+// Explain returns the fully resolved definition of the given image
+// type for the given architecture of the distro.
+func (d *DistroYAML) Explain(imgTypeName, archName string) (*Explanation, error) {
+	it, ok := d.imageTypes[imgTypeName]
+	if !ok {
+		return nil, fmt.Errorf("cannot find image type %q for distro %q", imgTypeName, d.ID)
+	}
+	ctx := it.whenContext(d.ID, archName)
+
+	expl := &Explanation{
+		Distro:       d.ID.String(),
+		Arch:         archName,
+		ImageType:    imgTypeName,
+		ExtendsChain: it.ExtendsChain(),
+		PackageSets:  make(map[string][]ExplainedValue),
+	}
+
+	// platform
+	platforms, err := it.PlatformsFor(d.ID)
+	if err != nil {
+		return nil, err
+	}
+	expl.PlatformSource = "platforms"
+	if it.PlatformsOverride != nil {
+		for _, name := range matchingConditions(it.PlatformsOverride.Conditions, func(cond *conditionsPlatforms) bool {
+			return cond.When.eval(&whenContext{id: d.ID, imgType: it.name})
+		}) {
+			expl.PlatformSource = conditionSource("platforms_override", name)
+		}
+	}
+	for _, pl := range platforms {
+		if pl.Arch.String() == archName {
+			expl.Platform = &pl
+			break
+		}
+	}
+	if expl.Platform == nil {
+		return nil, fmt.Errorf("image type %q is not available for arch %q", imgTypeName, archName)
+	}
+
+	// image config, the image type config inherits from the
+	// distro config so the distro layers come first
+	var layers []configLayer
+	if d.imageConfigDefault != nil {
+		layers = append(layers, configLayer{"distro image_config", d.imageConfigDefault})
+	}
+	for _, name := range matchingConditions(d.imageConfigConds, func(cond *distroImageConfigConditions) bool {
+		return cond.When.Eval(d.ID, "")
+	}) {
+		layers = append(layers, configLayer{conditionSource("distro image_config", name), d.imageConfigConds[name].ShallowMerge})
+	}
+	if it.ImageConfigYAML.ImageConfig != nil {
+		layers = append(layers, configLayer{"image_config", it.ImageConfigYAML.ImageConfig})
+	}
+	for _, name := range matchingConditions(it.ImageConfigYAML.Conditions, func(cond *conditionsImgConf) bool {
+		return cond.When.eval(ctx)
+	}) {
+		layers = append(layers, configLayer{conditionSource("image_config", name), it.ImageConfigYAML.Conditions[name].ShallowMerge})
+	}
+	expl.ImageConfig = explainConfig(it.ImageConfig(d.ID, archName).InheritFrom(d.ImageConfig()), layers)
+
+	// installer config
+	if it.InstallerConfigYAML.InstallerConfig != nil || len(it.InstallerConfigYAML.Conditions) > 0 {
+		layers = nil
+		if it.InstallerConfigYAML.InstallerConfig != nil {
+			layers = append(layers, configLayer{"installer_config", it.InstallerConfigYAML.InstallerConfig})
+		}
+		for _, name := range matchingConditions(it.InstallerConfigYAML.Conditions, func(cond *conditionsInstallerConf) bool {
+			return cond.When.eval(ctx)
+		}) {
+			layers = append(layers, configLayer{conditionSource("installer_config", name), it.InstallerConfigYAML.Conditions[name].ShallowMerge})
+		}
+		if installerConfig := it.InstallerConfig(d.ID, archName); installerConfig != nil {
+			expl.InstallerConfig = explainConfig(installerConfig, layers)
+		}
+	}
+
+	// package sets
+	for key, pkgSets := range it.PackageSetsYAML {
+		var values []ExplainedValue
+		add := func(include, exclude []string, source string) {
+			for _, pkg := range include {
+				values = append(values, ExplainedValue{Key: "include", Value: pkg, Source: source})
+			}
+			for _, pkg := range exclude {
+				values = append(values, ExplainedValue{Key: "exclude", Value: pkg, Source: source})
+			}
+		}
+		for idx, pkgSet := range pkgSets {
+			section := fmt.Sprintf("package_sets.%s[%d]", key, idx)
+			add(pkgSet.Include, pkgSet.Exclude, section)
+			for _, name := range matchingConditions(pkgSet.Conditions, func(cond *pkgSetConditions) bool {
+				return cond.When.eval(ctx)
+			}) {
+				cond := pkgSet.Conditions[name]
+				add(cond.Append.Include, cond.Append.Exclude, conditionSource(section, name))
+			}
+		}
+		expl.PackageSets[key] = values
+	}
+
+	// partition table
+	if it.PartitionTables != nil {
+		pt, err := it.PartitionTable(d.ID, archName)
+		if err != nil {
+			return nil, err
+		}
+		expl.PartitionTable = pt
+		expl.PartitionTableSource = fmt.Sprintf("partition_table.%s", archName)
+		if it.PartitionTablesOverrides != nil {
+			for _, name := range matchingConditions(it.PartitionTablesOverrides.Conditions, func(cond *partitionTablesOverwriteCondition) bool {
+				return cond.When.eval(ctx)
+			}) {
+				expl.PartitionTableSource = conditionSource("partition_tables_override", name)
+			}
+		}
+	}
+
+	return expl, nil
+}
@@ -0,0 +1,97 @@
+package defs_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/distro/defs"
+)
+
+func TestExplain(t *testing.T) {
+	baseDir := makeFakeDistrosYAML(t, "", `
+image_config:
+  default:
+    timezone: "UTC"
+    locale: "C.UTF-8"
+image_types:
+  test_type:
+    platforms:
+      - arch: x86_64
+        bios_platform: i386-pc
+    image_config:
+      locale: "en_US.UTF-8"
+      conditions:
+        "hostname on x86_64":
+          when:
+            arch: x86_64
+          shallow_merge:
+            hostname: "x86-host"
+    package_sets:
+      os:
+        - include: [base]
+          exclude: [unwanted]
+          conditions:
+            "extra for test-distro":
+              when:
+                distro_name: test-distro
+              append:
+                include: [extra]
+    partition_table:
+      x86_64:
+        size: 1_000_000_000
+    partition_tables_override:
+      conditions:
+        "bigger disk":
+          when:
+            expr: "version >= 1"
+          override:
+            x86_64:
+              size: 2_000_000_000
+`)
+	restore := defs.MockDataFS(baseDir)
+	defer restore()
+
+	d, err := defs.NewDistroYAML("test-distro-1")
+	require.NoError(t, err)
+	expl, err := d.Explain("test_type", "x86_64")
+	require.NoError(t, err)
+
+	assert.Equal(t, "test-distro-1", expl.Distro)
+	assert.Equal(t, []defs.ExplainedValue{
+		{Key: "hostname", Value: "x86-host", Source: `image_config condition "hostname on x86_64"`},
+		{Key: "timezone", Value: "UTC", Source: "distro image_config"},
+		{Key: "locale", Value: "en_US.UTF-8", Source: "image_config"},
+	}, expl.ImageConfig)
+	assert.Equal(t, map[string][]defs.ExplainedValue{
+		"os": {
+			{Key: "include", Value: "base", Source: "package_sets.os[0]"},
+			{Key: "exclude", Value: "unwanted", Source: "package_sets.os[0]"},
+			{Key: "include", Value: "extra", Source: `package_sets.os[0] condition "extra for test-distro"`},
+		},
+	}, expl.PackageSets)
+	assert.Equal(t, uint64(2_000_000_000), expl.PartitionTable.Size)
+	assert.Equal(t, `partition_tables_override condition "bigger disk"`, expl.PartitionTableSource)
+	assert.Equal(t, "i386-pc", expl.Platform.BIOSPlatform)
+	assert.Equal(t, "platforms", expl.PlatformSource)
+	assert.Nil(t, expl.InstallerConfig)
+}
+
+func TestExplainErrors(t *testing.T) {
+	baseDir := makeFakeDistrosYAML(t, "", `
+image_types:
+  test_type:
+    platforms:
+      - arch: x86_64
+`)
+	restore := defs.MockDataFS(baseDir)
+	defer restore()
+
+	d, err := defs.NewDistroYAML("test-distro-1")
+	require.NoError(t, err)
+	_, err = d.Explain("missing", "x86_64")
+	assert.EqualError(t, err, `cannot find image type "missing" for distro "test-distro-1"`)
+	_, err = d.Explain("test_type", "aarch64")
+	assert.EqualError(t, err, `image type "test_type" is not available for arch "aarch64"`)
+}
@@ -6,6 +6,8 @@ import (
 
 type WhenCondition = whenCondition
 
+var MergeDistrosLayers = mergeDistrosLayers
+
 func MockDataFS(path string) (restore func()) {
 	saved := defaultDataFS
 	defaultDataFS = os.DirFS(path)
@@ -0,0 +1,553 @@
+package defs
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/hashicorp/go-version"
+
+	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/distro"
+	"github.com/osbuild/images/pkg/platform"
+)
+
+// whenContext contains everything a whenCondition can be evaluated
+// against. The arch, the image type and the boot mode are not known
+// in all contexts (e.g. for distro wide conditions), conditions that
+// use them there are rejected when the definitions are loaded (see
+// whenCondition.checkFields()).
+type whenContext struct {
+	id       distro.ID
+	arch     string
+	imgType  string
+	bootMode func() platform.BootMode
+}
+
+func (ctx *whenContext) bootModeString() string {
+	if ctx.bootMode == nil {
+		return ""
+	}
+	return ctx.bootMode().String()
+}
+
+// whenExpr is a boolean expression that can be used in the "expr"
+// field of a whenCondition, e.g.:
+//
+//	expr: 'distro_name in [rhel, centos] and arch in [x86_64, aarch64] and version in 9.4..10'
+//
+// The grammar is:
+//
+//	expr       := or
+//	or         := and ("or" and)*
+//	and        := not ("and" not)*
+//	not        := "not" not | "(" expr ")" | comparison
+//	comparison := field op value | field ["not"] "in" (list | range)
+//	field      := "distro_name" | "arch" | "version" | "image_type" | "boot_mode"
+//	op         := "==" | "!=" | "<" | "<=" | ">" | ">="
+//	list       := "[" value ("," value)* "]"
+//	range      := version ".." version
+//
+// A range is half-open, i.e. "9.4..10" matches 9.4 <= v < 10. Only
+// "version" supports the ordering operators and ranges.
+//
+// The expression is parsed and type-checked when it is unmarshaled
+// so that errors are detected when the definitions are loaded.
+type whenExpr struct {
+	src  string
+	root exprNode
+}
+
+func (e *whenExpr) UnmarshalYAML(unmarshal func(any) error) error {
+	var src string
+	if err := unmarshal(&src); err != nil {
+		return err
+	}
+	parsed, err := parseWhenExpr(src)
+	if err != nil {
+		return err
+	}
+	*e = *parsed
+	return nil
+}
+
+func (e *whenExpr) MarshalYAML() (any, error) {
+	return e.src, nil
+}
+
+func (e *whenExpr) String() string {
+	return e.src
+}
+
+func (e *whenExpr) eval(ctx *whenContext) bool {
+	return e.root.eval(ctx)
+}
+
+type exprNode interface {
+	eval(ctx *whenContext) bool
+	// walkFields calls fn for each field used in the expression
+	walkFields(fn func(field string))
+}
+
+type exprAnd struct {
+	lhs, rhs exprNode
+}
+
+func (n *exprAnd) eval(ctx *whenContext) bool {
+	return n.lhs.eval(ctx) && n.rhs.eval(ctx)
+}
+
+func (n *exprAnd) walkFields(fn func(field string)) {
+	n.lhs.walkFields(fn)
+	n.rhs.walkFields(fn)
+}
+
+type exprOr struct {
+	lhs, rhs exprNode
+}
+
+func (n *exprOr) eval(ctx *whenContext) bool {
+	return n.lhs.eval(ctx) || n.rhs.eval(ctx)
+}
+
+func (n *exprOr) walkFields(fn func(field string)) {
+	n.lhs.walkFields(fn)
+	n.rhs.walkFields(fn)
+}
+
+type exprNot struct {
+	expr exprNode
+}
+
+func (n *exprNot) eval(ctx *whenContext) bool {
+	return !n.expr.eval(ctx)
+}
+
+func (n *exprNot) walkFields(fn func(field string)) {
+	n.expr.walkFields(fn)
+}
+
+type exprFieldKind int
+
+const (
+	exprFieldString exprFieldKind = iota
+	exprFieldVersion
+)
+
+type exprField struct {
+	kind exprFieldKind
+	get  func(ctx *whenContext) string
+	// normalize checks and normalizes a literal value for the
+	// field, if nil all values are accepted
+	normalize func(string) (string, error)
+}
+
+func normalizeArch(s string) (string, error) {
+	a, err := arch.FromString(s)
+	if err != nil {
+		return "", err
+	}
+	return a.String(), nil
+}
+
+func normalizeBootMode(s string) (string, error) {
+	for _, bm := range []platform.BootMode{platform.BOOT_NONE, platform.BOOT_LEGACY, platform.BOOT_UEFI, platform.BOOT_HYBRID} {
+		if s == bm.String() {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported boot mode %q", s)
+}
+
+func normalizeVersion(s string) (string, error) {
+	if _, err := version.NewVersion(s); err != nil {
+		return "", fmt.Errorf("invalid version %q: %w", s, err)
+	}
+	return s, nil
+}
+
+var exprFields = map[string]exprField{
+	"distro_name": {
+		kind: exprFieldString,
+		get:  func(ctx *whenContext) string { return ctx.id.Name },
+	},
+	"arch": {
+		kind:      exprFieldString,
+		get:       func(ctx *whenContext) string { return ctx.arch },
+		normalize: normalizeArch,
+	},
+	"version": {
+		kind:      exprFieldVersion,
+		get:       func(ctx *whenContext) string { return ctx.id.VersionString() },
+		normalize: normalizeVersion,
+	},
+	"image_type": {
+		kind: exprFieldString,
+		get:  func(ctx *whenContext) string { return ctx.imgType },
+	},
+	"boot_mode": {
+		kind:      exprFieldString,
+		get:       func(ctx *whenContext) string { return ctx.bootModeString() },
+		normalize: normalizeBootMode,
+	},
+}
+
+type exprCompare struct {
+	field  string
+	op     string
+	values []string
+}
+
+func (n *exprCompare) walkFields(fn func(field string)) {
+	fn(n.field)
+}
+
+func (n *exprCompare) eval(ctx *whenContext) bool {
+	field := exprFields[n.field]
+	val := field.get(ctx)
+	if val == "" {
+		return false
+	}
+
+	if field.kind == exprFieldVersion {
+		return n.evalVersion(ctx, val)
+	}
+
+	switch n.op {
+	case "==":
+		return val == n.values[0]
+	case "!=":
+		return val != n.values[0]
+	case "in":
+		return slices.Contains(n.values, val)
+	case "not in":
+		return !slices.Contains(n.values, val)
+	}
+	panic(fmt.Sprintf("unexpected operator %q", n.op))
+}
+
+// evalVersion compares versions numerically, i.e. "10.0" is equal to
+// "10" and "9.10" is bigger than "9.1". Versions without a minor are
+// ordered after all minors of their major (see versionStringForVerCmp())
+// but are still equal to the plain major.
+func (n *exprCompare) evalVersion(ctx *whenContext, val string) bool {
+	cur, err := version.NewVersion(val)
+	if err != nil {
+		return false
+	}
+	ord, err := version.NewVersion(versionStringForVerCmp(ctx.id))
+	if err != nil {
+		return false
+	}
+	// the values are checked by normalizeVersion() when parsing
+	equal := func(s string) bool {
+		return cur.Equal(version.Must(version.NewVersion(s)))
+	}
+	less := func(s string) bool {
+		return ord.LessThan(version.Must(version.NewVersion(s)))
+	}
+
+	switch n.op {
+	case "==":
+		return equal(n.values[0])
+	case "!=":
+		return !equal(n.values[0])
+	case "in":
+		return slices.ContainsFunc(n.values, equal)
+	case "not in":
+		return !slices.ContainsFunc(n.values, equal)
+	case "<":
+		return less(n.values[0])
+	case "<=":
+		return less(n.values[0]) || equal(n.values[0])
+	case ">":
+		return !(less(n.values[0]) || equal(n.values[0]))
+	case ">=":
+		return !less(n.values[0])
+	case "in range":
+		return !less(n.values[0]) && less(n.values[1])
+	case "not in range":
+		return less(n.values[0]) || !less(n.values[1])
+	}
+	panic(fmt.Sprintf("unexpected operator %q", n.op))
+}
+
+type exprToken struct {
+	// kind is either "word" (for identifiers, keywords and
+	// unquoted literals), "string" (for quoted literals) or the
+	// operator/punctuation itself
+	kind string
+	val  string
+	pos  int
+}
+
+func (t *exprToken) String() string {
+	if t.val != "" {
+		return t.val
+	}
+	return t.kind
+}
+
+func lexWhenExpr(src string) ([]exprToken, error) {
+	var tokens []exprToken
+	isWordChar := func(r byte) bool {
+		return r == '_' || r == '-' || r == '.' || r == '*' || unicode.IsLetter(rune(r)) || unicode.IsDigit(rune(r))
+	}
+
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case strings.HasPrefix(src[i:], ".."):
+			tokens = append(tokens, exprToken{kind: "..", pos: i})
+			i += 2
+		case strings.ContainsRune("()[],", rune(c)):
+			tokens = append(tokens, exprToken{kind: string(c), pos: i})
+			i++
+		case strings.HasPrefix(src[i:], "==") || strings.HasPrefix(src[i:], "!=") || strings.HasPrefix(src[i:], "<=") || strings.HasPrefix(src[i:], ">="):
+			tokens = append(tokens, exprToken{kind: src[i : i+2], pos: i})
+			i += 2
+		case c == '<' || c == '>':
+			tokens = append(tokens, exprToken{kind: string(c), pos: i})
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(src[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at position %v", i)
+			}
+			tokens = append(tokens, exprToken{kind: "string", val: src[i+1 : i+1+end], pos: i})
+			i += end + 2
+		case isWordChar(c):
+			start := i
+			// a ".." ends a word so that "9.4..10" is a range
+			for i < len(src) && isWordChar(src[i]) && !strings.HasPrefix(src[i:], "..") {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: "word", val: src[start:i], pos: start})
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %v", c, i)
+		}
+	}
+	return tokens, nil
+}
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+func (p *exprParser) peek() *exprToken {
+	if p.pos >= len(p.tokens) {
+		return nil
+	}
+	return &p.tokens[p.pos]
+}
+
+func (p *exprParser) peekKeyword(kw string) bool {
+	tok := p.peek()
+	return tok != nil && tok.kind == "word" && tok.val == kw
+}
+
+func (p *exprParser) next() (*exprToken, error) {
+	tok := p.peek()
+	if tok == nil {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	p.pos++
+	return tok, nil
+}
+
+func (p *exprParser) expect(kind string) (*exprToken, error) {
+	tok, err := p.next()
+	if err != nil {
+		return nil, fmt.Errorf("expected %q: %w", kind, err)
+	}
+	if tok.kind != kind {
+		return nil, fmt.Errorf("expected %q at position %v but got %q", kind, tok.pos, tok.String())
+	}
+	return tok, nil
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	lhs, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("or") {
+		p.pos++
+		rhs, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &exprOr{lhs, rhs}
+	}
+	return lhs, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	lhs, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("and") {
+		p.pos++
+		rhs, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &exprAnd{lhs, rhs}
+	}
+	return lhs, nil
+}
+
+func (p *exprParser) parseNot() (exprNode, error) {
+	if p.peekKeyword("not") {
+		p.pos++
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &exprNot{expr}, nil
+	}
+	if tok := p.peek(); tok != nil && tok.kind == "(" {
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseValue(field exprField) (string, error) {
+	tok, err := p.next()
+	if err != nil {
+		return "", fmt.Errorf("expected value: %w", err)
+	}
+	if tok.kind != "word" && tok.kind != "string" {
+		return "", fmt.Errorf("expected value at position %v but got %q", tok.pos, tok.String())
+	}
+	if field.normalize == nil {
+		return tok.val, nil
+	}
+	return field.normalize(tok.val)
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	tok, err := p.expect("word")
+	if err != nil {
+		return nil, err
+	}
+	fieldName := tok.val
+	field, ok := exprFields[fieldName]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q at position %v", fieldName, tok.pos)
+	}
+
+	opTok, err := p.next()
+	if err != nil {
+		return nil, fmt.Errorf("expected operator after %q: %w", fieldName, err)
+	}
+	op := opTok.kind
+	if op == "word" {
+		op = opTok.val
+		if op == "not" {
+			if !p.peekKeyword("in") {
+				return nil, fmt.Errorf(`expected "in" after "not" at position %v`, opTok.pos)
+			}
+			p.pos++
+			op = "not in"
+		}
+	}
+
+	switch op {
+	case "==", "!=":
+		val, err := p.parseValue(field)
+		if err != nil {
+			return nil, err
+		}
+		return &exprCompare{field: fieldName, op: op, values: []string{val}}, nil
+	case "<", "<=", ">", ">=":
+		if field.kind != exprFieldVersion {
+			return nil, fmt.Errorf("operator %q at position %v is only supported for versions, not for %q", op, opTok.pos, fieldName)
+		}
+		val, err := p.parseValue(field)
+		if err != nil {
+			return nil, err
+		}
+		return &exprCompare{field: fieldName, op: op, values: []string{val}}, nil
+	case "in", "not in":
+		if tok := p.peek(); tok != nil && tok.kind == "[" {
+			values, err := p.parseList(field)
+			if err != nil {
+				return nil, err
+			}
+			return &exprCompare{field: fieldName, op: op, values: values}, nil
+		}
+		if field.kind != exprFieldVersion {
+			return nil, fmt.Errorf("ranges at position %v are only supported for versions, not for %q", opTok.pos, fieldName)
+		}
+		lo, err := p.parseValue(field)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(".."); err != nil {
+			return nil, err
+		}
+		hi, err := p.parseValue(field)
+		if err != nil {
+			return nil, err
+		}
+		return &exprCompare{field: fieldName, op: op + " range", values: []string{lo, hi}}, nil
+	default:
+		return nil, fmt.Errorf("unknown operator %q at position %v", op, opTok.pos)
+	}
+}
+
+func (p *exprParser) parseList(field exprField) ([]string, error) {
+	if _, err := p.expect("["); err != nil {
+		return nil, err
+	}
+	var values []string
+	for {
+		val, err := p.parseValue(field)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, val)
+		tok, err := p.next()
+		if err != nil {
+			return nil, fmt.Errorf(`expected "," or "]": %w`, err)
+		}
+		switch tok.kind {
+		case ",":
+			continue
+		case "]":
+			return values, nil
+		default:
+			return nil, fmt.Errorf(`expected "," or "]" at position %v but got %q`, tok.pos, tok.String())
+		}
+	}
+}
+
+func parseWhenExpr(src string) (*whenExpr, error) {
+	tokens, err := lexWhenExpr(src)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse expression %q: %w", src, err)
+	}
+	p := &exprParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse expression %q: %w", src, err)
+	}
+	if tok := p.peek(); tok != nil {
+		return nil, fmt.Errorf("cannot parse expression %q: unexpected %q at position %v", src, tok.String(), tok.pos)
+	}
+	return &whenExpr{src: src, root: root}, nil
+}
@@ -0,0 +1,282 @@
+package defs_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/osbuild/images/pkg/distro"
+	"github.com/osbuild/images/pkg/distro/defs"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+func makeWhenCondition(t *testing.T, expr string) *defs.WhenCondition {
+	t.Helper()
+
+	var wc defs.WhenCondition
+	err := yaml.Unmarshal([]byte(fmt.Sprintf("expr: %q", expr)), &wc)
+	require.NoError(t, err)
+	return &wc
+}
+
+func TestWhenConditionExprEval(t *testing.T) {
+	rhel94 := distro.ID{Name: "rhel", MajorVersion: 9, MinorVersion: 4}
+	rhel93 := distro.ID{Name: "rhel", MajorVersion: 9, MinorVersion: 3}
+	rhel100 := distro.ID{Name: "rhel", MajorVersion: 10, MinorVersion: 0}
+	rhel910 := distro.ID{Name: "rhel", MajorVersion: 9, MinorVersion: 10}
+	centos9 := distro.ID{Name: "centos", MajorVersion: 9, MinorVersion: -1}
+	fedora42 := distro.ID{Name: "fedora", MajorVersion: 42, MinorVersion: -1}
+
+	for _, tc := range []struct {
+		expr     string
+		id       distro.ID
+		arch     string
+		expected bool
+	}{
+		{`distro_name == rhel`, rhel94, "x86_64", true},
+		{`distro_name == "rhel"`, fedora42, "x86_64", false},
+		{`distro_name != rhel`, fedora42, "x86_64", true},
+		{`distro_name in [rhel, centos]`, centos9, "x86_64", true},
+		{`distro_name in [rhel, centos]`, fedora42, "x86_64", false},
+		{`distro_name not in [rhel, centos]`, fedora42, "x86_64", true},
+		{`not distro_name == fedora`, fedora42, "x86_64", false},
+		{`arch in [x86_64, aarch64]`, rhel94, "aarch64", true},
+		{`arch == amd64`, rhel94, "x86_64", true},
+		{`arch in [x86_64, aarch64]`, rhel94, "s390x", false},
+		{`version in 9.4..10`, rhel94, "x86_64", true},
+		{`version in 9.4..10`, rhel93, "x86_64", false},
+		{`version in 9.4..10`, rhel100, "x86_64", false},
+		// centos without minor is always newer than any rhel minor
+		{`version in 9.4..10`, centos9, "x86_64", true},
+		{`version not in 9.4..10`, rhel93, "x86_64", true},
+		{`version < 9.4`, rhel93, "x86_64", true},
+		{`version <= 9.4`, rhel94, "x86_64", true},
+		{`version > 9.4`, rhel94, "x86_64", false},
+		{`version >= 9.4`, rhel94, "x86_64", true},
+		{`version == 9.4`, rhel94, "x86_64", true},
+		{`version in [9.3, 9.4]`, rhel94, "x86_64", true},
+		// versions compare numerically, "10.0" is "10"
+		{`version > 10`, rhel100, "x86_64", false},
+		{`version <= 10`, rhel100, "x86_64", true},
+		{`version >= 10`, rhel100, "x86_64", true},
+		{`version == 10`, rhel100, "x86_64", true},
+		{`version != 10`, rhel100, "x86_64", false},
+		{`version in [9, 10]`, rhel100, "x86_64", true},
+		{`version not in [9, 10]`, rhel100, "x86_64", false},
+		// and "9.10" is not "9.1"
+		{`version == 9.1`, rhel910, "x86_64", false},
+		{`version in [9.1]`, rhel910, "x86_64", false},
+		{`version > 9.1`, rhel910, "x86_64", true},
+		{`version <= 9.1`, rhel910, "x86_64", false},
+		{`version in 9.2..10`, rhel910, "x86_64", true},
+		{`version == 9.10`, rhel910, "x86_64", true},
+		// without a minor the version equals its major
+		{`version == 42`, fedora42, "x86_64", true},
+		{`version <= 42`, fedora42, "x86_64", true},
+		{`version > 42`, fedora42, "x86_64", false},
+		{`(distro_name == rhel or distro_name == centos) and (arch == x86_64 or arch == aarch64) and version in 9.4..10`, rhel94, "aarch64", true},
+		{`(distro_name == rhel or distro_name == centos) and (arch == x86_64 or arch == aarch64) and version in 9.4..10`, rhel94, "ppc64le", false},
+		{`distro_name == fedora or distro_name == rhel and arch == s390x`, fedora42, "x86_64", true},
+		{`not (distro_name == fedora or distro_name == rhel)`, centos9, "x86_64", true},
+		// image type is unknown for plain Eval()
+		{`image_type == qcow2`, rhel94, "x86_64", false},
+	} {
+		t.Run(tc.expr, func(t *testing.T) {
+			wc := makeWhenCondition(t, tc.expr)
+			assert.Equal(t, tc.expected, wc.Eval(tc.id, tc.arch))
+		})
+	}
+}
+
+func TestWhenConditionExprAndedWithFields(t *testing.T) {
+	var wc defs.WhenCondition
+	err := yaml.Unmarshal([]byte(`
+distro_name: rhel
+expr: "arch == x86_64"
+`), &wc)
+	require.NoError(t, err)
+
+	assert.True(t, wc.Eval(distro.ID{Name: "rhel", MajorVersion: 9, MinorVersion: 0}, "x86_64"))
+	assert.False(t, wc.Eval(distro.ID{Name: "centos", MajorVersion: 9, MinorVersion: -1}, "x86_64"))
+	assert.False(t, wc.Eval(distro.ID{Name: "rhel", MajorVersion: 9, MinorVersion: 0}, "aarch64"))
+}
+
+func TestWhenConditionExprErrors(t *testing.T) {
+	for _, tc := range []struct {
+		expr   string
+		errStr string
+	}{
+		{`distro == rhel`, `unknown field "distro" at position 0`},
+		{`arch == x86-64`, `unsupported architecture "x86-64"`},
+		{`boot_mode == efi`, `unsupported boot mode "efi"`},
+		{`version >= nine`, `invalid version "nine"`},
+		{`distro_name < rhel`, `operator "<" at position 12 is only supported for versions, not for "distro_name"`},
+		{`arch in x86_64..aarch64`, `ranges at position 5 are only supported for versions, not for "arch"`},
+		{`distro_name == rhel and`, `expected "word": unexpected end of expression`},
+		{`distro_name == rhel or (arch == x86_64`, `expected ")": unexpected end of expression`},
+		{`distro_name == rhel arch == x86_64`, `unexpected "arch" at position 20`},
+		{`distro_name in [rhel centos]`, `expected "," or "]" at position 21 but got "centos"`},
+		{`distro_name not == rhel`, `expected "in" after "not" at position 12`},
+		{`distro_name == "rhel`, `unterminated string at position 15`},
+		{`distro_name ~ rhel`, `unexpected character '~' at position 12`},
+	} {
+		t.Run(tc.expr, func(t *testing.T) {
+			var wc defs.WhenCondition
+			err := yaml.Unmarshal([]byte(fmt.Sprintf("expr: %q", tc.expr)), &wc)
+			assert.ErrorContains(t, err, fmt.Sprintf("cannot parse expression %q: ", tc.expr))
+			assert.ErrorContains(t, err, tc.errStr)
+		})
+	}
+}
+
+func TestWhenConditionExprErrorsOnLoad(t *testing.T) {
+	baseDir := makeFakeDistrosYAML(t, "", `
+image_types:
+  test_type:
+    package_sets:
+      os:
+        - include: [base]
+          conditions:
+            "typo":
+              when:
+                expr: "distro_nam == rhel"
+              append:
+                include: [extra]
+`)
+	restore := defs.MockDataFS(baseDir)
+	defer restore()
+
+	_, err := defs.NewDistroYAML("test-distro-1")
+	assert.ErrorContains(t, err, `cannot parse expression "distro_nam == rhel": unknown field "distro_nam" at position 0`)
+}
+
+func TestWhenConditionExprImageTypeAndBootMode(t *testing.T) {
+	it := makeTestImageType(t, `
+image_types:
+  test_type:
+    platforms:
+      - arch: x86_64
+        bios_platform: i386-pc
+        uefi_vendor: "{{.DistroVendor}}"
+      - arch: aarch64
+        uefi_vendor: "{{.DistroVendor}}"
+    package_sets:
+      os:
+        - include: [base]
+          conditions:
+            "image type":
+              when:
+                expr: "image_type in [test_type, other_type]"
+              append:
+                include: [for-image-type]
+            "hybrid boot":
+              when:
+                expr: "boot_mode == hybrid"
+              append:
+                include: [for-hybrid]
+            "uefi boot":
+              when:
+                expr: "boot_mode == uefi"
+              append:
+                include: [for-uefi]
+`)
+	id := distro.ID{Name: "test-distro", MajorVersion: 1, MinorVersion: -1}
+	assert.Equal(t, map[string]rpmmd.PackageSet{
+		"os": {Include: []string{"base", "for-hybrid", "for-image-type"}},
+	}, it.PackageSets(id, "x86_64"))
+	assert.Equal(t, map[string]rpmmd.PackageSet{
+		"os": {Include: []string{"base", "for-image-type", "for-uefi"}},
+	}, it.PackageSets(id, "aarch64"))
+}
+
+func TestWhenConditionUnknownFieldsErrorsOnLoad(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		distrosYAML   string
+		imgTypesYAML  string
+		expectedError string
+	}{
+		{
+			name: "distro image config with arch",
+			imgTypesYAML: `
+image_config:
+  conditions:
+    "x86 only":
+      when:
+        arch: x86_64
+      shallow_merge:
+        hostname: "x86"
+image_types:
+  test_type:
+    filename: "disk.img"
+`,
+			expectedError: `image config condition "x86 only": condition cannot use "arch", it is not known in this context`,
+		},
+		{
+			name: "distro image config with image type expr",
+			imgTypesYAML: `
+image_config:
+  conditions:
+    "qcow2 only":
+      when:
+        expr: "version >= 1 and image_type == test_type"
+      shallow_merge:
+        hostname: "qcow2"
+image_types:
+  test_type:
+    filename: "disk.img"
+`,
+			expectedError: `image config condition "qcow2 only": condition cannot use "image_type", it is not known in this context`,
+		},
+		{
+			name: "platforms override with boot mode",
+			imgTypesYAML: `
+image_types:
+  test_type:
+    filename: "disk.img"
+    platforms:
+      - arch: x86_64
+    platforms_override:
+      conditions:
+        "uefi only":
+          when:
+            expr: "not (boot_mode == legacy)"
+          override:
+            - arch: x86_64
+              uefi_vendor: "test-vendor"
+`,
+			expectedError: `image type "test_type": platforms override condition "uefi only": condition cannot use "boot_mode", it is not known in this context`,
+		},
+		{
+			name: "distro condition with boot mode",
+			distrosYAML: `
+distros:
+  - name: test-distro-1
+    defs_path: test-distro/
+    conditions:
+      "no legacy":
+        when:
+          expr: "boot_mode == legacy"
+        ignore_image_types: [test_type]
+`,
+			imgTypesYAML: `
+image_types:
+  test_type:
+    filename: "disk.img"
+`,
+			expectedError: `distro condition "no legacy" of "test-distro-1": condition cannot use "boot_mode", it is not known in this context`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			baseDir := makeFakeDistrosYAML(t, tc.distrosYAML, tc.imgTypesYAML)
+			restore := defs.MockDataFS(baseDir)
+			defer restore()
+
+			_, err := defs.NewDistroYAML("test-distro-1")
+			assert.EqualError(t, err, tc.expectedError)
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package defs
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// imageTypeExtends describes the image type that an image type
+// inherits from. In YAML it can be given either as a plain string
+// (e.g. "extends: qcow2") or as a map when the image type lives
+// in the definitions of another distro, e.g.
+//
+//	extends:
+//	  name: qcow2
+//	  defs_path: rhel-10
+type imageTypeExtends struct {
+	Name     string `yaml:"name"`
+	DefsPath string `yaml:"defs_path"`
+}
+
+func (e *imageTypeExtends) UnmarshalYAML(unmarshal func(any) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		*e = imageTypeExtends{Name: name}
+		return nil
+	}
+	type alias imageTypeExtends
+	var a alias
+	if err := unmarshal(&a); err != nil {
+		return err
+	}
+	*e = imageTypeExtends(a)
+	return nil
+}
+
+func parseExtends(v any) (*imageTypeExtends, error) {
+	switch ext := v.(type) {
+	case string:
+		return &imageTypeExtends{Name: ext}, nil
+	case map[string]any:
+		var res imageTypeExtends
+		for k, v := range ext {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid type %T for extends key %q", v, k)
+			}
+			switch k {
+			case "name":
+				res.Name = s
+			case "defs_path":
+				res.DefsPath = s
+			default:
+				return nil, fmt.Errorf("unknown extends key %q", k)
+			}
+		}
+		if res.Name == "" {
+			return nil, fmt.Errorf("extends requires a name")
+		}
+		return &res, nil
+	default:
+		return nil, fmt.Errorf("invalid type %T for extends", v)
+	}
+}
+
+// extendsMergeDepth defines how the keys of an image type are
+// merged with the image type that it extends. Maps are merged key
+// by key and lists are appended until the depth is reached, a
+// negative depth means there is no limit. Keys that are not listed
+// here are replaced as a whole.
+//
+// Partition tables are only merged per architecture because
+// appending partitions to an existing table will almost never
+// give a usable result.
+var extendsMergeDepth = map[string]int{
+	"package_sets":     -1,
+	"image_config":     -1,
+	"installer_config": -1,
+	"platforms":        -1,
+	"partition_table":  1,
+}
+
+// extendsNotInherited contains the keys that an image type will
+// never inherit from its parent.
+var extendsNotInherited = []string{
+	"extends",
+	"name_aliases",
+	"deprecated",
+}
+
+// replaceList returns the list from a "replace:" wrapper, i.e.
+//
+//	kernel_options:
+//	  replace: ["console=ttyS0"]
+//
+// which means the list of the parent is replaced instead of
+// appended to.
+func replaceList(v any) (any, bool) {
+	m, ok := v.(map[string]any)
+	if !ok || len(m) != 1 {
+		return nil, false
+	}
+	l, ok := m["replace"]
+	if !ok {
+		return nil, false
+	}
+	if _, ok := l.([]any); !ok {
+		return nil, false
+	}
+	return l, true
+}
+
+// mergeExtends merges the child value on top of the parent value
+// (see extendsMergeDepth for the rules). The parent is never
+// modified.
+func mergeExtends(parent, child any, depth int) any {
+	if l, ok := replaceList(child); ok {
+		return l
+	}
+	if depth == 0 {
+		return child
+	}
+
+	switch c := child.(type) {
+	case map[string]any:
+		p, _ := parent.(map[string]any)
+		res := make(map[string]any, len(p)+len(c))
+		for k, v := range p {
+			res[k] = v
+		}
+		for k, v := range c {
+			res[k] = mergeExtends(p[k], v, depth-1)
+		}
+		return res
+	case []any:
+		if p, ok := parent.([]any); ok {
+			return append(slices.Clone(p), c...)
+		}
+	}
+	return child
+}
+
+// extendsResolver resolves the "extends" of the image types, it
+// keeps a cache of the raw image types of all the defs paths that
+// it needed to load.
+type extendsResolver struct {
+	searchPaths []fs.FS
+
+	rawImageTypes map[string]map[string]any
+	resolved      map[string]map[string]any
+	chains        map[string][]string
+}
+
+func newExtendsResolver(searchPaths []fs.FS, defsPath string, rawImageTypes map[string]any) *extendsResolver {
+	return &extendsResolver{
+		searchPaths: searchPaths,
+		rawImageTypes: map[string]map[string]any{
+			path.Clean(defsPath): rawImageTypes,
+		},
+		resolved: make(map[string]map[string]any),
+		chains:   make(map[string][]string),
+	}
+}
+
+func extendsKey(defsPath, name string) string {
+	return fmt.Sprintf("%s:%s", defsPath, name)
+}
+
+func (r *extendsResolver) imageTypesFor(defsPath string) (map[string]any, error) {
+	if imgTypes, ok := r.rawImageTypes[defsPath]; ok {
+		return imgTypes, nil
+	}
+	raw, err := loadImageTypesRaw(r.searchPaths, defsPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	imgTypes, err := rawImageTypesMap(raw)
+	if err != nil {
+		return nil, err
+	}
+	r.rawImageTypes[defsPath] = imgTypes
+	return imgTypes, nil
+}
+
+// resolve returns the fully resolved raw image type with the given
+// name from the given defsPath.
+func (r *extendsResolver) resolve(defsPath, name string, stack []string) (map[string]any, error) {
+	key := extendsKey(defsPath, name)
+	if resolved, ok := r.resolved[key]; ok {
+		return resolved, nil
+	}
+	if slices.Contains(stack, key) {
+		return nil, fmt.Errorf("image type inheritance cycle detected: %s", strings.Join(append(stack, key), " -> "))
+	}
+	stack = append(stack, key)
+
+	imgTypes, err := r.imageTypesFor(defsPath)
+	if err != nil {
+		return nil, err
+	}
+	imgType, ok := imgTypes[name].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("cannot find image type %q in %q (extended via %s)", name, defsPath, strings.Join(stack, " -> "))
+	}
+	extendsRaw, ok := imgType["extends"]
+	if !ok {
+		r.resolved[key] = imgType
+		return imgType, nil
+	}
+	ext, err := parseExtends(extendsRaw)
+	if err != nil {
+		return nil, fmt.Errorf("image type %q: %w", name, err)
+	}
+	parentDefsPath := defsPath
+	if ext.DefsPath != "" {
+		parentDefsPath = path.Clean(ext.DefsPath)
+	}
+	parent, err := r.resolve(parentDefsPath, ext.Name, stack)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := make(map[string]any, len(parent)+len(imgType))
+	for k, v := range parent {
+		if slices.Contains(extendsNotInherited, k) {
+			continue
+		}
+		merged[k] = v
+	}
+	for k, v := range imgType {
+		if k == "extends" {
+			continue
+		}
+		depth, ok := extendsMergeDepth[k]
+		if !ok {
+			depth = 0
+		}
+		merged[k] = mergeExtends(parent[k], v, depth)
+	}
+	r.resolved[key] = merged
+	r.chains[key] = append([]string{extendsKey(parentDefsPath, ext.Name)}, r.chains[extendsKey(parentDefsPath, ext.Name)]...)
+	return merged, nil
+}
+
+// rawImageTypesMap returns the "image_types" of the given raw
+// imagetypes.yaml
+func rawImageTypesMap(raw map[string]any) (map[string]any, error) {
+	switch imgTypes := raw["image_types"].(type) {
+	case nil:
+		return map[string]any{}, nil
+	case map[string]any:
+		return imgTypes, nil
+	default:
+		return nil, fmt.Errorf("invalid type %T for image_types", imgTypes)
+	}
+}
+
+// resolveExtends replaces all image types in the given raw
+// imagetypes.yaml that use "extends" with their fully resolved
+// definition. It returns the chain of image types that each image
+// type extends (closest parent first).
+func resolveExtends(searchPaths []fs.FS, defsPath string, raw map[string]any) (map[string][]string, error) {
+	imgTypes, err := rawImageTypesMap(raw)
+	if err != nil {
+		return nil, err
+	}
+	defsPath = path.Clean(defsPath)
+	resolver := newExtendsResolver(searchPaths, defsPath, imgTypes)
+
+	names := make([]string, 0, len(imgTypes))
+	for name := range imgTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	resolvedImgTypes := make(map[string]any, len(imgTypes))
+	chains := make(map[string][]string)
+	for _, name := range names {
+		resolved, err := resolver.resolve(defsPath, name, nil)
+		if err != nil {
+			return nil, err
+		}
+		resolvedImgTypes[name] = resolved
+		if chain := resolver.chains[extendsKey(defsPath, name)]; len(chain) > 0 {
+			chains[name] = chain
+		}
+	}
+	raw["image_types"] = resolvedImgTypes
+	return chains, nil
+}
+
+// ResolvedYAML returns the fully resolved YAML definition of the
+// image type, i.e. with all "extends" applied. It returns nil if
+// the image type does not use "extends".
+func (it *ImageTypeYAML) ResolvedYAML() ([]byte, error) {
+	if it.resolvedRaw == nil {
+		return nil, nil
+	}
+	return yaml.Marshal(it.resolvedRaw)
+}
+
+// ExtendsChain returns the list of image types (in the form
+// "<defs_path>:<name>") that the image type inherits from, closest
+// parent first. It is empty if the image type does not use
+// "extends".
+func (it *ImageTypeYAML) ExtendsChain() []string {
+	return it.extendsChain
+}
@@ -0,0 +1,160 @@
+package defs_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/distro"
+	"github.com/osbuild/images/pkg/distro/defs"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+var extendsImgTypesYAML = `
+image_types:
+  base_type:
+    filename: base.img
+    mime_type: application/x-base
+    image_func: disk
+    name_aliases: ["base-alias"]
+    image_config:
+      locale: "C.UTF-8"
+      kernel_options: ["console=tty0"]
+    package_sets:
+      os:
+        - include: [base-pkg]
+    platforms:
+      - arch: x86_64
+      - arch: aarch64
+  test_type:
+    extends: base_type
+    filename: test.img
+    image_config:
+      hostname: "test-host"
+      kernel_options: ["console=ttyS0"]
+    package_sets:
+      os:
+        - include: [test-pkg]
+    platforms:
+      replace:
+        - arch: x86_64
+`
+
+func TestExtendsMergesImageTypes(t *testing.T) {
+	it := makeTestImageType(t, extendsImgTypesYAML)
+
+	assert.Equal(t, "test.img", it.Filename)
+	assert.Equal(t, "application/x-base", it.MimeType)
+	assert.Equal(t, "disk", it.Image)
+	// aliases are never inherited
+	assert.Len(t, it.NameAliases, 0)
+	assert.Nil(t, it.Extends)
+	assert.Equal(t, []string{"test-distro-1:base_type"}, it.ExtendsChain())
+
+	id := distro.ID{Name: "test-distro", MajorVersion: 1, MinorVersion: -1}
+	assert.Equal(t, &distro.ImageConfig{
+		Locale:        common.ToPtr("C.UTF-8"),
+		Hostname:      common.ToPtr("test-host"),
+		KernelOptions: []string{"console=tty0", "console=ttyS0"},
+	}, it.ImageConfig(id, "x86_64"))
+	assert.Equal(t, map[string]rpmmd.PackageSet{
+		"os": {Include: []string{"base-pkg", "test-pkg"}},
+	}, it.PackageSets(id, "x86_64"))
+	require.Len(t, it.InternalPlatforms, 1)
+	assert.Equal(t, "x86_64", it.InternalPlatforms[0].Arch.String())
+
+	resolved, err := it.ResolvedYAML()
+	require.NoError(t, err)
+	assert.Contains(t, string(resolved), "filename: test.img")
+	assert.Contains(t, string(resolved), "mime_type: application/x-base")
+	assert.NotContains(t, string(resolved), "extends")
+}
+
+func TestExtendsOtherDefsPath(t *testing.T) {
+	baseDir := makeFakeDistrosYAML(t, "", `
+image_types:
+  test_type:
+    extends:
+      name: other_type
+      defs_path: other-distro
+    filename: test.img
+`)
+	overlay := makeLayer(t, map[string]string{
+		"other-distro/imagetypes.yaml": `
+image_types:
+  other_type:
+    filename: other.img
+    mime_type: application/x-other
+    image_func: disk
+    platforms:
+      - arch: x86_64
+`,
+	})
+
+	d, err := defs.NewDistroYAML("test-distro-1", os.DirFS(baseDir), overlay)
+	require.NoError(t, err)
+	it := d.ImageTypes()["test_type"]
+	assert.Equal(t, "test.img", it.Filename)
+	assert.Equal(t, "application/x-other", it.MimeType)
+	assert.Equal(t, []string{"other-distro:other_type"}, it.ExtendsChain())
+}
+
+func TestExtendsChain(t *testing.T) {
+	it := makeTestImageType(t, `
+image_types:
+  a:
+    filename: a.img
+    image_func: disk
+  b:
+    extends: a
+    mime_type: application/x-b
+  test_type:
+    extends: b
+`)
+	assert.Equal(t, "a.img", it.Filename)
+	assert.Equal(t, "application/x-b", it.MimeType)
+	assert.Equal(t, []string{"test-distro-1:b", "test-distro-1:a"}, it.ExtendsChain())
+}
+
+func TestExtendsCycleErrors(t *testing.T) {
+	baseDir := makeFakeDistrosYAML(t, "", `
+image_types:
+  a:
+    extends: test_type
+  test_type:
+    extends: a
+`)
+	restore := defs.MockDataFS(baseDir)
+	defer restore()
+
+	_, err := defs.NewDistroYAML("test-distro-1")
+	assert.ErrorContains(t, err, "image type inheritance cycle detected: test-distro-1:a -> test-distro-1:test_type -> test-distro-1:a")
+}
+
+func TestExtendsUnknownParentErrors(t *testing.T) {
+	baseDir := makeFakeDistrosYAML(t, "", `
+image_types:
+  test_type:
+    extends: missing
+`)
+	restore := defs.MockDataFS(baseDir)
+	defer restore()
+
+	_, err := defs.NewDistroYAML("test-distro-1")
+	assert.ErrorContains(t, err, `cannot find image type "missing" in "test-distro-1"`)
+}
+
+func TestExtendsResolvedYAMLNilWithoutExtends(t *testing.T) {
+	it := makeTestImageType(t, `
+image_types:
+  test_type:
+    filename: test.img
+`)
+	resolved, err := it.ResolvedYAML()
+	assert.NoError(t, err)
+	assert.Nil(t, resolved)
+	assert.Nil(t, it.ExtendsChain())
+}
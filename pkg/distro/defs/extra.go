@@ -0,0 +1,162 @@
+package defs
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ExtraImageTypes are user defined image types that are loaded at
+// runtime (e.g. from a YAML file) and added to the builtin image
+// types of the distros they apply to. They use the same schema as
+// the "image_types" of an imagetypes.yaml and can "extends" the
+// builtin image types of the distro, e.g.:
+//
+//	when:
+//	  distro_name: rhel
+//	image_types:
+//	  qcow2-hardened:
+//	    extends: qcow2
+//	    package_sets:
+//	      os:
+//	        - include: [aide]
+type ExtraImageTypes struct {
+	source     string
+	when       *whenCondition
+	imageTypes map[string]any
+}
+
+type extraImageTypesYAML struct {
+	// When limits the distros that get the extra image types,
+	// only distro level conditions (distro_name and version)
+	// are supported
+	When       *whenCondition `yaml:"when,omitempty"`
+	Common     map[string]any `yaml:".common,omitempty"`
+	ImageTypes map[string]any `yaml:"image_types"`
+}
+
+// LoadExtraImageTypes loads the extra image types from the given
+// YAML file.
+func LoadExtraImageTypes(path string) (*ExtraImageTypes, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseExtraImageTypes(b, path)
+}
+
+// ParseExtraImageTypes parses the extra image types from the given
+// YAML content. The source is used in error messages.
+func ParseExtraImageTypes(b []byte, source string) (*ExtraImageTypes, error) {
+	var extra extraImageTypesYAML
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&extra); err != nil {
+		return nil, fmt.Errorf("cannot parse extra image types %q: %w", source, err)
+	}
+	if len(extra.ImageTypes) == 0 {
+		return nil, fmt.Errorf("cannot find any image types in %q", source)
+	}
+	for name, v := range extra.ImageTypes {
+		if _, ok := v.(map[string]any); !ok {
+			return nil, fmt.Errorf("invalid image type %q in %q: expected a map, got %T", name, source, v)
+		}
+	}
+	// the extra image types are added per distro, the arch, image
+	// type and boot mode are not known at this point
+	if err := extra.When.checkFields("arch", "image_type", "boot_mode"); err != nil {
+		return nil, fmt.Errorf("invalid when in %q: %w", source, err)
+	}
+
+	return &ExtraImageTypes{
+		source:     source,
+		when:       extra.When,
+		imageTypes: extra.ImageTypes,
+	}, nil
+}
+
+// Source returns where the extra image types were loaded from.
+func (e *ExtraImageTypes) Source() string {
+	return e.source
+}
+
+// AddExtraImageTypes adds the given extra image types to the distro
+// and returns the (sorted) names of the added image types. Nothing
+// is added if the "when" condition of the extra image types does not
+// match the distro. It is an error if an extra image type has the
+// same name as an existing image type.
+func (d *DistroYAML) AddExtraImageTypes(extra *ExtraImageTypes) ([]string, error) {
+	if extra.when != nil && !extra.when.eval(&whenContext{id: d.ID}) {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(extra.imageTypes))
+	for name := range extra.imageTypes {
+		if _, ok := d.imageTypes[name]; ok {
+			return nil, fmt.Errorf("image type %q from %q already exists in distro %q", name, extra.source, d.ID)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// the extra image types can extend the image types of the
+	// distro so resolve them together
+	searchPaths := searchPathsOrDefault(d.searchPaths)
+	raw, err := loadImageTypesRaw(searchPaths, d.DefsPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	builtin, err := rawImageTypesMap(raw)
+	if err != nil {
+		return nil, err
+	}
+	combined := make(map[string]any, len(builtin)+len(extra.imageTypes))
+	for name, v := range builtin {
+		combined[name] = v
+	}
+	for name, v := range extra.imageTypes {
+		combined[name] = v
+	}
+	defsPath := path.Clean(d.DefsPath)
+	resolver := newExtendsResolver(searchPaths, defsPath, combined)
+
+	resolved := make(map[string]any, len(names))
+	toplevel := &imageTypesYAML{
+		extendsChains: make(map[string][]string),
+		resolvedRaw:   make(map[string]any),
+	}
+	for _, name := range names {
+		imgType, err := resolver.resolve(defsPath, name, nil)
+		if err != nil {
+			return nil, fmt.Errorf("cannot resolve extends in %q: %w", extra.source, err)
+		}
+		resolved[name] = imgType
+		if chain := resolver.chains[extendsKey(defsPath, name)]; len(chain) > 0 {
+			toplevel.extendsChains[name] = chain
+			toplevel.resolvedRaw[name] = imgType
+		}
+	}
+
+	// decode with the strict decoder so that typos are detected
+	b, err := yaml.Marshal(map[string]any{"image_types": resolved})
+	if err != nil {
+		return nil, err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(toplevel); err != nil {
+		return nil, fmt.Errorf("cannot decode extra image types %q: %w", extra.source, err)
+	}
+	if d.imageTypes == nil {
+		d.imageTypes = make(map[string]ImageTypeYAML, len(names))
+	}
+	if err := d.addImageTypes(toplevel); err != nil {
+		return nil, fmt.Errorf("cannot add extra image types %q: %w", extra.source, err)
+	}
+
+	return names, nil
+}
@@ -0,0 +1,193 @@
+package defs_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/distro/defs"
+	"github.com/osbuild/images/pkg/distro/generic"
+)
+
+var fakeExtraImageTypesYAML = `
+image_types:
+  test_type-hardened:
+    extends: test_type
+    filename: hardened.img
+    package_sets:
+      os:
+        - include: [aide]
+`
+
+func TestParseExtraImageTypesErrors(t *testing.T) {
+	for _, tc := range []struct {
+		content     string
+		expectedErr string
+	}{
+		{"image_types: {}", `cannot find any image types in "extra.yaml"`},
+		{"image_types:\n  foo: bar", `invalid image type "foo" in "extra.yaml": expected a map, got string`},
+		{"image_config: {}", `cannot parse extra image types "extra.yaml": yaml: unmarshal errors:` + "\n" + `  line 1: field image_config not found in type defs.extraImageTypesYAML`},
+		{"when:\n  arch: x86_64\nimage_types:\n  foo: {}", `invalid when in "extra.yaml": condition cannot use "arch", it is not known in this context`},
+		{"when:\n  expr: 'version >= 1 and boot_mode == uefi'\nimage_types:\n  foo: {}", `invalid when in "extra.yaml": condition cannot use "boot_mode", it is not known in this context`},
+	} {
+		_, err := defs.ParseExtraImageTypes([]byte(tc.content), "extra.yaml")
+		assert.EqualError(t, err, tc.expectedErr)
+	}
+}
+
+func TestLoadExtraImageTypes(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "extra.yaml")
+	require.NoError(t, os.WriteFile(p, []byte(fakeExtraImageTypesYAML), 0644))
+
+	extra, err := defs.LoadExtraImageTypes(p)
+	require.NoError(t, err)
+	assert.Equal(t, p, extra.Source())
+}
+
+func TestAddExtraImageTypes(t *testing.T) {
+	baseDir := makeFakeDistrosYAML(t, "", baseLayerImgTypesYAML)
+	restore := defs.MockDataFS(baseDir)
+	defer restore()
+
+	extra, err := defs.ParseExtraImageTypes([]byte(fakeExtraImageTypesYAML), "extra.yaml")
+	require.NoError(t, err)
+
+	d, err := defs.NewDistroYAML("test-distro-1")
+	require.NoError(t, err)
+	names, err := d.AddExtraImageTypes(extra)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"test_type-hardened"}, names)
+
+	it := d.ImageTypes()["test_type-hardened"]
+	assert.Equal(t, "test_type-hardened", it.Name())
+	assert.Equal(t, "hardened.img", it.Filename)
+	assert.Equal(t, "disk", it.Image)
+	assert.Equal(t, "application/x-base", it.MimeType)
+	assert.Equal(t, []string{"test-distro-1:test_type"}, it.ExtendsChain())
+	assert.Equal(t, []string{"aide"}, it.PackageSets(d.ID, "x86_64")["os"].Include)
+	// builtin image types are unchanged
+	assert.Equal(t, "base.img", d.ImageTypes()["test_type"].Filename)
+
+	// adding them again conflicts
+	_, err = d.AddExtraImageTypes(extra)
+	assert.EqualError(t, err, `image type "test_type-hardened" from "extra.yaml" already exists in distro "test-distro-1"`)
+}
+
+func TestAddExtraImageTypesWhen(t *testing.T) {
+	baseDir := makeFakeDistrosYAML(t, "", baseLayerImgTypesYAML)
+	restore := defs.MockDataFS(baseDir)
+	defer restore()
+
+	extra, err := defs.ParseExtraImageTypes([]byte(`
+when:
+  distro_name: other-distro
+`+fakeExtraImageTypesYAML), "extra.yaml")
+	require.NoError(t, err)
+
+	d, err := defs.NewDistroYAML("test-distro-1")
+	require.NoError(t, err)
+	names, err := d.AddExtraImageTypes(extra)
+	require.NoError(t, err)
+	assert.Nil(t, names)
+	assert.NotContains(t, d.ImageTypes(), "test_type-hardened")
+}
+
+func TestAddExtraImageTypesErrors(t *testing.T) {
+	baseDir := makeFakeDistrosYAML(t, "", baseLayerImgTypesYAML)
+	restore := defs.MockDataFS(baseDir)
+	defer restore()
+
+	for _, tc := range []struct {
+		content     string
+		expectedErr string
+	}{
+		{`
+image_types:
+  test_type:
+    filename: clash.img
+`, `image type "test_type" from "extra.yaml" already exists in distro "test-distro-1"`},
+		{`
+image_types:
+  new_type:
+    extends: missing
+`, `cannot resolve extends in "extra.yaml": cannot find image type "missing" in "test-distro-1" (extended via test-distro-1:new_type -> test-distro-1:missing)`},
+		{`
+image_types:
+  new_type:
+    filenam: typo.img
+`, "cannot decode extra image types \"extra.yaml\": yaml: unmarshal errors:\n  line 3: field filenam not found in type defs.ImageTypeYAML"},
+	} {
+		extra, err := defs.ParseExtraImageTypes([]byte(tc.content), "extra.yaml")
+		require.NoError(t, err)
+		d, err := defs.NewDistroYAML("test-distro-1")
+		require.NoError(t, err)
+		_, err = d.AddExtraImageTypes(extra)
+		assert.EqualError(t, err, tc.expectedErr)
+	}
+}
+
+func TestDistroFactoryWithExtraImageTypes(t *testing.T) {
+	extra, err := defs.ParseExtraImageTypes([]byte(`
+when:
+  distro_name: rhel
+image_types:
+  qcow2-hardened:
+    extends: qcow2
+    filename: disk-hardened.qcow2
+    package_sets:
+      os:
+        - include: [aide]
+`), "extra.yaml")
+	require.NoError(t, err)
+
+	distroFactory := generic.DistroFactoryWithExtraImageTypes([]*defs.ExtraImageTypes{extra})
+	dist := distroFactory("rhel-9.6")
+	require.NotNil(t, dist)
+	ar, err := dist.GetArch("x86_64")
+	require.NoError(t, err)
+	assert.Contains(t, ar.ListImageTypes(), "qcow2-hardened")
+	it, err := ar.GetImageType("qcow2-hardened")
+	require.NoError(t, err)
+	assert.Equal(t, "disk-hardened.qcow2", it.Filename())
+	assert.Equal(t, "qcow2-hardened", it.Name())
+
+	// the "when" does not match centos
+	dist = distroFactory("centos-9")
+	require.NotNil(t, dist)
+	ar, err = dist.GetArch("x86_64")
+	require.NoError(t, err)
+	assert.NotContains(t, ar.ListImageTypes(), "qcow2-hardened")
+}
+
+func TestDistroFactoryWithExtraImageTypesInvalid(t *testing.T) {
+	baseDir := makeFakeDistrosYAML(t, "", baseLayerImgTypesYAML)
+
+	for _, tc := range []struct {
+		content     string
+		expectedErr string
+	}{
+		{`
+image_types:
+  new_type:
+    extends: test_type
+    image_func: bad
+`, `invalid image type "new_type" from "extra.yaml": unknown image_func "bad" (supported: [bootable_container container disk image_installer iot iot_commit iot_container iot_installer iot_simplified_installer live_installer netinst pxe_tar tar])`},
+		{`
+image_types:
+  new_type:
+    extends: test_type
+    platforms:
+      - arch: aarch64
+`, `invalid image type "new_type" from "extra.yaml": arch "aarch64" is not supported by distro "test-distro-1" (supported: [x86_64])`},
+	} {
+		extra, err := defs.ParseExtraImageTypes([]byte(tc.content), "extra.yaml")
+		require.NoError(t, err)
+		distroFactory := generic.DistroFactoryWithExtraImageTypes([]*defs.ExtraImageTypes{extra}, os.DirFS(baseDir))
+		assert.PanicsWithError(t, tc.expectedErr+" with distro test-distro-1", func() {
+			distroFactory("test-distro-1")
+		})
+	}
+}
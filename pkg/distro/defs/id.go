@@ -1,8 +1,13 @@
 package defs
 
 import (
+	"bytes"
 	"fmt"
+	"io/fs"
 	"regexp"
+	"slices"
+	"strings"
+	"text/template"
 
 	"github.com/osbuild/images/pkg/distro"
 )
@@ -62,7 +67,7 @@ func matchAndNormalize(reStr, nameVer string) (string, error) {
 //
 // If no match is found it will "nil" and no error (
 func ParseID(nameVer string) (*distro.ID, error) {
-	distros, err := loadDistros()
+	distros, err := loadDistros(nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -78,3 +83,115 @@ func ParseID(nameVer string) (*distro.ID, error) {
 	}
 	return nil, nil
 }
+
+// expandVersions expands the given list of versions into distro
+// IDs. A version is either a single version like "9.6" or "42" or
+// an inclusive range like "9.0-9.8" or "41-43". For ranges only the
+// last version component may differ.
+func expandVersions(versions []string) ([]distro.ID, error) {
+	var ids []distro.ID
+	for _, ver := range versions {
+		first, last, isRange := strings.Cut(ver, "-")
+		if !isRange {
+			last = first
+		}
+		firstID, err := distro.ParseID("v-" + first)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q: %w", ver, err)
+		}
+		lastID, err := distro.ParseID("v-" + last)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q: %w", ver, err)
+		}
+		if (firstID.MinorVersion == -1) != (lastID.MinorVersion == -1) || (firstID.MinorVersion != -1 && firstID.MajorVersion != lastID.MajorVersion) {
+			return nil, fmt.Errorf("invalid version range %q: only the last version component can differ", ver)
+		}
+
+		id := *firstID
+		id.Name = ""
+		step := func() *int {
+			if id.MinorVersion == -1 {
+				return &id.MajorVersion
+			}
+			return &id.MinorVersion
+		}()
+		end := lastID.MajorVersion
+		if lastID.MinorVersion != -1 {
+			end = lastID.MinorVersion
+		}
+		if *step > end {
+			return nil, fmt.Errorf("invalid version range %q: %s is bigger than %s", ver, first, last)
+		}
+		for ; *step <= end; *step++ {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// names returns all distro names that the distro definition can
+// produce. For distros with a "match" rule and a templated name
+// those are generated from the "versions" list.
+func (d *DistroYAML) names() ([]string, error) {
+	// a literal name is always found before the match rule is
+	// tried (even if the match rule is inherited via "<<:")
+	if d.Match == "" || !strings.Contains(d.Name, "{{") {
+		return []string{d.Name}, nil
+	}
+
+	ids, err := expandVersions(d.Versions)
+	if err != nil {
+		return nil, fmt.Errorf("cannot expand versions of %q: %w", d.Name, err)
+	}
+	templ, err := template.New("name").Parse(d.Name)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, id := range ids {
+		var buf bytes.Buffer
+		if err := templ.Execute(&buf, id); err != nil {
+			return nil, err
+		}
+		name := buf.String()
+		found, err := matchAndNormalize(d.Match, name)
+		if err != nil {
+			return nil, err
+		}
+		if found != name {
+			return nil, fmt.Errorf("version %s of %q results in %q which does not match %q", id.VersionString(), d.Name, name, d.Match)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// Distros returns the names of all distros that the distro
+// definitions in the given searchPaths can produce, see
+// NewDistroYAML for the searchPaths.
+//
+// Distros that use a "match" rule only contribute the versions
+// listed in their "versions" key. The names are returned in the
+// order of the definitions. If multiple definitions produce the
+// same name only the first one is used, just like NewDistroYAML
+// does.
+func Distros(searchPaths ...fs.FS) ([]string, error) {
+	distros, err := loadDistros(searchPaths, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []string
+	for _, d := range distros.Distros {
+		names, err := d.names()
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if !slices.Contains(res, name) {
+				res = append(res, name)
+			}
+		}
+	}
+	return res, nil
+}
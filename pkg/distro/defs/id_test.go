@@ -48,3 +48,38 @@ func TestMatchAndNormalizeSad(t *testing.T) {
 		assert.ErrorContains(t, err, tc.expectedErr)
 	}
 }
+
+func TestExpandVersions(t *testing.T) {
+	for _, tc := range []struct {
+		versions []string
+		expected []string
+	}{
+		{nil, nil},
+		{[]string{"42"}, []string{"42"}},
+		{[]string{"41-43"}, []string{"41", "42", "43"}},
+		{[]string{"9.8-9.10", "10.0"}, []string{"9.8", "9.9", "9.10", "10.0"}},
+	} {
+		ids, err := expandVersions(tc.versions)
+		assert.NoError(t, err)
+		var vers []string
+		for _, id := range ids {
+			vers = append(vers, id.VersionString())
+		}
+		assert.Equal(t, tc.expected, vers)
+	}
+}
+
+func TestExpandVersionsSad(t *testing.T) {
+	for _, tc := range []struct {
+		version     string
+		expectedErr string
+	}{
+		{"x", `invalid version "x": error when parsing distro name "v-x": parsing major version failed`},
+		{"9.0-10.0", `invalid version range "9.0-10.0": only the last version component can differ`},
+		{"9-9.2", `invalid version range "9-9.2": only the last version component can differ`},
+		{"9.4-9.2", `invalid version range "9.4-9.2": 9.4 is bigger than 9.2`},
+	} {
+		_, err := expandVersions([]string{tc.version})
+		assert.ErrorContains(t, err, tc.expectedErr)
+	}
+}
@@ -0,0 +1,267 @@
+package defs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MergeAction describes what a search path layer did to a given key
+// of the distro definitions.
+type MergeAction string
+
+const (
+	// MergeActionAdd is used when a layer adds a key that no
+	// earlier layer defined
+	MergeActionAdd MergeAction = "add"
+	// MergeActionOverride is used when a layer replaces a key
+	// from the base layer
+	MergeActionOverride MergeAction = "override"
+	// MergeActionConflict is used when a layer replaces a key
+	// that was already set by another (non-base) layer, i.e. when
+	// two layers on top of the base disagree
+	MergeActionConflict MergeAction = "conflict"
+)
+
+// MergeReportEntry is a single change that a search path layer
+// applied when merging the distro definitions.
+type MergeReportEntry struct {
+	// Layer is the index of the search path that made the change
+	Layer int
+	// File is the file (relative to the search path) that contains
+	// the change, e.g. "rhel-9/imagetypes.yaml"
+	File string
+	// Key is the dotted path of the changed key, e.g.
+	// "image_types.qcow2.filename"
+	Key    string
+	Action MergeAction
+}
+
+func (e MergeReportEntry) String() string {
+	return fmt.Sprintf("layer %v: %s: %s (%s)", e.Layer, e.Action, e.Key, e.File)
+}
+
+// MergeReport contains all the changes that were applied when merging
+// multiple search paths. It is intended for debugging layered
+// distro definitions.
+type MergeReport struct {
+	Entries []MergeReportEntry
+
+	// origin tracks what layer set a given key last
+	origin map[string]int
+}
+
+func newMergeReport() *MergeReport {
+	return &MergeReport{
+		origin: make(map[string]int),
+	}
+}
+
+// prevLayer returns the layer that last set the given key, if the
+// key itself was never set explicitly the layer that set the closest
+// parent key is returned.
+func (r *MergeReport) prevLayer(key string) int {
+	for {
+		if layer, ok := r.origin[key]; ok {
+			return layer
+		}
+		idx := strings.LastIndex(key, ".")
+		if idx < 0 {
+			return 0
+		}
+		key = key[:idx]
+	}
+}
+
+// has returns true if the key was recorded by an earlier layer
+func (r *MergeReport) has(key string) bool {
+	if r == nil {
+		return false
+	}
+	_, ok := r.origin[key]
+	return ok
+}
+
+func (r *MergeReport) record(layer int, file, key string, existed bool) {
+	if r == nil {
+		return
+	}
+	action := MergeActionAdd
+	if existed {
+		action = MergeActionOverride
+		if r.prevLayer(key) > 0 {
+			action = MergeActionConflict
+		}
+	}
+	r.origin[key] = layer
+	// the base layer is not interesting for the report
+	if layer == 0 {
+		return
+	}
+	r.Entries = append(r.Entries, MergeReportEntry{
+		Layer:  layer,
+		File:   file,
+		Key:    key,
+		Action: action,
+	})
+}
+
+// Conflicts returns only the entries that are conflicts.
+func (r *MergeReport) Conflicts() []MergeReportEntry {
+	var conflicts []MergeReportEntry
+	for _, e := range r.Entries {
+		if e.Action == MergeActionConflict {
+			conflicts = append(conflicts, e)
+		}
+	}
+	return conflicts
+}
+
+func (r *MergeReport) String() string {
+	var sb strings.Builder
+	for _, e := range r.Entries {
+		fmt.Fprintln(&sb, e.String())
+	}
+	return sb.String()
+}
+
+// searchPathsOrDefault returns the given searchPaths or, if there are
+// none, the default data search path.
+func searchPathsOrDefault(searchPaths []fs.FS) []fs.FS {
+	if len(searchPaths) == 0 {
+		return []fs.FS{dataFS()}
+	}
+	return searchPaths
+}
+
+// decodeLayers calls decodeFn for every search path that contains the
+// given file (in search path order). It errors if no search path
+// contains the file.
+func decodeLayers(searchPaths []fs.FS, name string, decodeFn func(layer int, dec *yaml.Decoder) error) error {
+	var found bool
+	for idx, searchPath := range searchPaths {
+		f, err := searchPath.Open(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+		err = decodeFn(idx, yaml.NewDecoder(f))
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("cannot decode %q from layer %v: %w", name, idx, err)
+		}
+	}
+	if !found {
+		return fmt.Errorf("cannot find %q in any search path: %w", name, fs.ErrNotExist)
+	}
+	return nil
+}
+
+// distroKey returns the key that identifies a distro in distros.yaml
+// across layers, the name alone is not enough as multiple distros
+// use the same (templated) name with different "match" rules
+func distroKey(d *DistroYAML) string {
+	if d.Match == "" {
+		return d.Name
+	}
+	return fmt.Sprintf("%s (%s)", d.Name, d.Match)
+}
+
+// mergeDistrosLayers merges the distros from the various layers, a
+// distro from a later layer replaces a distro with the same
+// name/match from an earlier layer. Distros from later layers are
+// matched first.
+func mergeDistrosLayers(layers [][]DistroYAML, report *MergeReport) []DistroYAML {
+	for idx, distros := range layers {
+		for _, d := range distros {
+			key := "distros." + distroKey(&d)
+			report.record(idx, "distros.yaml", key, report.has(key))
+		}
+	}
+
+	var merged []DistroYAML
+	seen := make(map[string]bool)
+	for idx := len(layers) - 1; idx >= 0; idx-- {
+		for _, d := range layers[idx] {
+			key := distroKey(&d)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			merged = append(merged, d)
+		}
+	}
+	return merged
+}
+
+// imageTypesMergeDepth defines how deep the top-level keys of
+// imagetypes.yaml are merged across layers before a value is
+// replaced as a whole. E.g. "image_types" has a depth of 2 which
+// means that layers can add new image types and override single
+// keys of an image type (like "image_types.qcow2.filename").
+var imageTypesMergeDepth = map[string]int{
+	"image_types":  2,
+	"image_config": 2,
+	".common":      1,
+}
+
+func mergeImageTypesLayer(dst, src map[string]any, layer int, file string, report *MergeReport) {
+	keys := make([]string, 0, len(src))
+	for k := range src {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		mergeMapKey(dst, src, k, k, imageTypesMergeDepth[k], layer, file, report)
+	}
+}
+
+func mergeMapKey(dst, src map[string]any, k, path string, depth, layer int, file string, report *MergeReport) {
+	srcMap, srcIsMap := src[k].(map[string]any)
+	dstMap, dstIsMap := dst[k].(map[string]any)
+	if depth == 0 || !srcIsMap || !dstIsMap {
+		_, existed := dst[k]
+		dst[k] = src[k]
+		report.record(layer, file, path, existed)
+		return
+	}
+
+	keys := make([]string, 0, len(srcMap))
+	for sk := range srcMap {
+		keys = append(keys, sk)
+	}
+	sort.Strings(keys)
+	for _, sk := range keys {
+		mergeMapKey(dstMap, srcMap, sk, path+"."+sk, depth-1, layer, file, report)
+	}
+}
+
+// loadImageTypesRaw loads the imagetypes.yaml from the given
+// defsPath from all search paths and merges them (see
+// imageTypesMergeDepth for the merge rules). The result is not
+// decoded yet so that it can be further processed.
+func loadImageTypesRaw(searchPaths []fs.FS, defsPath string, report *MergeReport) (map[string]any, error) {
+	p := path.Join(defsPath, "imagetypes.yaml")
+
+	merged := make(map[string]any)
+	err := decodeLayers(searchPaths, p, func(layer int, dec *yaml.Decoder) error {
+		var m map[string]any
+		if err := dec.Decode(&m); err != nil {
+			return err
+		}
+		mergeImageTypesLayer(merged, m, layer, p, report)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
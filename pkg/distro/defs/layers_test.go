@@ -0,0 +1,200 @@
+package defs_test
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/distro"
+	"github.com/osbuild/images/pkg/distro/defs"
+	"github.com/osbuild/images/pkg/distro/generic"
+)
+
+var baseLayerImgTypesYAML = `
+image_types:
+  test_type:
+    filename: base.img
+    image_func: disk
+    mime_type: application/x-base
+    platforms:
+      - arch: x86_64
+  other_type:
+    filename: other.img
+    image_func: disk
+    platforms:
+      - arch: x86_64
+`
+
+func makeLayer(t *testing.T, files map[string]string) fs.FS {
+	t.Helper()
+
+	tmpdir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(tmpdir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0644))
+	}
+	return os.DirFS(tmpdir)
+}
+
+func TestLayersOverrideAndAddImageTypes(t *testing.T) {
+	baseDir := makeFakeDistrosYAML(t, "", baseLayerImgTypesYAML)
+	overlay := makeLayer(t, map[string]string{
+		"test-distro-1/imagetypes.yaml": `
+image_types:
+  test_type:
+    filename: overlay.img
+  new_type:
+    filename: new.img
+    image_func: disk
+    platforms:
+      - arch: x86_64
+`,
+	})
+
+	d, err := defs.NewDistroYAML("test-distro-1", os.DirFS(baseDir), overlay)
+	require.NoError(t, err)
+	imgTypes := d.ImageTypes()
+	assert.Len(t, imgTypes, 3)
+	// single key is overriden, the rest is kept
+	assert.Equal(t, "overlay.img", imgTypes["test_type"].Filename)
+	assert.Equal(t, "application/x-base", imgTypes["test_type"].MimeType)
+	assert.Equal(t, "disk", imgTypes["test_type"].Image)
+	assert.Equal(t, "other.img", imgTypes["other_type"].Filename)
+	assert.Equal(t, "new.img", imgTypes["new_type"].Filename)
+
+	report := d.MergeReport()
+	require.NotNil(t, report)
+	assert.Contains(t, report.Entries, defs.MergeReportEntry{
+		Layer:  1,
+		File:   "test-distro-1/imagetypes.yaml",
+		Key:    "image_types.test_type.filename",
+		Action: defs.MergeActionOverride,
+	})
+	assert.Contains(t, report.Entries, defs.MergeReportEntry{
+		Layer:  1,
+		File:   "test-distro-1/imagetypes.yaml",
+		Key:    "image_types.new_type",
+		Action: defs.MergeActionAdd,
+	})
+	assert.Len(t, report.Conflicts(), 0)
+}
+
+func TestLayersConflictsAreReported(t *testing.T) {
+	baseDir := makeFakeDistrosYAML(t, "", baseLayerImgTypesYAML)
+	overlay1 := makeLayer(t, map[string]string{
+		"test-distro-1/imagetypes.yaml": `
+image_types:
+  test_type:
+    filename: overlay1.img
+`,
+	})
+	overlay2 := makeLayer(t, map[string]string{
+		"test-distro-1/imagetypes.yaml": `
+image_types:
+  test_type:
+    filename: overlay2.img
+`,
+	})
+
+	d, err := defs.NewDistroYAML("test-distro-1", os.DirFS(baseDir), overlay1, overlay2)
+	require.NoError(t, err)
+	assert.Equal(t, "overlay2.img", d.ImageTypes()["test_type"].Filename)
+	assert.Equal(t, []defs.MergeReportEntry{
+		{
+			Layer:  2,
+			File:   "test-distro-1/imagetypes.yaml",
+			Key:    "image_types.test_type.filename",
+			Action: defs.MergeActionConflict,
+		},
+	}, d.MergeReport().Conflicts())
+}
+
+func TestLayersAddDistro(t *testing.T) {
+	baseDir := makeFakeDistrosYAML(t, "", baseLayerImgTypesYAML)
+	overlay := makeLayer(t, map[string]string{
+		"distros.yaml": `
+distros:
+  - name: new-distro-2
+    vendor: new-vendor
+    defs_path: new-distro/
+`,
+		"new-distro/imagetypes.yaml": `
+image_types:
+  new_distro_type:
+    filename: new-distro.img
+    image_func: disk
+    platforms:
+      - arch: x86_64
+`,
+	})
+
+	d, err := defs.NewDistroYAML("new-distro-2", os.DirFS(baseDir), overlay)
+	require.NoError(t, err)
+	require.NotNil(t, d)
+	assert.Equal(t, distro.ID{Name: "new-distro", MajorVersion: 2, MinorVersion: -1}, d.ID)
+	assert.Equal(t, []string{"new_distro_type"}, keys(d.ImageTypes()))
+
+	// the base distro is still available
+	d, err = defs.NewDistroYAML("test-distro-1", os.DirFS(baseDir), overlay)
+	require.NoError(t, err)
+	require.NotNil(t, d)
+	assert.Equal(t, "test-vendor", d.Vendor)
+}
+
+func TestLayersUnknownFieldsInOverlayError(t *testing.T) {
+	baseDir := makeFakeDistrosYAML(t, "", baseLayerImgTypesYAML)
+	overlay := makeLayer(t, map[string]string{
+		"test-distro-1/imagetypes.yaml": `
+image_types:
+  test_type:
+    filenam: typo.img
+`,
+	})
+
+	_, err := defs.NewDistroYAML("test-distro-1", os.DirFS(baseDir), overlay)
+	assert.ErrorContains(t, err, "field filenam not found in type defs.ImageTypeYAML")
+}
+
+func TestLayersGenericDistroFactory(t *testing.T) {
+	baseDir := makeFakeDistrosYAML(t, "", baseLayerImgTypesYAML)
+	overlay := makeLayer(t, map[string]string{
+		"test-distro-1/imagetypes.yaml": `
+image_types:
+  test_type:
+    filename: overlay.img
+`,
+	})
+
+	distroFactory := generic.DistroFactoryWithSearchPaths(os.DirFS(baseDir), overlay)
+	dist := distroFactory("test-distro-1")
+	require.NotNil(t, dist)
+	ar, err := dist.GetArch("x86_64")
+	require.NoError(t, err)
+	it, err := ar.GetImageType("test_type")
+	require.NoError(t, err)
+	assert.Equal(t, "overlay.img", it.Filename())
+}
+
+func keys[T any](m map[string]T) []string {
+	var l []string
+	for k := range m {
+		l = append(l, k)
+	}
+	return l
+}
+
+func TestLayersMergeDistrosWithoutReport(t *testing.T) {
+	layers := [][]defs.DistroYAML{
+		{{Name: "test-distro-1"}, {Name: "other-distro-1"}},
+		{{Name: "test-distro-1", Vendor: "overlay-vendor"}},
+	}
+	merged := defs.MergeDistrosLayers(layers, nil)
+	require.Len(t, merged, 2)
+	assert.Equal(t, "overlay-vendor", merged[0].Vendor)
+	assert.Equal(t, "other-distro-1", merged[1].Name)
+}
@@ -6,12 +6,15 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
+	"maps"
 	"os"
-	"path/filepath"
+	"path"
 	"slices"
 	"sort"
 	"text/template"
+	"time"
 
+	"github.com/hashicorp/go-version"
 	"gopkg.in/yaml.v3"
 
 	"github.com/osbuild/images/data/distrodefs"
@@ -34,6 +37,7 @@ import (
 var (
 	ErrNoPartitionTableForImgType = errors.New("no partition table for image type")
 	ErrNoPartitionTableForArch    = errors.New("no partition table for arch")
+	ErrOverlappingConditions      = errors.New("overlapping conditions")
 )
 
 // this can be overriden in tests
@@ -62,6 +66,10 @@ type DistroYAML struct {
 	//   (?P<distro>rhel)-(?P<major>8)\.?(?P<minor>[0-9]+)
 	// will support a format like e.g. rhel-810 and rhel-8.10
 	Match string `yaml:"match"`
+	// Versions lists the versions of a distro with a Match rule
+	// that are known to exist, e.g. "9.6" or a range like
+	// "9.0-9.8". It is used to enumerate the distros (see Distros).
+	Versions []string `yaml:"versions"`
 
 	// The distro metadata, can contain go text template strings
 	// for {{.Major}}, {{.Minor}} which will be expanded by the
@@ -91,9 +99,18 @@ type DistroYAML struct {
 
 	OscapProfilesAllowList []oscap.Profile `yaml:"oscap_profiles_allowlist"`
 
+	// LifecycleDates contains the (optional) support lifecycle
+	// dates of the distro, see Lifecycle()
+	LifecycleDates lifecycleYAML `yaml:"lifecycle"`
+
 	imageTypes map[string]ImageTypeYAML
+	lifecycle  distro.Lifecycle
 	// distro wide default image config
-	imageConfig *distro.ImageConfig `yaml:"default"`
+	imageConfig        *distro.ImageConfig `yaml:"default"`
+	imageConfigDefault *distro.ImageConfig
+	imageConfigConds   map[string]*distroImageConfigConditions
+	// set if the image types enable "strict_conditions"
+	strictConditions bool
 
 	// ignore the given image types
 	Conditions map[string]distroConditions `yaml:"conditions"`
@@ -106,12 +123,25 @@ type DistroYAML struct {
 
 	// set by the loader
 	ID distro.ID
+
+	// only set when multiple search paths are used
+	mergeReport *MergeReport
+	// the custom search paths the distro was loaded from (if any),
+	// needed to resolve "extends" of extra image types
+	searchPaths []fs.FS
 }
 
 func (d *DistroYAML) ImageTypes() map[string]ImageTypeYAML {
 	return d.imageTypes
 }
 
+// MergeReport returns the report of how the search path layers were
+// merged for this distro. It is nil if only a single search path
+// was used.
+func (d *DistroYAML) MergeReport() *MergeReport {
+	return d.mergeReport
+}
+
 // ImageConfig returns the distro wide default ImageConfig.
 //
 // Each ImageType gets this as their default ImageConfig.
@@ -119,9 +149,23 @@ func (d *DistroYAML) ImageConfig() *distro.ImageConfig {
 	return d.imageConfig
 }
 
+// Lifecycle returns the support lifecycle of the distro.
+func (d *DistroYAML) Lifecycle() distro.Lifecycle {
+	lc := d.lifecycle
+	lc.Preview = d.Preview
+	return lc
+}
+
+// SkipImageType returns true if the image type should not be added
+// for the given arch, either because of the distro "conditions" or
+// because the image type was removed, see ImageTypeYAML.RemovedFor().
 func (d *DistroYAML) SkipImageType(imgTypeName, archName string) bool {
+	if it, ok := d.imageTypes[imgTypeName]; ok && it.RemovedFor(d.ID) {
+		return true
+	}
 	for _, cond := range d.Conditions {
-		if cond.When.Eval(d.ID, archName) && slices.Contains(cond.IgnoreImageTypes, imgTypeName) {
+		ctx := &whenContext{id: d.ID, arch: archName, imgType: imgTypeName}
+		if cond.When.eval(ctx) && slices.Contains(cond.IgnoreImageTypes, imgTypeName) {
 			return true
 		}
 	}
@@ -157,33 +201,68 @@ func (d *DistroYAML) runTemplates(id distro.ID) error {
 	return errors.Join(errs...)
 }
 
-func loadDistros() (*distrosYAML, error) {
-	f, err := dataFS().Open("distros.yaml")
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
+func loadDistros(searchPaths []fs.FS, report *MergeReport) (*distrosYAML, error) {
+	searchPaths = searchPathsOrDefault(searchPaths)
 
-	decoder := yaml.NewDecoder(f)
-	decoder.KnownFields(true)
+	layers := make([][]DistroYAML, len(searchPaths))
+	err := decodeLayers(searchPaths, "distros.yaml", func(layer int, decoder *yaml.Decoder) error {
+		decoder.KnownFields(true)
 
-	var distros distrosYAML
-	if err := decoder.Decode(&distros); err != nil {
+		var distros distrosYAML
+		if err := decoder.Decode(&distros); err != nil {
+			return err
+		}
+		layers[layer] = distros.Distros
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
+	if len(searchPaths) == 1 {
+		return &distrosYAML{Distros: layers[0]}, nil
+	}
 
-	return &distros, nil
+	return &distrosYAML{Distros: mergeDistrosLayers(layers, report)}, nil
 }
 
 // NewDistroYAML return the given distro or nil if the distro is not
 // found. This mimics the "distrofactory.GetDistro() interface.
 //
-// Note that eventually we want something like "Distros()" instead
-// that returns all known distros but for now we keep compatibility
-// with the way distrofactory/reporegistry work which is by defining
-// distros via repository files.
-func NewDistroYAML(nameVer string) (*DistroYAML, error) {
-	distros, err := loadDistros()
+// The optional searchPaths are an ordered list of layers that contain
+// a "distros.yaml" and/or "<defs_path>/imagetypes.yaml". Later layers
+// can add new distros and image types or override single keys of
+// existing image types (see MergeReport). If no searchPaths are
+// given the embedded distro definitions are used.
+//
+// Use Distros() to get the names of all known distros.
+//
+// If the image types set "strict_conditions" an error is returned
+// when conditions overlap (see ConditionOverlaps).
+func NewDistroYAML(nameVer string, searchPaths ...fs.FS) (*DistroYAML, error) {
+	d, err := newDistroYAML(nameVer, searchPaths...)
+	if err != nil || d == nil {
+		return nil, err
+	}
+	if d.strictConditions {
+		if overlaps := d.ConditionOverlaps(); len(overlaps) > 0 {
+			var errs []error
+			for _, o := range overlaps {
+				errs = append(errs, fmt.Errorf("%w: %s", ErrOverlappingConditions, o))
+			}
+			return nil, errors.Join(errs...)
+		}
+	}
+	return d, nil
+}
+
+func newDistroYAML(nameVer string, customSearchPaths ...fs.FS) (*DistroYAML, error) {
+	searchPaths := searchPathsOrDefault(customSearchPaths)
+	var report *MergeReport
+	if len(searchPaths) > 1 {
+		report = newMergeReport()
+	}
+
+	distros, err := loadDistros(searchPaths, report)
 	if err != nil {
 		return nil, err
 	}
@@ -218,40 +297,130 @@ func NewDistroYAML(nameVer string) (*DistroYAML, error) {
 	if err := foundDistro.runTemplates(*id); err != nil {
 		return nil, err
 	}
+	foundDistro.lifecycle, err = foundDistro.LifecycleDates.parse()
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse lifecycle of %q: %w", nameVer, err)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(foundDistro.Conditions)) {
+		// skipping image types is done before the boot mode is known
+		if err := foundDistro.Conditions[name].When.checkFields("boot_mode"); err != nil {
+			return nil, fmt.Errorf("distro condition %q of %q: %w", name, nameVer, err)
+		}
+	}
 
 	// load imageTypes
-	f, err := dataFS().Open(filepath.Join(foundDistro.DefsPath, "imagetypes.yaml"))
+	toplevel, err := loadImageTypes(searchPaths, foundDistro.DefsPath, report)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
-
-	var toplevel imageTypesYAML
-	decoder := yaml.NewDecoder(f)
-	decoder.KnownFields(true)
-	if err := decoder.Decode(&toplevel); err != nil {
-		return nil, err
+	for _, name := range sortedConditionNames(toplevel.ImageConfig.Conditions) {
+		// the distro wide image config is not specific to an
+		// architecture or image type
+		if err := toplevel.ImageConfig.Conditions[name].When.checkFields("arch", "image_type", "boot_mode"); err != nil {
+			return nil, fmt.Errorf("image config condition %q: %w", name, err)
+		}
 	}
 	if len(toplevel.ImageTypes) > 0 {
 		foundDistro.imageTypes = make(map[string]ImageTypeYAML, len(toplevel.ImageTypes))
-		for name := range toplevel.ImageTypes {
-			v := toplevel.ImageTypes[name]
-			v.name = name
-			if err := v.runTemplates(foundDistro); err != nil {
-				return nil, err
-			}
-			if err := v.setupDefaultFS(foundDistro.DefaultFSType.String()); err != nil {
-				return nil, err
-			}
-
-			foundDistro.imageTypes[name] = v
+		if err := foundDistro.addImageTypes(toplevel); err != nil {
+			return nil, err
 		}
 	}
 	foundDistro.imageConfig = toplevel.ImageConfig.For(foundDistro.ID)
+	foundDistro.imageConfigDefault = toplevel.ImageConfig.Default
+	foundDistro.imageConfigConds = toplevel.ImageConfig.Conditions
+	foundDistro.strictConditions = toplevel.StrictConditions
+	foundDistro.searchPaths = customSearchPaths
+	if report != nil {
+		for _, conflict := range report.Conflicts() {
+			olog.Printf("WARNING: conflict when merging distro definitions: %s", conflict)
+		}
+		foundDistro.mergeReport = report
+	}
 
 	return foundDistro, nil
 }
 
+// addImageTypes adds the image types of the given (decoded)
+// imagetypes.yaml to the distro
+func (d *DistroYAML) addImageTypes(toplevel *imageTypesYAML) error {
+	for name := range toplevel.ImageTypes {
+		v := toplevel.ImageTypes[name]
+		v.name = name
+		v.extendsChain = toplevel.extendsChains[name]
+		if len(v.extendsChain) > 0 {
+			v.resolvedRaw = toplevel.resolvedRaw[name]
+		}
+		if err := v.runTemplates(d); err != nil {
+			return err
+		}
+		if err := v.setupDefaultFS(d.DefaultFSType.String()); err != nil {
+			return err
+		}
+		if err := v.validateDeprecation(); err != nil {
+			return err
+		}
+		if err := v.validatePlatformsOverride(); err != nil {
+			return err
+		}
+
+		d.imageTypes[name] = v
+	}
+	return nil
+}
+
+func loadImageTypes(searchPaths []fs.FS, defsPath string, report *MergeReport) (*imageTypesYAML, error) {
+	p := path.Join(defsPath, "imagetypes.yaml")
+
+	// fast path: a single search path without any "extends" can
+	// be decoded directly
+	if len(searchPaths) == 1 {
+		b, err := fs.ReadFile(searchPaths[0], p)
+		if err != nil {
+			return nil, err
+		}
+		usesExtends, err := imageTypesUseExtends(b)
+		if err != nil {
+			return nil, err
+		}
+		if !usesExtends {
+			var toplevel imageTypesYAML
+			decoder := yaml.NewDecoder(bytes.NewReader(b))
+			decoder.KnownFields(true)
+			if err := decoder.Decode(&toplevel); err != nil {
+				return nil, err
+			}
+			return &toplevel, nil
+		}
+	}
+
+	raw, err := loadImageTypesRaw(searchPaths, defsPath, report)
+	if err != nil {
+		return nil, err
+	}
+	chains, err := resolveExtends(searchPaths, defsPath, raw)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve extends in %q: %w", p, err)
+	}
+
+	// the merged result is decoded again with the strict
+	// decoder so that typos in layers are detected
+	b, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var toplevel imageTypesYAML
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&toplevel); err != nil {
+		return nil, fmt.Errorf("cannot decode merged %q: %w", p, err)
+	}
+	toplevel.extendsChains = chains
+	toplevel.resolvedRaw, _ = rawImageTypesMap(raw)
+	return &toplevel, nil
+}
+
 // imageTypesYAML describes the image types for a given distribution
 // family. Note that multiple distros may use the same image types,
 // e.g. centos/rhel
@@ -259,6 +428,33 @@ type imageTypesYAML struct {
 	ImageConfig distroImageConfig        `yaml:"image_config,omitempty"`
 	ImageTypes  map[string]ImageTypeYAML `yaml:"image_types"`
 	Common      map[string]any           `yaml:".common,omitempty"`
+
+	// StrictConditions makes loading fail if conditions overlap
+	StrictConditions bool `yaml:"strict_conditions,omitempty"`
+
+	// set by the loader when "extends" needed to be resolved
+	extendsChains map[string][]string
+	resolvedRaw   map[string]any
+}
+
+// imageTypesUseExtends checks if any of the image types in the
+// given imagetypes.yaml content uses "extends". Such image types
+// cannot be decoded directly (e.g. because of "replace:" lists).
+func imageTypesUseExtends(b []byte) (bool, error) {
+	var peek struct {
+		ImageTypes map[string]struct {
+			Extends any `yaml:"extends"`
+		} `yaml:"image_types"`
+	}
+	if err := yaml.Unmarshal(b, &peek); err != nil {
+		return false, err
+	}
+	for _, it := range peek.ImageTypes {
+		if it.Extends != nil {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 type distroImageConfig struct {
@@ -274,9 +470,18 @@ type whenCondition struct {
 	VersionLessThan       string `yaml:"version_less_than,omitempty"`
 	VersionGreaterOrEqual string `yaml:"version_greater_or_equal,omitempty"`
 	VersionEqual          string `yaml:"version_equal,omitempty"`
+
+	// Expr is a boolean expression (see whenExpr for the grammar)
+	Expr *whenExpr `yaml:"expr,omitempty"`
 }
 
 func (wc *whenCondition) Eval(id distro.ID, archStr string) bool {
+	return wc.eval(&whenContext{id: id, arch: archStr})
+}
+
+func (wc *whenCondition) eval(ctx *whenContext) bool {
+	id := ctx.id
+	archStr := ctx.arch
 	match := true
 
 	if wc.DistroName != "" {
@@ -297,15 +502,58 @@ func (wc *whenCondition) Eval(id distro.ID, archStr string) bool {
 	if wc.VersionEqual != "" {
 		match = match && (id.VersionString() == wc.VersionEqual)
 	}
+	if wc.Expr != nil {
+		match = match && wc.Expr.eval(ctx)
+	}
 
 	return match
 }
 
+// fields returns the names of the fields (see exprFields) that are
+// used by the condition.
+func (wc *whenCondition) fields() []string {
+	var fields []string
+	add := func(field string) {
+		if !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+	if wc.DistroName != "" || wc.NotDistroName != "" {
+		add("distro_name")
+	}
+	if wc.Architecture != "" {
+		add("arch")
+	}
+	if wc.VersionLessThan != "" || wc.VersionGreaterOrEqual != "" || wc.VersionEqual != "" {
+		add("version")
+	}
+	if wc.Expr != nil {
+		wc.Expr.root.walkFields(add)
+	}
+	return fields
+}
+
+// checkFields returns an error if the condition uses one of the
+// given fields. It is used for conditions that are evaluated in a
+// context where these fields are not known, they would never match.
+func (wc *whenCondition) checkFields(unknown ...string) error {
+	if wc == nil {
+		return nil
+	}
+	for _, field := range wc.fields() {
+		if slices.Contains(unknown, field) {
+			return fmt.Errorf("condition cannot use %q, it is not known in this context", field)
+		}
+	}
+	return nil
+}
+
 func (di *distroImageConfig) For(id distro.ID) *distro.ImageConfig {
 	imgConfig := di.Default
 
 	if di.Conditions != nil {
-		for _, cond := range di.Conditions {
+		for _, name := range sortedConditionNames(di.Conditions) {
+			cond := di.Conditions[name]
 			// distro image config cannot have architecure
 			// specific conditions
 			arch := ""
@@ -320,15 +568,54 @@ func (di *distroImageConfig) For(id distro.ID) *distro.ImageConfig {
 
 type distroImageConfigConditions struct {
 	When         whenCondition       `yaml:"when,omitempty"`
+	Priority     int                 `yaml:"priority,omitempty"`
 	ShallowMerge *distro.ImageConfig `yaml:"shallow_merge,omitempty"`
 }
 
+// lifecycleDateLayout is the format of the dates in the "lifecycle"
+// section of distros.yaml
+const lifecycleDateLayout = "2006-01-02"
+
+type lifecycleYAML struct {
+	GA               string `yaml:"ga,omitempty"`
+	EndOfMaintenance string `yaml:"end_of_maintenance,omitempty"`
+	EndOfLife        string `yaml:"end_of_life,omitempty"`
+}
+
+func (l *lifecycleYAML) parse() (distro.Lifecycle, error) {
+	var lc distro.Lifecycle
+	for _, f := range []struct {
+		name  string
+		value string
+		dst   *time.Time
+	}{
+		{"ga", l.GA, &lc.GA},
+		{"end_of_maintenance", l.EndOfMaintenance, &lc.EndOfMaintenance},
+		{"end_of_life", l.EndOfLife, &lc.EndOfLife},
+	} {
+		if f.value == "" {
+			continue
+		}
+		t, err := time.Parse(lifecycleDateLayout, f.value)
+		if err != nil {
+			return lc, fmt.Errorf("invalid %s date: %w", f.name, err)
+		}
+		*f.dst = t
+	}
+	return lc, nil
+}
+
 type distroConditions struct {
 	When             *whenCondition `yaml:"when"`
 	IgnoreImageTypes []string       `yaml:"ignore_image_types"`
 }
 
 type ImageTypeYAML struct {
+	// Extends names the image type that this image type inherits
+	// from, see extendsMergeDepth for the merge rules. It is
+	// always nil once the image type is loaded.
+	Extends *imageTypeExtends `yaml:"extends,omitempty"`
+
 	// This maps "pkgsKey" to their package sets. The
 	// map key here is a string that can either be:
 	// - "os": packages for the os
@@ -344,6 +631,9 @@ type ImageTypeYAML struct {
 	PartitionTables map[string]*disk.PartitionTable `yaml:"partition_table"`
 	// override specific aspects of the partition table
 	PartitionTablesOverrides *partitionTablesOverrides `yaml:"partition_tables_override"`
+	// archStr->partitionTables of additional (data) disks, only used
+	// by "disk" images in raw or qcow2 format without compression
+	DataDisks map[string][]*disk.PartitionTable `yaml:"data_disks,omitempty"`
 
 	ImageConfigYAML     imageConfig     `yaml:"image_config,omitempty"`
 	InstallerConfigYAML installerConfig `yaml:"installer_config,omitempty"`
@@ -383,6 +673,10 @@ type ImageTypeYAML struct {
 
 	NameAliases []string `yaml:"name_aliases"`
 
+	// Deprecated marks the image type as deprecated, see
+	// DeprecationFor()
+	Deprecated *distro.Deprecation `yaml:"deprecated,omitempty"`
+
 	InstallWeakDeps *bool `yaml:"install_weak_deps"`
 
 	// for RHEL7 compat
@@ -397,26 +691,127 @@ type ImageTypeYAML struct {
 
 	// name is set by the loader
 	name string
+	// set by the loader if the image type uses "extends"
+	extendsChain []string
+	resolvedRaw  any
 }
 
 func (it *ImageTypeYAML) Name() string {
 	return it.name
 }
 
+// whenContext returns the context to evaluate the conditions of the
+// image type for the given distro/arch.
+func (it *ImageTypeYAML) whenContext(id distro.ID, archName string) *whenContext {
+	return &whenContext{
+		id:      id,
+		arch:    archName,
+		imgType: it.name,
+		bootMode: func() platform.BootMode {
+			platforms, err := it.PlatformsFor(id)
+			if err != nil {
+				return platform.BOOT_NONE
+			}
+			for _, pl := range platforms {
+				if pl.Arch.String() == archName {
+					return platform.BootModeFor(&pl)
+				}
+			}
+			return platform.BOOT_NONE
+		},
+	}
+}
+
+// DeprecationFor returns the deprecation of the image type for the
+// given distro or nil if it is not deprecated. An image type with a
+// "since" version is only deprecated for that version and later.
+func (it *ImageTypeYAML) DeprecationFor(id distro.ID) *distro.Deprecation {
+	dep := it.Deprecated
+	if dep == nil {
+		return nil
+	}
+	if dep.Since != "" && common.VersionLessThan(versionStringForVerCmp(id), dep.Since) {
+		return nil
+	}
+	return dep
+}
+
+// RemovedFor returns true if the given distro is at or past the
+// "removal" version of the deprecation of the image type. Such image
+// types are no longer available for the distro.
+func (it *ImageTypeYAML) RemovedFor(id distro.ID) bool {
+	dep := it.Deprecated
+	if dep == nil || dep.Removal == "" {
+		return false
+	}
+	return !common.VersionLessThan(versionStringForVerCmp(id), dep.Removal)
+}
+
+// validateDeprecation checks that the versions of the deprecation can
+// be compared with the distro versions, see DeprecationFor() and
+// RemovedFor()
+func (it *ImageTypeYAML) validateDeprecation() error {
+	if it.Deprecated == nil {
+		return nil
+	}
+	for _, field := range []struct {
+		name string
+		ver  string
+	}{
+		{"since", it.Deprecated.Since},
+		{"removal", it.Deprecated.Removal},
+	} {
+		if field.ver == "" {
+			continue
+		}
+		if _, err := version.NewVersion(field.ver); err != nil {
+			return fmt.Errorf("image type %q: invalid deprecation %s version %q: %w", it.name, field.name, field.ver, err)
+		}
+	}
+	if it.Deprecated.Since != "" && it.Deprecated.Removal != "" && !common.VersionLessThan(it.Deprecated.Since, it.Deprecated.Removal) {
+		return fmt.Errorf("image type %q: deprecation removal version %q must be after the since version %q", it.name, it.Deprecated.Removal, it.Deprecated.Since)
+	}
+	return nil
+}
+
+// validatePlatformsOverride checks that the platform override
+// conditions only use fields that are known before the platform is
+// picked, see PlatformsFor()
+func (it *ImageTypeYAML) validatePlatformsOverride() error {
+	if it.PlatformsOverride == nil {
+		return nil
+	}
+	for _, name := range sortedConditionNames(it.PlatformsOverride.Conditions) {
+		if err := it.PlatformsOverride.Conditions[name].When.checkFields("arch", "boot_mode"); err != nil {
+			return fmt.Errorf("image type %q: platforms override condition %q: %w", it.name, name, err)
+		}
+	}
+	return nil
+}
+
 func (it *ImageTypeYAML) PlatformsFor(id distro.ID) ([]platform.Data, error) {
 	pl := it.InternalPlatforms
 	if it.PlatformsOverride != nil {
-		var nMatches int
-		for _, cond := range it.PlatformsOverride.Conditions {
-			// arch does not make sense for platform overrides
-			arch := ""
-			if cond.When.Eval(id, arch) {
+		// the conditions are sorted by ascending priority, the
+		// last match has the highest priority and wins, only
+		// matches with the same priority are ambiguous
+		var nMatches, matchPriority int
+		for _, name := range sortedConditionNames(it.PlatformsOverride.Conditions) {
+			cond := it.PlatformsOverride.Conditions[name]
+			// arch and boot mode do not make sense for platform
+			// overrides
+			ctx := &whenContext{id: id, imgType: it.name}
+			if cond.When.eval(ctx) {
+				if nMatches == 0 || cond.Priority != matchPriority {
+					nMatches = 0
+					matchPriority = cond.Priority
+				}
 				pl = cond.Override
 				nMatches++
 			}
 		}
 		if nMatches > 1 {
-			return nil, fmt.Errorf("platform conditionals for image type %q should match only once but matched %v times", it.Name(), nMatches)
+			return nil, fmt.Errorf("platform conditionals for image type %q should match only once but matched %v times with priority %v", it.Name(), nMatches, matchPriority)
 		}
 	}
 	return pl, nil
@@ -468,7 +863,12 @@ func (it *ImageTypeYAML) runTemplates(distro *DistroYAML) error {
 }
 
 func (it *ImageTypeYAML) setupDefaultFS(distroDefaultFS string) error {
-	subs := func(pts map[string]*disk.PartitionTable) error {
+	// read-only filesystems are created from a tree, they cannot be
+	// used for arbitrary mountpoints
+	if fsType, err := disk.NewFSType(distroDefaultFS); err == nil && fsType.ReadOnly() {
+		return fmt.Errorf("read-only filesystem %q cannot be the default filesystem of the distribution", distroDefaultFS)
+	}
+	subs := func(pts ...*disk.PartitionTable) error {
 		for _, pt := range pts {
 			err := pt.ForEachMountable(func(mnt disk.Mountable, _ []disk.Entity) error {
 				elem, ok := mnt.(*disk.Filesystem)
@@ -489,18 +889,23 @@ func (it *ImageTypeYAML) setupDefaultFS(distroDefaultFS string) error {
 		}
 		return nil
 	}
-	// we need to update both the partition tables and all
-	// partition tables overrides
-	if err := subs(it.PartitionTables); err != nil {
+	// we need to update the partition tables, all partition tables
+	// overrides and the partition tables of the data disks
+	if err := subs(slices.Collect(maps.Values(it.PartitionTables))...); err != nil {
 		return err
 	}
 	if it.PartitionTablesOverrides != nil {
 		for _, cond := range it.PartitionTablesOverrides.Conditions {
-			if err := subs(cond.Override); err != nil {
+			if err := subs(slices.Collect(maps.Values(cond.Override))...); err != nil {
 				return err
 			}
 		}
 	}
+	for _, pts := range it.DataDisks {
+		if err := subs(pts...); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -511,6 +916,7 @@ type platformsOverride struct {
 
 type conditionsPlatforms struct {
 	When     whenCondition   `yaml:"when,omitempty"`
+	Priority int             `yaml:"priority,omitempty"`
 	Override []platform.Data `yaml:"override"`
 }
 
@@ -521,6 +927,7 @@ type imageConfig struct {
 
 type conditionsImgConf struct {
 	When         whenCondition       `yaml:"when,omitempty"`
+	Priority     int                 `yaml:"priority,omitempty"`
 	ShallowMerge *distro.ImageConfig `yaml:"shallow_merge"`
 }
 
@@ -531,6 +938,7 @@ type installerConfig struct {
 
 type conditionsInstallerConf struct {
 	When         whenCondition           `yaml:"when,omitempty"`
+	Priority     int                     `yaml:"priority,omitempty"`
 	ShallowMerge *distro.InstallerConfig `yaml:"shallow_merge,omitempty"`
 }
 
@@ -541,8 +949,9 @@ type packageSet struct {
 }
 
 type pkgSetConditions struct {
-	When   whenCondition `yaml:"when,omitempty"`
-	Append struct {
+	When     whenCondition `yaml:"when,omitempty"`
+	Priority int           `yaml:"priority,omitempty"`
+	Append   struct {
 		Include []string `yaml:"include"`
 		Exclude []string `yaml:"exclude"`
 	} `yaml:"append,omitempty"`
@@ -554,6 +963,7 @@ type partitionTablesOverrides struct {
 
 type partitionTablesOverwriteCondition struct {
 	When     whenCondition                   `yaml:"when,omitempty"`
+	Priority int                             `yaml:"priority,omitempty"`
 	Override map[string]*disk.PartitionTable `yaml:"override"`
 }
 
@@ -578,6 +988,7 @@ func versionStringForVerCmp(u distro.ID) string {
 // discovered via the imagetype.
 func (imgType *ImageTypeYAML) PackageSets(id distro.ID, archName string) map[string]rpmmd.PackageSet {
 	res := make(map[string]rpmmd.PackageSet)
+	ctx := imgType.whenContext(id, archName)
 	for key, pkgSets := range imgType.PackageSetsYAML {
 		var rpmmdPkgSet rpmmd.PackageSet
 		for _, pkgSet := range pkgSets {
@@ -587,8 +998,9 @@ func (imgType *ImageTypeYAML) PackageSets(id distro.ID, archName string) map[str
 			})
 
 			if pkgSet.Conditions != nil {
-				for _, cond := range pkgSet.Conditions {
-					if cond.When.Eval(id, archName) {
+				for _, name := range sortedConditionNames(pkgSet.Conditions) {
+					cond := pkgSet.Conditions[name]
+					if cond.When.eval(ctx) {
 						rpmmdPkgSet = rpmmdPkgSet.Append(rpmmd.PackageSet{
 							Include: cond.Append.Include,
 							Exclude: cond.Append.Exclude,
@@ -617,8 +1029,10 @@ func (imgType *ImageTypeYAML) PartitionTable(id distro.ID, archName string) (*di
 	}
 
 	if imgType.PartitionTablesOverrides != nil {
-		for _, cond := range imgType.PartitionTablesOverrides.Conditions {
-			if cond.When.Eval(id, archName) {
+		ctx := imgType.whenContext(id, archName)
+		for _, name := range sortedConditionNames(imgType.PartitionTablesOverrides.Conditions) {
+			cond := imgType.PartitionTablesOverrides.Conditions[name]
+			if cond.When.eval(ctx) {
 				pt = cond.Override[archName]
 			}
 		}
@@ -627,11 +1041,19 @@ func (imgType *ImageTypeYAML) PartitionTable(id distro.ID, archName string) (*di
 	return pt, nil
 }
 
+// DataPartitionTables returns the partition tables of the additional
+// (data) disks for the given distro/imgType, if any.
+func (imgType *ImageTypeYAML) DataPartitionTables(id distro.ID, archName string) []*disk.PartitionTable {
+	return imgType.DataDisks[archName]
+}
+
 // ImageConfig returns the image type specific ImageConfig
 func (imgType *ImageTypeYAML) ImageConfig(id distro.ID, archName string) *distro.ImageConfig {
 	imgConfig := imgType.ImageConfigYAML.ImageConfig
-	for _, cond := range imgType.ImageConfigYAML.Conditions {
-		if cond.When.Eval(id, archName) {
+	ctx := imgType.whenContext(id, archName)
+	for _, name := range sortedConditionNames(imgType.ImageConfigYAML.Conditions) {
+		cond := imgType.ImageConfigYAML.Conditions[name]
+		if cond.When.eval(ctx) {
 			imgConfig = cond.ShallowMerge.InheritFrom(imgConfig)
 
 		}
@@ -645,8 +1067,10 @@ func (imgType *ImageTypeYAML) ImageConfig(id distro.ID, archName string) *distro
 // any merging in YAML
 func (imgType *ImageTypeYAML) InstallerConfig(id distro.ID, archName string) *distro.InstallerConfig {
 	installerConfig := imgType.InstallerConfigYAML.InstallerConfig
-	for _, cond := range imgType.InstallerConfigYAML.Conditions {
-		if cond.When.Eval(id, archName) {
+	ctx := imgType.whenContext(id, archName)
+	for _, name := range sortedConditionNames(imgType.InstallerConfigYAML.Conditions) {
+		cond := imgType.InstallerConfigYAML.Conditions[name]
+		if cond.When.eval(ctx) {
 			installerConfig = cond.ShallowMerge.InheritFrom(installerConfig)
 		}
 	}
@@ -7,11 +7,13 @@ import (
 	"os/exec"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v3"
 
+	"github.com/osbuild/blueprint/pkg/blueprint"
 	"github.com/osbuild/images/internal/common"
 	"github.com/osbuild/images/pkg/arch"
 	"github.com/osbuild/images/pkg/customizations/oscap"
@@ -414,6 +416,147 @@ image_types:
 	}, partTable)
 }
 
+func TestDefsDataDisks(t *testing.T) {
+	fakeDistrosYaml := `
+distros:
+  - name: test-distro-1
+    defs_path: test-distro
+    default_fs_type: ext4
+    distro_like: fedora
+`
+	fakeImageTypesYaml := `
+image_types:
+  test_type:
+    filename: test.qcow2
+    image_func: disk
+    exports: ["qcow2"]
+    platforms:
+      - arch: x86_64
+        image_format: qcow2
+    partition_table:
+      x86_64:
+        partitions:
+          - payload_type: filesystem
+            payload:
+              mountpoint: "/"
+    data_disks:
+      x86_64:
+        - size: "10 GiB"
+          partitions:
+            - payload_type: filesystem
+              payload:
+                # note that no "type: <fstype>" is set here
+                mountpoint: "/var/lib/containers"
+`
+	baseDir := makeFakeDistrosYAML(t, fakeDistrosYaml, fakeImageTypesYaml)
+	restore := defs.MockDataFS(baseDir)
+	defer restore()
+	td, err := defs.NewDistroYAML("test-distro-1")
+	require.NoError(t, err)
+	it := td.ImageTypes()["test_type"]
+	require.NotNil(t, it)
+
+	id := distro.ID{Name: "test-distro", MajorVersion: 1}
+	assert.Equal(t, []*disk.PartitionTable{
+		{
+			Size: 10 * datasizes.GiB,
+			Partitions: []disk.Partition{
+				{
+					Payload: &disk.Filesystem{
+						Type:       "ext4",
+						Mountpoint: "/var/lib/containers",
+					},
+				},
+			},
+		},
+	}, it.DataPartitionTables(id, "x86_64"))
+	assert.Empty(t, it.DataPartitionTables(id, "aarch64"))
+
+	dist := generic.DistroFactory("test-distro-1")
+	require.NotNil(t, dist)
+	ar, err := dist.GetArch("x86_64")
+	require.NoError(t, err)
+	imgType, err := ar.GetImageType("test_type")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"qcow2", "qcow2-data-1"}, imgType.Exports())
+
+	mf, _, err := imgType.Manifest(&blueprint.Blueprint{}, distro.ImageOptions{}, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, imgType.Exports(), mf.GetExports())
+
+	// the mountpoints of the data disks must not clash with the ones of
+	// the main disk
+	bp := &blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Filesystem: []blueprint.FilesystemCustomization{
+				{Mountpoint: "/var/lib/containers", MinSize: 1 * datasizes.GiB},
+			},
+		},
+	}
+	_, _, err = imgType.Manifest(bp, distro.ImageOptions{}, nil, nil)
+	assert.EqualError(t, err, `filesystem customization for "/var/lib/containers" is not allowed: the mountpoint is on data disk 1`)
+}
+
+func TestDefsDataDisksExports(t *testing.T) {
+	fakeDistrosYaml := `
+distros:
+  - name: test-distro-1
+    defs_path: test-distro
+    default_fs_type: ext4
+`
+	fakeImageTypesYaml := `
+image_types:
+  test_vhd:
+    filename: test.vhd
+    image_func: disk
+    exports: ["vpc"]
+    platforms:
+      - arch: x86_64
+        image_format: vhd
+    partition_table: &pt
+      x86_64:
+        partitions:
+          - payload_type: filesystem
+            payload:
+              mountpoint: "/"
+    data_disks: &data_disks
+      x86_64:
+        - size: "10 GiB"
+          partitions:
+            - payload_type: filesystem
+              payload:
+                mountpoint: "/var/lib/containers"
+  test_xz:
+    filename: test.raw.xz
+    image_func: disk
+    exports: ["xz"]
+    compression: xz
+    platforms:
+      - arch: x86_64
+        image_format: raw
+    partition_table: *pt
+    data_disks: *data_disks
+`
+	baseDir := makeFakeDistrosYAML(t, fakeDistrosYaml, fakeImageTypesYaml)
+	restore := defs.MockDataFS(baseDir)
+	defer restore()
+
+	dist := generic.DistroFactory("test-distro-1")
+	require.NotNil(t, dist)
+	ar, err := dist.GetArch("x86_64")
+	require.NoError(t, err)
+
+	// images that cannot have data disks do not export them
+	for name, expectedExports := range map[string][]string{
+		"test_vhd": {"vpc"},
+		"test_xz":  {"xz"},
+	} {
+		imgType, err := ar.GetImageType(name)
+		require.NoError(t, err)
+		assert.Equal(t, expectedExports, imgType.Exports())
+	}
+}
+
 func TestDefsPartitionTableFilesystemDistroDefaultErr(t *testing.T) {
 	fakeDistrosYaml := `
 distros:
@@ -441,6 +584,33 @@ image_types:
 	assert.EqualError(t, err, `mount "/" requires a default filesystem for the distribution but none set`)
 }
 
+func TestDefsPartitionTableFilesystemDistroDefaultReadOnlyErr(t *testing.T) {
+	fakeDistrosYaml := `
+distros:
+  - name: test-distro-1
+    defs_path: test-distro
+    default_fs_type: erofs
+`
+	fakeImageTypesYaml := `
+image_types:
+  test_type:
+    filename: test.img
+    platforms:
+      - arch: x86_64
+    partition_table:
+      test_arch:
+        partitions:
+          - payload_type: filesystem
+            payload:
+              mountpoint: "/"
+`
+	baseDir := makeFakeDistrosYAML(t, fakeDistrosYaml, fakeImageTypesYaml)
+	restore := defs.MockDataFS(baseDir)
+	defer restore()
+	_, err := defs.NewDistroYAML("test-distro-1")
+	assert.EqualError(t, err, `read-only filesystem "erofs" cannot be the default filesystem of the distribution`)
+}
+
 var fakeImageTypesYaml = `
 image_types:
   test_type:
@@ -1226,7 +1396,54 @@ image_types:
 	assert.Len(t, imgTypes, 1)
 	imgType := imgTypes["test_type"]
 	_, err = imgType.PlatformsFor(distro.ID)
-	assert.EqualError(t, err, `platform conditionals for image type "test_type" should match only once but matched 2 times`)
+	assert.EqualError(t, err, `platform conditionals for image type "test_type" should match only once but matched 2 times with priority 0`)
+}
+
+func TestImageTypesPlatformOverridesPriority(t *testing.T) {
+	fakeImageTypesYaml := `
+image_types:
+  test_type:
+    filename: "disk.qcow2"
+    exports: ["qcow2"]
+    platforms:
+      - arch: x86_64
+    platforms_override:
+      conditions:
+        "this is true":
+          when:
+            version_less_than: "2"
+          override:
+            - arch: x86_64
+              uefi_vendor: "uefi-for-ver-2"
+        "this is also true but wins":
+          when:
+            version_less_than: "3"
+          priority: 10
+          override:
+            - arch: x86_64
+              uefi_vendor: "uefi-for-ver-3"
+        "this is true with a lower priority":
+          when:
+            version_less_than: "4"
+          priority: 5
+          override:
+            - arch: x86_64
+              uefi_vendor: "uefi-for-ver-4"
+`
+	makeTestImageType(t, fakeImageTypesYaml)
+
+	distro, err := defs.NewDistroYAML("test-distro-1")
+	require.NoError(t, err)
+	require.NotNil(t, distro)
+	imgType := distro.ImageTypes()["test_type"]
+	platforms, err := imgType.PlatformsFor(distro.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []platform.Data{
+		{
+			Arch:       arch.ARCH_X86_64,
+			UEFIVendor: "uefi-for-ver-3",
+		},
+	}, platforms)
 }
 
 func TestDistrosLoadingMatchTransforms(t *testing.T) {
@@ -1266,3 +1483,233 @@ distros:
 		})
 	}
 }
+
+func TestDistros(t *testing.T) {
+	baseDir := makeFakeDistrosYAML(t, `
+distros:
+  - name: test-distro-1
+    defs_path: test-distro/
+  - name: "test-distro-{{.MajorVersion}}"
+    match: 'test-distro-[0-9]+'
+    versions: ["1-3", "5"]
+    defs_path: test-distro/
+  - name: "other-distro-{{.MajorVersion}}.{{.MinorVersion}}"
+    match: '(?P<name>other-distro)-(?P<major>1)\.?(?P<minor>[0-9]+)'
+    versions: ["1.9-1.10"]
+    defs_path: test-distro/
+  - name: "no-versions-{{.MajorVersion}}"
+    match: 'no-versions-[0-9]+'
+    defs_path: test-distro/
+`, "")
+	restore := defs.MockDataFS(baseDir)
+	defer restore()
+
+	names, err := defs.Distros()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"test-distro-1", "test-distro-2", "test-distro-3", "test-distro-5", "other-distro-1.9", "other-distro-1.10"}, names)
+}
+
+func TestDistrosVersionNotMatching(t *testing.T) {
+	baseDir := makeFakeDistrosYAML(t, `
+distros:
+  - name: "test-distro-{{.MajorVersion}}"
+    match: 'test-distro-1[0-9]'
+    versions: ["9-10"]
+    defs_path: test-distro/
+`, "")
+	restore := defs.MockDataFS(baseDir)
+	defer restore()
+
+	_, err := defs.Distros()
+	assert.EqualError(t, err, `version 9 of "test-distro-{{.MajorVersion}}" results in "test-distro-9" which does not match "test-distro-1[0-9]"`)
+}
+
+func TestDistrosDistrodefs(t *testing.T) {
+	names, err := defs.Distros()
+	require.NoError(t, err)
+	for _, name := range []string{"fedora-42", "fedora-43", "rhel-7.9", "rhel-8.10", "rhel-9.6", "rhel-10.0", "centos-9", "centos-10", "almalinux_kitten-10"} {
+		assert.Contains(t, names, name)
+	}
+	// every enumerated distro can be loaded
+	for _, name := range names {
+		d, err := defs.NewDistroYAML(name)
+		require.NoError(t, err, name)
+		require.NotNil(t, d, name)
+		assert.Equal(t, name, d.ID.String())
+	}
+}
+
+func TestDistroYAMLLifecycle(t *testing.T) {
+	fakeDistrosYAML := `
+distros:
+ - &rhel9
+   name: rhel-9
+   lifecycle:
+     ga: "2022-05-17"
+     end_of_maintenance: 2027-05-31
+     end_of_life: "2032-05-31"
+ - <<: *rhel9
+   name: centos-9
+   lifecycle:
+     end_of_life: "2027-05-31"
+ - name: fedora-43
+   preview: true
+ - name: broken-1
+   lifecycle:
+     ga: "May 2025"
+`
+	baseDir := makeFakeDistrosYAML(t, fakeDistrosYAML, "")
+	restore := defs.MockDataFS(baseDir)
+	defer restore()
+
+	date := func(s string) time.Time {
+		d, err := time.Parse(time.DateOnly, s)
+		require.NoError(t, err)
+		return d
+	}
+
+	for _, tc := range []struct {
+		distroNameVer string
+		expected      distro.Lifecycle
+	}{
+		{"rhel-9", distro.Lifecycle{GA: date("2022-05-17"), EndOfMaintenance: date("2027-05-31"), EndOfLife: date("2032-05-31")}},
+		{"centos-9", distro.Lifecycle{EndOfLife: date("2027-05-31")}},
+		{"fedora-43", distro.Lifecycle{Preview: true}},
+	} {
+		d, err := defs.NewDistroYAML(tc.distroNameVer)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, d.Lifecycle(), tc.distroNameVer)
+	}
+
+	_, err := defs.NewDistroYAML("broken-1")
+	assert.ErrorContains(t, err, `cannot parse lifecycle of "broken-1": invalid ga date: `)
+}
+
+func TestImageTypeDeprecation(t *testing.T) {
+	fakeDistrosYAML := `
+distros:
+ - name: "rhel-{{.MajorVersion}}.{{.MinorVersion}}"
+   match: 'rhel-(?:9|10)\.[0-9]{1,2}'
+   vendor: redhat
+   distro_like: rhel-9
+   defs_path: test-distro/
+`
+	fakeImageTypesYaml := `
+image_types:
+  old_type:
+    filename: "disk.tar"
+    image_func: "tar"
+    exports: ["archive"]
+    platforms:
+      - arch: x86_64
+    deprecated:
+      since: "9.6"
+      removal: "10.0"
+      replacement: new_type
+      reason: "old_type was renamed"
+  new_type:
+    extends: old_type
+`
+	baseDir := makeFakeDistrosYAML(t, fakeDistrosYAML, fakeImageTypesYaml)
+	restore := defs.MockDataFS(baseDir)
+	defer restore()
+
+	expected := &distro.Deprecation{
+		Since:       "9.6",
+		Removal:     "10.0",
+		Replacement: "new_type",
+		Reason:      "old_type was renamed",
+	}
+	for _, tc := range []struct {
+		distroNameVer string
+		imgType       string
+		expected      *distro.Deprecation
+	}{
+		{"rhel-9.4", "old_type", nil},
+		{"rhel-9.6", "old_type", expected},
+		{"rhel-9.8", "old_type", expected},
+		// deprecation is never inherited via "extends"
+		{"rhel-9.8", "new_type", nil},
+		{"rhel-10.0", "new_type", nil},
+	} {
+		t.Run(tc.distroNameVer+"/"+tc.imgType, func(t *testing.T) {
+			d := generic.DistroFactory(tc.distroNameVer)
+			require.NotNil(t, d)
+			a, err := d.GetArch("x86_64")
+			require.NoError(t, err)
+			it, err := a.GetImageType(tc.imgType)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, it.Deprecation())
+
+			// the deprecation is not a warning of the manifest, it
+			// must not fail the manifest generation
+			_, warnings, err := it.Manifest(&blueprint.Blueprint{}, distro.ImageOptions{}, nil, nil)
+			require.NoError(t, err)
+			assert.Empty(t, warnings)
+		})
+	}
+
+	// the image type is gone once the removal version is reached
+	for _, distroNameVer := range []string{"rhel-10.0", "rhel-10.2"} {
+		d := generic.DistroFactory(distroNameVer)
+		require.NotNil(t, d)
+		a, err := d.GetArch("x86_64")
+		require.NoError(t, err)
+		_, err = a.GetImageType("old_type")
+		assert.ErrorContains(t, err, `invalid image type: old_type`)
+	}
+	assert.Equal(t, `image type "old_type" is deprecated since 9.6 and will be removed in 10.0: old_type was renamed (use "new_type" instead)`, expected.Warning("old_type"))
+}
+
+func TestImageTypeDeprecationRemovalBeforeSince(t *testing.T) {
+	fakeDistrosYAML := `
+distros:
+ - name: "rhel-9.6"
+   vendor: redhat
+   defs_path: test-distro/
+`
+	fakeImageTypesYaml := `
+image_types:
+  old_type:
+    filename: "disk.tar"
+    image_func: "tar"
+    exports: ["archive"]
+    platforms:
+      - arch: x86_64
+    deprecated:
+      since: "9.6"
+      removal: "9.6"
+`
+	baseDir := makeFakeDistrosYAML(t, fakeDistrosYAML, fakeImageTypesYaml)
+	restore := defs.MockDataFS(baseDir)
+	defer restore()
+
+	_, err := defs.NewDistroYAML("rhel-9.6")
+	assert.EqualError(t, err, `image type "old_type": deprecation removal version "9.6" must be after the since version "9.6"`)
+}
+
+func TestImageTypeDeprecationInvalidVersion(t *testing.T) {
+	fakeDistrosYAML := `
+distros:
+ - name: "rhel-9.6"
+   vendor: redhat
+   defs_path: test-distro/
+`
+	fakeImageTypesYaml := `
+image_types:
+  old_type:
+    filename: "disk.tar"
+    image_func: "tar"
+    exports: ["archive"]
+    platforms:
+      - arch: x86_64
+    deprecated:
+      since: "9,6"
+`
+	baseDir := makeFakeDistrosYAML(t, fakeDistrosYAML, fakeImageTypesYaml)
+	restore := defs.MockDataFS(baseDir)
+	defer restore()
+
+	_, err := defs.NewDistroYAML("rhel-9.6")
+	assert.EqualError(t, err, `image type "old_type": invalid deprecation since version "9,6": Malformed version: 9,6`)
+}
@@ -0,0 +1,386 @@
+package defs
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/osbuild/images/internal/jsonschema"
+	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/customizations/fsnode"
+	"github.com/osbuild/images/pkg/disk"
+	"github.com/osbuild/images/pkg/distro"
+	"github.com/osbuild/images/pkg/manifest"
+	"github.com/osbuild/images/pkg/osbuild"
+	"github.com/osbuild/images/pkg/platform"
+)
+
+// sizeSchema matches the sizes that datasizes.Parse() understands
+// (or a plain number of bytes)
+func sizeSchema() *jsonschema.Schema {
+	zero := 0.0
+	return &jsonschema.Schema{
+		AnyOf: []*jsonschema.Schema{
+			{Type: "integer", Minimum: &zero},
+			{Type: "string", Pattern: `^\s*[0-9]+\s*(kB|KiB|MB|MiB|GB|GiB|TB|TiB)?\s*$`},
+		},
+	}
+}
+
+// enumHook is the hook for types that unmarshal from a fixed set of
+// strings. The values are checked against the unmarshaler of the type
+// so that the schema cannot get out of sync.
+func enumHook(values ...string) jsonschema.TypeHook {
+	return func(r *jsonschema.Reflector, t reflect.Type) (*jsonschema.Schema, error) {
+		enum := make([]any, 0, len(values))
+		for _, v := range values {
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal(b, reflect.New(t).Interface()); err != nil {
+				return nil, fmt.Errorf("invalid enum value %q for %v: %w", v, t, err)
+			}
+			enum = append(enum, v)
+		}
+		return &jsonschema.Schema{Type: "string", Enum: enum}, nil
+	}
+}
+
+// viaJSONHook is the hook for structs that are decoded via
+// common.UnmarshalYAMLviaJSON(), i.e. their fields are named by the
+// "json" tag. The given fields accept datasizes strings like "1 GiB".
+func viaJSONHook(sizeFields ...string) jsonschema.TypeHook {
+	return func(r *jsonschema.Reflector, t reflect.Type) (*jsonschema.Schema, error) {
+		return r.WithTag("json", func() (*jsonschema.Schema, error) {
+			s, err := r.ReflectStruct(t)
+			if err != nil {
+				return nil, err
+			}
+			for _, field := range sizeFields {
+				if _, ok := s.Properties[field]; !ok {
+					return nil, fmt.Errorf("cannot find size field %q in %v", field, t)
+				}
+				s.Properties[field] = sizeSchema()
+			}
+			return s, nil
+		})
+	}
+}
+
+// payloadHook is the hook for disk entities with a "payload", the
+// payload is described by the "payload_type" next to it (see
+// disk.unmarshalJSONPayload())
+func payloadHook(sizeFields ...string) jsonschema.TypeHook {
+	structHook := viaJSONHook(sizeFields...)
+	return func(r *jsonschema.Reflector, t reflect.Type) (*jsonschema.Schema, error) {
+		s, err := structHook(r, t)
+		if err != nil {
+			return nil, err
+		}
+		payloadTypes := disk.PayloadTypes()
+		names := make([]string, 0, len(payloadTypes))
+		for name := range payloadTypes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		// without a payload_type there must not be a payload
+		variants := []*jsonschema.Schema{
+			{
+				Properties: map[string]*jsonschema.Schema{
+					"payload_type": {Const: ""},
+					"payload":      {Type: "null"},
+				},
+			},
+		}
+		enum := []any{""}
+		for _, name := range names {
+			payload, err := r.WithTag("json", func() (*jsonschema.Schema, error) {
+				return r.ReflectType(payloadTypes[name])
+			})
+			if err != nil {
+				return nil, err
+			}
+			variants = append(variants, &jsonschema.Schema{
+				Properties: map[string]*jsonschema.Schema{
+					"payload_type": {Const: name},
+					"payload":      payload,
+				},
+				Required: []string{"payload_type"},
+			})
+			enum = append(enum, name)
+		}
+		s.Properties["payload"] = &jsonschema.Schema{}
+		s.Properties["payload_type"] = &jsonschema.Schema{Type: "string", Enum: enum}
+		s.OneOf = variants
+		return s, nil
+	}
+}
+
+// fsnodeHook is the hook for the fsnode.{File,Directory} types, the
+// common fields are unexported so they are described here
+func fsnodeHook(extra map[string]*jsonschema.Schema) jsonschema.TypeHook {
+	return func(r *jsonschema.Reflector, t reflect.Type) (*jsonschema.Schema, error) {
+		zero := 0.0
+		userOrID := &jsonschema.Schema{
+			AnyOf: []*jsonschema.Schema{
+				{Type: "string"},
+				{Type: "integer", Minimum: &zero},
+			},
+		}
+		props := map[string]*jsonschema.Schema{
+			"path":  {Type: "string"},
+			"mode":  {Type: "integer", Minimum: &zero},
+			"user":  userOrID,
+			"group": userOrID,
+		}
+		for name, prop := range extra {
+			props[name] = prop
+		}
+		return &jsonschema.Schema{
+			Type:                 "object",
+			Properties:           props,
+			Required:             []string{"path"},
+			AdditionalProperties: false,
+		}, nil
+	}
+}
+
+// anyOfHook is the hook for types that unmarshal into one of the
+// given (json) types
+func anyOfHook(types ...reflect.Type) jsonschema.TypeHook {
+	return func(r *jsonschema.Reflector, t reflect.Type) (*jsonschema.Schema, error) {
+		s := &jsonschema.Schema{}
+		for _, variant := range types {
+			vs, err := r.WithTag("json", func() (*jsonschema.Schema, error) {
+				return r.ReflectType(variant)
+			})
+			if err != nil {
+				return nil, err
+			}
+			s.AnyOf = append(s.AnyOf, vs)
+		}
+		return s, nil
+	}
+}
+
+// arrayOfHook is like anyOfHook but for list types
+func arrayOfHook(types ...reflect.Type) jsonschema.TypeHook {
+	anyOf := anyOfHook(types...)
+	return func(r *jsonschema.Reflector, t reflect.Type) (*jsonschema.Schema, error) {
+		items, err := anyOf(r, t)
+		if err != nil {
+			return nil, err
+		}
+		return &jsonschema.Schema{Type: "array", Items: items}, nil
+	}
+}
+
+// schemaHook is the hook for types with a fixed schema
+func schemaHook(s *jsonschema.Schema) jsonschema.TypeHook {
+	return func(r *jsonschema.Reflector, t reflect.Type) (*jsonschema.Schema, error) {
+		return s, nil
+	}
+}
+
+// udevRuleJSON is the format of a rule in osbuild.UdevRules when
+// unmarshaling
+type udevRuleJSON struct {
+	Rule []struct {
+		K string `json:"K"`
+		A string `json:"A,omitempty"`
+		O string `json:"O"`
+		V string `json:"V"`
+	} `json:"rule"`
+}
+
+// extraImageTypesSchemaYAML is extraImageTypesYAML with the image
+// types decoded, they are kept raw when loading (see
+// LoadExtraImageTypes()) but decoded as ImageTypeYAML later
+type extraImageTypesSchemaYAML struct {
+	When       *whenCondition           `yaml:"when,omitempty"`
+	Common     map[string]any           `yaml:".common,omitempty"`
+	ImageTypes map[string]ImageTypeYAML `yaml:"image_types"`
+}
+
+func newSchemaReflector() *jsonschema.Reflector {
+	typeOf := reflect.TypeOf
+	interfaceOf := func(v any) reflect.Type {
+		return reflect.TypeOf(v).Elem()
+	}
+
+	return &jsonschema.Reflector{
+		Hooks: map[reflect.Type]jsonschema.TypeHook{
+			// defs
+			typeOf(whenExpr{}): schemaHook(&jsonschema.Schema{Type: "string"}),
+			typeOf(imageTypeExtends{}): func(r *jsonschema.Reflector, t reflect.Type) (*jsonschema.Schema, error) {
+				obj, err := r.ReflectStruct(t)
+				if err != nil {
+					return nil, err
+				}
+				obj.Required = []string{"name"}
+				return &jsonschema.Schema{
+					AnyOf: []*jsonschema.Schema{{Type: "string"}, obj},
+				}, nil
+			},
+
+			typeOf(lifecycleYAML{}): func(r *jsonschema.Reflector, t reflect.Type) (*jsonschema.Schema, error) {
+				obj, err := r.ReflectStruct(t)
+				if err != nil {
+					return nil, err
+				}
+				for _, prop := range obj.Properties {
+					prop.Pattern = `^[0-9]{4}-[0-9]{2}-[0-9]{2}$`
+				}
+				return obj, nil
+			},
+			typeOf(distro.Deprecation{}): func(r *jsonschema.Reflector, t reflect.Type) (*jsonschema.Schema, error) {
+				obj, err := r.ReflectStruct(t)
+				if err != nil {
+					return nil, err
+				}
+				for _, name := range []string{"since", "removal"} {
+					obj.Properties[name].Pattern = `^[0-9]+(\.[0-9]+)*$`
+				}
+				return obj, nil
+			},
+
+			// enums
+			typeOf(arch.Arch(0)):               enumHook("x86_64", "amd64", "aarch64", "arm64", "s390x", "ppc64le", "riscv64"),
+			typeOf(disk.FSType(0)):             enumHook("", "vfat", "ext4", "xfs", "btrfs", "erofs", "squashfs", "f2fs"),
+			typeOf(disk.PartitionTableType(0)): enumHook("", "dos", "gpt"),
+			typeOf(manifest.Distro(0)):         enumHook("unset", "rhel-10", "rhel-9", "rhel-8", "rhel-7", "fedora"),
+			typeOf(manifest.ISOBootType(0)):    enumHook("", "grub2-uefi", "syslinux", "grub2"),
+			typeOf(manifest.ISORootfsType(0)):  enumHook("", "squashfs-ext4", "squashfs", "erofs"),
+			typeOf(platform.Bootloader(0)):     enumHook("", "none", "grub2", "zipl", "uki"),
+			typeOf(platform.ImageFormat(0)):    enumHook("unset", "raw", "iso", "qcow2", "vmdk", "vhd", "gce", "ova", "vagrant_libvirt", "vagrant_virtualbox"),
+
+			// disk
+			typeOf(disk.PartitionTable{}):   viaJSONHook("size", "start_offset"),
+			typeOf(disk.Partition{}):        payloadHook("size"),
+			typeOf(disk.LUKSContainer{}):    payloadHook(),
+			typeOf(disk.LVMVolumeGroup{}):   viaJSONHook(),
+			typeOf(disk.LVMLogicalVolume{}): payloadHook("size"),
+			typeOf(disk.BtrfsSubvolume{}):   viaJSONHook("size"),
+
+			// fsnode
+			typeOf(fsnode.Directory{}): fsnodeHook(map[string]*jsonschema.Schema{
+				"ensure_parent_dirs": {Type: "boolean"},
+			}),
+			typeOf(fsnode.File{}): fsnodeHook(map[string]*jsonschema.Schema{
+				"data": {Type: "string"},
+			}),
+
+			// osbuild stage options with polymorphic fields
+			typeOf(osbuild.ChronyConfigRefclock{}): viaJSONHook(),
+			typeOf(osbuild.PamLimitsConfigLine{}):  viaJSONHook(),
+			typeOf(osbuild.SshdConfigConfig{}):     viaJSONHook(),
+			typeOf(osbuild.ModprobeConfigCmdList{}): arrayOfHook(
+				typeOf(osbuild.ModprobeConfigCmdBlacklist{}),
+				typeOf(osbuild.ModprobeConfigCmdInstall{}),
+			),
+			typeOf(osbuild.UdevRules{}): arrayOfHook(
+				typeOf(osbuild.UdevRuleComment{}),
+				typeOf(udevRuleJSON{}),
+			),
+			interfaceOf((*osbuild.RefclockDriver)(nil)): anyOfHook(
+				typeOf(osbuild.ChronyDriverPPS{}),
+				typeOf(osbuild.ChronyDriverSHM{}),
+				typeOf(osbuild.ChronyDriverSOCK{}),
+				typeOf(osbuild.ChronyDriverPHC{}),
+			),
+			interfaceOf((*osbuild.PamLimitsValue)(nil)): schemaHook(&jsonschema.Schema{
+				AnyOf: []*jsonschema.Schema{{Type: "string"}, {Type: "integer"}},
+			}),
+			interfaceOf((*osbuild.PermitRootLoginValue)(nil)): schemaHook(&jsonschema.Schema{
+				AnyOf: []*jsonschema.Schema{{Type: "string"}, {Type: "boolean"}},
+			}),
+		},
+	}
+}
+
+// ImageTypesJSONSchema returns the JSON Schema for the imagetypes.yaml
+// files.
+func ImageTypesJSONSchema() (*jsonschema.Schema, error) {
+	return reflectSchema(imageTypesYAML{}, "imagetypes.yaml", true)
+}
+
+// DistrosJSONSchema returns the JSON Schema for the distros.yaml file.
+func DistrosJSONSchema() (*jsonschema.Schema, error) {
+	return reflectSchema(distrosYAML{}, "distros.yaml", false)
+}
+
+// ExtraImageTypesJSONSchema returns the JSON Schema for the files with
+// user defined image types (see LoadExtraImageTypes()).
+func ExtraImageTypesJSONSchema() (*jsonschema.Schema, error) {
+	return reflectSchema(extraImageTypesSchemaYAML{}, "extra image types", true)
+}
+
+// reflectSchema returns the schema for the given toplevel value, lists
+// can be given as "replace:" wrappers if the file supports "extends"
+func reflectSchema(v any, title string, withExtends bool) (*jsonschema.Schema, error) {
+	s, err := newSchemaReflector().Reflect(v)
+	if err != nil {
+		return nil, err
+	}
+	s.Title = title
+	if withExtends {
+		allowReplaceLists(s)
+	}
+	return s, nil
+}
+
+// allowReplaceLists allows all lists in the schema to be given as
+//
+//	replace: [...]
+//
+// as well, see replaceList()
+func allowReplaceLists(s *jsonschema.Schema) {
+	var lists []*jsonschema.Schema
+	s.Walk(func(sub *jsonschema.Schema) {
+		if sub.Type == "array" {
+			lists = append(lists, sub)
+		}
+	})
+	for _, l := range lists {
+		list := *l
+		*l = jsonschema.Schema{
+			AnyOf: []*jsonschema.Schema{
+				&list,
+				{
+					Type: "object",
+					Properties: map[string]*jsonschema.Schema{
+						"replace": &list,
+					},
+					Required:             []string{"replace"},
+					AdditionalProperties: false,
+				},
+			},
+		}
+	}
+}
+
+// JSONSchemaFiles returns the (indented) JSON Schemas for the distro
+// definitions keyed by their filename, these are checked in under
+// data/distrodefs/schemas so that editors and CI can use them.
+func JSONSchemaFiles() (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	for name, gen := range map[string]func() (*jsonschema.Schema, error){
+		"distros.schema.json":          DistrosJSONSchema,
+		"imagetypes.schema.json":       ImageTypesJSONSchema,
+		"extra-imagetypes.schema.json": ExtraImageTypesJSONSchema,
+	} {
+		s, err := gen()
+		if err != nil {
+			return nil, fmt.Errorf("cannot generate %s: %w", name, err)
+		}
+		b, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		files[name] = append(b, '\n')
+	}
+	return files, nil
+}
@@ -0,0 +1,134 @@
+package defs_test
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/osbuild/images/data/distrodefs"
+	"github.com/osbuild/images/pkg/distro/defs"
+)
+
+func TestJSONSchemaFilesUpToDate(t *testing.T) {
+	files, err := defs.JSONSchemaFiles()
+	require.NoError(t, err)
+
+	for name, content := range files {
+		checkedIn, err := os.ReadFile(filepath.Join("../../../data/distrodefs/schemas", name))
+		require.NoError(t, err)
+		assert.Equal(t, string(content), string(checkedIn), "%s is outdated, run: go run ./cmd/distrodefs schema", name)
+	}
+}
+
+func decodeYAMLForSchema(t *testing.T, content []byte) any {
+	t.Helper()
+	var doc any
+	require.NoError(t, yaml.Unmarshal(content, &doc))
+	return doc
+}
+
+func TestJSONSchemaValidatesDistroDefs(t *testing.T) {
+	distrosSchema, err := defs.DistrosJSONSchema()
+	require.NoError(t, err)
+	imgTypesSchema, err := defs.ImageTypesJSONSchema()
+	require.NoError(t, err)
+
+	b, err := fs.ReadFile(distrodefs.Data, "distros.yaml")
+	require.NoError(t, err)
+	assert.NoError(t, distrosSchema.Validate(decodeYAMLForSchema(t, b)))
+
+	imgTypesFiles, err := fs.Glob(distrodefs.Data, "*/imagetypes.yaml")
+	require.NoError(t, err)
+	require.NotEmpty(t, imgTypesFiles)
+	for _, p := range imgTypesFiles {
+		b, err := fs.ReadFile(distrodefs.Data, p)
+		require.NoError(t, err)
+		assert.NoError(t, imgTypesSchema.Validate(decodeYAMLForSchema(t, b)), p)
+	}
+}
+
+func TestJSONSchemaImageTypesErrors(t *testing.T) {
+	s, err := defs.ImageTypesJSONSchema()
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		content     string
+		expectedErr string
+	}{
+		{baseLayerImgTypesYAML, ""},
+		{`
+image_types:
+  test_type:
+    filenam: typo.img
+`, `/image_types/test_type: unknown property "filenam"`},
+		{`
+image_types:
+  test_type:
+    platforms:
+      - arch: x86-64
+`, `/image_types/test_type/platforms/0/arch: value "x86-64" is not one of ["x86_64" "amd64" "aarch64" "arm64" "s390x" "ppc64le" "riscv64"]`},
+		{`
+image_types:
+  test_type:
+    partition_table:
+      x86_64:
+        type: gpt
+        size: 10 GB
+        partitions:
+          - size: 1 GiB
+            payload_type: filesystem
+            payload:
+              type: xfs
+              mountpoint: /
+`, ""},
+		{`
+image_types:
+  test_type:
+    partition_table:
+      x86_64:
+        type: gpd
+        size: 10 gigs
+`, `/image_types/test_type/partition_table/x86_64/size: value "10 gigs" does not match "^\\s*[0-9]+\\s*(kB|KiB|MB|MiB|GB|GiB|TB|TiB)?\\s*$"` + "\n" +
+			`/image_types/test_type/partition_table/x86_64/type: value "gpd" is not one of ["" "dos" "gpt"]`},
+		{`
+image_types:
+  test_type:
+    deprecated:
+      since: "9,6"
+      removal: "10"
+`, `/image_types/test_type/deprecated/since: value "9,6" does not match "^[0-9]+(\\.[0-9]+)*$"`},
+		{`
+image_types:
+  test_type:
+    extends: other_type
+    image_config:
+      kernel_options:
+        replace: ["console=ttyS0"]
+`, ""},
+	} {
+		err := s.Validate(decodeYAMLForSchema(t, []byte(tc.content)))
+		if tc.expectedErr == "" {
+			assert.NoError(t, err, tc.content)
+		} else {
+			assert.EqualError(t, err, tc.expectedErr, tc.content)
+		}
+	}
+}
+
+func TestJSONSchemaExtraImageTypes(t *testing.T) {
+	s, err := defs.ExtraImageTypesJSONSchema()
+	require.NoError(t, err)
+
+	assert.NoError(t, s.Validate(decodeYAMLForSchema(t, []byte(fakeExtraImageTypesYAML))))
+	err = s.Validate(decodeYAMLForSchema(t, []byte(`
+when:
+  distro_nam: rhel
+image_types: {}
+`)))
+	assert.EqualError(t, err, `/when: unknown property "distro_nam"`)
+}
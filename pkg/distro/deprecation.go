@@ -0,0 +1,42 @@
+package distro
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Deprecation describes an image type that is deprecated and that
+// will be removed (or was renamed) in a future release.
+type Deprecation struct {
+	// Since is the distro version that deprecated the image type,
+	// e.g. "9.6"
+	Since string `yaml:"since,omitempty" json:"since,omitempty"`
+	// Removal is the distro version that will no longer have the
+	// image type
+	Removal string `yaml:"removal,omitempty" json:"removal,omitempty"`
+	// Replacement is the name of the image type that should be
+	// used instead
+	Replacement string `yaml:"replacement,omitempty" json:"replacement,omitempty"`
+	// Reason is a human readable explanation of the deprecation
+	Reason string `yaml:"reason,omitempty" json:"reason,omitempty"`
+}
+
+// Warning returns a human readable warning for the deprecation of the
+// given image type.
+func (d *Deprecation) Warning(imgTypeName string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "image type %q is deprecated", imgTypeName)
+	if d.Since != "" {
+		fmt.Fprintf(&b, " since %s", d.Since)
+	}
+	if d.Removal != "" {
+		fmt.Fprintf(&b, " and will be removed in %s", d.Removal)
+	}
+	if d.Reason != "" {
+		fmt.Fprintf(&b, ": %s", d.Reason)
+	}
+	if d.Replacement != "" {
+		fmt.Fprintf(&b, " (use %q instead)", d.Replacement)
+	}
+	return b.String()
+}
@@ -47,6 +47,9 @@ type Distro interface {
 	// Returns the ostree reference template
 	OSTreeRef() string
 
+	// Returns the support lifecycle of the distro.
+	Lifecycle() Lifecycle
+
 	// Returns a sorted list of the names of the architectures this distro
 	// supports.
 	ListArches() []string
@@ -82,6 +85,10 @@ type ImageType interface {
 	// Returns the aliases for the image type.
 	Aliases() []string
 
+	// Returns the deprecation of the image type or nil if the image
+	// type is not deprecated.
+	Deprecation() *Deprecation
+
 	// Returns the parent architecture
 	Arch() Arch
 
@@ -135,6 +142,15 @@ type ImageType interface {
 	Manifest(bp *blueprint.Blueprint, options ImageOptions, repos []rpmmd.RepoConfig, seed *int64) (*manifest.Manifest, []string, error)
 }
 
+// PartitionTableExplainer is implemented by image types that can
+// explain how their partition table is resolved, see
+// disk.ExplainPartitionTable().
+type PartitionTableExplainer interface {
+	// ExplainPartitionTable returns a report of the partition table
+	// that the image type creates for the given blueprint and options.
+	ExplainPartitionTable(bp *blueprint.Blueprint, options ImageOptions) (*disk.PartitionTableReport, error)
+}
+
 // The ImageOptions specify options for a specific image build
 type ImageOptions struct {
 	Size             uint64                     `json:"size"`
@@ -143,6 +159,12 @@ type ImageOptions struct {
 	Facts            *facts.ImageOptions        `json:"facts,omitempty"`
 	PartitioningMode partition.PartitioningMode `json:"partitioning-mode,omitempty"`
 
+	// UUIDNamespace, if set, makes the UUIDs of the disk (partitions,
+	// filesystems, LUKS containers, ...) reproducible: they are
+	// derived from the namespace (e.g. the name and version of the
+	// image) instead of the random seed of the manifest.
+	UUIDNamespace string `json:"uuid-namespace,omitempty"`
+
 	UseBootstrapContainer bool `json:"use_bootstrap_container,omitempty"`
 }
 
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io/fs"
 	"sort"
 	"text/template"
 
@@ -69,8 +70,12 @@ func (d *distribution) getISOLabelFunc(isoLabel string) isoLabelFunc {
 	}
 }
 
-func newDistro(nameVer string) (distro.Distro, error) {
-	distroYAML, err := defs.NewDistroYAML(nameVer)
+func newDistro(nameVer string, searchPaths ...fs.FS) (distro.Distro, error) {
+	return newDistroWithExtraImageTypes(nameVer, nil, searchPaths...)
+}
+
+func newDistroWithExtraImageTypes(nameVer string, extraImageTypes []*defs.ExtraImageTypes, searchPaths ...fs.FS) (distro.Distro, error) {
+	distroYAML, err := defs.NewDistroYAML(nameVer, searchPaths...)
 	if err != nil {
 		return nil, err
 	}
@@ -89,22 +94,25 @@ func newDistro(nameVer string) (distro.Distro, error) {
 		if imgTypeYAML.Filename == "" {
 			continue
 		}
-		platforms, err := imgTypeYAML.PlatformsFor(distroYAML.ID)
+		if err := rd.addImageTypeYAML(imgTypeYAML, true); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, extra := range extraImageTypes {
+		names, err := rd.DistroYAML.AddExtraImageTypes(extra)
 		if err != nil {
 			return nil, err
 		}
-		for _, pl := range platforms {
-			ar, ok := rd.arches[pl.Arch.String()]
-			if !ok {
-				ar = newArchitecture(rd, pl.Arch)
-				rd.arches[pl.Arch.String()] = ar
-			}
-			if distroYAML.SkipImageType(imgTypeYAML.Name(), pl.Arch.String()) {
-				continue
+		for _, name := range names {
+			imgTypeYAML := rd.DistroYAML.ImageTypes()[name]
+			if err := validateExtraImageType(&imgTypeYAML); err != nil {
+				return nil, fmt.Errorf("invalid image type %q from %q: %w", name, extra.Source(), err)
 			}
-			it := newImageTypeFrom(rd, ar, imgTypeYAML)
-			if err := ar.addImageType(&pl, it); err != nil {
-				return nil, err
+			// extra image types can only use the arches that
+			// the distro already supports
+			if err := rd.addImageTypeYAML(imgTypeYAML, false); err != nil {
+				return nil, fmt.Errorf("invalid image type %q from %q: %w", name, extra.Source(), err)
 			}
 		}
 	}
@@ -112,6 +120,46 @@ func newDistro(nameVer string) (distro.Distro, error) {
 	return rd, nil
 }
 
+// validateExtraImageType checks that the given user defined image
+// type can be used with the existing image implementations
+func validateExtraImageType(imgTypeYAML *defs.ImageTypeYAML) error {
+	if _, ok := imageFuncs[imgTypeYAML.Image]; !ok {
+		return fmt.Errorf("unknown image_func %q (supported: %v)", imgTypeYAML.Image, ImageFuncNames())
+	}
+	if imgTypeYAML.Filename == "" {
+		return fmt.Errorf("missing filename")
+	}
+	return nil
+}
+
+// addImageTypeYAML adds the given image type to all arches of its
+// platforms. If newArches is false the image type cannot add arches
+// to the distro.
+func (rd *distribution) addImageTypeYAML(imgTypeYAML defs.ImageTypeYAML, newArches bool) error {
+	platforms, err := imgTypeYAML.PlatformsFor(rd.DistroYAML.ID)
+	if err != nil {
+		return err
+	}
+	for _, pl := range platforms {
+		ar, ok := rd.arches[pl.Arch.String()]
+		if !ok {
+			if !newArches {
+				return fmt.Errorf("arch %q is not supported by distro %q (supported: %v)", pl.Arch, rd.Name(), rd.ListArches())
+			}
+			ar = newArchitecture(rd, pl.Arch)
+			rd.arches[pl.Arch.String()] = ar
+		}
+		if rd.DistroYAML.SkipImageType(imgTypeYAML.Name(), pl.Arch.String()) {
+			continue
+		}
+		it := newImageTypeFrom(rd, ar, imgTypeYAML)
+		if err := ar.addImageType(&pl, it); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (d *distribution) Name() string {
 	return d.DistroYAML.Name
 }
@@ -140,6 +188,10 @@ func (d *distribution) OSTreeRef() string {
 	return d.DistroYAML.OSTreeRefTmpl
 }
 
+func (d *distribution) Lifecycle() distro.Lifecycle {
+	return d.DistroYAML.Lifecycle()
+}
+
 func (d *distribution) ListArches() []string {
 	archNames := make([]string, 0, len(d.arches))
 	for name := range d.arches {
@@ -225,7 +277,47 @@ func (a *architecture) Distro() distro.Distro {
 }
 
 func DistroFactory(idStr string) distro.Distro {
-	distro, err := newDistro(idStr)
+	return distroFactory(idStr, nil)
+}
+
+// DistroFactoryWithSearchPaths returns a distro factory that loads
+// the distro definitions from the given ordered list of search
+// paths (see defs.NewDistroYAML for details about the layering).
+func DistroFactoryWithSearchPaths(searchPaths ...fs.FS) func(idStr string) distro.Distro {
+	return func(idStr string) distro.Distro {
+		return distroFactory(idStr, nil, searchPaths...)
+	}
+}
+
+// DistroFactoryWithExtraImageTypes returns a distro factory that
+// adds the given user defined image types to the distros they apply
+// to (see defs.ExtraImageTypes). The extra image types are limited
+// to the existing "image_func" implementations and the arches that
+// the distro already supports. The optional searchPaths work like
+// in DistroFactoryWithSearchPaths.
+func DistroFactoryWithExtraImageTypes(extraImageTypes []*defs.ExtraImageTypes, searchPaths ...fs.FS) func(idStr string) distro.Distro {
+	return func(idStr string) distro.Distro {
+		return distroFactory(idStr, extraImageTypes, searchPaths...)
+	}
+}
+
+// ListDistros returns the names of all distros that DistroFactory
+// can create.
+func ListDistros() ([]string, error) {
+	return defs.Distros()
+}
+
+// ListDistrosWithSearchPaths returns a function that lists the
+// names of all distros that DistroFactoryWithSearchPaths can create
+// for the same search paths.
+func ListDistrosWithSearchPaths(searchPaths ...fs.FS) func() ([]string, error) {
+	return func() ([]string, error) {
+		return defs.Distros(searchPaths...)
+	}
+}
+
+func distroFactory(idStr string, extraImageTypes []*defs.ExtraImageTypes, searchPaths ...fs.FS) distro.Distro {
+	distro, err := newDistroWithExtraImageTypes(idStr, extraImageTypes, searchPaths...)
 	if errors.Is(err, ErrDistroNotFound) {
 		return nil
 	}
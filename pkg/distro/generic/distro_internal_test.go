@@ -1,12 +1,20 @@
 package generic
 
 import (
+	"math/rand"
 	"testing"
 
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/blueprint/pkg/blueprint"
 	"github.com/osbuild/images/internal/common"
 	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/datasizes"
+	"github.com/osbuild/images/pkg/disk"
+	"github.com/osbuild/images/pkg/distro"
 	"github.com/osbuild/images/pkg/distro/defs"
-	"github.com/stretchr/testify/assert"
 )
 
 func TestISOLabel(t *testing.T) {
@@ -26,3 +34,73 @@ func TestISOLabel(t *testing.T) {
 	isoLabelFunc := d.getISOLabelFunc("iso-label")
 	assert.Equal(t, "name:rhel,major:9,minor:1,product:some-product,arch:s390x,iso-label:iso-label", isoLabelFunc(imgType))
 }
+
+func TestGetPartitionTableUUIDNamespace(t *testing.T) {
+	dist := common.Must(newDistro("rhel-10.0"))
+	a, err := dist.GetArch("x86_64")
+	require.NoError(t, err)
+	imgType, err := a.GetImageType("qcow2")
+	require.NoError(t, err)
+	it := imgType.(*imageType)
+
+	getPartitionTable := func(options distro.ImageOptions, seed int64) *disk.PartitionTable {
+		/* #nosec G404 */
+		pt, err := it.getPartitionTable(&blueprint.Customizations{}, options, rand.New(rand.NewSource(seed)))
+		require.NoError(t, err)
+		return pt
+	}
+
+	options := distro.ImageOptions{UUIDNamespace: "my-image-1.0"}
+	pt := getPartitionTable(options, 0)
+	assert.Equal(t, it.uuidNamespace(options), pt.UUIDNamespace)
+	rootFS := pt.FindMountable("/").(*disk.Filesystem)
+	assert.Equal(t, uuid.Version(5), uuid.MustParse(rootFS.UUID).Version())
+
+	// the seed does not matter
+	assert.Equal(t, pt, getPartitionTable(options, 1))
+	// but the namespace does
+	other := getPartitionTable(distro.ImageOptions{UUIDNamespace: "my-image-1.1"}, 0)
+	assert.NotEqual(t, rootFS.UUID, other.FindMountable("/").(*disk.Filesystem).UUID)
+
+	// the base partition table is not modified
+	basePT, err := it.BasePartitionTable()
+	require.NoError(t, err)
+	assert.Equal(t, uuid.Nil, basePT.UUIDNamespace)
+
+	// without a namespace the UUIDs are random
+	pt = getPartitionTable(distro.ImageOptions{}, 0)
+	assert.Equal(t, uuid.Nil, pt.UUIDNamespace)
+	assert.Equal(t, uuid.Version(4), uuid.MustParse(pt.FindMountable("/").(*disk.Filesystem).UUID).Version())
+}
+
+func TestExplainPartitionTable(t *testing.T) {
+	dist := common.Must(newDistro("rhel-10.0"))
+	a, err := dist.GetArch("x86_64")
+	require.NoError(t, err)
+	imgType, err := a.GetImageType("qcow2")
+	require.NoError(t, err)
+	explainer, ok := imgType.(distro.PartitionTableExplainer)
+	require.True(t, ok)
+
+	bp := &blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Filesystem: []blueprint.FilesystemCustomization{
+				{Mountpoint: "/var", MinSize: 5 * datasizes.GiB},
+			},
+		},
+	}
+	report, err := explainer.ExplainPartitionTable(bp, distro.ImageOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, imgType.Size(0), report.RequestedSize)
+
+	var varLV *disk.EntityReport
+	for idx, ent := range report.Entities {
+		if ent.Kind == "logical_volume" && ent.Path == "p3/rootvg/varlv" {
+			varLV = &report.Entities[idx]
+		}
+	}
+	require.NotNil(t, varLV)
+	assert.Equal(t, []disk.SizeReason{
+		{Source: disk.SizeSourceBlueprint, Detail: "filesystem /var", Size: 5 * datasizes.GiB, Driving: true},
+	}, varLV.Reasons)
+}
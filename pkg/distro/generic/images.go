@@ -484,6 +484,11 @@ func diskImage(t *imageType,
 	}
 	img.PartitionTable = pt
 
+	img.DataPartitionTables, err = t.getDataPartitionTables(pt, bp.Customizations, rng)
+	if err != nil {
+		return nil, err
+	}
+
 	img.VPCForceSize = t.ImageTypeYAML.DiskImageVPCForceSize
 
 	if img.OSCustomizations.NoBLS {
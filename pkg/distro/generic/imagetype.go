@@ -5,6 +5,10 @@ import (
 	"fmt"
 	"math/rand"
 	"slices"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
 
 	"github.com/osbuild/blueprint/pkg/blueprint"
 	"github.com/osbuild/images/internal/common"
@@ -24,6 +28,35 @@ type imageFunc func(t *imageType, bp *blueprint.Blueprint, options distro.ImageO
 
 type isoLabelFunc func(t *imageType) string
 
+// imageFuncs maps the "image_func" names from the YAML definitions
+// to their implementation
+var imageFuncs = map[string]imageFunc{
+	"disk":                     diskImage,
+	"container":                containerImage,
+	"image_installer":          imageInstallerImage,
+	"live_installer":           liveInstallerImage,
+	"bootable_container":       bootableContainerImage,
+	"iot":                      iotImage,
+	"iot_commit":               iotCommitImage,
+	"iot_container":            iotContainerImage,
+	"iot_installer":            iotInstallerImage,
+	"iot_simplified_installer": iotSimplifiedInstallerImage,
+	"tar":                      tarImage,
+	"netinst":                  netinstImage,
+	"pxe_tar":                  pxeTarImage,
+}
+
+// ImageFuncNames returns the sorted names of all supported
+// "image_func" values for the YAML definitions.
+func ImageFuncNames() []string {
+	names := make([]string, 0, len(imageFuncs))
+	for name := range imageFuncs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // imageType implements the distro.ImageType interface
 var _ = distro.ImageType(&imageType{})
 
@@ -43,37 +76,12 @@ func newImageTypeFrom(d *distribution, ar *architecture, imgYAML defs.ImageTypeY
 		isoLabel:      d.getISOLabelFunc(imgYAML.ISOLabel),
 	}
 
-	switch imgYAML.Image {
-	case "disk":
-		it.image = diskImage
-	case "container":
-		it.image = containerImage
-	case "image_installer":
-		it.image = imageInstallerImage
-	case "live_installer":
-		it.image = liveInstallerImage
-	case "bootable_container":
-		it.image = bootableContainerImage
-	case "iot":
-		it.image = iotImage
-	case "iot_commit":
-		it.image = iotCommitImage
-	case "iot_container":
-		it.image = iotContainerImage
-	case "iot_installer":
-		it.image = iotInstallerImage
-	case "iot_simplified_installer":
-		it.image = iotSimplifiedInstallerImage
-	case "tar":
-		it.image = tarImage
-	case "netinst":
-		it.image = netinstImage
-	case "pxe_tar":
-		it.image = pxeTarImage
-	default:
+	imgFunc, ok := imageFuncs[imgYAML.Image]
+	if !ok {
 		err := fmt.Errorf("unknown image func: %v for %v", imgYAML.Image, imgYAML.Name())
 		panic(err)
 	}
+	it.image = imgFunc
 
 	return it
 }
@@ -86,6 +94,10 @@ func (t *imageType) Aliases() []string {
 	return t.ImageTypeYAML.NameAliases
 }
 
+func (t *imageType) Deprecation() *distro.Deprecation {
+	return t.ImageTypeYAML.DeprecationFor(t.arch.distro.ID)
+}
+
 func (t *imageType) Arch() distro.Arch {
 	return t.arch
 }
@@ -133,21 +145,40 @@ func (t *imageType) PayloadPackageSets() []string {
 }
 
 func (t *imageType) Exports() []string {
-	if len(t.ImageTypeYAML.Exports) > 0 {
-		return t.ImageTypeYAML.Exports
+	if len(t.ImageTypeYAML.Exports) == 0 {
+		return []string{"assembler"}
+	}
+
+	// data disks are exported next to the main disk, if the image
+	// can have them at all (see image.DiskImage)
+	exports := slices.Clone(t.ImageTypeYAML.Exports)
+	if !t.hasDataDisks() {
+		return exports
 	}
-	return []string{"assembler"}
+	dataPartitionTables := t.ImageTypeYAML.DataPartitionTables(t.arch.distro.ID, t.arch.arch.String())
+	for idx := 1; idx <= len(dataPartitionTables); idx++ {
+		for _, exp := range t.ImageTypeYAML.Exports {
+			exports = append(exports, manifest.DataDiskPipelineName(exp, idx))
+		}
+	}
+	return exports
+}
+
+// hasDataDisks returns true if the images of the image type are
+// written together with their data disks, this is only the case for
+// disk images in a format that supports them.
+func (t *imageType) hasDataDisks() bool {
+	if len(t.ImageTypeYAML.DataPartitionTables(t.arch.distro.ID, t.arch.arch.String())) == 0 {
+		return false
+	}
+	if t.ImageTypeYAML.Image != "disk" {
+		return false
+	}
+	return image.CheckDataDisks(t.platform.GetImageFormat(), t.ImageTypeYAML.Compression) == nil
 }
 
 func (t *imageType) BootMode() platform.BootMode {
-	if t.platform.GetUEFIVendor() != "" && t.platform.GetBIOSPlatform() != "" {
-		return platform.BOOT_HYBRID
-	} else if t.platform.GetUEFIVendor() != "" {
-		return platform.BOOT_UEFI
-	} else if t.platform.GetBIOSPlatform() != "" || t.platform.GetZiplSupport() {
-		return platform.BOOT_LEGACY
-	}
-	return platform.BOOT_NONE
+	return platform.BootModeFor(t.platform)
 }
 
 func (t *imageType) BasePartitionTable() (*disk.PartitionTable, error) {
@@ -166,6 +197,7 @@ func (t *imageType) getPartitionTable(customizations *blueprint.Customizations,
 		return nil, err
 	}
 
+	uuidNamespace := t.uuidNamespace(options)
 	defaultFsType := t.arch.distro.DefaultFSType
 	if partitioning != nil {
 		// Use the new custom partition table to create a PT fully based on the user's customizations.
@@ -182,14 +214,127 @@ func (t *imageType) getPartitionTable(customizations *blueprint.Customizations,
 			DefaultFSType:      defaultFsType,
 			RequiredMinSizes:   t.ImageTypeYAML.RequiredPartitionSizes,
 			Architecture:       t.platform.GetArch(),
+			UUIDNamespace:      uuidNamespace,
 		}
 		return disk.NewCustomPartitionTable(partitioning, partOptions, rng)
 	}
 
+	if uuidNamespace != uuid.Nil {
+		// the base partition table is shared, do not modify it
+		basePartitionTable = basePartitionTable.Clone().(*disk.PartitionTable)
+		basePartitionTable.UUIDNamespace = uuidNamespace
+	}
+
 	mountpoints := customizations.GetFilesystems()
 	return disk.NewPartitionTable(basePartitionTable, mountpoints, imageSize, options.PartitioningMode, t.platform.GetArch(), t.ImageTypeYAML.RequiredPartitionSizes, defaultFsType.String(), rng)
 }
 
+// ExplainPartitionTable implements distro.PartitionTableExplainer.
+func (t *imageType) ExplainPartitionTable(bp *blueprint.Blueprint, options distro.ImageOptions) (*disk.PartitionTableReport, error) {
+	if t.ImageTypeYAML.PartitionTables == nil {
+		return nil, fmt.Errorf("image type %q has no partition table", t.Name())
+	}
+	if bp == nil {
+		bp = &blueprint.Blueprint{}
+	}
+	customizations := bp.Customizations
+
+	// the UUIDs are not part of the report, the seed does not matter
+	/* #nosec G404 */
+	pt, err := t.getPartitionTable(customizations, options, rand.New(rand.NewSource(0)))
+	if err != nil {
+		return nil, err
+	}
+
+	explainOptions := &disk.ExplainOptions{
+		Mode:          options.PartitioningMode,
+		ImageSize:     t.Size(options.Size),
+		RequiredSizes: t.ImageTypeYAML.RequiredPartitionSizes,
+	}
+	partitioning, err := customizations.GetPartitioning()
+	if err != nil {
+		return nil, err
+	}
+	if partitioning != nil {
+		explainOptions.Disk = partitioning
+		if options.Size == 0 {
+			explainOptions.ImageSize = partitioning.MinSize
+		}
+	} else {
+		explainOptions.BasePartitionTable, err = t.BasePartitionTable()
+		if err != nil {
+			return nil, err
+		}
+		explainOptions.Mountpoints = customizations.GetFilesystems()
+	}
+	return disk.ExplainPartitionTable(pt, explainOptions), nil
+}
+
+// getDataPartitionTables returns the partition tables of the data disks
+// of the image type. The mountpoints of the data disks must not clash
+// with the ones of the partition table of the main disk (pt) or of the
+// other data disks. The mountpoints of the data disks cannot be
+// customized, filesystem customizations for them are rejected.
+func (t *imageType) getDataPartitionTables(pt *disk.PartitionTable, customizations *blueprint.Customizations, rng *rand.Rand) ([]*disk.PartitionTable, error) {
+	basePartitionTables := t.ImageTypeYAML.DataPartitionTables(t.arch.distro.ID, t.arch.arch.String())
+	if len(basePartitionTables) == 0 {
+		return nil, nil
+	}
+
+	for idx, basePartitionTable := range basePartitionTables {
+		for _, fs := range customizations.GetFilesystems() {
+			if basePartitionTable.ContainsMountpoint(fs.Mountpoint) {
+				return nil, fmt.Errorf("filesystem customization for %q is not allowed: the mountpoint is on data disk %d", fs.Mountpoint, idx+1)
+			}
+		}
+	}
+
+	mountpoints := make(map[string]bool)
+	collect := func(mnt disk.Mountable, _ []disk.Entity) error {
+		mountpoint := mnt.GetMountpoint()
+		if mountpoints[mountpoint] {
+			return fmt.Errorf("mountpoint %q of a data disk is already used", mountpoint)
+		}
+		mountpoints[mountpoint] = true
+		return nil
+	}
+	if err := pt.ForEachMountable(collect); err != nil {
+		return nil, err
+	}
+
+	dataPartitionTables := make([]*disk.PartitionTable, 0, len(basePartitionTables))
+	for idx, basePartitionTable := range basePartitionTables {
+		if pt.UUIDNamespace != uuid.Nil {
+			// every data disk gets its own namespace so that the
+			// UUIDs do not clash with the ones of the main disk
+			basePartitionTable = basePartitionTable.Clone().(*disk.PartitionTable)
+			basePartitionTable.UUIDNamespace = uuid.NewSHA1(pt.UUIDNamespace, []byte(fmt.Sprintf("data-disk:%d", idx)))
+		}
+		dataPT, err := disk.NewDataPartitionTable(basePartitionTable, rng)
+		if err != nil {
+			return nil, err
+		}
+		if err := dataPT.ForEachMountable(collect); err != nil {
+			return nil, err
+		}
+		dataPartitionTables = append(dataPartitionTables, dataPT)
+	}
+	return dataPartitionTables, nil
+}
+
+// uuidNamespace returns the namespace for the deterministic UUIDs of
+// the partition tables of the image (see disk.PartitionTable) or
+// uuid.Nil if the options do not set a UUID namespace. The namespace
+// includes the distro, architecture and image type so that different
+// images built with the same options get distinct UUIDs.
+func (t *imageType) uuidNamespace(options distro.ImageOptions) uuid.UUID {
+	if options.UUIDNamespace == "" {
+		return uuid.Nil
+	}
+	name := strings.Join([]string{t.arch.distro.Name(), t.arch.Name(), t.Name(), options.UUIDNamespace}, "/")
+	return uuid.NewSHA1(uuid.Nil, []byte(name))
+}
+
 func (t *imageType) getDefaultImageConfig() *distro.ImageConfig {
 	imageConfig := t.ImageConfig(t.arch.distro.ID, t.arch.arch.String())
 	return imageConfig.InheritFrom(t.arch.distro.ImageConfig())
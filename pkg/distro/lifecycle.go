@@ -0,0 +1,65 @@
+package distro
+
+import (
+	"time"
+)
+
+// SupportPhase is the phase of the support lifecycle that a distro is
+// in at a given time.
+type SupportPhase string
+
+const (
+	// The lifecycle of the distro is not known
+	SupportPhaseUnknown SupportPhase = "unknown"
+	// The distro is not released yet
+	SupportPhasePreview SupportPhase = "preview"
+	// The distro is released and fully supported
+	SupportPhaseFull SupportPhase = "full"
+	// The distro only gets maintenance (e.g. security) updates
+	SupportPhaseMaintenance SupportPhase = "maintenance"
+	// The distro reached its end of life
+	SupportPhaseEOL SupportPhase = "eol"
+)
+
+// Lifecycle describes the support lifecycle of a distro. Dates that
+// are not known are left zero.
+type Lifecycle struct {
+	// Preview is set for distros that are not released yet
+	Preview bool
+
+	// GA is the date of the general availability
+	GA time.Time
+	// EndOfMaintenance is the date when full support ends and
+	// only maintenance updates are provided
+	EndOfMaintenance time.Time
+	// EndOfLife is the date when the distro stops getting any
+	// updates
+	EndOfLife time.Time
+}
+
+// Phase returns the support phase of the distro at the given time.
+func (l Lifecycle) Phase(now time.Time) SupportPhase {
+	switch {
+	case !l.EndOfLife.IsZero() && !now.Before(l.EndOfLife):
+		return SupportPhaseEOL
+	case l.Preview:
+		return SupportPhasePreview
+	case !l.GA.IsZero() && now.Before(l.GA):
+		return SupportPhasePreview
+	case !l.EndOfMaintenance.IsZero() && !now.Before(l.EndOfMaintenance):
+		return SupportPhaseMaintenance
+	case l.GA.IsZero() && l.EndOfMaintenance.IsZero() && l.EndOfLife.IsZero():
+		return SupportPhaseUnknown
+	}
+	return SupportPhaseFull
+}
+
+// Supported returns true if the distro is released and gets full or
+// maintenance support at the given time.
+func (l Lifecycle) Supported(now time.Time) bool {
+	switch l.Phase(now) {
+	case SupportPhaseFull, SupportPhaseMaintenance:
+		return true
+	}
+	return false
+}
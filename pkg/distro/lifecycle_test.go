@@ -0,0 +1,53 @@
+package distro_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/osbuild/images/pkg/distro"
+)
+
+func mustParseDate(t *testing.T, s string) time.Time {
+	t.Helper()
+	d, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestLifecyclePhase(t *testing.T) {
+	lc := distro.Lifecycle{
+		GA:               mustParseDate(t, "2022-05-17"),
+		EndOfMaintenance: mustParseDate(t, "2027-05-31"),
+		EndOfLife:        mustParseDate(t, "2032-05-31"),
+	}
+
+	for _, tc := range []struct {
+		lc                *distro.Lifecycle
+		now               string
+		expectedPhase     distro.SupportPhase
+		expectedSupported bool
+	}{
+		{nil, "2020-01-01", distro.SupportPhasePreview, false},
+		{nil, "2022-05-17", distro.SupportPhaseFull, true},
+		{nil, "2027-05-30", distro.SupportPhaseFull, true},
+		{nil, "2027-05-31", distro.SupportPhaseMaintenance, true},
+		{nil, "2032-05-31", distro.SupportPhaseEOL, false},
+		{&distro.Lifecycle{}, "2020-01-01", distro.SupportPhaseUnknown, false},
+		{&distro.Lifecycle{Preview: true}, "2020-01-01", distro.SupportPhasePreview, false},
+		{&distro.Lifecycle{GA: lc.GA}, "2040-01-01", distro.SupportPhaseFull, true},
+		{&distro.Lifecycle{EndOfLife: lc.EndOfLife}, "2020-01-01", distro.SupportPhaseFull, true},
+		{&distro.Lifecycle{Preview: true, EndOfLife: lc.EndOfLife}, "2040-01-01", distro.SupportPhaseEOL, false},
+	} {
+		l := lc
+		if tc.lc != nil {
+			l = *tc.lc
+		}
+		now := mustParseDate(t, tc.now)
+		assert.Equal(t, tc.expectedPhase, l.Phase(now), "%+v at %s", l, tc.now)
+		assert.Equal(t, tc.expectedSupported, l.Supported(now), "%+v at %s", l, tc.now)
+	}
+}
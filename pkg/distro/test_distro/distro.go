@@ -103,6 +103,10 @@ func (d *TestDistro) OSTreeRef() string {
 	return d.ostreeRef
 }
 
+func (d *TestDistro) Lifecycle() distro.Lifecycle {
+	return distro.Lifecycle{} // not supported
+}
+
 func (d *TestDistro) ListArches() []string {
 	archs := make([]string, 0, len(d.arches))
 	for name := range d.arches {
@@ -180,6 +184,10 @@ func (t *TestImageType) Aliases() []string {
 	return t.aliases
 }
 
+func (t *TestImageType) Deprecation() *distro.Deprecation {
+	return nil
+}
+
 func (t *TestImageType) Arch() distro.Arch {
 	return t.architecture
 }
@@ -2,12 +2,16 @@ package distrofactory
 
 import (
 	"fmt"
+	"io/fs"
+	"slices"
 	"sort"
 
 	"github.com/osbuild/images/pkg/distro"
 	"github.com/osbuild/images/pkg/distro/bootc"
+	"github.com/osbuild/images/pkg/distro/defs"
 	"github.com/osbuild/images/pkg/distro/generic"
 	"github.com/osbuild/images/pkg/distro/test_distro"
+	"github.com/osbuild/images/pkg/distrosort"
 )
 
 // FactoryFunc is a function that returns a distro.Distro for a given distro
@@ -15,9 +19,14 @@ import (
 // be detected by the factory, it should return nil.
 type FactoryFunc func(idStr string) distro.Distro
 
+// ListerFunc is a function that returns the names of all distros that
+// a FactoryFunc can create.
+type ListerFunc func() ([]string, error)
+
 // Factory is a list of distro.Distro factories.
 type Factory struct {
 	factories []FactoryFunc
+	listers   []ListerFunc
 
 	// distro ID string aliases
 	aliases map[string]string
@@ -95,6 +104,34 @@ func (f *Factory) RegisterAliases(aliases map[string]string) error {
 	return nil
 }
 
+// RegisterListers adds the given functions that are used by
+// ListDistros to enumerate the known distros.
+func (f *Factory) RegisterListers(listers ...ListerFunc) {
+	f.listers = append(f.listers, listers...)
+}
+
+// ListDistros returns the sorted names of all distros known to the
+// registered listers. Distros of factories without a lister (e.g.
+// bootc, which is based on container refs) are not included.
+func (f *Factory) ListDistros() ([]string, error) {
+	var names []string
+	for _, lister := range f.listers {
+		l, err := lister()
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range l {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	if err := distrosort.Names(names); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
 // New returns a Factory of distro.Distro factories for the given distros.
 func New(factories ...FactoryFunc) *Factory {
 	return &Factory{
@@ -105,10 +142,39 @@ func New(factories ...FactoryFunc) *Factory {
 // NewDefault returns a Factory of distro.Distro factories for all supported
 // distros.
 func NewDefault() *Factory {
-	return New(
+	f := New(
 		generic.DistroFactory,
 		bootc.DistroFactory,
 	)
+	f.RegisterListers(generic.ListDistros)
+	return f
+}
+
+// NewDefaultWithSearchPaths returns a Factory of distro.Distro
+// factories for all supported distros. The YAML based distros are
+// loaded from the given ordered list of search paths, later search
+// paths are layered on top of earlier ones. To extend the builtin
+// definitions pass distrodefs.Data as the first search path.
+func NewDefaultWithSearchPaths(searchPaths ...fs.FS) *Factory {
+	f := New(
+		generic.DistroFactoryWithSearchPaths(searchPaths...),
+		bootc.DistroFactory,
+	)
+	f.RegisterListers(generic.ListDistrosWithSearchPaths(searchPaths...))
+	return f
+}
+
+// NewDefaultWithExtraImageTypes returns a Factory of distro.Distro
+// factories for all supported distros where the YAML based distros
+// also contain the given user defined image types. The optional
+// searchPaths work like in NewDefaultWithSearchPaths.
+func NewDefaultWithExtraImageTypes(extraImageTypes []*defs.ExtraImageTypes, searchPaths ...fs.FS) *Factory {
+	f := New(
+		generic.DistroFactoryWithExtraImageTypes(extraImageTypes, searchPaths...),
+		bootc.DistroFactory,
+	)
+	f.RegisterListers(generic.ListDistrosWithSearchPaths(searchPaths...))
+	return f
 }
 
 // NewTestDefault returns a Factory of distro.Distro factory for the test_distro.
@@ -1,6 +1,7 @@
 package distrofactory
 
 import (
+	"slices"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -136,3 +137,27 @@ func TestGetDistroDefaultListWithAliases(t *testing.T) {
 	}
 
 }
+
+func TestListDistrosDefault(t *testing.T) {
+	df := NewDefault()
+	names, err := df.ListDistros()
+	assert.NoError(t, err)
+	for _, name := range []string{"centos-9", "fedora-42", "rhel-8.10", "rhel-9.6", "rhel-10.0"} {
+		assert.Contains(t, names, name)
+	}
+	for _, name := range names {
+		d := df.GetDistro(name)
+		if assert.NotNil(t, d, name) {
+			assert.Equal(t, name, d.Name())
+		}
+	}
+	// sorted by name and version
+	assert.Less(t, slices.Index(names, "rhel-9.6"), slices.Index(names, "rhel-10.0"))
+}
+
+func TestListDistrosNoListers(t *testing.T) {
+	df := NewTestDefault()
+	names, err := df.ListDistros()
+	assert.NoError(t, err)
+	assert.Empty(t, names)
+}
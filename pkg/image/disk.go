@@ -19,7 +19,11 @@ import (
 type DiskImage struct {
 	Base
 
-	PartitionTable   *disk.PartitionTable
+	PartitionTable *disk.PartitionTable
+	// DataPartitionTables are the partition tables of additional
+	// disks, every disk results in its own disk image
+	DataPartitionTables []*disk.PartitionTable
+
 	OSCustomizations manifest.OSCustomizations
 	Environment      environment.Environment
 	Compression      string
@@ -41,22 +45,50 @@ func NewDiskImage(platform platform.Platform, filename string) *DiskImage {
 	}
 }
 
+// CheckDataDisks returns an error if disk images with the given format
+// and compression cannot have data disks. Only raw and qcow2 images
+// without compression are written next to their data disks.
+func CheckDataDisks(format platform.ImageFormat, compression string) error {
+	switch format {
+	case platform.FORMAT_RAW, platform.FORMAT_QCOW2:
+	default:
+		return fmt.Errorf("data disks are not supported for image format %s", format)
+	}
+	if compression != "" {
+		return fmt.Errorf("data disks are not supported with compression %q", compression)
+	}
+	return nil
+}
+
 func (img *DiskImage) InstantiateManifest(m *manifest.Manifest,
 	repos []rpmmd.RepoConfig,
 	runner runner.Runner,
 	rng *rand.Rand) (*artifact.Artifact, error) {
 
+	if len(img.DataPartitionTables) > 0 {
+		if err := CheckDataDisks(img.platform.GetImageFormat(), img.Compression); err != nil {
+			return nil, err
+		}
+	}
+
 	buildPipeline := addBuildBootstrapPipelines(m, runner, repos, nil)
 	buildPipeline.Checkpoint()
 
 	osPipeline := manifest.NewOS(buildPipeline, img.platform, repos)
 	osPipeline.PartitionTable = img.PartitionTable
+	osPipeline.DataPartitionTables = img.DataPartitionTables
 	osPipeline.OSCustomizations = img.OSCustomizations
 	osPipeline.Environment = img.Environment
 	osPipeline.OSProduct = img.OSProduct
 	osPipeline.OSVersion = img.OSVersion
 	osPipeline.OSNick = img.OSNick
 
+	// the images of read-only filesystems are created from the tree
+	// before the disk images they are written to
+	for _, pt := range append([]*disk.PartitionTable{img.PartitionTable}, img.DataPartitionTables...) {
+		manifest.NewReadOnlyFSImages(buildPipeline, osPipeline, pt)
+	}
+
 	rawImagePipeline := manifest.NewRawImage(buildPipeline, osPipeline)
 	rawImagePipeline.PartTool = img.PartTool
 
@@ -122,5 +154,22 @@ func (img *DiskImage) InstantiateManifest(m *manifest.Manifest,
 	compressionPipeline := GetCompressionPipeline(img.Compression, buildPipeline, imagePipeline)
 	compressionPipeline.SetFilename(img.filename)
 
+	// every data disk gets its own pipelines and export, named after the
+	// ones of the main disk, e.g. "qcow2-data-1"
+	for idx := 1; idx <= len(img.DataPartitionTables); idx++ {
+		rawDataPipeline := manifest.NewRawDataImage(buildPipeline, rawImagePipeline, idx)
+		var dataImagePipeline manifest.FilePipeline
+		switch img.platform.GetImageFormat() {
+		case platform.FORMAT_RAW:
+			dataImagePipeline = rawDataPipeline
+		case platform.FORMAT_QCOW2:
+			qcow2Pipeline := manifest.NewDataDiskQCOW2(buildPipeline, rawDataPipeline)
+			qcow2Pipeline.Compat = img.platform.GetQCOW2Compat()
+			dataImagePipeline = qcow2Pipeline
+		}
+		dataImagePipeline.SetFilename(manifest.DataDiskFilename(img.filename, idx))
+		dataImagePipeline.Export()
+	}
+
 	return compressionPipeline.Export(), nil
 }
@@ -0,0 +1,189 @@
+package image_test
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/testdisk"
+	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/datasizes"
+	"github.com/osbuild/images/pkg/disk"
+	"github.com/osbuild/images/pkg/image"
+	"github.com/osbuild/images/pkg/manifest"
+	"github.com/osbuild/images/pkg/platform"
+	"github.com/osbuild/images/pkg/rpmmd"
+	"github.com/osbuild/images/pkg/runner"
+)
+
+func makeDataPartitionTable(t *testing.T, rng *rand.Rand, mountpoint string) *disk.PartitionTable {
+	pt, err := disk.NewDataPartitionTable(&disk.PartitionTable{
+		Size: 20 * datasizes.GiB,
+		Type: disk.PT_GPT,
+		Partitions: []disk.Partition{
+			{
+				Type: disk.FilesystemDataGUID,
+				Payload: &disk.Filesystem{
+					Type:         "xfs",
+					Mountpoint:   mountpoint,
+					FSTabOptions: "defaults",
+				},
+			},
+		},
+	}, rng)
+	require.NoError(t, err)
+	return pt
+}
+
+func TestDiskImageDataDisks(t *testing.T) {
+	rng := rand.New(rand.NewSource(0)) // nolint:gosec
+
+	for _, tc := range []struct {
+		format          platform.ImageFormat
+		expectedExports []string
+	}{
+		{platform.FORMAT_RAW, []string{"image", "image-data-1", "image-data-2"}},
+		{platform.FORMAT_QCOW2, []string{"qcow2", "qcow2-data-1", "qcow2-data-2"}},
+	} {
+		expectedFilenames := []string{"disk.img", "disk-data-1.img", "disk-data-2.img"}
+		t.Run(tc.format.String(), func(t *testing.T) {
+			pf := &platform.Data{
+				Arch:         arch.ARCH_X86_64,
+				BIOSPlatform: "i386-pc",
+				ImageFormat:  tc.format,
+			}
+			pt := testdisk.MakeFakePartitionTable("/", "/boot")
+			img := image.NewDiskImage(pf, "disk.img")
+			img.PartitionTable = pt
+			img.DataPartitionTables = []*disk.PartitionTable{
+				makeDataPartitionTable(t, rng, "/var/lib/containers"),
+				makeDataPartitionTable(t, rng, "/srv"),
+			}
+
+			mf := manifest.New()
+			art, err := img.InstantiateManifest(&mf, []rpmmd.RepoConfig{}, &runner.Fedora{Version: 42}, rng)
+			require.NoError(t, err)
+			assert.Equal(t, "disk.img", art.Filename())
+			assert.Equal(t, tc.expectedExports, mf.GetExports())
+			assert.Subset(t, mf.PayloadPipelines(), tc.expectedExports)
+			// every disk is exported with its own file
+			var filenames []string
+			for _, exp := range mf.GetExports() {
+				filenames = append(filenames, mf.GetPipeline(exp).(manifest.FilePipeline).Filename())
+			}
+			assert.Equal(t, expectedFilenames, filenames)
+		})
+	}
+}
+
+func TestDiskImageDataDisksCopy(t *testing.T) {
+	rng := rand.New(rand.NewSource(0)) // nolint:gosec
+
+	pf := &platform.Data{
+		Arch:        arch.ARCH_X86_64,
+		ImageFormat: platform.FORMAT_RAW,
+	}
+	img := image.NewDiskImage(pf, "disk.img")
+	img.PartitionTable = testdisk.MakeFakePartitionTable("/", "/boot")
+	img.DataPartitionTables = []*disk.PartitionTable{makeDataPartitionTable(t, rng, "/var/lib/containers")}
+
+	var mf struct {
+		Pipelines []struct {
+			Name   string `json:"name"`
+			Stages []struct {
+				Type    string `json:"type"`
+				Options struct {
+					Paths []struct {
+						From string `json:"from"`
+						To   string `json:"to"`
+					} `json:"paths"`
+				} `json:"options"`
+				Mounts []struct {
+					Target string `json:"target"`
+				} `json:"mounts"`
+			} `json:"stages"`
+		} `json:"pipelines"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(instantiateAndSerialize(t, img, mockPackageSets(), nil, nil)), &mf))
+
+	var checked []string
+	for _, pipeline := range mf.Pipelines {
+		switch pipeline.Name {
+		case "image":
+			// the tree is copied once, the filesystem of the data disk
+			// is mounted at its mountpoint
+			var targets []string
+			for _, stage := range pipeline.Stages {
+				if stage.Type != "org.osbuild.copy" {
+					continue
+				}
+				for _, mount := range stage.Mounts {
+					targets = append(targets, mount.Target)
+				}
+			}
+			assert.Equal(t, []string{"/", "/boot", "/var/lib/containers"}, targets)
+		case "image-data-1":
+			// the data disk is taken from the image pipeline
+			require.Len(t, pipeline.Stages, 1)
+			assert.Equal(t, "org.osbuild.copy", pipeline.Stages[0].Type)
+			require.Len(t, pipeline.Stages[0].Options.Paths, 1)
+			assert.Equal(t, "input://image/disk-data-1.img", pipeline.Stages[0].Options.Paths[0].From)
+			assert.Equal(t, "tree:///disk-data-1.img", pipeline.Stages[0].Options.Paths[0].To)
+		default:
+			continue
+		}
+		checked = append(checked, pipeline.Name)
+	}
+	assert.Equal(t, []string{"image", "image-data-1"}, checked)
+}
+
+func TestDiskImageDataDisksUnsupported(t *testing.T) {
+	rng := rand.New(rand.NewSource(0)) // nolint:gosec
+
+	pf := &platform.Data{
+		Arch:        arch.ARCH_X86_64,
+		ImageFormat: platform.FORMAT_VHD,
+	}
+	img := image.NewDiskImage(pf, "disk.vhd")
+	img.PartitionTable = testdisk.MakeFakePartitionTable("/")
+	img.DataPartitionTables = []*disk.PartitionTable{makeDataPartitionTable(t, rng, "/var/lib/containers")}
+
+	mf := manifest.New()
+	_, err := img.InstantiateManifest(&mf, []rpmmd.RepoConfig{}, &runner.Fedora{Version: 42}, rng)
+	assert.EqualError(t, err, "data disks are not supported for image format vhd")
+
+	pf.ImageFormat = platform.FORMAT_QCOW2
+	img.Compression = "xz"
+	mf = manifest.New()
+	_, err = img.InstantiateManifest(&mf, []rpmmd.RepoConfig{}, &runner.Fedora{Version: 42}, rng)
+	assert.EqualError(t, err, `data disks are not supported with compression "xz"`)
+}
+
+func TestDiskImageReadOnlyFS(t *testing.T) {
+	rng := rand.New(rand.NewSource(0)) // nolint:gosec
+
+	pf := &platform.Data{
+		Arch:         arch.ARCH_X86_64,
+		BIOSPlatform: "i386-pc",
+		ImageFormat:  platform.FORMAT_QCOW2,
+	}
+	pt := testdisk.MakeFakePartitionTable("/", "/boot")
+	pt.Partitions = append(pt.Partitions, disk.Partition{
+		Size: 5 * datasizes.GiB,
+		Payload: &disk.Filesystem{
+			Type:       "erofs",
+			Mountpoint: "/usr",
+		},
+	})
+	img := image.NewDiskImage(pf, "disk.qcow2")
+	img.PartitionTable = pt
+
+	mf := manifest.New()
+	_, err := img.InstantiateManifest(&mf, []rpmmd.RepoConfig{}, &runner.Fedora{Version: 42}, rng)
+	require.NoError(t, err)
+	// the image of the filesystem is created before the disk image
+	assert.Equal(t, []string{"os", "erofs-usr-tree", "erofs-usr", "image", "qcow2"}, mf.PayloadPipelines())
+}
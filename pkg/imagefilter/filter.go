@@ -3,7 +3,9 @@ package imagefilter
 import (
 	"fmt"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gobwas/glob"
 
@@ -12,18 +14,28 @@ import (
 
 const (
 	// supported filter prefixes
-	prefixDistro   = "distro"
-	prefixArch     = "arch"
-	prefixType     = "type"
-	prefixBootmode = "bootmode"
+	prefixDistro     = "distro"
+	prefixArch       = "arch"
+	prefixType       = "type"
+	prefixBootmode   = "bootmode"
+	prefixLifecycle  = "lifecycle"
+	prefixDeprecated = "deprecated"
+
+	// lifecycleSupported is a special "lifecycle:" filter term
+	// that matches distros with full or maintenance support
+	lifecycleSupported = "supported"
 )
 
+// timeNow is used to determine the support phase of the distros,
+// mocked in tests
+var timeNow = time.Now
+
 // SupportedFilters returns what filter prefixes are supported
 func SupportedFilters() []string {
 	return []string{
 		// this should be ordered by "importance", i.e. the
 		// most common prefixes/filters first
-		prefixDistro, prefixArch, prefixType, prefixBootmode,
+		prefixDistro, prefixArch, prefixType, prefixBootmode, prefixLifecycle, prefixDeprecated,
 	}
 }
 
@@ -47,6 +59,9 @@ func splitPrefixSearchTerm(s string) (string, string) {
 // "arch:" - the architecture, e.g. x86_64
 // "type": - the image type, e.g. ami, or qcow?
 // "bootmode": - the bootmode, e.g. "legacy", "uefi", "hybrid"
+// "lifecycle": - the support phase of the distro, e.g. "full", "eol" or
+// "supported" (for "full" or "maintenance")
+// "deprecated": - if the image type is deprecated, e.g. "true" or "false"
 func newFilter(sl ...string) (*filter, error) {
 	filter := &filter{
 		terms: make([]term, len(sl)),
@@ -61,14 +76,16 @@ func newFilter(sl ...string) (*filter, error) {
 			return nil, err
 		}
 		filter.terms[i].prefix = prefix
+		filter.terms[i].searchTerm = searchTerm
 		filter.terms[i].pattern = gl
 	}
 	return filter, nil
 }
 
 type term struct {
-	prefix  string
-	pattern glob.Glob
+	prefix     string
+	searchTerm string
+	pattern    glob.Glob
 }
 
 // filter provides a way to filter a list of image defintions for the
@@ -114,6 +131,15 @@ func (fl filter) Matches(distro distro.Distro, arch distro.Arch, imgType distro.
 			// mostly here to show how flexible this is
 		case prefixBootmode:
 			m = m && term.pattern.Match(imgType.BootMode().String())
+		case prefixLifecycle:
+			lc := distro.Lifecycle()
+			if term.searchTerm == lifecycleSupported {
+				m = m && lc.Supported(timeNow())
+			} else {
+				m = m && term.pattern.Match(string(lc.Phase(timeNow())))
+			}
+		case prefixDeprecated:
+			m = m && term.pattern.Match(strconv.FormatBool(imgType.Deprecation() != nil))
 		}
 	}
 	return m
@@ -2,6 +2,7 @@ package imagefilter
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -46,6 +47,12 @@ func TestImageFilterFilter(t *testing.T) {
 		// bootmode: prefix
 		{[]string{"bootmode:uefi"}, "test-distro-1", "test_arch3", "qcow2", false},
 		{[]string{"bootmode:hybrid"}, "test-distro-1", "test_arch3", "qcow2", true},
+		// lifecycle: prefix (test distros have no lifecycle)
+		{[]string{"lifecycle:unknown"}, "test-distro-1", "test_arch3", "qcow2", true},
+		{[]string{"lifecycle:supported"}, "test-distro-1", "test_arch3", "qcow2", false},
+		// deprecated: prefix
+		{[]string{"deprecated:true"}, "test-distro-1", "test_arch3", "qcow2", false},
+		{[]string{"deprecated:false"}, "test-distro-1", "test_arch3", "qcow2", true},
 		// multiple filters are AND
 		{[]string{"distro:test-distro-1", "type:ami"}, "test-distro-1", "test_arch3", "qcow2", false},
 		{[]string{"distro:test-distro-1", "type:qcow2"}, "test-distro-1", "test_arch3", "qcow2", true},
@@ -70,9 +77,45 @@ func TestImageFilterFilter(t *testing.T) {
 
 func TestImageFilterError(t *testing.T) {
 	_, err := newFilter("random:filter")
-	require.EqualError(t, err, `unsupported filter prefix: "random" (supported: distro,arch,type,bootmode)`)
+	require.EqualError(t, err, `unsupported filter prefix: "random" (supported: distro,arch,type,bootmode,lifecycle,deprecated)`)
 }
 
 func TestSupportedFilters(t *testing.T) {
 	assert.Contains(t, SupportedFilters(), "distro")
 }
+
+func TestImageFilterLifecycle(t *testing.T) {
+	fac := distrofactory.NewDefault()
+	di := fac.GetDistro("rhel-9.6")
+	require.NotNil(t, di)
+	ar, err := di.GetArch("x86_64")
+	require.NoError(t, err)
+	im, err := ar.GetImageType("qcow2")
+	require.NoError(t, err)
+	defer func() { timeNow = time.Now }()
+
+	for _, tc := range []struct {
+		now          string
+		searchExpr   string
+		expectsMatch bool
+	}{
+		{"2021-01-01", "lifecycle:preview", true},
+		{"2021-01-01", "lifecycle:supported", false},
+		{"2025-01-01", "lifecycle:full", true},
+		{"2025-01-01", "lifecycle:supported", true},
+		{"2030-01-01", "lifecycle:maintenance", true},
+		{"2030-01-01", "lifecycle:supported", true},
+		{"2030-01-01", "lifecycle:full", false},
+		{"2033-01-01", "lifecycle:eol", true},
+		{"2033-01-01", "lifecycle:supported", false},
+		{"2033-01-01", "lifecycle:e*", true},
+	} {
+		now, err := time.Parse(time.DateOnly, tc.now)
+		require.NoError(t, err)
+		timeNow = func() time.Time { return now }
+
+		ff, err := newFilter(tc.searchExpr)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expectsMatch, ff.Matches(di, ar, im), tc)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/osbuild/images/pkg/distro"
 	"github.com/osbuild/images/pkg/distrosort"
 	// we cannot use "maps" yet, as it needs go1.23
 	"golang.org/x/exp/maps"
@@ -158,7 +159,8 @@ type archResultJSON struct {
 }
 
 type imgTypeResultJSON struct {
-	Name string `json:"name"`
+	Name       string              `json:"name"`
+	Deprecated *distro.Deprecation `json:"deprecated,omitempty"`
 }
 
 type filteredResultJSON struct {
@@ -181,7 +183,8 @@ func (*jsonResultsFormatter) Output(w io.Writer, all []Result) error {
 				Name: arch.Name(),
 			},
 			ImgType: imgTypeResultJSON{
-				Name: res.ImgType.Name(),
+				Name:       res.ImgType.Name(),
+				Deprecated: res.ImgType.Deprecation(),
 			},
 		})
 	}
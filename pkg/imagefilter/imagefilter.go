@@ -3,6 +3,7 @@ package imagefilter
 import (
 	"errors"
 	"fmt"
+	"slices"
 
 	"github.com/osbuild/images/pkg/distro"
 	"github.com/osbuild/images/pkg/distrofactory"
@@ -20,25 +21,60 @@ type MinimalRepoRegistry interface {
 type Result struct {
 	ImgType distro.ImageType
 	Repos   []rpmmd.RepoConfig
+	// HasRepos is false if no repositories are configured for
+	// the image (see ImageFilter.IncludeWithoutRepos)
+	HasRepos bool
 }
 
 // ImageFilter is an a flexible way to filter the available images.
 type ImageFilter struct {
 	fac   *distrofactory.Factory
 	repos MinimalRepoRegistry
+
+	includeWithoutRepos bool
 }
 
 // New creates a new ImageFilter that can be used to filter the list
-// of available images
+// of available images.
+//
+// The repos can be nil, in this case all distros known to the
+// distrofactory are used and no image has repositories.
 func New(fac *distrofactory.Factory, repos MinimalRepoRegistry) (*ImageFilter, error) {
 	if fac == nil {
 		return nil, fmt.Errorf("cannot create ImageFilter without a valid distrofactory")
 	}
-	if repos == nil {
-		return nil, fmt.Errorf("cannot create ImageFilter without a valid reporegistry")
-	}
 
-	return &ImageFilter{fac: fac, repos: repos}, nil
+	return &ImageFilter{fac: fac, repos: repos, includeWithoutRepos: repos == nil}, nil
+}
+
+// IncludeWithoutRepos controls if images without repositories are
+// part of the results. By default they are skipped as they cannot
+// be built. When included the distros known to the distrofactory
+// are used in addition to the distros of the repository registry.
+func (i *ImageFilter) IncludeWithoutRepos(include bool) {
+	i.includeWithoutRepos = include
+}
+
+func (i *ImageFilter) distroNames() ([]string, error) {
+	var distroNames []string
+	if i.repos != nil {
+		distroNames = append(distroNames, i.repos.ListDistros()...)
+	}
+	if i.includeWithoutRepos {
+		known, err := i.fac.ListDistros()
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range known {
+			if !slices.Contains(distroNames, name) {
+				distroNames = append(distroNames, name)
+			}
+		}
+	}
+	if err := distrosort.Names(distroNames); err != nil {
+		return nil, err
+	}
+	return distroNames, nil
 }
 
 // Filter filters the available images for the given
@@ -54,16 +90,18 @@ func New(fac *distrofactory.Factory, repos MinimalRepoRegistry) (*ImageFilter, e
 // "arch:" - the architecture, e.g. x86_64
 // "type": - the image type, e.g. ami, or qcow?
 // "bootmode": - the bootmode, e.g. "legacy", "uefi", "hybrid"
+// "lifecycle": - the support phase of the distro, e.g. "full", "eol" or
+// "supported" (for "full" or "maintenance")
+// "deprecated": - if the image type is deprecated, e.g. "true" or "false"
 func (i *ImageFilter) Filter(searchTerms ...string) ([]Result, error) {
 	var res []Result
 
-	distroNames := i.repos.ListDistros()
 	filter, err := newFilter(searchTerms...)
 	if err != nil {
 		return nil, err
 	}
-
-	if err := distrosort.Names(distroNames); err != nil {
+	distroNames, err := i.distroNames()
+	if err != nil {
 		return nil, err
 	}
 	for _, distroName := range distroNames {
@@ -82,17 +120,25 @@ func (i *ImageFilter) Filter(searchTerms ...string) ([]Result, error) {
 				if err != nil {
 					return nil, err
 				}
-				if filter.Matches(distro, a, imgType) {
-					repos, err := i.repos.ReposByImageTypeName(distroName, archName, imgTypeName)
-					if errors.Is(err, reporegistry.ErrNoRepoFound) {
-						// skip the image if no repositories are found, we cannot build it without repos (except bootc images but those do not use ImageFilter)
-						continue
-					}
-					if err != nil {
-						return nil, err
+				if !filter.Matches(distro, a, imgType) {
+					continue
+				}
+				if i.repos == nil {
+					res = append(res, Result{ImgType: imgType})
+					continue
+				}
+				repos, err := i.repos.ReposByImageTypeName(distroName, archName, imgTypeName)
+				if errors.Is(err, reporegistry.ErrNoRepoFound) {
+					// skip the image if no repositories are found, we cannot build it without repos (except bootc images but those do not use ImageFilter)
+					if i.includeWithoutRepos {
+						res = append(res, Result{ImgType: imgType})
 					}
-					res = append(res, Result{imgType, repos})
+					continue
+				}
+				if err != nil {
+					return nil, err
 				}
+				res = append(res, Result{ImgType: imgType, Repos: repos, HasRepos: true})
 			}
 		}
 	}
@@ -7,6 +7,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/osbuild/images/pkg/distro/defs"
 	"github.com/osbuild/images/pkg/distrofactory"
 	"github.com/osbuild/images/pkg/imagefilter"
 	"github.com/osbuild/images/pkg/rpmmd"
@@ -95,3 +96,70 @@ func TestImageFilterFilter(t *testing.T) {
 		})
 	}
 }
+
+func TestImageFilterWithoutRepos(t *testing.T) {
+	fac := distrofactory.NewDefault()
+
+	imgFilter, err := imagefilter.New(fac, nil)
+	require.NoError(t, err)
+
+	// rhel-9.1 has no repositories in the test repos
+	res, err := imgFilter.Filter("distro:rhel-9.1", "arch:x86_64", "type:qcow2")
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	assert.Equal(t, "rhel-9.1", res[0].ImgType.Arch().Distro().Name())
+	assert.False(t, res[0].HasRepos)
+	assert.Nil(t, res[0].Repos)
+}
+
+func TestImageFilterIncludeWithoutRepos(t *testing.T) {
+	fac := distrofactory.NewDefault()
+	repos, err := testrepos.New()
+	require.NoError(t, err)
+
+	imgFilter, err := imagefilter.New(fac, repos)
+	require.NoError(t, err)
+
+	res, err := imgFilter.Filter("distro:rhel-9.[01]", "arch:x86_64", "type:qcow2")
+	require.NoError(t, err)
+	assert.Len(t, res, 1)
+	assert.Equal(t, "rhel-9.0", res[0].ImgType.Arch().Distro().Name())
+	assert.True(t, res[0].HasRepos)
+
+	imgFilter.IncludeWithoutRepos(true)
+	res, err = imgFilter.Filter("distro:rhel-9.[01]", "arch:x86_64", "type:qcow2")
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	assert.Equal(t, "rhel-9.0", res[0].ImgType.Arch().Distro().Name())
+	assert.True(t, res[0].HasRepos)
+	assert.True(t, len(res[0].Repos) > 0)
+	assert.Equal(t, "rhel-9.1", res[1].ImgType.Arch().Distro().Name())
+	assert.False(t, res[1].HasRepos)
+}
+
+func TestImageFilterExtraImageTypes(t *testing.T) {
+	// "extends" must be able to find "qcow2" in all distros so
+	// limit to centos
+	extra, err := defs.ParseExtraImageTypes([]byte(`
+when:
+  distro_name: centos
+image_types:
+  qcow2-hardened:
+    extends: qcow2
+    filename: disk-hardened.qcow2
+`), "extra.yaml")
+	require.NoError(t, err)
+	fac := distrofactory.NewDefaultWithExtraImageTypes([]*defs.ExtraImageTypes{extra})
+	repos, err := testrepos.New()
+	require.NoError(t, err)
+
+	imgFilter, err := imagefilter.New(fac, repos)
+	require.NoError(t, err)
+
+	res, err := imgFilter.Filter("distro:centos-9", "arch:x86_64", "type:qcow2*")
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	assert.Equal(t, "qcow2", res[0].ImgType.Name())
+	assert.Equal(t, "qcow2-hardened", res[1].ImgType.Name())
+	assert.True(t, res[1].HasRepos)
+}
@@ -11,12 +11,12 @@ import (
 // collection of org.osbuild.systemd.unit.create stages for .mount and .swap
 // units (and an org.osbuild.systemd stage to enable them) depending on the
 // pipeline configuration.
-func filesystemConfigStages(pt *disk.PartitionTable, mountConfiguration osbuild.MountConfiguration) ([]*osbuild.Stage, error) {
+func filesystemConfigStages(pt *disk.PartitionTable, dataPTs []*disk.PartitionTable, mountConfiguration osbuild.MountConfiguration) ([]*osbuild.Stage, error) {
 	switch mountConfiguration {
 	case osbuild.MOUNT_CONFIGURATION_UNITS:
-		return osbuild.GenSystemdMountStages(pt)
+		return osbuild.GenSystemdMountStages(pt, dataPTs...)
 	case osbuild.MOUNT_CONFIGURATION_FSTAB:
-		opts, err := osbuild.NewFSTabStageOptions(pt)
+		opts, err := osbuild.NewFSTabStageOptions(pt, dataPTs...)
 		if err != nil {
 			return nil, err
 		}
@@ -27,3 +27,24 @@ func filesystemConfigStages(pt *disk.PartitionTable, mountConfiguration osbuild.
 		return nil, fmt.Errorf("Unexpected mount configuration %d", mountConfiguration)
 	}
 }
+
+// dataDisksMkdirStage returns a stage that creates the mountpoints of the
+// filesystems on the data disks in the tree. Their content is copied to
+// the data disks when the disk images are created.
+func dataDisksMkdirStage(dataPTs []*disk.PartitionTable) *osbuild.Stage {
+	var paths []osbuild.MkdirStagePath
+	for _, pt := range dataPTs {
+		_ = pt.ForEachMountable(func(mnt disk.Mountable, _ []disk.Entity) error {
+			paths = append(paths, osbuild.MkdirStagePath{
+				Path:    mnt.GetMountpoint(),
+				Parents: true,
+				ExistOk: true,
+			})
+			return nil
+		})
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	return osbuild.NewMkdirStage(&osbuild.MkdirStageOptions{Paths: paths})
+}
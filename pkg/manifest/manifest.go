@@ -15,6 +15,7 @@ package manifest
 import (
 	"encoding/json"
 	"fmt"
+	"slices"
 
 	"github.com/osbuild/images/internal/common"
 	"github.com/osbuild/images/pkg/container"
@@ -138,6 +139,35 @@ func (m *Manifest) addPipeline(p Pipeline) {
 	}
 }
 
+// Pipelines returns the pipelines of the manifest in the order in which
+// they are serialized.
+func (m *Manifest) Pipelines() []Pipeline {
+	return slices.Clone(m.pipelines)
+}
+
+// GetPipeline returns the pipeline with the given name or nil if the
+// manifest has no such pipeline.
+func (m *Manifest) GetPipeline(name string) Pipeline {
+	for _, p := range m.pipelines {
+		if p.Name() == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// OSPipeline returns the pipeline that builds the filesystem tree of the
+// operating system of the image or nil if the manifest has none (e.g. for
+// images that are built from a bootable container).
+func (m *Manifest) OSPipeline() *OS {
+	for _, p := range m.pipelines {
+		if os, ok := p.(*OS); ok {
+			return os
+		}
+	}
+	return nil
+}
+
 type PackageSelector func([]rpmmd.PackageSet) []rpmmd.PackageSet
 
 func (m Manifest) GetPackageSetChains() (map[string][]rpmmd.PackageSet, error) {
@@ -6,8 +6,11 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/osbuild/images/pkg/arch"
 	"github.com/osbuild/images/pkg/manifest"
 	"github.com/osbuild/images/pkg/osbuild"
+	"github.com/osbuild/images/pkg/platform"
+	"github.com/osbuild/images/pkg/runner"
 )
 
 func TestDistroUnmarshal(t *testing.T) {
@@ -41,6 +44,25 @@ func TestAllDistrosHaveNames(t *testing.T) {
 	assert.Equal(t, len(manifest.DistroNames), int(manifest.DISTRO_COUNT))
 }
 
+func TestManifestPipelines(t *testing.T) {
+	m := manifest.New()
+	assert.Empty(t, m.Pipelines())
+	assert.Nil(t, m.OSPipeline())
+
+	build := manifest.NewBuild(&m, &runner.Fedora{Version: 42}, nil, nil)
+	os := manifest.NewOS(build, &platform.Data{Arch: arch.ARCH_X86_64}, nil)
+	tar := manifest.NewTar(build, os, "archive")
+
+	var names []string
+	for _, p := range m.Pipelines() {
+		names = append(names, p.Name())
+	}
+	assert.Equal(t, []string{"build", "os", "archive"}, names)
+	assert.Equal(t, os, m.OSPipeline())
+	assert.Equal(t, tar, m.GetPipeline("archive"))
+	assert.Nil(t, m.GetPipeline("image"))
+}
+
 func findStage(name string, stages []*osbuild.Stage) *osbuild.Stage {
 	for _, s := range stages {
 		if s.Type == name {
@@ -195,6 +195,13 @@ type OSCustomizations struct {
 
 	// InstallLangs determines which locale files are installed by RPMs
 	InstallLangs []string
+
+	// ExtraStages are added to the pipeline after all other
+	// customizations, but before the SELinux labeling so that the files
+	// they create are labeled. They are meant for stages that the
+	// customizations cannot express and must not need any content
+	// (packages, containers or commits) as inputs.
+	ExtraStages []*osbuild.Stage
 }
 
 // OS represents the filesystem tree of the target image. This roughly
@@ -224,6 +231,10 @@ type OS struct {
 	// Partition table, if nil the tree cannot be put on a partitioned disk
 	PartitionTable *disk.PartitionTable
 
+	// Partition tables of additional (data) disks, the filesystems on
+	// them are mounted by the system (see disk.NewDataPartitionTable())
+	DataPartitionTables []*disk.PartitionTable
+
 	// content-related fields
 	repos            []rpmmd.RepoConfig
 	packageSpecs     []rpmmd.PackageSpec
@@ -267,6 +278,9 @@ func (p *OS) getPackageSetChain(Distro) ([]rpmmd.PackageSet, error) {
 	if p.PartitionTable != nil {
 		partitionTablePackages = p.PartitionTable.GetBuildPackages()
 	}
+	for _, pt := range p.DataPartitionTables {
+		partitionTablePackages = append(partitionTablePackages, pt.GetBuildPackages()...)
+	}
 
 	if p.OSCustomizations.KernelName != "" {
 		// kernel is considered part of the platform package set
@@ -396,6 +410,9 @@ func (p *OS) getBuildPackages(distro Distro) ([]string, error) {
 	if p.PartitionTable != nil {
 		packages = append(packages, p.PartitionTable.GetBuildPackages()...)
 	}
+	for _, pt := range p.DataPartitionTables {
+		packages = append(packages, pt.GetBuildPackages()...)
+	}
 	packages = append(packages, "rpm")
 	if p.OSTreeRef != "" {
 		packages = append(packages, "rpm-ostree")
@@ -774,12 +791,16 @@ func (p *OS) serialize() (osbuild.Pipeline, error) {
 			}))
 		}
 
-		fsCfgStages, err := filesystemConfigStages(pt, p.OSCustomizations.MountConfiguration)
+		fsCfgStages, err := filesystemConfigStages(pt, p.DataPartitionTables, p.OSCustomizations.MountConfiguration)
 		if err != nil {
 			return osbuild.Pipeline{}, err
 		}
 		pipeline.AddStages(fsCfgStages...)
 
+		if mkdirStage := dataDisksMkdirStage(p.DataPartitionTables); mkdirStage != nil {
+			pipeline.AddStage(mkdirStage)
+		}
+
 		switch p.platform.GetBootloader() {
 		case platform.BOOTLOADER_GRUB2:
 			pipeline.AddStage(grubStage(p, pt, kernelOptions))
@@ -967,6 +988,8 @@ func (p *OS) serialize() (osbuild.Pipeline, error) {
 		}))
 	}
 
+	pipeline.AddStages(p.OSCustomizations.ExtraStages...)
+
 	if p.OSCustomizations.SELinux != "" {
 		pipeline.AddStage(osbuild.NewSELinuxStage(&osbuild.SELinuxStageOptions{
 			FileContexts:     fmt.Sprintf("etc/selinux/%s/contexts/files/file_contexts", p.OSCustomizations.SELinux),
@@ -251,6 +251,25 @@ func TestMachineIdUninitializedDoesNotIncludeMachineIdStage(t *testing.T) {
 	require.Nil(t, st)
 }
 
+func TestExtraStagesBeforeSELinuxStage(t *testing.T) {
+	os := manifest.NewTestOS()
+
+	os.OSCustomizations.SELinux = "targeted"
+	os.OSCustomizations.ExtraStages = []*osbuild.Stage{
+		osbuild.NewMkdirStage(&osbuild.MkdirStageOptions{
+			Paths: []osbuild.MkdirStagePath{{Path: "/etc/audit-stamp"}},
+		}),
+	}
+
+	pipeline, err := os.Serialize()
+	assert.NoError(t, err)
+
+	require.GreaterOrEqual(t, len(pipeline.Stages), 2)
+	last := pipeline.Stages[len(pipeline.Stages)-2:]
+	assert.Equal(t, "org.osbuild.mkdir", last[0].Type)
+	assert.Equal(t, "org.osbuild.selinux", last[1].Type)
+}
+
 func TestModularityIncludesConfigStage(t *testing.T) {
 	os := manifest.NewTestOS()
 
@@ -348,7 +348,7 @@ func (p *OSTreeDeployment) serialize() (osbuild.Pipeline, error) {
 	configStage.MountOSTree(p.osName, ref, 0)
 	pipeline.AddStage(configStage)
 
-	fsCfgStages, err := filesystemConfigStages(p.PartitionTable, p.MountConfiguration)
+	fsCfgStages, err := filesystemConfigStages(p.PartitionTable, nil, p.MountConfiguration)
 	if err != nil {
 		return osbuild.Pipeline{}, err
 	}
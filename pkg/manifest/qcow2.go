@@ -26,8 +26,21 @@ func (p *QCOW2) SetFilename(filename string) {
 // raw image. The pipeline name is the name of the new pipeline. Filename is the name
 // of the produced qcow2 image.
 func NewQCOW2(buildPipeline Build, imgPipeline FilePipeline) *QCOW2 {
+	return newQCOW2("qcow2", buildPipeline, imgPipeline)
+}
+
+// NewDataDiskQCOW2 creates a new QCOW2 pipeline for the data disk produced
+// by imgPipeline, the pipeline is named after the data disk, e.g.
+// "qcow2-data-1".
+func NewDataDiskQCOW2(buildPipeline Build, imgPipeline *RawDataImage) *QCOW2 {
+	p := newQCOW2(DataDiskPipelineName("qcow2", imgPipeline.idx), buildPipeline, imgPipeline)
+	p.filename = DataDiskFilename(p.filename, imgPipeline.idx)
+	return p
+}
+
+func newQCOW2(name string, buildPipeline Build, imgPipeline FilePipeline) *QCOW2 {
 	p := &QCOW2{
-		Base:        NewBase("qcow2", buildPipeline),
+		Base:        NewBase(name, buildPipeline),
 		imgPipeline: imgPipeline,
 		filename:    "image.qcow2",
 	}
@@ -25,6 +25,12 @@ func (p *RawImage) SetFilename(filename string) {
 	p.filename = filename
 }
 
+// dataFilename returns the name of the file of the data disk with the
+// given (1-based) index in the tree of the pipeline.
+func (p RawImage) dataFilename(idx int) string {
+	return DataDiskFilename(p.filename, idx)
+}
+
 func NewRawImage(buildPipeline Build, treePipeline *OS) *RawImage {
 	p := &RawImage{
 		Base:         NewBase("image", buildPipeline),
@@ -62,10 +68,27 @@ func (p *RawImage) serialize() (osbuild.Pipeline, error) {
 		pipeline.AddStage(stage)
 	}
 
+	// the files of the data disks are created next to the main disk,
+	// see RawDataImage
+	dataPTs := p.treePipeline.DataPartitionTables
+	dataFilenames := make([]string, 0, len(dataPTs))
+	for idx, dataPT := range dataPTs {
+		dataFilenames = append(dataFilenames, p.dataFilename(idx+1))
+		for _, stage := range osbuild.GenImagePrepareStages(dataPT, p.dataFilename(idx+1), p.PartTool, p.treePipeline.Name()) {
+			pipeline.AddStage(stage)
+		}
+	}
+
 	inputName := "root-tree"
 	copyOptions, copyDevices, copyMounts := osbuild.GenCopyFSTreeOptions(inputName, p.treePipeline.Name(), p.Filename(), pt)
 	copyInputs := osbuild.NewPipelineTreeInputs(inputName, p.treePipeline.Name())
-	pipeline.AddStage(osbuild.NewCopyStage(copyOptions, copyInputs, copyDevices, copyMounts))
+	// the filesystems of the data disks are mounted while the tree is
+	// copied, their content is not copied to the main disk
+	treeDevices, treeMounts, err := osbuild.GenDataDisksMountsDevices(copyDevices, copyMounts, dataFilenames, dataPTs)
+	if err != nil {
+		return osbuild.Pipeline{}, err
+	}
+	pipeline.AddStage(osbuild.NewCopyStage(copyOptions, copyInputs, treeDevices, treeMounts))
 
 	bootFiles := p.treePipeline.platform.GetBootFiles()
 	if len(bootFiles) > 0 {
@@ -102,6 +125,11 @@ func (p *RawImage) serialize() (osbuild.Pipeline, error) {
 	for _, stage := range osbuild.GenImageFinishStages(pt, p.Filename()) {
 		pipeline.AddStage(stage)
 	}
+	for idx, dataPT := range dataPTs {
+		for _, stage := range osbuild.GenImageFinishStages(dataPT, p.dataFilename(idx+1)) {
+			pipeline.AddStage(stage)
+		}
+	}
 
 	switch p.treePipeline.platform.GetArch() {
 	case arch.ARCH_S390X:
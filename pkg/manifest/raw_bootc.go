@@ -181,7 +181,7 @@ func (p *RawBootcImage) serialize() (osbuild.Pipeline, error) {
 	mounts = append(mounts, *osbuild.NewOSTreeDeploymentMountDefault("ostree.deployment", osbuild.OSTreeMountSourceMount))
 	mounts = append(mounts, *osbuild.NewBindMount("bind-ostree-deployment-to-tree", "mount://", "tree://"))
 
-	fsCfgStages, err := filesystemConfigStages(pt, p.MountConfiguration)
+	fsCfgStages, err := filesystemConfigStages(pt, nil, p.MountConfiguration)
 	if err != nil {
 		return osbuild.Pipeline{}, err
 	}
@@ -0,0 +1,91 @@
+package manifest
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/osbuild/images/pkg/artifact"
+	"github.com/osbuild/images/pkg/osbuild"
+)
+
+// DataDiskPipelineName returns the name of the pipeline for the data
+// disk with the given (1-based) index that corresponds to the pipeline
+// with the given name for the main disk, e.g. "qcow2-data-1".
+func DataDiskPipelineName(name string, idx int) string {
+	return fmt.Sprintf("%s-data-%d", name, idx)
+}
+
+// DataDiskFilename returns the filename of the data disk with the given
+// (1-based) index that corresponds to the filename of the main disk,
+// e.g. "disk-data-1.qcow2".
+func DataDiskFilename(filename string, idx int) string {
+	ext := filepath.Ext(filename)
+	return fmt.Sprintf("%s-data-%d%s", strings.TrimSuffix(filename, ext), idx, ext)
+}
+
+// A RawDataImage represents the raw image file of an additional (data)
+// disk of an image. The disk is described by one of the
+// DataPartitionTables of the OSPipeline. The file is created, together
+// with the one of the main disk, by the RawImage pipeline: the tree is
+// copied once with the filesystems of the data disks mounted at their
+// mountpoints so that their content is not part of the main disk.
+type RawDataImage struct {
+	Base
+	imgPipeline *RawImage
+	idx         int
+	filename    string
+}
+
+func (p RawDataImage) Filename() string {
+	return p.filename
+}
+
+func (p *RawDataImage) SetFilename(filename string) {
+	p.filename = filename
+}
+
+// NewRawDataImage creates the pipeline for the data disk with the given
+// (1-based) index, i.e. the DataPartitionTables[idx-1] of the OSPipeline
+// of imgPipeline.
+func NewRawDataImage(buildPipeline Build, imgPipeline *RawImage, idx int) *RawDataImage {
+	p := &RawDataImage{
+		Base:        NewBase(DataDiskPipelineName(imgPipeline.Name(), idx), buildPipeline),
+		imgPipeline: imgPipeline,
+		idx:         idx,
+		filename:    DataDiskFilename("disk.img", idx),
+	}
+	buildPipeline.addDependent(p)
+	return p
+}
+
+func (p *RawDataImage) serialize() (osbuild.Pipeline, error) {
+	pipeline, err := p.Base.serialize()
+	if err != nil {
+		return osbuild.Pipeline{}, err
+	}
+
+	if p.idx < 1 || p.idx > len(p.imgPipeline.treePipeline.DataPartitionTables) {
+		return osbuild.Pipeline{}, fmt.Errorf("no partition table for data disk %d", p.idx)
+	}
+
+	inputName := "image"
+	pipeline.AddStage(osbuild.NewCopyStageSimple(
+		&osbuild.CopyStageOptions{
+			Paths: []osbuild.CopyStagePath{
+				{
+					From: fmt.Sprintf("input://%s/%s", inputName, p.imgPipeline.dataFilename(p.idx)),
+					To:   fmt.Sprintf("tree:///%s", p.Filename()),
+				},
+			},
+		},
+		osbuild.NewPipelineTreeInputs(inputName, p.imgPipeline.Name()),
+	))
+
+	return pipeline, nil
+}
+
+func (p *RawDataImage) Export() *artifact.Artifact {
+	p.Base.export = true
+	return artifact.New(p.Name(), p.Filename(), nil)
+}
@@ -0,0 +1,130 @@
+package manifest
+
+import (
+	"fmt"
+
+	"github.com/osbuild/images/pkg/disk"
+	"github.com/osbuild/images/pkg/osbuild"
+)
+
+// A ReadOnlyFSImage represents the image of a read-only filesystem (erofs
+// or squashfs) of a partition table. It is created from the content of the
+// tree below the mountpoint of the filesystem and written to its partition
+// by the pipeline that creates the disk image. Read-only filesystems cannot
+// be mounted on "/" (see disk.ErrReadOnlyRootUnsupported).
+type ReadOnlyFSImage struct {
+	Base
+
+	treePipeline *OS
+	fs           *disk.Filesystem
+	excludePaths []string
+	// contentPipeline holds the part of the tree below the mountpoint
+	// of the filesystem
+	contentPipeline *readOnlyFSTree
+}
+
+// readOnlyFSTree holds the part of the tree below the mountpoint of a
+// read-only filesystem.
+type readOnlyFSTree struct {
+	Base
+
+	treePipeline *OS
+	mountpoint   string
+}
+
+// NewReadOnlyFSImages creates the pipelines for the images of all
+// read-only filesystems of the partition table. They need to be created
+// before the pipelines of the disk image that use them.
+func NewReadOnlyFSImages(buildPipeline Build, treePipeline *OS, pt *disk.PartitionTable) []*ReadOnlyFSImage {
+	if pt == nil {
+		return nil
+	}
+
+	var pipelines []*ReadOnlyFSImage
+	_ = pt.ForEachEntity(func(ent disk.Entity, _ []disk.Entity) error {
+		fs, ok := ent.(*disk.Filesystem)
+		if !ok || !fs.ReadOnly() {
+			return nil
+		}
+		name, _ := osbuild.ReadOnlyFSImage(fs)
+		tree := &readOnlyFSTree{
+			Base:         NewBase(name+"-tree", buildPipeline),
+			treePipeline: treePipeline,
+			mountpoint:   fs.Mountpoint,
+		}
+		buildPipeline.addDependent(tree)
+		p := &ReadOnlyFSImage{
+			Base:            NewBase(name, buildPipeline),
+			treePipeline:    treePipeline,
+			fs:              fs,
+			excludePaths:    osbuild.ReadOnlyFSExcludePaths(pt, fs),
+			contentPipeline: tree,
+		}
+		buildPipeline.addDependent(p)
+		pipelines = append(pipelines, p)
+		return nil
+	})
+	return pipelines
+}
+
+func (p *readOnlyFSTree) serialize() (osbuild.Pipeline, error) {
+	pipeline, err := p.Base.serialize()
+	if err != nil {
+		return osbuild.Pipeline{}, err
+	}
+
+	inputName := "tree"
+	copyOptions := &osbuild.CopyStageOptions{
+		Paths: []osbuild.CopyStagePath{
+			{
+				From: fmt.Sprintf("input://%s%s/", inputName, p.mountpoint),
+				To:   "tree:///",
+			},
+		},
+	}
+	copyInputs := osbuild.NewPipelineTreeInputs(inputName, p.treePipeline.Name())
+	pipeline.AddStage(osbuild.NewCopyStageSimple(copyOptions, copyInputs))
+	return pipeline, nil
+}
+
+func (p *ReadOnlyFSImage) getBuildPackages(Distro) ([]string, error) {
+	switch p.fs.Type {
+	case "erofs":
+		return []string{"erofs-utils"}, nil
+	case "squashfs":
+		return []string{"squashfs-tools"}, nil
+	}
+	return nil, nil
+}
+
+func (p *ReadOnlyFSImage) serialize() (osbuild.Pipeline, error) {
+	pipeline, err := p.Base.serialize()
+	if err != nil {
+		return osbuild.Pipeline{}, err
+	}
+
+	_, path := osbuild.ReadOnlyFSImage(p.fs)
+	switch p.fs.Type {
+	case "erofs":
+		options := &osbuild.ErofsStageOptions{
+			Filename:     path,
+			ExcludePaths: p.excludePaths,
+			Compression: &osbuild.ErofsCompression{
+				Method: "zstd",
+			},
+		}
+		pipeline.AddStage(osbuild.NewErofsStage(options, p.contentPipeline.Name()))
+	case "squashfs":
+		options := &osbuild.SquashfsStageOptions{
+			Filename:     path,
+			ExcludePaths: p.excludePaths,
+			Compression: osbuild.FSCompression{
+				Method: "xz",
+			},
+		}
+		pipeline.AddStage(osbuild.NewSquashfsStage(options, p.contentPipeline.Name()))
+	default:
+		return osbuild.Pipeline{}, fmt.Errorf("unsupported read-only filesystem type %q", p.fs.Type)
+	}
+	return pipeline, nil
+}
@@ -0,0 +1,47 @@
+package manifest_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/disk"
+	"github.com/osbuild/images/pkg/manifest"
+	"github.com/osbuild/images/pkg/osbuild"
+)
+
+func TestReadOnlyFSImageSerialize(t *testing.T) {
+	os := manifest.NewTestOS()
+	pt := &disk.PartitionTable{
+		Type: disk.PT_GPT,
+		Partitions: []disk.Partition{
+			{Payload: &disk.Filesystem{Type: "xfs", Mountpoint: "/"}},
+			{Payload: &disk.Filesystem{Type: "squashfs", Mountpoint: "/opt"}},
+			{Payload: &disk.Filesystem{Type: "xfs", Mountpoint: "/opt/data"}},
+			{Payload: &disk.Filesystem{Type: "erofs", Mountpoint: "/usr"}},
+		},
+	}
+
+	pipelines := manifest.NewReadOnlyFSImages(os.BuildPipeline(), os, pt)
+	require.Len(t, pipelines, 2)
+
+	opt, err := manifest.Serialize(pipelines[0])
+	require.NoError(t, err)
+	assert.Equal(t, "squashfs-opt", opt.Name)
+	require.Len(t, opt.Stages, 1)
+	assert.Equal(t, &osbuild.SquashfsStageOptions{
+		Filename:     "/opt.squashfs",
+		ExcludePaths: []string{"data/.*"},
+		Compression:  osbuild.FSCompression{Method: "xz"},
+	}, opt.Stages[0].Options)
+	assert.Equal(t, osbuild.NewPipelineTreeInputs("tree", "squashfs-opt-tree"), opt.Stages[0].Inputs)
+
+	// the filesystems are created from a copy of their part of the tree
+	usr, err := manifest.Serialize(pipelines[1])
+	require.NoError(t, err)
+	assert.Equal(t, "erofs-usr", usr.Name)
+	require.Len(t, usr.Stages, 1)
+	assert.Equal(t, "org.osbuild.erofs", usr.Stages[0].Type)
+	assert.Equal(t, osbuild.NewPipelineTreeInputs("tree", "erofs-usr-tree"), usr.Stages[0].Inputs)
+}
@@ -0,0 +1,13 @@
+package manifestgen
+
+import (
+	"time"
+)
+
+func MockTimeNow(f func() time.Time) (restore func()) {
+	saved := timeNow
+	timeNow = f
+	return func() {
+		timeNow = saved
+	}
+}
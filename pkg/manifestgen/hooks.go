@@ -0,0 +1,46 @@
+package manifestgen
+
+import (
+	"github.com/osbuild/images/pkg/manifest"
+	"github.com/osbuild/images/pkg/osbuild"
+)
+
+// ManifestHook can change the manifest of every generated image,
+// e.g. to add the same extra stages to all images regardless of their
+// image type.
+type ManifestHook interface {
+	// PreSerialize is called with the manifest of the image right
+	// after it was created from the image type, before its content
+	// is resolved and it is serialized. Pipelines can be added with
+	// the pipeline constructors and existing pipelines can be changed,
+	// see Manifest.OSPipeline() for the pipeline of the operating
+	// system (e.g. its OSCustomizations.ExtraStages).
+	PreSerialize(m *manifest.Manifest) error
+
+	// PostSerialize is called with the final osbuild manifest, all
+	// of its stages are decoded into typed options (see
+	// osbuild.NewManifestFromBytes()). Any changes are part of the
+	// generated manifest.
+	PostSerialize(mf *osbuild.Manifest) error
+}
+
+// ManifestHookFuncs implements a ManifestHook with functions, either
+// of them can be nil.
+type ManifestHookFuncs struct {
+	Pre  func(m *manifest.Manifest) error
+	Post func(mf *osbuild.Manifest) error
+}
+
+func (h ManifestHookFuncs) PreSerialize(m *manifest.Manifest) error {
+	if h.Pre == nil {
+		return nil
+	}
+	return h.Pre(m)
+}
+
+func (h ManifestHookFuncs) PostSerialize(mf *osbuild.Manifest) error {
+	if h.Post == nil {
+		return nil
+	}
+	return h.Post(mf)
+}
@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/osbuild/blueprint/pkg/blueprint"
 	"github.com/osbuild/images/pkg/container"
@@ -23,6 +24,9 @@ import (
 	"github.com/osbuild/images/pkg/sbom"
 )
 
+// timeNow is used to check the lifecycle of the distro, mocked in tests
+var timeNow = time.Now
+
 const (
 	defaultDepsolverSBOMType = sbom.StandardTypeSpdx
 	defaultSBOMExt           = "spdx.json"
@@ -48,7 +52,9 @@ type Options struct {
 
 	// WarningsOutput will receive any warnings that are part of
 	// the manifest generation. If it is unset any warnings will
-	// generate an error.
+	// generate an error. The exception is the (informational)
+	// warning about distros that reached their end of life, it is
+	// only written if WarningsOutput is set.
 	WarningsOutput io.Writer
 
 	// DepsolveWarningsOutput will receive any warnings that are
@@ -73,6 +79,25 @@ type Options struct {
 	// Use the a bootstrap container to buildroot (useful for e.g.
 	// cross-arch or cross-distro builds)
 	UseBootstrapContainer bool
+
+	// Mirror makes the sources of the manifest point to a local
+	// mirror of the content (e.g. for air-gapped builds), it
+	// requires the curl rpm downloader.
+	Mirror *Mirror
+
+	// FetchListWriter will be called with the fetch list (see
+	// FetchList) of each generated manifest, the filename
+	// contains the suggested filename and the content is json.
+	FetchListWriter FetchListWriterFunc
+
+	// StageSchemas are used to validate the options of the stages
+	// of the generated manifest, generating a manifest with invalid
+	// options fails. Stages without a schema are not validated.
+	StageSchemas *osbuild.StageSchemas
+
+	// Hooks are called for every generated manifest in the given
+	// order, see ManifestHook.
+	Hooks []ManifestHook
 }
 
 // Generator can generate an osbuild manifest from a given repository
@@ -84,6 +109,7 @@ type Generator struct {
 	containerResolver      ContainerResolverFunc
 	commitResolver         CommitResolverFunc
 	sbomWriter             SBOMWriterFunc
+	fetchListWriter        FetchListWriterFunc
 	warningsOutput         io.Writer
 	depsolveWarningsOutput io.Writer
 
@@ -95,6 +121,12 @@ type Generator struct {
 	overrideRepos []rpmmd.RepoConfig
 
 	useBootstrapContainer bool
+
+	mirror *Mirror
+
+	stageSchemas *osbuild.StageSchemas
+
+	hooks []ManifestHook
 }
 
 // New will create a new manifest generator
@@ -111,11 +143,23 @@ func New(reporegistry *reporegistry.RepoRegistry, opts *Options) (*Generator, er
 		commitResolver:         opts.CommitResolver,
 		rpmDownloader:          opts.RpmDownloader,
 		sbomWriter:             opts.SBOMWriter,
+		fetchListWriter:        opts.FetchListWriter,
 		warningsOutput:         opts.WarningsOutput,
 		depsolveWarningsOutput: opts.DepsolveWarningsOutput,
 		customSeed:             opts.CustomSeed,
 		overrideRepos:          opts.OverrideRepos,
 		useBootstrapContainer:  opts.UseBootstrapContainer,
+		mirror:                 opts.Mirror,
+		stageSchemas:           opts.StageSchemas,
+		hooks:                  opts.Hooks,
+	}
+	if mg.mirror != nil {
+		if err := mg.mirror.validate(); err != nil {
+			return nil, err
+		}
+		if mg.rpmDownloader != osbuild.RpmDownloaderCurl {
+			return nil, fmt.Errorf("mirror requires the curl rpm downloader")
+		}
 	}
 	if mg.depsolver == nil {
 		mg.depsolver = DefaultDepsolver
@@ -139,6 +183,12 @@ func (mg *Generator) Generate(bp *blueprint.Blueprint, imgType distro.ImageType,
 	imgOpts.UseBootstrapContainer = mg.useBootstrapContainer
 	a := imgType.Arch()
 	dist := a.Distro()
+	if lc := dist.Lifecycle(); lc.Phase(timeNow()) == distro.SupportPhaseEOL && mg.warningsOutput != nil {
+		fmt.Fprintf(mg.warningsOutput, "distro %q reached its end of life on %s\n", dist.Name(), lc.EndOfLife.Format(time.DateOnly))
+	}
+	if dep := imgType.Deprecation(); dep != nil && mg.warningsOutput != nil {
+		fmt.Fprintf(mg.warningsOutput, "%s\n", dep.Warning(imgType.Name()))
+	}
 
 	var repos []rpmmd.RepoConfig
 	if mg.overrideRepos != nil {
@@ -166,6 +216,11 @@ func (mg *Generator) Generate(bp *blueprint.Blueprint, imgType distro.ImageType,
 			return nil, fmt.Errorf("Warnings during manifest creation:\n%v", warn)
 		}
 	}
+	for _, hook := range mg.hooks {
+		if err := hook.PreSerialize(preManifest); err != nil {
+			return nil, fmt.Errorf("manifest hook failed: %w", err)
+		}
+	}
 	pkgSetChains, err := preManifest.GetPackageSetChains()
 	if err != nil {
 		return nil, err
@@ -190,6 +245,31 @@ func (mg *Generator) Generate(bp *blueprint.Blueprint, imgType distro.ImageType,
 	if err != nil {
 		return nil, err
 	}
+
+	// XXX: sync with image-builder-cli:build.go name generation - can we have a shared helper?
+	imageName := fmt.Sprintf("%s-%s-%s", dist.Name(), imgType.Name(), a.Name())
+	if mg.fetchListWriter != nil {
+		fetchList, err := newFetchList(depsolved, containerSpecs, commitSpecs)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(fetchList); err != nil {
+			return nil, err
+		}
+		if err := mg.fetchListWriter(imageName+".fetchlist.json", &buf); err != nil {
+			return nil, err
+		}
+	}
+	if mg.mirror != nil {
+		depsolved, containerSpecs, commitSpecs, err = mg.mirror.rewrite(depsolved, containerSpecs, commitSpecs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	opts := &manifest.SerializeOptions{
 		RpmDownloader: mg.rpmDownloader,
 	}
@@ -197,6 +277,17 @@ func (mg *Generator) Generate(bp *blueprint.Blueprint, imgType distro.ImageType,
 	if err != nil {
 		return nil, err
 	}
+	if len(mg.hooks) > 0 {
+		mf, err = runPostSerializeHooks(mg.hooks, mf)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if mg.stageSchemas != nil {
+		if err := mg.stageSchemas.ValidateManifest(mf); err != nil {
+			return nil, fmt.Errorf("invalid stage options in manifest for %s:\n%w", imageName, err)
+		}
+	}
 
 	if mg.sbomWriter != nil {
 		// XXX: this is very similar to
@@ -210,8 +301,6 @@ func (mg *Generator) Generate(bp *blueprint.Blueprint, imgType distro.ImageType,
 			case slices.Contains(preManifest.BuildPipelines(), plName):
 				pipelinePurpose = "buildroot"
 			}
-			// XXX: sync with image-builder-cli:build.go name generation - can we have a shared helper?
-			imageName := fmt.Sprintf("%s-%s-%s", dist.Name(), imgType.Name(), a.Name())
 			sbomDocOutputFilename := fmt.Sprintf("%s.%s-%s.%s", imageName, pipelinePurpose, plName, defaultSBOMExt)
 
 			var buf bytes.Buffer
@@ -228,6 +317,21 @@ func (mg *Generator) Generate(bp *blueprint.Blueprint, imgType distro.ImageType,
 	return mf, nil
 }
 
+// runPostSerializeHooks decodes the serialized manifest, calls the
+// PostSerialize() method of all hooks on it and serializes it again
+func runPostSerializeHooks(hooks []ManifestHook, mf manifest.OSBuildManifest) (manifest.OSBuildManifest, error) {
+	decoded, err := osbuild.NewManifestFromBytes(mf)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode manifest for hooks: %w", err)
+	}
+	for _, hook := range hooks {
+		if err := hook.PostSerialize(decoded); err != nil {
+			return nil, fmt.Errorf("manifest hook failed: %w", err)
+		}
+	}
+	return json.Marshal(decoded)
+}
+
 func xdgCacheHome() (string, error) {
 	xdgCacheHome := os.Getenv("XDG_CACHE_HOME")
 	if xdgCacheHome != "" {
@@ -328,4 +432,6 @@ type (
 	CommitResolverFunc func(commitSources map[string][]ostree.SourceSpec) (map[string][]ostree.CommitSpec, error)
 
 	SBOMWriterFunc func(filename string, content io.Reader, docType sbom.StandardType) error
+
+	FetchListWriterFunc func(filename string, content io.Reader) error
 )
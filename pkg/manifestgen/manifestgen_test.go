@@ -8,6 +8,8 @@ import (
 	"io"
 	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -16,10 +18,13 @@ import (
 	"github.com/osbuild/images/internal/common"
 	"github.com/osbuild/images/pkg/arch"
 	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/customizations/fsnode"
 	"github.com/osbuild/images/pkg/depsolvednf"
+	"github.com/osbuild/images/pkg/disk/partition"
 	"github.com/osbuild/images/pkg/distro"
 	"github.com/osbuild/images/pkg/distrofactory"
 	"github.com/osbuild/images/pkg/imagefilter"
+	"github.com/osbuild/images/pkg/manifest"
 	"github.com/osbuild/images/pkg/manifestgen"
 	"github.com/osbuild/images/pkg/osbuild"
 	"github.com/osbuild/images/pkg/osbuild/manifesttest"
@@ -347,6 +352,90 @@ func TestManifestGeneratorDepsolveOutput(t *testing.T) {
 	assert.Equal(t, []byte("fake depsolve output"), depsolveWarningsOutput.Bytes())
 }
 
+func TestManifestGeneratorEOLWarning(t *testing.T) {
+	repos, err := testrepos.New()
+	assert.NoError(t, err)
+	fac := distrofactory.NewDefault()
+
+	filter, err := imagefilter.New(fac, repos)
+	assert.NoError(t, err)
+	res, err := filter.Filter("distro:rhel-9.6", "type:qcow2", "arch:x86_64")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(res))
+
+	for _, tc := range []struct {
+		now             string
+		expectedWarning string
+	}{
+		{"2025-01-01", ""},
+		{"2032-05-31", `distro "rhel-9.6" reached its end of life on 2032-05-31` + "\n"},
+	} {
+		now, err := time.Parse(time.DateOnly, tc.now)
+		require.NoError(t, err)
+		restore := manifestgen.MockTimeNow(func() time.Time { return now })
+		defer restore()
+
+		var warningsOutput bytes.Buffer
+		opts := &manifestgen.Options{
+			Depsolver:      fakeDepsolve,
+			WarningsOutput: &warningsOutput,
+		}
+		mg, err := manifestgen.New(repos, opts)
+		require.NoError(t, err)
+
+		var bp blueprint.Blueprint
+		_, err = mg.Generate(&bp, res[0].ImgType, nil)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expectedWarning, warningsOutput.String())
+
+		// without a warnings output the EOL is not an error
+		mg, err = manifestgen.New(repos, &manifestgen.Options{Depsolver: fakeDepsolve})
+		require.NoError(t, err)
+		_, err = mg.Generate(&bp, res[0].ImgType, nil)
+		assert.NoError(t, err)
+	}
+}
+
+// deprecatedImageType is an image type with a deprecation, there is
+// no deprecated image type in the distro definitions
+type deprecatedImageType struct {
+	distro.ImageType
+}
+
+func (deprecatedImageType) Deprecation() *distro.Deprecation {
+	return &distro.Deprecation{Removal: "10.0", Replacement: "qcow2"}
+}
+
+func TestManifestGeneratorDeprecationNotice(t *testing.T) {
+	repos, err := testrepos.New()
+	assert.NoError(t, err)
+	fac := distrofactory.NewDefault()
+
+	filter, err := imagefilter.New(fac, repos)
+	assert.NoError(t, err)
+	res, err := filter.Filter("distro:rhel-9.6", "type:qcow2", "arch:x86_64")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(res))
+	imgType := deprecatedImageType{res[0].ImgType}
+
+	var warningsOutput bytes.Buffer
+	mg, err := manifestgen.New(repos, &manifestgen.Options{
+		Depsolver:      fakeDepsolve,
+		WarningsOutput: &warningsOutput,
+	})
+	require.NoError(t, err)
+	var bp blueprint.Blueprint
+	_, err = mg.Generate(&bp, imgType, nil)
+	require.NoError(t, err)
+	assert.Equal(t, `image type "qcow2" is deprecated and will be removed in 10.0 (use "qcow2" instead)`+"\n", warningsOutput.String())
+
+	// without a warnings output the deprecation is not an error
+	mg, err = manifestgen.New(repos, &manifestgen.Options{Depsolver: fakeDepsolve})
+	require.NoError(t, err)
+	_, err = mg.Generate(&bp, imgType, nil)
+	assert.NoError(t, err)
+}
+
 func TestManifestGeneratorOverrideRepos(t *testing.T) {
 	repos, err := testrepos.New()
 	assert.NoError(t, err)
@@ -426,3 +515,433 @@ func TestManifestGeneratorUseBootstrapContainer(t *testing.T) {
 		})
 	}
 }
+
+func TestManifestGeneratorMirror(t *testing.T) {
+	repos, err := testrepos.New()
+	assert.NoError(t, err)
+	fac := distrofactory.NewDefault()
+
+	filter, err := imagefilter.New(fac, repos)
+	assert.NoError(t, err)
+	res, err := filter.Filter("distro:centos-9", "type:qcow2", "arch:x86_64")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(res))
+
+	fetchLists := map[string]*manifestgen.FetchList{}
+	opts := &manifestgen.Options{
+		Depsolver:         fakeDepsolve,
+		CommitResolver:    panicCommitResolver,
+		ContainerResolver: fakeContainerResolver,
+
+		Mirror: &manifestgen.Mirror{
+			URL:               "file:///srv/mirror/",
+			Registry:          "localhost:5000",
+			RegistryTLSVerify: common.ToPtr(false),
+		},
+		FetchListWriter: func(filename string, content io.Reader) error {
+			var fl manifestgen.FetchList
+			if err := json.NewDecoder(content).Decode(&fl); err != nil {
+				return err
+			}
+			fetchLists[filename] = &fl
+			return nil
+		},
+	}
+	mg, err := manifestgen.New(repos, opts)
+	require.NoError(t, err)
+	bp := blueprint.Blueprint{
+		Containers: []blueprint.Container{
+			{Source: "quay.io/fedora/fedora-minimal"},
+		},
+	}
+	osbuildManifest, err := mg.Generate(&bp, res[0].ImgType, nil)
+	require.NoError(t, err)
+
+	// the sources point to the mirror
+	kernelChecksum := sha256For("kernel")
+	kernelPath := fmt.Sprintf("rpms/sha256/%s.rpm", strings.TrimPrefix(kernelChecksum, "sha256:"))
+	mani, err := manifesttest.NewManifestFromBytes(osbuildManifest)
+	require.NoError(t, err)
+	curlItems := mani.Sources["org.osbuild.curl"]["items"].(map[string]any)
+	assert.Equal(t, map[string]any{"url": "file:///srv/mirror/" + kernelPath}, curlItems[kernelChecksum])
+	for _, item := range curlItems {
+		assert.True(t, strings.HasPrefix(item.(map[string]any)["url"].(string), "file:///srv/mirror/rpms/sha256/"))
+	}
+	skopeoItems := mani.Sources["org.osbuild.skopeo"]["items"].(map[string]any)
+	require.Len(t, skopeoItems, 1)
+	for _, item := range skopeoItems {
+		image := item.(map[string]any)["image"].(map[string]any)
+		assert.Equal(t, "localhost:5000/resolved-cnt-quay.io/fedora/fedora-minimal", image["name"])
+		assert.Equal(t, false, image["tls-verify"])
+	}
+
+	// the fetch list has the original locations
+	require.Len(t, fetchLists, 1)
+	fl := fetchLists["centos-9-qcow2-x86_64.fetchlist.json"]
+	require.NotNil(t, fl)
+	assert.Contains(t, fl.RPMs, manifestgen.FetchRPM{
+		Checksum: kernelChecksum,
+		URL:      "https://rpmrepo.osbuild.org/v2/mirror/public/el9/cs9-x86_64-appstream-20250825/kernel.rpm",
+		Path:     kernelPath,
+	})
+	require.Len(t, fl.Containers, 1)
+	assert.Equal(t, "resolved-cnt-quay.io/fedora/fedora-minimal", fl.Containers[0].Source)
+	assert.Equal(t, "resolved-cnt-quay.io/fedora/fedora-minimal", fl.Containers[0].Path)
+	assert.Empty(t, fl.Commits)
+}
+
+func TestManifestGeneratorMirrorOstree(t *testing.T) {
+	repos, err := testrepos.New()
+	assert.NoError(t, err)
+	fac := distrofactory.NewDefault()
+
+	filter, err := imagefilter.New(fac, repos)
+	assert.NoError(t, err)
+	res, err := filter.Filter("distro:centos-9", "type:edge-ami", "arch:x86_64")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(res))
+
+	opts := &manifestgen.Options{
+		Depsolver:         fakeDepsolve,
+		CommitResolver:    fakeCommitResolver,
+		ContainerResolver: panicContainerResolver,
+		Mirror:            &manifestgen.Mirror{URL: "http://mirror.example.org"},
+	}
+	imageOpts := &distro.ImageOptions{
+		OSTree: &ostree.ImageOptions{
+			URL: "http://example.com/",
+		},
+	}
+	mg, err := manifestgen.New(repos, opts)
+	require.NoError(t, err)
+	var bp blueprint.Blueprint
+	osbuildManifest, err := mg.Generate(&bp, res[0].ImgType, imageOpts)
+	require.NoError(t, err)
+	assert.Contains(t, string(osbuildManifest), `{"url":"http://mirror.example.org/ostree"}`)
+	assert.NotContains(t, string(osbuildManifest), "resolved-url-for")
+}
+
+func TestManifestGeneratorMirrorErrors(t *testing.T) {
+	repos, err := testrepos.New()
+	assert.NoError(t, err)
+
+	_, err = manifestgen.New(repos, &manifestgen.Options{
+		Mirror: &manifestgen.Mirror{URL: "/srv/mirror"},
+	})
+	assert.EqualError(t, err, `invalid mirror url "/srv/mirror": scheme must be file, http or https`)
+
+	_, err = manifestgen.New(repos, &manifestgen.Options{
+		Mirror:        &manifestgen.Mirror{URL: "file:///srv/mirror"},
+		RpmDownloader: osbuild.RpmDownloaderLibrepo,
+	})
+	assert.EqualError(t, err, "mirror requires the curl rpm downloader")
+
+	fac := distrofactory.NewDefault()
+	filter, err := imagefilter.New(fac, repos)
+	assert.NoError(t, err)
+	res, err := filter.Filter("distro:centos-9", "type:qcow2", "arch:x86_64")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(res))
+
+	mg, err := manifestgen.New(repos, &manifestgen.Options{
+		Depsolver:         fakeDepsolve,
+		CommitResolver:    panicCommitResolver,
+		ContainerResolver: fakeContainerResolver,
+		Mirror:            &manifestgen.Mirror{URL: "file:///srv/mirror"},
+	})
+	require.NoError(t, err)
+	bp := blueprint.Blueprint{
+		Containers: []blueprint.Container{
+			{Source: "quay.io/fedora/fedora-minimal"},
+		},
+	}
+	_, err = mg.Generate(&bp, res[0].ImgType, nil)
+	assert.EqualError(t, err, `mirror has no registry for container "resolved-cnt-quay.io/fedora/fedora-minimal"`)
+}
+
+func TestManifestGeneratorStageSchemas(t *testing.T) {
+	repos, err := testrepos.New()
+	assert.NoError(t, err)
+	fac := distrofactory.NewDefault()
+	schemas, err := osbuild.DefaultStageSchemas()
+	require.NoError(t, err)
+
+	filter, err := imagefilter.New(fac, repos)
+	assert.NoError(t, err)
+
+	opts := &manifestgen.Options{
+		Depsolver:         fakeDepsolve,
+		CommitResolver:    fakeCommitResolver,
+		ContainerResolver: panicContainerResolver,
+		StageSchemas:      schemas,
+	}
+	mg, err := manifestgen.New(repos, opts)
+	require.NoError(t, err)
+	// the manifests of the image types must match the snapshot of
+	// the schemas
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Hostname: common.ToPtr("test-host"),
+			User: []blueprint.UserCustomization{
+				{Name: "alice", Groups: []string{"wheel"}},
+			},
+			Group: []blueprint.GroupCustomization{
+				{Name: "staff", GID: common.ToPtr(1100)},
+			},
+			Firewall: &blueprint.FirewallCustomization{
+				Ports: []string{"22:tcp"},
+			},
+		},
+	}
+	for _, imgTypeName := range []string{"qcow2", "ami", "vhd", "vmdk", "gce", "oci", "edge-commit"} {
+		t.Run(imgTypeName, func(t *testing.T) {
+			res, err := filter.Filter("distro:centos-9", "type:"+imgTypeName, "arch:x86_64")
+			require.NoError(t, err)
+			require.Equal(t, 1, len(res))
+
+			_, err = mg.Generate(&bp, res[0].ImgType, nil)
+			assert.NoError(t, err)
+		})
+	}
+
+	t.Run("qcow2-lvm", func(t *testing.T) {
+		res, err := filter.Filter("distro:centos-9", "type:qcow2", "arch:x86_64")
+		require.NoError(t, err)
+		require.Equal(t, 1, len(res))
+
+		imgOpts := &distro.ImageOptions{PartitioningMode: partition.LVMPartitioningMode}
+		mf, err := mg.Generate(&bp, res[0].ImgType, imgOpts)
+		assert.NoError(t, err)
+		assert.Contains(t, string(mf), `"org.osbuild.lvm2.create"`)
+	})
+}
+
+func TestManifestGeneratorStageSchemasInvalid(t *testing.T) {
+	repos, err := testrepos.New()
+	assert.NoError(t, err)
+	fac := distrofactory.NewDefault()
+
+	filter, err := imagefilter.New(fac, repos)
+	assert.NoError(t, err)
+	res, err := filter.Filter("distro:centos-9", "type:qcow2", "arch:x86_64")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(res))
+
+	// a schema that does not allow the language of the image
+	schemas, err := osbuild.NewStageSchemasFromFS(fstest.MapFS{
+		"org.osbuild.locale.meta.json": &fstest.MapFile{Data: []byte(`{
+  "schema": {
+    "additionalProperties": false,
+    "properties": {
+      "language": {"enum": ["de_DE.UTF-8"]}
+    }
+  }
+}`)},
+	})
+	require.NoError(t, err)
+	opts := &manifestgen.Options{
+		Depsolver:         fakeDepsolve,
+		CommitResolver:    panicCommitResolver,
+		ContainerResolver: panicContainerResolver,
+		StageSchemas:      schemas,
+	}
+	mg, err := manifestgen.New(repos, opts)
+	require.NoError(t, err)
+	var bp blueprint.Blueprint
+	_, err = mg.Generate(&bp, res[0].ImgType, nil)
+	assert.EqualError(t, err, `invalid stage options in manifest for centos-9-qcow2-x86_64:
+pipeline "os", stage 3 (org.osbuild.locale): options /language: value "C.UTF-8" is not one of ["de_DE.UTF-8"]`)
+	var optsErr *osbuild.StageOptionsError
+	require.ErrorAs(t, err, &optsErr)
+	assert.Equal(t, "/language", optsErr.Path)
+}
+
+func TestManifestGeneratorDecodeManifest(t *testing.T) {
+	repos, err := testrepos.New()
+	assert.NoError(t, err)
+	fac := distrofactory.NewDefault()
+
+	filter, err := imagefilter.New(fac, repos)
+	assert.NoError(t, err)
+
+	opts := &manifestgen.Options{
+		Depsolver:         fakeDepsolve,
+		CommitResolver:    fakeCommitResolver,
+		ContainerResolver: fakeContainerResolver,
+	}
+	mg, err := manifestgen.New(repos, opts)
+	require.NoError(t, err)
+	bp := blueprint.Blueprint{
+		Containers: []blueprint.Container{
+			{Source: "quay.io/fedora/fedora-minimal"},
+		},
+		Customizations: &blueprint.Customizations{
+			User: []blueprint.UserCustomization{
+				{Name: "alice", Groups: []string{"wheel"}},
+			},
+			Kernel: &blueprint.KernelCustomization{
+				Append: "debug",
+			},
+		},
+	}
+	// the decoded manifests must serialize to the same manifests, all
+	// stages must have typed options
+	for _, imgTypeName := range []string{"qcow2", "ami", "vhd", "vmdk", "gce", "oci", "image-installer"} {
+		t.Run(imgTypeName, func(t *testing.T) {
+			res, err := filter.Filter("distro:centos-9", "type:"+imgTypeName, "arch:x86_64")
+			require.NoError(t, err)
+			require.Equal(t, 1, len(res))
+
+			mf, err := mg.Generate(&bp, res[0].ImgType, nil)
+			require.NoError(t, err)
+			decoded, err := osbuild.NewManifestFromBytes(mf)
+			require.NoError(t, err)
+			for _, p := range decoded.Pipelines {
+				for _, stage := range p.Stages {
+					_, raw := stage.Options.(osbuild.RawStageOptions)
+					assert.False(t, raw, "stage %q has no typed options", stage.Type)
+				}
+			}
+			encoded, err := json.Marshal(decoded)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(mf), string(encoded))
+		})
+	}
+
+	res, err := filter.Filter("distro:centos-9", "type:qcow2", "arch:x86_64")
+	require.NoError(t, err)
+	mf, err := mg.Generate(&bp, res[0].ImgType, nil)
+	require.NoError(t, err)
+	decoded, err := osbuild.NewManifestFromBytes(mf)
+	require.NoError(t, err)
+	users := osbuild.FindStageOptions[*osbuild.UsersStageOptions](decoded.GetPipeline("os"))
+	require.Len(t, users, 1)
+	assert.Equal(t, []string{"wheel"}, users[0].Users["alice"].Groups)
+	cmdlines := osbuild.FindStageOptions[*osbuild.KernelCmdlineStageOptions](decoded.GetPipeline("os"))
+	require.Len(t, cmdlines, 1)
+	assert.Contains(t, cmdlines[0].KernelOpts, "debug")
+}
+
+// complianceHook adds an audit stamp, a scanner and its run to the OS of
+// every image and changes the language of the final manifest
+type complianceHook struct{}
+
+func (complianceHook) PreSerialize(m *manifest.Manifest) error {
+	os := m.OSPipeline()
+	if os == nil {
+		return fmt.Errorf("no os pipeline")
+	}
+	stamp, err := fsnode.NewFile("/etc/audit-stamp", nil, nil, nil, []byte("audited\n"))
+	if err != nil {
+		return err
+	}
+	os.OSCustomizations.Files = append(os.OSCustomizations.Files, stamp)
+	os.OSCustomizations.BasePackages = append(os.OSCustomizations.BasePackages, "scanner")
+	os.OSCustomizations.ExtraStages = append(os.OSCustomizations.ExtraStages, &osbuild.Stage{
+		Type:    "org.example.scan",
+		Options: osbuild.RawStageOptions(`{"profile":"strict"}`),
+	})
+	return nil
+}
+
+func (complianceHook) PostSerialize(mf *osbuild.Manifest) error {
+	for _, locale := range osbuild.FindStageOptions[*osbuild.LocaleStageOptions](mf.GetPipeline("os")) {
+		locale.Language = "de_DE.UTF-8"
+	}
+	return nil
+}
+
+func TestManifestGeneratorHooks(t *testing.T) {
+	repos, err := testrepos.New()
+	assert.NoError(t, err)
+	fac := distrofactory.NewDefault()
+
+	filter, err := imagefilter.New(fac, repos)
+	assert.NoError(t, err)
+
+	opts := &manifestgen.Options{
+		Depsolver:         fakeDepsolve,
+		CommitResolver:    fakeCommitResolver,
+		ContainerResolver: fakeContainerResolver,
+		Hooks:             []manifestgen.ManifestHook{complianceHook{}},
+	}
+	mg, err := manifestgen.New(repos, opts)
+	require.NoError(t, err)
+	var bp blueprint.Blueprint
+	for _, imgTypeName := range []string{"qcow2", "oci", "image-installer"} {
+		t.Run(imgTypeName, func(t *testing.T) {
+			res, err := filter.Filter("distro:centos-9", "type:"+imgTypeName, "arch:x86_64")
+			require.NoError(t, err)
+			require.Equal(t, 1, len(res))
+
+			mf, err := mg.Generate(&bp, res[0].ImgType, nil)
+			require.NoError(t, err)
+			decoded, err := osbuild.NewManifestFromBytes(mf)
+			require.NoError(t, err)
+			os := decoded.GetPipeline("os")
+			require.NotNil(t, os)
+
+			// the package of the scanner is depsolved
+			assert.Contains(t, string(mf), sha256For("scanner"))
+
+			copies := osbuild.FindStageOptions[*osbuild.CopyStageOptions](os)
+			var targets []string
+			for _, c := range copies {
+				for _, p := range c.Paths {
+					targets = append(targets, p.To)
+				}
+			}
+			assert.Contains(t, targets, "tree:///etc/audit-stamp")
+
+			scans := osbuild.FindStageOptions[osbuild.RawStageOptions](os)
+			require.Len(t, scans, 1)
+			assert.JSONEq(t, `{"profile":"strict"}`, string(scans[0]))
+
+			locales := osbuild.FindStageOptions[*osbuild.LocaleStageOptions](os)
+			require.Len(t, locales, 1)
+			assert.Equal(t, "de_DE.UTF-8", locales[0].Language)
+		})
+	}
+}
+
+func TestManifestGeneratorHooksErrors(t *testing.T) {
+	repos, err := testrepos.New()
+	assert.NoError(t, err)
+	fac := distrofactory.NewDefault()
+
+	filter, err := imagefilter.New(fac, repos)
+	assert.NoError(t, err)
+	res, err := filter.Filter("distro:centos-9", "type:qcow2", "arch:x86_64")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(res))
+
+	for _, tc := range []struct {
+		hook        manifestgen.ManifestHookFuncs
+		expectedErr string
+	}{
+		{
+			hook: manifestgen.ManifestHookFuncs{
+				Pre: func(m *manifest.Manifest) error { return fmt.Errorf("pre failed") },
+			},
+			expectedErr: "manifest hook failed: pre failed",
+		},
+		{
+			hook: manifestgen.ManifestHookFuncs{
+				Post: func(mf *osbuild.Manifest) error { return fmt.Errorf("post failed") },
+			},
+			expectedErr: "manifest hook failed: post failed",
+		},
+	} {
+		opts := &manifestgen.Options{
+			Depsolver:         fakeDepsolve,
+			CommitResolver:    panicCommitResolver,
+			ContainerResolver: panicContainerResolver,
+			Hooks:             []manifestgen.ManifestHook{tc.hook},
+		}
+		mg, err := manifestgen.New(repos, opts)
+		require.NoError(t, err)
+		var bp blueprint.Blueprint
+		_, err = mg.Generate(&bp, res[0].ImgType, nil)
+		assert.EqualError(t, err, tc.expectedErr)
+	}
+}
@@ -0,0 +1,257 @@
+package manifestgen
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/containers/image/v5/docker/reference"
+
+	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/depsolvednf"
+	"github.com/osbuild/images/pkg/ostree"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// Mirror is a local mirror of the content of the manifests, for building
+// images without access to the original repositories and registries.
+// With a mirror the sources of the manifests point to the mirror instead
+// of the original locations:
+//
+//	<URL>/rpms/<algorithm>/<hash>.rpm  rpms by their checksum
+//	<URL>/ostree                       an ostree repository with all commits
+//	<Registry>/<domain>/<path>         container images by their digest
+//
+// The mirror is populated from the fetch list (see FetchListWriter), the
+// content is still resolved (depsolved) from the original locations.
+type Mirror struct {
+	// URL of the mirror for rpms and ostree commits, e.g.
+	// "file:///srv/mirror" or "http://mirror.example.com:8080"
+	URL string
+
+	// Registry that mirrors the container images, e.g.
+	// "localhost:5000". Required if the manifests contain container
+	// images.
+	Registry string
+	// RegistryTLSVerify controls the TLS verification of the registry
+	RegistryTLSVerify *bool
+}
+
+func (m *Mirror) validate() error {
+	u, err := url.Parse(m.URL)
+	if err != nil {
+		return fmt.Errorf("invalid mirror url %q: %w", m.URL, err)
+	}
+	switch u.Scheme {
+	case "file", "http", "https":
+	default:
+		return fmt.Errorf("invalid mirror url %q: scheme must be file, http or https", m.URL)
+	}
+	return nil
+}
+
+func (m *Mirror) url(p string) string {
+	return strings.TrimSuffix(m.URL, "/") + "/" + p
+}
+
+// mirrorRPMPath returns the path of an rpm in the mirror
+func mirrorRPMPath(checksum string) (string, error) {
+	algo, hash, ok := strings.Cut(checksum, ":")
+	if !ok || algo == "" || hash == "" {
+		return "", fmt.Errorf("invalid rpm checksum %q", checksum)
+	}
+	return fmt.Sprintf("rpms/%s/%s.rpm", algo, hash), nil
+}
+
+// mirrorOSTreePath is the path of the ostree repository in the mirror
+const mirrorOSTreePath = "ostree"
+
+// mirrorContainerPath returns the path of a container image in the
+// mirror registry, the domain of the original registry is part of the
+// path so that images with the same path on different registries do not
+// clash
+func mirrorContainerPath(source string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(source)
+	if err != nil {
+		return "", fmt.Errorf("invalid container source %q: %w", source, err)
+	}
+	domain := strings.ReplaceAll(reference.Domain(named), ":", "-")
+	return domain + "/" + reference.Path(named), nil
+}
+
+// rewrite returns copies of the resolved content with the locations
+// pointing to the mirror
+func (m *Mirror) rewrite(depsolved map[string]depsolvednf.DepsolveResult, containers map[string][]container.Spec, commits map[string][]ostree.CommitSpec) (map[string]depsolvednf.DepsolveResult, map[string][]container.Spec, map[string][]ostree.CommitSpec, error) {
+	newDepsolved := make(map[string]depsolvednf.DepsolveResult, len(depsolved))
+	for name, res := range depsolved {
+		pkgs := make([]rpmmd.PackageSpec, len(res.Packages))
+		for idx, pkg := range res.Packages {
+			p, err := mirrorRPMPath(pkg.Checksum)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("package %s: %w", pkg.Name, err)
+			}
+			pkg.RemoteLocation = m.url(p)
+			// the mirror is local, no subscription or client
+			// certificates are needed
+			pkg.Secrets = ""
+			pkg.IgnoreSSL = false
+			pkgs[idx] = pkg
+		}
+		res.Packages = pkgs
+		newDepsolved[name] = res
+	}
+
+	newContainers := make(map[string][]container.Spec, len(containers))
+	for name, specs := range containers {
+		newSpecs := make([]container.Spec, len(specs))
+		for idx, spec := range specs {
+			if !spec.LocalStorage {
+				if m.Registry == "" {
+					return nil, nil, nil, fmt.Errorf("mirror has no registry for container %q", spec.Source)
+				}
+				p, err := mirrorContainerPath(spec.Source)
+				if err != nil {
+					return nil, nil, nil, err
+				}
+				spec.Source = m.Registry + "/" + p
+				spec.TLSVerify = m.RegistryTLSVerify
+			}
+			newSpecs[idx] = spec
+		}
+		newContainers[name] = newSpecs
+	}
+
+	newCommits := make(map[string][]ostree.CommitSpec, len(commits))
+	for name, specs := range commits {
+		newSpecs := make([]ostree.CommitSpec, len(specs))
+		for idx, spec := range specs {
+			spec.URL = m.url(mirrorOSTreePath)
+			spec.ContentURL = ""
+			spec.Secrets = ""
+			newSpecs[idx] = spec
+		}
+		newCommits[name] = newSpecs
+	}
+
+	return newDepsolved, newContainers, newCommits, nil
+}
+
+// FetchList is the content of a manifest with the original locations and
+// the locations in the mirror (see Mirror), to populate the mirror on a
+// machine with access to the original locations, e.g. with
+//
+//	curl -o <mirror>/<path> <url>
+//	skopeo copy --all --preserve-digests docker://<source>@<digest> docker://<registry>/<path>:<tag>
+//	ostree --repo=<mirror>/ostree pull <remote with the url> <checksum>
+//
+// Container images are fetched by their (manifest list) digest which pins
+// all of their blobs.
+type FetchList struct {
+	RPMs       []FetchRPM       `json:"rpms,omitempty"`
+	Containers []FetchContainer `json:"containers,omitempty"`
+	Commits    []FetchCommit    `json:"commits,omitempty"`
+}
+
+type FetchRPM struct {
+	Checksum string `json:"checksum"`
+	URL      string `json:"url"`
+	// Secrets needed to fetch the rpm, e.g. "org.osbuild.rhsm"
+	Secrets string `json:"secrets,omitempty"`
+	// Path in the mirror
+	Path string `json:"path"`
+}
+
+type FetchContainer struct {
+	Source     string `json:"source"`
+	Digest     string `json:"digest"`
+	ListDigest string `json:"list_digest,omitempty"`
+	ImageID    string `json:"image_id"`
+	// Path in the mirror registry
+	Path string `json:"path"`
+}
+
+type FetchCommit struct {
+	Checksum   string `json:"checksum"`
+	Ref        string `json:"ref,omitempty"`
+	URL        string `json:"url"`
+	ContentURL string `json:"contenturl,omitempty"`
+	Secrets    string `json:"secrets,omitempty"`
+	// Path of the ostree repository in the mirror
+	Path string `json:"path"`
+}
+
+// newFetchList returns the fetch list for the resolved content of a
+// manifest, content that is used by multiple pipelines is only listed once
+func newFetchList(depsolved map[string]depsolvednf.DepsolveResult, containers map[string][]container.Spec, commits map[string][]ostree.CommitSpec) (*FetchList, error) {
+	fl := &FetchList{}
+
+	seen := make(map[string]bool)
+	for _, res := range depsolved {
+		for _, pkg := range res.Packages {
+			if seen[pkg.Checksum] {
+				continue
+			}
+			seen[pkg.Checksum] = true
+			p, err := mirrorRPMPath(pkg.Checksum)
+			if err != nil {
+				return nil, fmt.Errorf("package %s: %w", pkg.Name, err)
+			}
+			fl.RPMs = append(fl.RPMs, FetchRPM{
+				Checksum: pkg.Checksum,
+				URL:      pkg.RemoteLocation,
+				Secrets:  pkg.Secrets,
+				Path:     p,
+			})
+		}
+	}
+	sort.Slice(fl.RPMs, func(i, j int) bool {
+		return fl.RPMs[i].Checksum < fl.RPMs[j].Checksum
+	})
+
+	for _, specs := range containers {
+		for _, spec := range specs {
+			// local containers are not fetched
+			if spec.LocalStorage || seen[spec.ImageID] {
+				continue
+			}
+			seen[spec.ImageID] = true
+			p, err := mirrorContainerPath(spec.Source)
+			if err != nil {
+				return nil, err
+			}
+			fl.Containers = append(fl.Containers, FetchContainer{
+				Source:     spec.Source,
+				Digest:     spec.Digest,
+				ListDigest: spec.ListDigest,
+				ImageID:    spec.ImageID,
+				Path:       p,
+			})
+		}
+	}
+	sort.Slice(fl.Containers, func(i, j int) bool {
+		return fl.Containers[i].ImageID < fl.Containers[j].ImageID
+	})
+
+	for _, specs := range commits {
+		for _, spec := range specs {
+			if seen[spec.Checksum] {
+				continue
+			}
+			seen[spec.Checksum] = true
+			fl.Commits = append(fl.Commits, FetchCommit{
+				Checksum:   spec.Checksum,
+				Ref:        spec.Ref,
+				URL:        spec.URL,
+				ContentURL: spec.ContentURL,
+				Secrets:    spec.Secrets,
+				Path:       mirrorOSTreePath,
+			})
+		}
+	}
+	sort.Slice(fl.Commits, func(i, j int) bool {
+		return fl.Commits[i].Checksum < fl.Commits[j].Checksum
+	})
+
+	return fl, nil
+}
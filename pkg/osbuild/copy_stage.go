@@ -2,6 +2,9 @@ package osbuild
 
 import (
 	"fmt"
+	"maps"
+	"slices"
+	"sort"
 
 	"github.com/osbuild/images/pkg/disk"
 )
@@ -77,3 +80,45 @@ func GenCopyFSTreeOptions(inputName, inputPipeline, filename string, pt *disk.Pa
 
 	return &options, devices, mounts
 }
+
+// GenDataDisksMountsDevices adds the devices and mounts of the
+// filesystems of the data disks (see disk.NewDataPartitionTable()) to the
+// devices and mounts of the main disk (see GenCopyFSTreeOptions()). The
+// filesystems are mounted at their mountpoints, a copy of the full tree
+// to the main disk puts the content below them on the data disks only.
+// filenames are the names of the image files of the data disks.
+func GenDataDisksMountsDevices(devices map[string]Device, mounts []Mount, filenames []string, pts []*disk.PartitionTable) (map[string]Device, []Mount, error) {
+	if len(filenames) != len(pts) {
+		return nil, nil, fmt.Errorf("got %d filenames for %d data disks", len(filenames), len(pts))
+	}
+
+	allDevices := maps.Clone(devices)
+	allMounts := slices.Clone(mounts)
+	for idx, pt := range pts {
+		dataMounts, dataDevices, err := genMountsDevices(filenames[idx], pt)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, name := range slices.Sorted(maps.Keys(dataDevices)) {
+			if _, exists := allDevices[name]; exists {
+				return nil, nil, fmt.Errorf("the device name %q of data disk %d is already used", name, idx+1)
+			}
+			allDevices[name] = dataDevices[name]
+		}
+		for _, mount := range dataMounts {
+			if slices.ContainsFunc(allMounts, func(m Mount) bool { return m.Name == mount.Name }) {
+				return nil, nil, fmt.Errorf("the mount name %q of data disk %d is already used", mount.Name, idx+1)
+			}
+			allMounts = append(allMounts, mount)
+		}
+	}
+
+	// the mounts of all disks are sorted like the ones of a single disk
+	// (see genMountsDevices()), the data disks are mounted after the
+	// filesystems of the main disk they are mounted on
+	sort.SliceStable(allMounts, func(i, j int) bool {
+		return allMounts[i].Target < allMounts[j].Target
+	})
+
+	return allDevices, allMounts, nil
+}
@@ -5,6 +5,11 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/testdisk"
+	"github.com/osbuild/images/pkg/datasizes"
+	"github.com/osbuild/images/pkg/disk"
 )
 
 func TestNewCopyStage(t *testing.T) {
@@ -67,3 +72,47 @@ func TestNewCopyStageSimpleSourcesInputs(t *testing.T) {
 	actualStage := NewCopyStageSimple(&CopyStageOptions{paths}, &filesInputs)
 	assert.Equal(t, expectedStage, actualStage)
 }
+
+func TestGenDataDisksMountsDevices(t *testing.T) {
+	_, mounts, devices, err := GenMountsDevicesFromPT("disk.img", testdisk.MakeFakePartitionTable("/", "/var"))
+	require.NoError(t, err)
+
+	dataPT := &disk.PartitionTable{
+		Type: disk.PT_GPT,
+		Partitions: []disk.Partition{
+			{
+				Start: 1 * datasizes.MiB,
+				Size:  10 * datasizes.GiB,
+				Payload: &disk.Filesystem{
+					Type:       "xfs",
+					Mountpoint: "/var/lib/containers",
+				},
+			},
+			{
+				Start: 10*datasizes.GiB + 1*datasizes.MiB,
+				Size:  5 * datasizes.GiB,
+				Payload: &disk.Filesystem{
+					Type:       "ext4",
+					Mountpoint: "/srv",
+				},
+			},
+		},
+	}
+
+	allDevices, allMounts, err := GenDataDisksMountsDevices(devices, mounts, []string{"disk-data-1.img"}, []*disk.PartitionTable{dataPT})
+	require.NoError(t, err)
+	var targets []string
+	for _, mount := range allMounts {
+		targets = append(targets, mount.Target)
+	}
+	// the data disk filesystems are mounted below the ones of the main disk
+	assert.Equal(t, []string{"/", "/srv", "/var", "/var/lib/containers"}, targets)
+	assert.Len(t, allDevices, len(devices)+2)
+	assert.Equal(t, "disk-data-1.img", allDevices["srv"].Options.(*LoopbackDeviceOptions).Filename)
+	// the devices and mounts of the main disk are not modified
+	assert.Len(t, mounts, 2)
+	assert.Len(t, devices, 2)
+
+	_, _, err = GenDataDisksMountsDevices(devices, mounts, []string{"disk-data-1.img", "disk-data-2.img"}, []*disk.PartitionTable{dataPT, dataPT})
+	assert.EqualError(t, err, `the device name "srv" of data disk 2 is already used`)
+}
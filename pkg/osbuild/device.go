@@ -1,6 +1,7 @@
 package osbuild
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"sort"
@@ -19,6 +20,35 @@ type DeviceOptions interface {
 	isDeviceOptions()
 }
 
+// RawDeviceOptions are the options of a decoded device, they are kept as
+// they are serialized.
+type RawDeviceOptions json.RawMessage
+
+func (RawDeviceOptions) isDeviceOptions() {}
+
+func (o RawDeviceOptions) MarshalJSON() ([]byte, error) {
+	return json.RawMessage(o).MarshalJSON()
+}
+
+func (d *Device) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Type    string          `json:"type"`
+		Parent  string          `json:"parent,omitempty"`
+		Options json.RawMessage `json:"options,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*d = Device{
+		Type:   raw.Type,
+		Parent: raw.Parent,
+	}
+	if !isNullJSON(raw.Options) {
+		d.Options = RawDeviceOptions(raw.Options)
+	}
+	return nil
+}
+
 func GenDeviceCreationStages(pt *disk.PartitionTable, filename string) []*Stage {
 	stages := make([]*Stage, 0)
 
@@ -259,6 +289,12 @@ func genOsbuildMount(source string, mnt disk.Mountable) (*Mount, error) {
 		return NewFATMount(name, source, mountpoint), nil
 	case "ext4":
 		return NewExt4Mount(name, source, mountpoint), nil
+	case "f2fs":
+		return NewF2fsMount(name, source, mountpoint), nil
+	case "erofs", "squashfs":
+		// while the disk image is created read-only filesystems
+		// are backed by a scratch ext4 filesystem, see readonly_fs.go
+		return NewExt4Mount(name, source, mountpoint), nil
 	case "btrfs":
 		if subvol, isSubvol := mnt.(*disk.BtrfsSubvolume); isSubvol {
 			return NewBtrfsMount(name, source, mountpoint, subvol.Name, subvol.Compress), nil
@@ -279,9 +315,29 @@ func genOsbuildMount(source string, mnt disk.Mountable) (*Mount, error) {
 // 3) generated devices
 // 4) error if any
 func GenMountsDevicesFromPT(filename string, pt *disk.PartitionTable) (string, []Mount, map[string]Device, error) {
+	mounts, devices, err := genMountsDevices(filename, pt)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	var fsRootMntName string
+	for _, mount := range mounts {
+		if mount.Target == "/" {
+			fsRootMntName = mount.Name
+		}
+	}
+	if fsRootMntName == "" {
+		return "", nil, nil, fmt.Errorf("no mount found for the filesystem root")
+	}
+
+	return fsRootMntName, mounts, devices, nil
+}
+
+// genMountsDevices generates the (sorted) mounts and the devices for all
+// mountables in the partition table.
+func genMountsDevices(filename string, pt *disk.PartitionTable) ([]Mount, map[string]Device, error) {
 	devices := make(map[string]Device, len(pt.Partitions))
 	mounts := make([]Mount, 0, len(pt.Partitions))
-	var fsRootMntName string
 	genMounts := func(mnt disk.Mountable, path []disk.Entity) error {
 		stageDevices, leafDeviceName := getDevices(path, filename, false)
 		mount, err := genOsbuildMount(leafDeviceName, mnt)
@@ -289,11 +345,6 @@ func GenMountsDevicesFromPT(filename string, pt *disk.PartitionTable) (string, [
 			return err
 		}
 
-		mountpoint := mnt.GetMountpoint()
-		if mountpoint == "/" {
-			fsRootMntName = mount.Name
-		}
-
 		mounts = append(mounts, *mount)
 
 		// update devices map with new elements from stageDevices
@@ -311,7 +362,7 @@ func GenMountsDevicesFromPT(filename string, pt *disk.PartitionTable) (string, [
 	}
 
 	if err := pt.ForEachMountable(genMounts); err != nil {
-		return "", nil, nil, err
+		return nil, nil, err
 	}
 
 	// sort the mounts, using < should just work because:
@@ -322,9 +373,5 @@ func GenMountsDevicesFromPT(filename string, pt *disk.PartitionTable) (string, [
 		return mounts[i].Target < mounts[j].Target
 	})
 
-	if fsRootMntName == "" {
-		return "", nil, nil, fmt.Errorf("no mount found for the filesystem root")
-	}
-
-	return fsRootMntName, mounts, devices, nil
+	return mounts, devices, nil
 }
@@ -168,7 +168,8 @@ func GenImagePrepareStages(pt *disk.PartitionTable, filename string, partTool Pa
 }
 
 func GenImageFinishStages(pt *disk.PartitionTable, filename string) []*Stage {
-	return GenDeviceFinishStages(pt, filename)
+	stages := genReadOnlyFSWriteStages(pt, filename)
+	return append(stages, GenDeviceFinishStages(pt, filename)...)
 }
 
 func GenImageKernelOptions(pt *disk.PartitionTable, mountConfiguration MountConfiguration) (string, []string, error) {
@@ -178,6 +179,9 @@ func GenImageKernelOptions(pt *disk.PartitionTable, mountConfiguration MountConf
 	if rootFs == nil {
 		return "", nil, fmt.Errorf("root filesystem must be defined for kernel-cmdline stage, this is a programming error")
 	}
+	if fs, ok := rootFs.(*disk.Filesystem); ok && fs.ReadOnly() {
+		return "", nil, disk.ErrReadOnlyRootUnsupported
+	}
 	rootFsUUID := rootFs.GetFSSpec().UUID
 
 	// if /usr is on a separate filesystem, it needs to be defined in the
@@ -190,9 +194,17 @@ func GenImageKernelOptions(pt *disk.PartitionTable, mountConfiguration MountConf
 		if err != nil {
 			panic(fmt.Sprintf("error getting filesystem options for /usr mountpoint: %s", err))
 		}
+		usrSpec := fmt.Sprintf("UUID=%s", usrFs.GetFSSpec().UUID)
+		device, err := findReadOnlyFSDevice(pt, "/usr")
+		if err != nil {
+			return "", nil, err
+		}
+		if device != "" {
+			usrSpec = device
+		}
 		cmdline = append(
 			cmdline,
-			fmt.Sprintf("mount.usr=UUID=%s", usrFs.GetFSSpec().UUID),
+			fmt.Sprintf("mount.usr=%s", usrSpec),
 			fmt.Sprintf("mount.usrfstype=%s", usrFs.GetFSType()),
 			fmt.Sprintf("mount.usrflags=%s", fsOptions.MntOps),
 		)
@@ -0,0 +1,10 @@
+package osbuild
+
+func NewF2fsMount(name, source, target string) *Mount {
+	return &Mount{
+		Type:   "org.osbuild.f2fs",
+		Name:   name,
+		Source: source,
+		Target: target,
+	}
+}
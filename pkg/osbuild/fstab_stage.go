@@ -37,6 +37,7 @@ func NewFSTabStage(options *FSTabStageOptions) *Stage {
 type FSTabEntry struct {
 	UUID    string `json:"uuid,omitempty"`
 	Label   string `json:"label,omitempty"`
+	Device  string `json:"device,omitempty"`
 	VFSType string `json:"vfs_type"`
 	Path    string `json:"path,omitempty"`
 	Options string `json:"options,omitempty"`
@@ -56,7 +57,10 @@ func (options *FSTabStageOptions) AddFilesystem(id string, vfsType string, path
 	})
 }
 
-func NewFSTabStageOptions(pt *disk.PartitionTable) (*FSTabStageOptions, error) {
+// NewFSTabStageOptions creates the options for the fstab of the given
+// partition table and the partition tables of the data disks of the
+// image (if any).
+func NewFSTabStageOptions(pt *disk.PartitionTable, dataPTs ...*disk.PartitionTable) (*FSTabStageOptions, error) {
 	var options FSTabStageOptions
 	genOption := func(mnt disk.FSTabEntity, path []disk.Entity) error {
 		fsSpec := mnt.GetFSSpec()
@@ -64,6 +68,21 @@ func NewFSTabStageOptions(pt *disk.PartitionTable) (*FSTabStageOptions, error) {
 		if err != nil {
 			return err
 		}
+		if fs, ok := mnt.(*disk.Filesystem); ok && fs.ReadOnly() {
+			device, err := readOnlyFSDevice(mnt, path)
+			if err != nil {
+				return err
+			}
+			options.FileSystems = append(options.FileSystems, &FSTabEntry{
+				Device:  device,
+				VFSType: mnt.GetFSType(),
+				Path:    mnt.GetFSFile(),
+				Options: fsOptions.MntOps,
+				Freq:    fsOptions.Freq,
+				PassNo:  fsOptions.PassNo,
+			})
+			return nil
+		}
 		options.AddFilesystem(fsSpec.UUID, mnt.GetFSType(), mnt.GetFSFile(), fsOptions.MntOps, fsOptions.Freq, fsOptions.PassNo)
 		return nil
 	}
@@ -72,9 +91,17 @@ func NewFSTabStageOptions(pt *disk.PartitionTable) (*FSTabStageOptions, error) {
 		return fmt.Sprintf("%d%s", fs.PassNo, fs.Path)
 	}
 
-	err := pt.ForEachFSTabEntity(genOption)
-	if err != nil {
-		return nil, err
+	for _, pt := range append([]*disk.PartitionTable{pt}, dataPTs...) {
+		err := pt.ForEachFSTabEntity(func(ent disk.FSTabEntity, path []disk.Entity) error {
+			// mounted by systemd-gpt-auto-generator
+			if pt.DPSAutoMounted(ent, path) {
+				return nil
+			}
+			return genOption(ent, path)
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// sort the entries by PassNo to maintain backward compatibility
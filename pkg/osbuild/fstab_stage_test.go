@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/osbuild/images/internal/testdisk"
+	"github.com/osbuild/images/pkg/disk"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -128,3 +129,60 @@ func TestNewFSTabStageOptions(t *testing.T) {
 		})
 	}
 }
+
+func TestNewFSTabStageOptionsDataDisks(t *testing.T) {
+	pt := &disk.PartitionTable{
+		Partitions: []disk.Partition{
+			{
+				Payload: &disk.Filesystem{
+					Type:         "xfs",
+					UUID:         "6e4ff95f-f662-45ee-a82a-bdf44a2d0b75",
+					Mountpoint:   "/",
+					FSTabOptions: "defaults",
+				},
+			},
+		},
+	}
+	dataPT := &disk.PartitionTable{
+		Partitions: []disk.Partition{
+			{
+				Payload: &disk.Filesystem{
+					Type:         "xfs",
+					UUID:         "0194fdc2-fa2f-4cc0-81d3-ff12045b73c8",
+					Mountpoint:   "/var/lib/containers",
+					FSTabOptions: "defaults",
+				},
+			},
+		},
+	}
+
+	options, err := NewFSTabStageOptions(pt, dataPT)
+	require.NoError(t, err)
+	assert.Equal(t, []*FSTabEntry{
+		{UUID: "6e4ff95f-f662-45ee-a82a-bdf44a2d0b75", VFSType: "xfs", Path: "/", Options: "defaults"},
+		{UUID: "0194fdc2-fa2f-4cc0-81d3-ff12045b73c8", VFSType: "xfs", Path: "/var/lib/containers", Options: "defaults"},
+	}, options.FileSystems)
+}
+
+func TestNewFSTabStageOptionsDPS(t *testing.T) {
+	pt := testdisk.MakeFakePartitionTable("/", "/home", "swap")
+	pt.DPS = true
+	pt.Partitions[1].Type = disk.HomePartitionGUID
+	pt.Partitions[2].Type = disk.SwapPartitionGUID
+
+	// /home and swap are mounted by systemd-gpt-auto-generator
+	options, err := NewFSTabStageOptions(pt)
+	require.NoError(t, err)
+	require.Len(t, options.FileSystems, 1)
+	assert.Equal(t, "/", options.FileSystems[0].Path)
+
+	stages, err := GenSystemdMountStages(pt)
+	require.NoError(t, err)
+	var units []string
+	for _, stage := range stages {
+		if opts, ok := stage.Options.(*SystemdUnitCreateStageOptions); ok {
+			units = append(units, opts.Filename)
+		}
+	}
+	assert.Equal(t, []string{"-.mount"}, units)
+}
@@ -1,10 +1,22 @@
 package osbuild
 
+import "encoding/json"
+
 // Collection of Inputs for a Stage
 type Inputs interface {
 	isStageInputs()
 }
 
+// RawInputs are the inputs of a decoded stage, they are kept as they are
+// serialized.
+type RawInputs json.RawMessage
+
+func (RawInputs) isStageInputs() {}
+
+func (i RawInputs) MarshalJSON() ([]byte, error) {
+	return json.RawMessage(i).MarshalJSON()
+}
+
 // Single Input for a Stage
 type Input interface {
 	isInput()
@@ -0,0 +1,504 @@
+// Package manifestdiff compares two osbuild manifests semantically: it
+// reports the pipelines that were added or removed, the stages that were
+// inserted, deleted, moved or whose options changed, the packages that
+// are installed in a different version and the changes of the sources.
+//
+// The stage options and inputs are compared as plain json values, so
+// manifests with stages that are unknown to this library can be compared
+// as well.
+package manifestdiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/osbuild"
+)
+
+// Change is the kind of a difference between two manifests
+type Change string
+
+const (
+	Added   Change = "added"
+	Removed Change = "removed"
+	Changed Change = "changed"
+	// Moved is used for stages that are unchanged but at a different
+	// position of their pipeline
+	Moved Change = "moved"
+	// Upgraded and Downgraded are used for packages that are installed
+	// in a newer or older version
+	Upgraded   Change = "upgraded"
+	Downgraded Change = "downgraded"
+)
+
+// Diff is the semantic difference between two manifests
+type Diff struct {
+	// Changes of the top-level values of the manifest, e.g. the version
+	Changes   []ValueChange  `json:"changes,omitempty"`
+	Pipelines []PipelineDiff `json:"pipelines,omitempty"`
+	Sources   []SourceDiff   `json:"sources,omitempty"`
+}
+
+// ValueChange is a changed json value. Old is nil for added and New is
+// nil for removed values.
+type ValueChange struct {
+	// Path of the value, e.g. `options.users.alice.groups[0]`
+	Path string `json:"path"`
+	Old  any    `json:"old,omitempty"`
+	New  any    `json:"new,omitempty"`
+}
+
+// PipelineDiff is the difference of a pipeline. Only the Name and the
+// Change are set for pipelines that were added or removed.
+type PipelineDiff struct {
+	Name   string `json:"name"`
+	Change Change `json:"change"`
+	// Changes of the build and runner of the pipeline
+	Changes  []ValueChange `json:"changes,omitempty"`
+	Stages   []StageDiff   `json:"stages,omitempty"`
+	Packages []PackageDiff `json:"packages,omitempty"`
+}
+
+// StageDiff is the difference of a stage. The indices are the positions
+// of the stage in the pipeline of the old and the new manifest, OldIndex
+// is nil for added and NewIndex for removed stages.
+type StageDiff struct {
+	Type     string `json:"type"`
+	Change   Change `json:"change"`
+	OldIndex *int   `json:"old_index,omitempty"`
+	NewIndex *int   `json:"new_index,omitempty"`
+	// Changes of the options, inputs, devices and mounts of the stage.
+	// The references of inputs from sources are not compared, they
+	// change with the content they refer to, which is reported in the
+	// packages and the sources.
+	Changes []ValueChange `json:"changes,omitempty"`
+}
+
+// PackageDiff is the difference of a package installed by the rpm stages
+// of a pipeline, packages are identified by their name and architecture.
+// The versions are "version-release" (the epoch is not part of the
+// manifest), Old is empty for added and New for removed packages.
+type PackageDiff struct {
+	Name   string `json:"name"`
+	Arch   string `json:"arch,omitempty"`
+	Change Change `json:"change"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new,omitempty"`
+}
+
+// SourceDiff is the difference of a source, e.g. "org.osbuild.curl"
+type SourceDiff struct {
+	Name   string `json:"name"`
+	Change Change `json:"change"`
+	// Changes of the options of the source
+	Changes []ValueChange    `json:"changes,omitempty"`
+	Items   []SourceItemDiff `json:"items,omitempty"`
+}
+
+// SourceItemDiff is the difference of an item of a source, identified
+// by its id (usually the checksum of the content)
+type SourceItemDiff struct {
+	ID     string `json:"id"`
+	Change Change `json:"change"`
+	Old    any    `json:"old,omitempty"`
+	New    any    `json:"new,omitempty"`
+}
+
+// Empty returns true if the manifests are equivalent
+func (d *Diff) Empty() bool {
+	return len(d.Changes) == 0 && len(d.Pipelines) == 0 && len(d.Sources) == 0
+}
+
+// manifest is the generic representation of a serialized osbuild
+// manifest, the stage options of osbuild.Manifest cannot be unmarshalled
+// and its sources are limited to the known source types
+type manifest struct {
+	Pipelines []pipeline        `json:"pipelines"`
+	Sources   map[string]source `json:"sources"`
+
+	// all other top-level values, e.g. the version
+	extra    map[string]any
+	packages map[string]packageRef
+}
+
+type pipeline struct {
+	Name   string  `json:"name"`
+	Build  string  `json:"build,omitempty"`
+	Runner string  `json:"runner,omitempty"`
+	Stages []stage `json:"stages"`
+}
+
+type stage struct {
+	Type string `json:"type"`
+	// content of the stage without the type, id and source references
+	content map[string]any
+	// ids of the packages installed by an rpm stage
+	packages []string
+}
+
+type source struct {
+	Items   map[string]any `json:"items"`
+	Options any            `json:"options,omitempty"`
+}
+
+func decode(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// keep the numbers as they are, e.g. sizes
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+func (s *stage) UnmarshalJSON(data []byte) error {
+	var content map[string]any
+	if err := decode(data, &content); err != nil {
+		return err
+	}
+	typ, ok := content["type"].(string)
+	if !ok {
+		return fmt.Errorf("stage without a type: %s", data)
+	}
+	delete(content, "type")
+	delete(content, "id")
+
+	s.Type = typ
+	s.content = content
+	s.packages = nil
+
+	inputs, _ := content["inputs"].(map[string]any)
+	for name, in := range inputs {
+		input, ok := in.(map[string]any)
+		if !ok || input["origin"] != osbuild.InputOriginSource {
+			continue
+		}
+		if typ == "org.osbuild.rpm" && name == "packages" {
+			s.packages = referenceIDs(input["references"])
+		}
+		delete(input, "references")
+	}
+	return nil
+}
+
+// referenceIDs returns the ids of the references of an input, which are
+// either a list of ids, a list of objects with an id or an object with
+// the ids as keys
+func referenceIDs(refs any) []string {
+	var ids []string
+	switch refs := refs.(type) {
+	case []any:
+		for _, ref := range refs {
+			switch ref := ref.(type) {
+			case string:
+				ids = append(ids, ref)
+			case map[string]any:
+				if id, ok := ref["id"].(string); ok {
+					ids = append(ids, id)
+				}
+			}
+		}
+	case map[string]any:
+		for id := range refs {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func parseManifest(data []byte) (*manifest, error) {
+	var m manifest
+	if err := decode(data, &m); err != nil {
+		return nil, err
+	}
+	if err := decode(data, &m.extra); err != nil {
+		return nil, err
+	}
+	delete(m.extra, "pipelines")
+	delete(m.extra, "sources")
+
+	names := make(map[string]bool, len(m.Pipelines))
+	for _, p := range m.Pipelines {
+		if names[p.Name] {
+			return nil, fmt.Errorf("pipeline %q is defined more than once", p.Name)
+		}
+		names[p.Name] = true
+	}
+	m.packages = packageRefs(m.Sources)
+	return &m, nil
+}
+
+// Compare returns the difference between two serialized manifests
+func Compare(oldManifest, newManifest []byte) (*Diff, error) {
+	oldM, err := parseManifest(oldManifest)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse old manifest: %w", err)
+	}
+	newM, err := parseManifest(newManifest)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse new manifest: %w", err)
+	}
+	return compareManifests(oldM, newM), nil
+}
+
+// CompareManifests returns the difference between two manifests
+func CompareManifests(oldManifest, newManifest *osbuild.Manifest) (*Diff, error) {
+	oldData, err := json.Marshal(oldManifest)
+	if err != nil {
+		return nil, err
+	}
+	newData, err := json.Marshal(newManifest)
+	if err != nil {
+		return nil, err
+	}
+	return Compare(oldData, newData)
+}
+
+func compareManifests(oldM, newM *manifest) *Diff {
+	diff := &Diff{
+		Changes: diffValues("", toAny(oldM.extra), toAny(newM.extra), nil),
+	}
+
+	newPipelines := make(map[string]*pipeline, len(newM.Pipelines))
+	for idx := range newM.Pipelines {
+		newPipelines[newM.Pipelines[idx].Name] = &newM.Pipelines[idx]
+	}
+	oldPipelines := make(map[string]*pipeline, len(oldM.Pipelines))
+	for idx := range oldM.Pipelines {
+		p := &oldM.Pipelines[idx]
+		oldPipelines[p.Name] = p
+		if _, ok := newPipelines[p.Name]; !ok {
+			diff.Pipelines = append(diff.Pipelines, PipelineDiff{Name: p.Name, Change: Removed})
+		}
+	}
+	for idx := range newM.Pipelines {
+		p := &newM.Pipelines[idx]
+		oldP, ok := oldPipelines[p.Name]
+		if !ok {
+			diff.Pipelines = append(diff.Pipelines, PipelineDiff{Name: p.Name, Change: Added})
+			continue
+		}
+		pd := comparePipelines(oldP, p, oldM.packages, newM.packages)
+		if len(pd.Changes) > 0 || len(pd.Stages) > 0 || len(pd.Packages) > 0 {
+			diff.Pipelines = append(diff.Pipelines, pd)
+		}
+	}
+
+	diff.Sources = compareSources(oldM.Sources, newM.Sources)
+	return diff
+}
+
+func comparePipelines(oldP, newP *pipeline, oldPkgs, newPkgs map[string]packageRef) PipelineDiff {
+	pd := PipelineDiff{
+		Name:   newP.Name,
+		Change: Changed,
+	}
+	if oldP.Build != newP.Build {
+		pd.Changes = append(pd.Changes, ValueChange{Path: "build", Old: nilIfEmpty(oldP.Build), New: nilIfEmpty(newP.Build)})
+	}
+	if oldP.Runner != newP.Runner {
+		pd.Changes = append(pd.Changes, ValueChange{Path: "runner", Old: nilIfEmpty(oldP.Runner), New: nilIfEmpty(newP.Runner)})
+	}
+	pd.Stages = compareStages(oldP.Stages, newP.Stages)
+	pd.Packages = comparePackages(pipelinePackages(oldP, oldPkgs), pipelinePackages(newP, newPkgs))
+	return pd
+}
+
+// compareStages aligns the stages of two pipelines by their type (via
+// the longest common subsequence) and compares the aligned stages. Stages
+// that are deleted in one place and inserted unchanged in another are
+// reported as moved.
+func compareStages(oldStages, newStages []stage) []StageDiff {
+	n, m := len(oldStages), len(newStages)
+	// lcs[i][j] is the length of the longest common subsequence of
+	// oldStages[i:] and newStages[j:]
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if oldStages[i].Type == newStages[j].Type {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var diffs []StageDiff
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && oldStages[i].Type == newStages[j].Type:
+			changes := diffValues("", toAny(oldStages[i].content), toAny(newStages[j].content), nil)
+			if len(changes) > 0 {
+				diffs = append(diffs, StageDiff{
+					Type:     newStages[j].Type,
+					Change:   Changed,
+					OldIndex: common.ToPtr(i),
+					NewIndex: common.ToPtr(j),
+					Changes:  changes,
+				})
+			}
+			i++
+			j++
+		case j < m && (i == n || lcs[i][j+1] >= lcs[i+1][j]):
+			diffs = append(diffs, StageDiff{Type: newStages[j].Type, Change: Added, NewIndex: common.ToPtr(j)})
+			j++
+		default:
+			diffs = append(diffs, StageDiff{Type: oldStages[i].Type, Change: Removed, OldIndex: common.ToPtr(i)})
+			i++
+		}
+	}
+
+	// pair the removed stages with added stages of the same content
+	moved := make(map[int]bool)
+	for a := range diffs {
+		if diffs[a].Change != Added {
+			continue
+		}
+		for r := range diffs {
+			if diffs[r].Change != Removed || moved[r] || diffs[r].Type != diffs[a].Type {
+				continue
+			}
+			if reflect.DeepEqual(oldStages[*diffs[r].OldIndex].content, newStages[*diffs[a].NewIndex].content) {
+				diffs[a].Change = Moved
+				diffs[a].OldIndex = diffs[r].OldIndex
+				moved[r] = true
+				break
+			}
+		}
+	}
+	res := diffs[:0]
+	for idx, d := range diffs {
+		if !moved[idx] {
+			res = append(res, d)
+		}
+	}
+	return res
+}
+
+// compareSources compares the sources and their items
+func compareSources(oldSources, newSources map[string]source) []SourceDiff {
+	var diffs []SourceDiff
+	for _, name := range unionKeys(oldSources, newSources) {
+		oldS, inOld := oldSources[name]
+		newS, inNew := newSources[name]
+		sd := SourceDiff{Name: name, Change: Changed}
+		switch {
+		case !inOld:
+			sd.Change = Added
+		case !inNew:
+			sd.Change = Removed
+		default:
+			sd.Changes = diffValues("options", oldS.Options, newS.Options, nil)
+		}
+		for _, id := range unionKeys(oldS.Items, newS.Items) {
+			oldItem, inOld := oldS.Items[id]
+			newItem, inNew := newS.Items[id]
+			switch {
+			case !inOld:
+				sd.Items = append(sd.Items, SourceItemDiff{ID: id, Change: Added, New: newItem})
+			case !inNew:
+				sd.Items = append(sd.Items, SourceItemDiff{ID: id, Change: Removed, Old: oldItem})
+			case !reflect.DeepEqual(oldItem, newItem):
+				sd.Items = append(sd.Items, SourceItemDiff{ID: id, Change: Changed, Old: oldItem, New: newItem})
+			}
+		}
+		if sd.Change != Changed || len(sd.Changes) > 0 || len(sd.Items) > 0 {
+			diffs = append(diffs, sd)
+		}
+	}
+	return diffs
+}
+
+// diffValues appends the differences between two json values to changes
+func diffValues(path string, oldV, newV any, changes []ValueChange) []ValueChange {
+	oldMap, oldIsMap := oldV.(map[string]any)
+	newMap, newIsMap := newV.(map[string]any)
+	if oldIsMap && newIsMap {
+		for _, key := range unionKeys(oldMap, newMap) {
+			keyPath := joinPath(path, key)
+			oldVal, inOld := oldMap[key]
+			newVal, inNew := newMap[key]
+			switch {
+			case !inOld:
+				changes = append(changes, ValueChange{Path: keyPath, New: newVal})
+			case !inNew:
+				changes = append(changes, ValueChange{Path: keyPath, Old: oldVal})
+			default:
+				changes = diffValues(keyPath, oldVal, newVal, changes)
+			}
+		}
+		return changes
+	}
+
+	// lists are compared element by element, the elements at the end
+	// of the longer list are added or removed
+	oldList, oldIsList := oldV.([]any)
+	newList, newIsList := newV.([]any)
+	if oldIsList && newIsList {
+		for idx := 0; idx < max(len(oldList), len(newList)); idx++ {
+			idxPath := fmt.Sprintf("%s[%d]", path, idx)
+			switch {
+			case idx >= len(oldList):
+				changes = append(changes, ValueChange{Path: idxPath, New: newList[idx]})
+			case idx >= len(newList):
+				changes = append(changes, ValueChange{Path: idxPath, Old: oldList[idx]})
+			default:
+				changes = diffValues(idxPath, oldList[idx], newList[idx], changes)
+			}
+		}
+		return changes
+	}
+
+	if !reflect.DeepEqual(oldV, newV) {
+		changes = append(changes, ValueChange{Path: path, Old: oldV, New: newV})
+	}
+	return changes
+}
+
+// joinPath appends a key to the path of a value, keys that are not
+// identifiers (e.g. "gpgkeys.fromtree") are quoted
+func joinPath(path, key string) string {
+	if key == "" || strings.ContainsAny(key, ".[]\" ") {
+		return fmt.Sprintf("%s[%s]", path, strconv.Quote(key))
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func unionKeys[V any](a, b map[string]V) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// toAny converts a map to a json value, nil maps are json null
+func toAny(m map[string]any) any {
+	if m == nil {
+		return nil
+	}
+	return m
+}
+
+func nilIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,345 @@
+package manifestdiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/osbuild"
+)
+
+var oldManifest = []byte(`{
+  "version": "2",
+  "pipelines": [
+    {
+      "name": "build",
+      "runner": "org.osbuild.fedora41",
+      "stages": [
+        {
+          "type": "org.osbuild.rpm",
+          "inputs": {
+            "packages": {
+              "type": "org.osbuild.files",
+              "origin": "org.osbuild.source",
+              "references": [
+                {"id": "sha256:aaa"},
+                {"id": "sha256:bbb"},
+                {"id": "sha256:ccc"}
+              ]
+            }
+          }
+        }
+      ]
+    },
+    {
+      "name": "os",
+      "build": "name:build",
+      "stages": [
+        {"type": "org.osbuild.kernel-cmdline", "options": {"root_fs_uuid": "6e4f", "kernel_opts": "quiet"}},
+        {"type": "org.osbuild.hostname", "options": {"hostname": "old"}},
+        {"type": "org.osbuild.locale", "options": {"language": "en_US.UTF-8"}},
+        {"type": "org.osbuild.users", "options": {"users": {"alice": {"groups": ["wheel"]}}}},
+        {"type": "org.osbuild.selinux", "options": {"file_contexts": "etc/selinux/targeted/contexts/files/file_contexts"}}
+      ]
+    },
+    {
+      "name": "tar",
+      "build": "name:build",
+      "stages": [{"type": "org.osbuild.tar", "options": {"filename": "root.tar"}}]
+    }
+  ],
+  "sources": {
+    "org.osbuild.curl": {
+      "items": {
+        "sha256:aaa": "https://example.com/Packages/bash-5.2.26-3.fc41.x86_64.rpm",
+        "sha256:bbb": {"url": "https://example.com/Packages/kernel-6.11.4-301.fc41.x86_64.rpm"},
+        "sha256:ccc": "https://example.com/Packages/vim-minimal-9.1.785-1.fc41.x86_64.rpm"
+      }
+    },
+    "org.osbuild.inline": {
+      "items": {
+        "sha256:111": {"encoding": "base64", "data": "b2xk"}
+      }
+    }
+  }
+}`)
+
+var newManifest = []byte(`{
+  "version": "2",
+  "pipelines": [
+    {
+      "name": "build",
+      "runner": "org.osbuild.fedora42",
+      "stages": [
+        {
+          "type": "org.osbuild.rpm",
+          "inputs": {
+            "packages": {
+              "type": "org.osbuild.files",
+              "origin": "org.osbuild.source",
+              "references": [
+                {"id": "sha256:aaa"},
+                {"id": "sha256:ddd"},
+                {"id": "sha256:eee"},
+                {"id": "sha256:fff"}
+              ]
+            }
+          }
+        }
+      ]
+    },
+    {
+      "name": "os",
+      "build": "name:build",
+      "stages": [
+        {"type": "org.osbuild.kernel-cmdline", "options": {"root_fs_uuid": "6e4f", "kernel_opts": "quiet"}},
+        {"type": "org.osbuild.locale", "options": {"language": "en_US.UTF-8"}},
+        {"type": "org.osbuild.hostname", "options": {"hostname": "new"}},
+        {"type": "org.osbuild.users", "options": {"users": {"alice": {"groups": ["wheel", "adm"]}, "bob": {}}}},
+        {"type": "org.osbuild.selinux", "options": {"file_contexts": "etc/selinux/targeted/contexts/files/file_contexts", "labels": {"/usr/bin/cp": "system_u:object_r:install_exec_t:s0"}}},
+        {"type": "org.osbuild.fix-bls", "options": {}}
+      ]
+    },
+    {
+      "name": "qcow2",
+      "build": "name:build",
+      "stages": [{"type": "org.osbuild.qemu", "options": {"filename": "disk.qcow2"}}]
+    }
+  ],
+  "sources": {
+    "org.osbuild.curl": {
+      "items": {
+        "sha256:aaa": "https://example.com/Packages/bash-5.2.26-3.fc41.x86_64.rpm",
+        "sha256:ddd": {"url": "https://example.com/Packages/kernel-6.9.12-200.fc41.x86_64.rpm"},
+        "sha256:eee": "https://example.com/Packages/vim-minimal-9.1.825-1.fc42.x86_64.rpm",
+        "sha256:fff": "https://example.com/Packages/zstd-1.5.6-2.fc41.x86_64.rpm"
+      }
+    },
+    "org.osbuild.ostree": {
+      "items": {
+        "abc": {"remote": {"url": "https://example.com/repo"}}
+      }
+    }
+  }
+}`)
+
+func TestCompare(t *testing.T) {
+	diff, err := Compare(oldManifest, newManifest)
+	require.NoError(t, err)
+
+	assert.Empty(t, diff.Changes)
+	require.Len(t, diff.Pipelines, 4)
+	assert.Equal(t, PipelineDiff{Name: "tar", Change: Removed}, diff.Pipelines[0])
+	assert.Equal(t, PipelineDiff{Name: "qcow2", Change: Added}, diff.Pipelines[3])
+
+	// the package references of the rpm stage are reported as packages
+	build := diff.Pipelines[1]
+	assert.Equal(t, "build", build.Name)
+	assert.Equal(t, Changed, build.Change)
+	assert.Equal(t, []ValueChange{{Path: "runner", Old: "org.osbuild.fedora41", New: "org.osbuild.fedora42"}}, build.Changes)
+	assert.Empty(t, build.Stages)
+	assert.Equal(t, []PackageDiff{
+		{Name: "kernel", Arch: "x86_64", Change: Downgraded, Old: "6.11.4-301.fc41", New: "6.9.12-200.fc41"},
+		{Name: "vim-minimal", Arch: "x86_64", Change: Upgraded, Old: "9.1.785-1.fc41", New: "9.1.825-1.fc42"},
+		{Name: "zstd", Arch: "x86_64", Change: Added, New: "1.5.6-2.fc41"},
+	}, build.Packages)
+
+	osPipeline := diff.Pipelines[2]
+	assert.Equal(t, "os", osPipeline.Name)
+	assert.Empty(t, osPipeline.Packages)
+	// the locale stage was moved before the hostname stage
+	assert.Equal(t, []StageDiff{
+		{
+			Type:     "org.osbuild.locale",
+			Change:   Moved,
+			OldIndex: common.ToPtr(2),
+			NewIndex: common.ToPtr(1),
+		},
+		{
+			Type:     "org.osbuild.hostname",
+			Change:   Changed,
+			OldIndex: common.ToPtr(1),
+			NewIndex: common.ToPtr(2),
+			Changes:  []ValueChange{{Path: "options.hostname", Old: "old", New: "new"}},
+		},
+		{
+			Type:     "org.osbuild.users",
+			Change:   Changed,
+			OldIndex: common.ToPtr(3),
+			NewIndex: common.ToPtr(3),
+			Changes: []ValueChange{
+				{Path: "options.users.alice.groups[1]", New: "adm"},
+				{Path: "options.users.bob", New: map[string]any{}},
+			},
+		},
+		{
+			Type:     "org.osbuild.selinux",
+			Change:   Changed,
+			OldIndex: common.ToPtr(4),
+			NewIndex: common.ToPtr(4),
+			Changes: []ValueChange{
+				{Path: "options.labels", New: map[string]any{"/usr/bin/cp": "system_u:object_r:install_exec_t:s0"}},
+			},
+		},
+		{
+			Type:     "org.osbuild.fix-bls",
+			Change:   Added,
+			NewIndex: common.ToPtr(5),
+		},
+	}, osPipeline.Stages)
+
+	require.Len(t, diff.Sources, 3)
+	assert.Equal(t, "org.osbuild.curl", diff.Sources[0].Name)
+	assert.Equal(t, Changed, diff.Sources[0].Change)
+	assert.Equal(t, []SourceItemDiff{
+		{ID: "sha256:bbb", Change: Removed, Old: map[string]any{"url": "https://example.com/Packages/kernel-6.11.4-301.fc41.x86_64.rpm"}},
+		{ID: "sha256:ccc", Change: Removed, Old: "https://example.com/Packages/vim-minimal-9.1.785-1.fc41.x86_64.rpm"},
+		{ID: "sha256:ddd", Change: Added, New: map[string]any{"url": "https://example.com/Packages/kernel-6.9.12-200.fc41.x86_64.rpm"}},
+		{ID: "sha256:eee", Change: Added, New: "https://example.com/Packages/vim-minimal-9.1.825-1.fc42.x86_64.rpm"},
+		{ID: "sha256:fff", Change: Added, New: "https://example.com/Packages/zstd-1.5.6-2.fc41.x86_64.rpm"},
+	}, diff.Sources[0].Items)
+	assert.Equal(t, "org.osbuild.inline", diff.Sources[1].Name)
+	assert.Equal(t, Removed, diff.Sources[1].Change)
+	assert.Equal(t, "org.osbuild.ostree", diff.Sources[2].Name)
+	assert.Equal(t, Added, diff.Sources[2].Change)
+}
+
+func TestCompareMovedStage(t *testing.T) {
+	oldM := []byte(`{"version": "2", "pipelines": [{"name": "os", "stages": [
+		{"type": "org.osbuild.mkdir", "options": {"paths": [{"path": "/a"}]}},
+		{"type": "org.osbuild.locale", "options": {"language": "C"}},
+		{"type": "org.osbuild.hostname", "options": {"hostname": "h"}}
+	]}]}`)
+	newM := []byte(`{"version": "2", "pipelines": [{"name": "os", "stages": [
+		{"type": "org.osbuild.locale", "options": {"language": "C"}},
+		{"type": "org.osbuild.hostname", "options": {"hostname": "h"}},
+		{"type": "org.osbuild.mkdir", "options": {"paths": [{"path": "/a"}]}}
+	]}]}`)
+
+	diff, err := Compare(oldM, newM)
+	require.NoError(t, err)
+	require.Len(t, diff.Pipelines, 1)
+	assert.Equal(t, []StageDiff{
+		{Type: "org.osbuild.mkdir", Change: Moved, OldIndex: common.ToPtr(0), NewIndex: common.ToPtr(2)},
+	}, diff.Pipelines[0].Stages)
+}
+
+func TestCompareEqual(t *testing.T) {
+	diff, err := Compare(oldManifest, oldManifest)
+	require.NoError(t, err)
+	assert.True(t, diff.Empty())
+
+	var buf bytes.Buffer
+	require.NoError(t, diff.WriteText(&buf))
+	assert.Equal(t, "manifests are equivalent\n", buf.String())
+}
+
+func TestCompareErrors(t *testing.T) {
+	_, err := Compare([]byte(`{`), newManifest)
+	assert.EqualError(t, err, "cannot parse old manifest: unexpected EOF")
+
+	_, err = Compare(oldManifest, []byte(`{"pipelines": [{"name": "os", "stages": [{}]}]}`))
+	assert.EqualError(t, err, "cannot parse new manifest: stage without a type: {}")
+
+	_, err = Compare(oldManifest, []byte(`{"pipelines": [{"name": "os"}, {"name": "os"}]}`))
+	assert.EqualError(t, err, `cannot parse new manifest: pipeline "os" is defined more than once`)
+}
+
+func TestCompareManifests(t *testing.T) {
+	oldM := &osbuild.Manifest{
+		Version: "2",
+		Pipelines: []osbuild.Pipeline{
+			{Name: "os", Stages: []*osbuild.Stage{osbuild.NewHostnameStage(&osbuild.HostnameStageOptions{Hostname: "old"})}},
+		},
+	}
+	newM := &osbuild.Manifest{
+		Version: "2",
+		Pipelines: []osbuild.Pipeline{
+			{Name: "os", Stages: []*osbuild.Stage{osbuild.NewHostnameStage(&osbuild.HostnameStageOptions{Hostname: "new"})}},
+		},
+	}
+
+	diff, err := CompareManifests(oldM, newM)
+	require.NoError(t, err)
+	require.Len(t, diff.Pipelines, 1)
+	require.Len(t, diff.Pipelines[0].Stages, 1)
+	assert.Equal(t, []ValueChange{{Path: "options.hostname", Old: "old", New: "new"}}, diff.Pipelines[0].Stages[0].Changes)
+}
+
+func TestWriteText(t *testing.T) {
+	diff, err := Compare(oldManifest, newManifest)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, diff.WriteText(&buf))
+	assert.Equal(t, `pipeline "tar": removed
+pipeline "build": changed
+  ~ runner: "org.osbuild.fedora41" -> "org.osbuild.fedora42"
+  package kernel.x86_64: downgraded 6.11.4-301.fc41 -> 6.9.12-200.fc41
+  package vim-minimal.x86_64: upgraded 9.1.785-1.fc41 -> 9.1.825-1.fc42
+  package zstd.x86_64: added 1.5.6-2.fc41
+pipeline "os": changed
+  stage 2->1 org.osbuild.locale: moved
+  stage 1->2 org.osbuild.hostname: changed
+    ~ options.hostname: "old" -> "new"
+  stage 3->3 org.osbuild.users: changed
+    + options.users.alice.groups[1]: "adm"
+    + options.users.bob: {}
+  stage 4->4 org.osbuild.selinux: changed
+    + options.labels: {"/usr/bin/cp":"system_u:object_r:install_exec_t:s0"}
+  stage 5 org.osbuild.fix-bls: added
+pipeline "qcow2": added
+source "org.osbuild.curl": changed (3 items added, 2 removed, 0 changed)
+  - sha256:bbb (kernel-6.11.4-301.fc41.x86_64.rpm)
+  - sha256:ccc (vim-minimal-9.1.785-1.fc41.x86_64.rpm)
+  + sha256:ddd (kernel-6.9.12-200.fc41.x86_64.rpm)
+  + sha256:eee (vim-minimal-9.1.825-1.fc42.x86_64.rpm)
+  + sha256:fff (zstd-1.5.6-2.fc41.x86_64.rpm)
+source "org.osbuild.inline": removed (0 items added, 1 removed, 0 changed)
+  - sha256:111
+source "org.osbuild.ostree": added (1 items added, 0 removed, 0 changed)
+  + abc
+`, buf.String())
+
+	// the json output is stable as well
+	data, err := json.Marshal(diff.Pipelines[0])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name": "tar", "change": "removed"}`, string(data))
+}
+
+func TestRPMVerCmp(t *testing.T) {
+	// test cases from the rpmvercmp tests of rpm
+	testCases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "2.0", -1},
+		{"2.0.1", "2.0.1", 0},
+		{"2.0", "2.0.1", -1},
+		{"2.0.1a", "2.0.1", 1},
+		{"5.5p1", "5.5p10", -1},
+		{"10xyz", "10.1xyz", -1},
+		{"xyz10", "xyz10.1", -1},
+		{"1.0010", "1.9", 1},
+		{"1.05", "1.5", 0},
+		{"1.0", "1", 1},
+		{"2.0", "2_0", 0},
+		{"a", "1", -1},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0~rc1", "1.0~rc2", -1},
+		{"1.0~rc1~git123", "1.0~rc1", -1},
+		{"1.0^", "1.0", 1},
+		{"1.0^git1", "1.0^git2", -1},
+		{"1.0^git1", "1.01", -1},
+		{"1.0^git1~pre", "1.0^git1", -1},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, rpmvercmp(tc.a, tc.b), "%s <=> %s", tc.a, tc.b)
+		assert.Equal(t, -tc.expected, rpmvercmp(tc.b, tc.a), "%s <=> %s", tc.b, tc.a)
+	}
+}
@@ -0,0 +1,235 @@
+package manifestdiff
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/osbuild/images/pkg/osbuild"
+)
+
+// packageRef is a package as far as it is known from the manifest, the
+// name, version, release and architecture are taken from the filename
+// of the rpm in the sources (which does not include the epoch)
+type packageRef struct {
+	Name    string
+	Version string
+	Release string
+	Arch    string
+}
+
+func (p packageRef) vr() string {
+	if p.Version == "" {
+		return ""
+	}
+	return p.Version + "-" + p.Release
+}
+
+// parseRPMFilename splits the filename of an rpm into its name, version,
+// release and architecture ("name-version-release.arch.rpm")
+func parseRPMFilename(filename string) (packageRef, bool) {
+	base, ok := strings.CutSuffix(path.Base(filename), ".rpm")
+	if !ok {
+		return packageRef{}, false
+	}
+	dot := strings.LastIndex(base, ".")
+	if dot < 0 {
+		return packageRef{}, false
+	}
+	nvr, arch := base[:dot], base[dot+1:]
+	relDash := strings.LastIndex(nvr, "-")
+	if relDash < 0 {
+		return packageRef{}, false
+	}
+	verDash := strings.LastIndex(nvr[:relDash], "-")
+	if verDash <= 0 {
+		return packageRef{}, false
+	}
+	return packageRef{
+		Name:    nvr[:verDash],
+		Version: nvr[verDash+1 : relDash],
+		Release: nvr[relDash+1:],
+		Arch:    arch,
+	}, true
+}
+
+// packageRefs returns the packages of the curl and librepo sources by
+// the ids of their items
+func packageRefs(sources map[string]source) map[string]packageRef {
+	refs := make(map[string]packageRef)
+	for id, item := range sources[osbuild.SourceNameCurl].Items {
+		var url string
+		switch item := item.(type) {
+		case string:
+			url = item
+		case map[string]any:
+			url, _ = item["url"].(string)
+		}
+		if pkg, ok := parseRPMFilename(url); ok {
+			refs[id] = pkg
+		}
+	}
+	for id, item := range sources[osbuild.SourceNameLibrepo].Items {
+		if item, ok := item.(map[string]any); ok {
+			p, _ := item["path"].(string)
+			if pkg, ok := parseRPMFilename(p); ok {
+				refs[id] = pkg
+			}
+		}
+	}
+	return refs
+}
+
+// pipelinePackages returns the packages installed by the rpm stages of
+// a pipeline by their name and architecture. Packages that cannot be
+// found in the sources are identified by their id.
+func pipelinePackages(p *pipeline, refs map[string]packageRef) map[string]packageRef {
+	pkgs := make(map[string]packageRef)
+	for _, s := range p.Stages {
+		for _, id := range s.packages {
+			pkg, ok := refs[id]
+			if !ok {
+				pkg = packageRef{Name: id}
+			}
+			pkgs[pkg.Name+"."+pkg.Arch] = pkg
+		}
+	}
+	return pkgs
+}
+
+func comparePackages(oldPkgs, newPkgs map[string]packageRef) []PackageDiff {
+	var diffs []PackageDiff
+	for _, key := range unionKeys(oldPkgs, newPkgs) {
+		oldPkg, inOld := oldPkgs[key]
+		newPkg, inNew := newPkgs[key]
+		switch {
+		case !inOld:
+			diffs = append(diffs, PackageDiff{Name: newPkg.Name, Arch: newPkg.Arch, Change: Added, New: newPkg.vr()})
+		case !inNew:
+			diffs = append(diffs, PackageDiff{Name: oldPkg.Name, Arch: oldPkg.Arch, Change: Removed, Old: oldPkg.vr()})
+		default:
+			change := Upgraded
+			switch cmp := compareVR(oldPkg, newPkg); {
+			case cmp == 0:
+				continue
+			case cmp > 0:
+				change = Downgraded
+			}
+			diffs = append(diffs, PackageDiff{Name: newPkg.Name, Arch: newPkg.Arch, Change: change, Old: oldPkg.vr(), New: newPkg.vr()})
+		}
+	}
+	sort.SliceStable(diffs, func(i, j int) bool {
+		return diffs[i].Name < diffs[j].Name
+	})
+	return diffs
+}
+
+// compareVR compares the version and release of two packages like rpm
+// does
+func compareVR(a, b packageRef) int {
+	if cmp := rpmvercmp(a.Version, b.Version); cmp != 0 {
+		return cmp
+	}
+	return rpmvercmp(a.Release, b.Release)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isAlnum(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// rpmvercmp compares two version (or release) strings with the algorithm
+// of rpmvercmp(3) of rpm
+func rpmvercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+	for {
+		for len(a) > 0 && !isAlnum(a[0]) && a[0] != '~' && a[0] != '^' {
+			a = a[1:]
+		}
+		for len(b) > 0 && !isAlnum(b[0]) && b[0] != '~' && b[0] != '^' {
+			b = b[1:]
+		}
+
+		// a tilde sorts before everything, even the end of the string
+		if strings.HasPrefix(a, "~") || strings.HasPrefix(b, "~") {
+			if !strings.HasPrefix(a, "~") {
+				return 1
+			}
+			if !strings.HasPrefix(b, "~") {
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+
+		// a caret sorts after the end of the string but before
+		// everything else
+		if strings.HasPrefix(a, "^") || strings.HasPrefix(b, "^") {
+			if a == "" {
+				return -1
+			}
+			if b == "" {
+				return 1
+			}
+			if !strings.HasPrefix(a, "^") {
+				return 1
+			}
+			if !strings.HasPrefix(b, "^") {
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+
+		if a == "" || b == "" {
+			break
+		}
+
+		numeric := isDigit(a[0])
+		segment := func(s string) (string, string) {
+			end := 0
+			for end < len(s) && isAlnum(s[end]) && isDigit(s[end]) == numeric {
+				end++
+			}
+			return s[:end], s[end:]
+		}
+		var segA, segB string
+		segA, a = segment(a)
+		segB, b = segment(b)
+
+		// numeric segments are newer than alphabetic ones
+		if segB == "" {
+			if numeric {
+				return 1
+			}
+			return -1
+		}
+		if numeric {
+			segA = strings.TrimLeft(segA, "0")
+			segB = strings.TrimLeft(segB, "0")
+			if len(segA) != len(segB) {
+				if len(segA) > len(segB) {
+					return 1
+				}
+				return -1
+			}
+		}
+		if cmp := strings.Compare(segA, segB); cmp != 0 {
+			return cmp
+		}
+	}
+
+	switch {
+	case a == "" && b == "":
+		return 0
+	case a == "":
+		return -1
+	default:
+		return 1
+	}
+}
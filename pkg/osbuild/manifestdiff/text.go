@@ -0,0 +1,126 @@
+package manifestdiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// formatValue returns the compact json of a value
+func formatValue(v any) string {
+	if v == nil {
+		return "(none)"
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
+
+func writeValueChanges(w io.Writer, indent string, changes []ValueChange) {
+	for _, c := range changes {
+		switch {
+		case c.Old == nil:
+			fmt.Fprintf(w, "%s+ %s: %s\n", indent, c.Path, formatValue(c.New))
+		case c.New == nil:
+			fmt.Fprintf(w, "%s- %s: %s\n", indent, c.Path, formatValue(c.Old))
+		default:
+			fmt.Fprintf(w, "%s~ %s: %s -> %s\n", indent, c.Path, formatValue(c.Old), formatValue(c.New))
+		}
+	}
+}
+
+func formatIndex(idx *int) string {
+	if idx == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%d", *idx)
+}
+
+// sourceItemName returns a readable name for an item of a source, the
+// filename for items with a url or a path
+func sourceItemName(item any) string {
+	switch item := item.(type) {
+	case string:
+		if strings.Contains(item, "://") {
+			return path.Base(item)
+		}
+	case map[string]any:
+		for _, key := range []string{"url", "path"} {
+			if s, ok := item[key].(string); ok {
+				return path.Base(s)
+			}
+		}
+	}
+	return ""
+}
+
+// WriteText writes the difference in a human readable form
+func (d *Diff) WriteText(w io.Writer) error {
+	if d.Empty() {
+		_, err := fmt.Fprintln(w, "manifests are equivalent")
+		return err
+	}
+
+	writeValueChanges(w, "", d.Changes)
+	for _, p := range d.Pipelines {
+		fmt.Fprintf(w, "pipeline %q: %s\n", p.Name, p.Change)
+		writeValueChanges(w, "  ", p.Changes)
+		for _, s := range p.Stages {
+			// the position in the old and/or the new pipeline
+			var pos string
+			switch s.Change {
+			case Added:
+				pos = formatIndex(s.NewIndex)
+			case Removed:
+				pos = formatIndex(s.OldIndex)
+			default:
+				pos = formatIndex(s.OldIndex) + "->" + formatIndex(s.NewIndex)
+			}
+			fmt.Fprintf(w, "  stage %s %s: %s\n", pos, s.Type, s.Change)
+			writeValueChanges(w, "    ", s.Changes)
+		}
+		for _, pkg := range p.Packages {
+			name := pkg.Name
+			if pkg.Arch != "" {
+				name += "." + pkg.Arch
+			}
+			switch {
+			case pkg.Old == "" && pkg.New == "":
+				// the version is unknown if the package is not in
+				// the sources
+				fmt.Fprintf(w, "  package %s: %s\n", name, pkg.Change)
+			case pkg.Change == Added:
+				fmt.Fprintf(w, "  package %s: added %s\n", name, pkg.New)
+			case pkg.Change == Removed:
+				fmt.Fprintf(w, "  package %s: removed %s\n", name, pkg.Old)
+			default:
+				fmt.Fprintf(w, "  package %s: %s %s -> %s\n", name, pkg.Change, pkg.Old, pkg.New)
+			}
+		}
+	}
+
+	for _, s := range d.Sources {
+		counts := make(map[Change]int)
+		for _, item := range s.Items {
+			counts[item.Change]++
+		}
+		fmt.Fprintf(w, "source %q: %s (%d items added, %d removed, %d changed)\n", s.Name, s.Change, counts[Added], counts[Removed], counts[Changed])
+		writeValueChanges(w, "  ", s.Changes)
+		for _, item := range s.Items {
+			sign := map[Change]string{Added: "+", Removed: "-", Changed: "~"}[item.Change]
+			name := sourceItemName(item.New)
+			if name == "" {
+				name = sourceItemName(item.Old)
+			}
+			if name != "" {
+				name = " (" + name + ")"
+			}
+			fmt.Fprintf(w, "  %s %s%s\n", sign, item.ID, name)
+		}
+	}
+	return nil
+}
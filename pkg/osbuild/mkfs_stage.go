@@ -71,6 +71,16 @@ func GenFsStages(pt *disk.PartitionTable, filename string, soucePipeline string)
 				}
 
 				stages = append(stages, NewMkfsExt4Stage(options, stageDevices))
+			case "f2fs":
+				options := &MkfsF2fsStageOptions{
+					UUID:  e.UUID,
+					Label: e.Label,
+				}
+				stages = append(stages, NewMkfsF2fsStage(options, stageDevices))
+			case "erofs", "squashfs":
+				// the filesystem image is written after the tree
+				// was copied, see readonly_fs.go
+				stages = append(stages, genReadOnlyFSScratchStage(e, stageDevices))
 			default:
 				panic(fmt.Sprintf("unknown fs type: %s", e.GetFSType()))
 			}
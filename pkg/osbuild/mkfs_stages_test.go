@@ -509,6 +509,61 @@ func TestGenFsStagesUnitVfatGeometry(t *testing.T) {
 	}, stages)
 }
 
+func TestGenFsStagesUnitF2fs(t *testing.T) {
+	pt := &disk.PartitionTable{
+		Type: disk.PT_GPT,
+		Partitions: []disk.Partition{
+			{
+				Payload: &disk.Filesystem{
+					Type:       "f2fs",
+					UUID:       "6e4ff95f-f662-45ee-a82a-bdf44a2d0b75",
+					Label:      "data",
+					Mountpoint: "/var",
+				},
+			},
+		},
+	}
+	stages := GenFsStages(pt, "file.img", "build")
+	assert.Equal(t, []*Stage{
+		{
+			Type: "org.osbuild.mkfs.f2fs",
+			Options: &MkfsF2fsStageOptions{
+				UUID:  "6e4ff95f-f662-45ee-a82a-bdf44a2d0b75",
+				Label: "data",
+			},
+			Devices: defaultStageDevices,
+		},
+	}, stages)
+}
+
+func TestGenFsStagesUnitReadOnly(t *testing.T) {
+	for _, fsType := range []string{"erofs", "squashfs"} {
+		pt := &disk.PartitionTable{
+			Type: disk.PT_GPT,
+			Partitions: []disk.Partition{
+				{
+					Payload: &disk.Filesystem{
+						Type:       fsType,
+						Mountpoint: "/usr",
+					},
+				},
+			},
+		}
+		// a scratch filesystem that takes the content of the tree
+		// until the image of the filesystem is written
+		stages := GenFsStages(pt, "file.img", "build")
+		assert.Equal(t, []*Stage{
+			{
+				Type: "org.osbuild.mkfs.ext4",
+				Options: &MkfsExt4StageOptions{
+					UUID: uuid.NewSHA1(uuid.Nil, []byte("scratch:/usr")).String(),
+				},
+				Devices: defaultStageDevices,
+			},
+		}, stages, fsType)
+	}
+}
+
 func TestGenFsStagesUnhappy(t *testing.T) {
 	pt := &disk.PartitionTable{
 		Type: disk.PT_GPT,
@@ -1,5 +1,7 @@
 package osbuild
 
+import "encoding/json"
+
 type Mount struct {
 	Name      string       `json:"name"`
 	Type      string       `json:"type"`
@@ -12,3 +14,29 @@ type Mount struct {
 type MountOptions interface {
 	isMountOptions()
 }
+
+// RawMountOptions are the options of a decoded mount, they are kept as
+// they are serialized.
+type RawMountOptions json.RawMessage
+
+func (RawMountOptions) isMountOptions() {}
+
+func (o RawMountOptions) MarshalJSON() ([]byte, error) {
+	return json.RawMessage(o).MarshalJSON()
+}
+
+func (m *Mount) UnmarshalJSON(data []byte) error {
+	type mountAlias Mount
+	var raw struct {
+		mountAlias
+		Options json.RawMessage `json:"options,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*m = Mount(raw.mountAlias)
+	if !isNullJSON(raw.Options) {
+		m.Options = RawMountOptions(raw.Options)
+	}
+	return nil
+}
@@ -57,8 +57,8 @@ func (p *Pipeline) AddStages(stages ...*Stage) {
 	}
 }
 
-// Take some bytes and deserialize them into a Manifest; mostly used to take
-// an inspected manifest
+// Take some bytes and deserialize them into a Manifest, the options of the
+// stages are decoded into their typed options (see Stage.UnmarshalJSON)
 func NewManifestFromBytes(data []byte) (*Manifest, error) {
 	manifest := &Manifest{}
 
@@ -84,3 +84,34 @@ func (p *Pipeline) GetID() (string, error) {
 
 	return lastStage.ID, nil
 }
+
+// GetPipeline returns the pipeline with the given name or nil if there is
+// no such pipeline.
+func (m *Manifest) GetPipeline(name string) *Pipeline {
+	for idx := range m.Pipelines {
+		if m.Pipelines[idx].Name == name {
+			return &m.Pipelines[idx]
+		}
+	}
+	return nil
+}
+
+// FindStageOptions returns the options of type T of all stages of the
+// pipeline in order, e.g. the users created in the "os" pipeline of a
+// decoded manifest are
+//
+//	FindStageOptions[*UsersStageOptions](m.GetPipeline("os"))
+//
+// Decoded stages always have pointers to their options.
+func FindStageOptions[T StageOptions](p *Pipeline) []T {
+	if p == nil {
+		return nil
+	}
+	var res []T
+	for _, stage := range p.Stages {
+		if options, ok := stage.Options.(T); ok {
+			res = append(res, options)
+		}
+	}
+	return res
+}
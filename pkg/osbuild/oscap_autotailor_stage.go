@@ -1,6 +1,7 @@
 package osbuild
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/osbuild/images/pkg/customizations/oscap"
@@ -11,6 +12,35 @@ type OscapAutotailorStageOptions struct {
 	Config   OscapAutotailorConfig `json:"config"`
 }
 
+func (o *OscapAutotailorStageOptions) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Filepath string          `json:"filepath"`
+		Config   json.RawMessage `json:"config"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	// the json config is the only one with a tailoring file
+	var peek struct {
+		TailoringFile string `json:"tailoring_file"`
+	}
+	if err := json.Unmarshal(raw.Config, &peek); err != nil {
+		return err
+	}
+	var config OscapAutotailorConfig
+	if peek.TailoringFile != "" {
+		config = new(AutotailorJSONConfig)
+	} else {
+		config = new(AutotailorKeyValueConfig)
+	}
+	if err := json.Unmarshal(raw.Config, config); err != nil {
+		return err
+	}
+	o.Filepath = raw.Filepath
+	o.Config = config
+	return nil
+}
+
 type OscapAutotailorConfig interface {
 	validate() error
 	isAutotailorConfig()
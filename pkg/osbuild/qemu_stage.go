@@ -20,6 +20,43 @@ type QEMUStageOptions struct {
 
 func (QEMUStageOptions) isStageOptions() {}
 
+func (o *QEMUStageOptions) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Filename string          `json:"filename"`
+		Format   json.RawMessage `json:"format"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	var peek struct {
+		Type QEMUFormat `json:"type"`
+	}
+	if err := json.Unmarshal(raw.Format, &peek); err != nil {
+		return err
+	}
+	var format QEMUFormatOptions
+	switch peek.Type {
+	case QEMUFormatQCOW2:
+		format = new(QCOW2Options)
+	case QEMUFormatVDI:
+		format = new(VDIOptions)
+	case QEMUFormatVMDK:
+		format = new(VMDKOptions)
+	case QEMUFormatVPC:
+		format = new(VPCOptions)
+	case QEMUFormatVHDX:
+		format = new(VHDXOptions)
+	default:
+		return fmt.Errorf("unsupported qemu format type: %q", peek.Type)
+	}
+	if err := json.Unmarshal(raw.Format, format); err != nil {
+		return err
+	}
+	o.Filename = raw.Filename
+	o.Format = format
+	return nil
+}
+
 type QEMUFormat string
 type VMDKSubformat string
 
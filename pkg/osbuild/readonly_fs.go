@@ -0,0 +1,116 @@
+package osbuild
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/osbuild/images/pkg/disk"
+)
+
+// Read-only filesystems (erofs, squashfs) cannot be created empty and
+// filled with the content of the tree afterwards like the other
+// filesystems of a disk image. Their images are created from the tree in
+// a separate pipeline (see ReadOnlyFSImage()). While the disk image is
+// created their partitions hold a scratch ext4 filesystem, that takes the
+// content of the tree below their mountpoints when the tree is copied to
+// the disk, and are overwritten with the filesystem images afterwards
+// (see GenImageFinishStages()).
+
+// ReadOnlyFSImage returns the name of the pipeline that creates the image
+// of the read-only filesystem fs and the path of the image in the tree of
+// that pipeline, e.g. "erofs-usr" and "/usr.erofs".
+func ReadOnlyFSImage(fs *disk.Filesystem) (pipelineName, path string) {
+	name := pathEscape(fs.Mountpoint)
+	return fmt.Sprintf("%s-%s", fs.Type, name), fmt.Sprintf("/%s.%s", name, fs.Type)
+}
+
+// ReadOnlyFSExcludePaths returns the paths (as regular expressions
+// relative to the mountpoint of fs) that must be excluded from the image
+// of the read-only filesystem fs because they belong to other filesystems
+// of the partition table, e.g. "local/.*" for "/usr" if there is a
+// "/usr/local".
+func ReadOnlyFSExcludePaths(pt *disk.PartitionTable, fs *disk.Filesystem) []string {
+	var excludes []string
+	_ = pt.ForEachMountable(func(mnt disk.Mountable, _ []disk.Entity) error {
+		mountpoint := mnt.GetMountpoint()
+		if mountpoint == fs.Mountpoint {
+			return nil
+		}
+		rel, err := filepath.Rel(fs.Mountpoint, mountpoint)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			return nil
+		}
+		excludes = append(excludes, rel+"/.*")
+		return nil
+	})
+	return excludes
+}
+
+// genReadOnlyFSScratchStage returns the stage that creates the scratch
+// filesystem on the partition of the read-only filesystem fs.
+func genReadOnlyFSScratchStage(fs *disk.Filesystem, devices map[string]Device) *Stage {
+	options := &MkfsExt4StageOptions{
+		UUID: uuid.NewSHA1(uuid.Nil, []byte("scratch:"+fs.Mountpoint)).String(),
+	}
+	return NewMkfsExt4Stage(options, devices)
+}
+
+// genReadOnlyFSWriteStages returns the stages that write the images of all
+// read-only filesystems of the partition table to their partitions.
+func genReadOnlyFSWriteStages(pt *disk.PartitionTable, filename string) []*Stage {
+	var stages []*Stage
+	genStage := func(ent disk.Entity, path []disk.Entity) error {
+		fs, ok := ent.(*disk.Filesystem)
+		if !ok || !fs.ReadOnly() {
+			return nil
+		}
+		inputName := "tree"
+		pipelineName, imagePath := ReadOnlyFSImage(fs)
+		options := &WriteDeviceStageOptions{
+			From: fmt.Sprintf("input://%s%s", inputName, imagePath),
+		}
+		inputs := NewPipelineTreeInputs(inputName, pipelineName)
+		stages = append(stages, NewWriteDeviceStage(options, inputs, getDevicesForFsStage(path, filename)))
+		return nil
+	}
+	_ = pt.ForEachEntity(genStage)
+	return stages
+}
+
+// readOnlyFSDevice returns the device path for the read-only filesystem
+// ent, which has no filesystem UUID and is identified via the UUID of its
+// partition instead, e.g. "/dev/disk/by-partuuid/<uuid>".
+func readOnlyFSDevice(ent disk.FSTabEntity, path []disk.Entity) (string, error) {
+	// the last element of the path is the filesystem itself
+	var part *disk.Partition
+	if len(path) > 1 {
+		part, _ = path[len(path)-2].(*disk.Partition)
+	}
+	if part == nil {
+		return "", fmt.Errorf("read-only %s filesystem %q must be placed directly on a partition", ent.GetFSType(), ent.GetFSFile())
+	}
+	if part.UUID == "" {
+		return "", fmt.Errorf("read-only %s filesystem %q requires a partition with a UUID (gpt partition table)", ent.GetFSType(), ent.GetFSFile())
+	}
+	return filepath.Join("/dev/disk/by-partuuid", strings.ToLower(part.UUID)), nil
+}
+
+// findReadOnlyFSDevice returns the device path of the read-only filesystem
+// with the given mountpoint or an empty string if there is no such
+// filesystem in the partition table.
+func findReadOnlyFSDevice(pt *disk.PartitionTable, mountpoint string) (string, error) {
+	var device string
+	err := pt.ForEachFSTabEntity(func(ent disk.FSTabEntity, path []disk.Entity) error {
+		fs, ok := ent.(*disk.Filesystem)
+		if !ok || !fs.ReadOnly() || fs.Mountpoint != mountpoint {
+			return nil
+		}
+		var err error
+		device, err = readOnlyFSDevice(ent, path)
+		return err
+	})
+	return device, err
+}
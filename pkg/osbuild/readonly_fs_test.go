@@ -0,0 +1,109 @@
+package osbuild
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/datasizes"
+	"github.com/osbuild/images/pkg/disk"
+)
+
+func makeReadOnlyFSPartitionTable(fsType string) *disk.PartitionTable {
+	return &disk.PartitionTable{
+		Type: disk.PT_GPT,
+		UUID: "D209C89E-EA5E-4FBD-B161-B461CCE297E0",
+		Partitions: []disk.Partition{
+			{
+				Start: 1 * datasizes.MiB,
+				Size:  1 * datasizes.GiB,
+				UUID:  "CB07C243-BC44-4717-853E-28852021225B",
+				Payload: &disk.Filesystem{
+					Type:         "xfs",
+					UUID:         "0194fdc2-fa2f-4cc0-81d3-ff12045b73c8",
+					Mountpoint:   "/",
+					FSTabOptions: "defaults",
+				},
+			},
+			{
+				Start: 1*datasizes.MiB + 1*datasizes.GiB,
+				Size:  1 * datasizes.GiB,
+				UUID:  "6264D520-3FB9-423F-8AB8-7A0A8E3D3562",
+				Payload: &disk.Filesystem{
+					Type:       fsType,
+					Mountpoint: "/usr",
+				},
+			},
+			{
+				Start: 1*datasizes.MiB + 2*datasizes.GiB,
+				Size:  1 * datasizes.GiB,
+				UUID:  "A0E1C2B3-1F2E-4D3C-8B4A-596877665544",
+				Payload: &disk.Filesystem{
+					Type:         "xfs",
+					UUID:         "fb180daf-48a7-4ee0-b10d-394651850fd4",
+					Mountpoint:   "/usr/local",
+					FSTabOptions: "defaults",
+				},
+			},
+		},
+	}
+}
+
+func TestReadOnlyFSImage(t *testing.T) {
+	name, path := ReadOnlyFSImage(&disk.Filesystem{Type: "erofs", Mountpoint: "/usr"})
+	assert.Equal(t, "erofs-usr", name)
+	assert.Equal(t, "/usr.erofs", path)
+
+	name, path = ReadOnlyFSImage(&disk.Filesystem{Type: "squashfs", Mountpoint: "/opt/data"})
+	assert.Equal(t, "squashfs-opt-data", name)
+	assert.Equal(t, "/opt-data.squashfs", path)
+}
+
+func TestReadOnlyFSExcludePaths(t *testing.T) {
+	pt := makeReadOnlyFSPartitionTable("erofs")
+	usr := pt.FindMountable("/usr").(*disk.Filesystem)
+	assert.Equal(t, []string{"local/.*"}, ReadOnlyFSExcludePaths(pt, usr))
+}
+
+func TestReadOnlyFSFSTabOptions(t *testing.T) {
+	for _, fsType := range []string{"erofs", "squashfs"} {
+		options, err := NewFSTabStageOptions(makeReadOnlyFSPartitionTable(fsType))
+		require.NoError(t, err)
+		assert.Contains(t, options.FileSystems, &FSTabEntry{
+			Device:  "/dev/disk/by-partuuid/6264d520-3fb9-423f-8ab8-7a0a8e3d3562",
+			VFSType: fsType,
+			Path:    "/usr",
+			Options: "ro",
+		})
+	}
+
+	// without partition uuids the filesystem cannot be identified
+	pt := makeReadOnlyFSPartitionTable("erofs")
+	pt.Partitions[1].UUID = ""
+	_, err := NewFSTabStageOptions(pt)
+	assert.EqualError(t, err, `read-only erofs filesystem "/usr" requires a partition with a UUID (gpt partition table)`)
+}
+
+func TestReadOnlyFSKernelOptions(t *testing.T) {
+	pt := makeReadOnlyFSPartitionTable("erofs")
+	_, cmdline, err := GenImageKernelOptions(pt, MOUNT_CONFIGURATION_UNITS)
+	require.NoError(t, err)
+	assert.Contains(t, cmdline, "mount.usr=/dev/disk/by-partuuid/6264d520-3fb9-423f-8ab8-7a0a8e3d3562")
+	assert.Contains(t, cmdline, "mount.usrfstype=erofs")
+	assert.Contains(t, cmdline, "mount.usrflags=ro")
+
+	pt.Partitions[0].Payload.(*disk.Filesystem).Type = "squashfs"
+	_, _, err = GenImageKernelOptions(pt, MOUNT_CONFIGURATION_FSTAB)
+	assert.ErrorIs(t, err, disk.ErrReadOnlyRootUnsupported)
+}
+
+func TestReadOnlyFSImageFinishStages(t *testing.T) {
+	pt := makeReadOnlyFSPartitionTable("erofs")
+	stages := GenImageFinishStages(pt, "disk.img")
+	require.Len(t, stages, 1)
+	assert.Equal(t, "org.osbuild.write-device", stages[0].Type)
+	assert.Equal(t, &WriteDeviceStageOptions{From: "input://tree/usr.erofs"}, stages[0].Options)
+	assert.Equal(t, NewPipelineTreeInputs("tree", "erofs-usr"), stages[0].Inputs)
+	assert.Equal(t, uint64((1*datasizes.MiB+1*datasizes.GiB)/512), stages[0].Devices["device"].Options.(*LoopbackDeviceOptions).Start)
+}
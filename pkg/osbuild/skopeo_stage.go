@@ -1,5 +1,10 @@
 package osbuild
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 type SkopeoDestination interface {
 	isSkopeoDestination()
 }
@@ -26,6 +31,37 @@ type SkopeoStageOptions struct {
 
 func (o SkopeoStageOptions) isStageOptions() {}
 
+func (o *SkopeoStageOptions) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Destination      json.RawMessage `json:"destination"`
+		RemoveSignatures *bool           `json:"remove-signatures,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	var peek struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(raw.Destination, &peek); err != nil {
+		return err
+	}
+	var dest SkopeoDestination
+	switch peek.Type {
+	case "containers-storage":
+		dest = new(SkopeoDestinationContainersStorage)
+	case "oci":
+		dest = new(SkopeoDestinationOCI)
+	default:
+		return fmt.Errorf("unsupported skopeo destination type: %q", peek.Type)
+	}
+	if err := json.Unmarshal(raw.Destination, dest); err != nil {
+		return err
+	}
+	o.Destination = dest
+	o.RemoveSignatures = raw.RemoveSignatures
+	return nil
+}
+
 type SkopeoStageInputs struct {
 	Images        ContainersInput `json:"images"`
 	ManifestLists *FilesInput     `json:"manifest-lists,omitempty"`
@@ -11,6 +11,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/osbuild/images/internal/common"
 	"github.com/osbuild/images/pkg/container"
 	"github.com/osbuild/images/pkg/depsolvednf"
 	"github.com/osbuild/images/pkg/rpmmd"
@@ -118,6 +119,46 @@ func TestSource_UnmarshalJSON(t *testing.T) {
 				data: []byte(`{"org.osbuild.librepo":{"items":{"checksum1":{"path":"path1","mirror":"mirror1"}},"options":{"mirrors":{"mirror1":{"url":"http://example.com/metalink","type":"metalink"}}}}}`),
 			},
 		},
+		{
+			name: "skopeo",
+			fields: fields{
+				Type: "org.osbuild.skopeo",
+				Source: &SkopeoSource{
+					Items: map[string]SkopeoSourceItem{
+						"sha256:id1": {Image: SkopeopSourceImage{Name: "quay.io/fedora/fedora", Digest: "sha256:digest1", TLSVerify: common.ToPtr(false)}},
+					},
+				},
+			},
+			args: args{
+				data: []byte(`{"org.osbuild.skopeo":{"items":{"sha256:id1":{"image":{"name":"quay.io/fedora/fedora","digest":"sha256:digest1","tls-verify":false}}}}}`),
+			},
+		},
+		{
+			name: "skopeo-index",
+			fields: fields{
+				Type: "org.osbuild.skopeo-index",
+				Source: &SkopeoIndexSource{
+					Items: map[string]SkopeoIndexSourceItem{
+						"sha256:list1": {Image: SkopeoIndexSourceImage{Name: "quay.io/fedora/fedora"}},
+					},
+				},
+			},
+			args: args{
+				data: []byte(`{"org.osbuild.skopeo-index":{"items":{"sha256:list1":{"image":{"name":"quay.io/fedora/fedora"}}}}}`),
+			},
+		},
+		{
+			name: "containers-storage",
+			fields: fields{
+				Type: "org.osbuild.containers-storage",
+				Source: &ContainersStorageSource{
+					Items: map[string]struct{}{"sha256:id1": {}},
+				},
+			},
+			args: args{
+				data: []byte(`{"org.osbuild.containers-storage":{"items":{"sha256:id1":{}}}}`),
+			},
+		},
 	}
 	for idx, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -1,5 +1,10 @@
 package osbuild
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 // Single stage of a pipeline executing one step
 type Stage struct {
 	// Well-known name in reverse domain-name notation, uniquely identifying
@@ -21,6 +26,49 @@ type StageOptions interface {
 	isStageOptions()
 }
 
+// UnmarshalJSON decodes a serialized stage. The options are decoded into
+// the options type of the stage type (see NewStageOptionsFor), the options
+// of unknown stage types, the inputs and the options of the devices and
+// mounts are kept as they are serialized.
+func (s *Stage) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Type    string            `json:"type"`
+		ID      string            `json:"id,omitempty"`
+		Inputs  json.RawMessage   `json:"inputs,omitempty"`
+		Options json.RawMessage   `json:"options,omitempty"`
+		Devices map[string]Device `json:"devices,omitempty"`
+		Mounts  []Mount           `json:"mounts,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*s = Stage{
+		Type:    raw.Type,
+		ID:      raw.ID,
+		Devices: raw.Devices,
+		Mounts:  raw.Mounts,
+	}
+	if !isNullJSON(raw.Inputs) {
+		s.Inputs = RawInputs(raw.Inputs)
+	}
+	if !isNullJSON(raw.Options) {
+		options := NewStageOptionsFor(raw.Type)
+		if options == nil {
+			s.Options = RawStageOptions(raw.Options)
+		} else {
+			if err := json.Unmarshal(raw.Options, options); err != nil {
+				return fmt.Errorf("cannot decode options of stage %q: %w", raw.Type, err)
+			}
+			s.Options = options
+		}
+	}
+	return nil
+}
+
+func isNullJSON(data json.RawMessage) bool {
+	return len(data) == 0 || string(data) == "null"
+}
+
 // MountOSTree adds an ostree mount to a stage which makes it run in a deployed
 // ostree stateroot.
 func (s *Stage) MountOSTree(osName, ref string, serial int) {
@@ -0,0 +1,166 @@
+package osbuild
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// stageOptionsTypes maps the stage types to the types of their options.
+// All stages that are generated by this library must be listed here so
+// that serialized manifests can be decoded into typed stages.
+var stageOptionsTypes = map[string]reflect.Type{
+	"org.osbuild.anaconda":                    reflect.TypeFor[AnacondaStageOptions](),
+	"org.osbuild.authconfig":                  reflect.TypeFor[AuthconfigStageOptions](),
+	"org.osbuild.authselect":                  reflect.TypeFor[AuthselectStageOptions](),
+	"org.osbuild.bootc.install-to-filesystem": reflect.TypeFor[BootcInstallToFilesystemOptions](),
+	"org.osbuild.bootc.install.config":        reflect.TypeFor[BootcInstallConfigStageOptions](),
+	"org.osbuild.bootiso.mono":                reflect.TypeFor[BootISOMonoStageOptions](),
+	"org.osbuild.bootupd":                     reflect.TypeFor[BootupdStageOptions](),
+	"org.osbuild.btrfs.subvol":                reflect.TypeFor[BtrfsSubVolOptions](),
+	"org.osbuild.buildstamp":                  reflect.TypeFor[BuildstampStageOptions](),
+	"org.osbuild.chmod":                       reflect.TypeFor[ChmodStageOptions](),
+	"org.osbuild.chown":                       reflect.TypeFor[ChownStageOptions](),
+	"org.osbuild.chrony":                      reflect.TypeFor[ChronyStageOptions](),
+	"org.osbuild.clevis.luks-bind":            reflect.TypeFor[ClevisLuksBindStageOptions](),
+	"org.osbuild.cloud-init":                  reflect.TypeFor[CloudInitStageOptions](),
+	"org.osbuild.container-deploy":            reflect.TypeFor[ContainerDeployOptions](),
+	"org.osbuild.containers.storage.conf":     reflect.TypeFor[ContainersStorageConfStageOptions](),
+	"org.osbuild.copy":                        reflect.TypeFor[CopyStageOptions](),
+	"org.osbuild.discinfo":                    reflect.TypeFor[DiscinfoStageOptions](),
+	"org.osbuild.dnf-automatic.config":        reflect.TypeFor[DNFAutomaticConfigStageOptions](),
+	"org.osbuild.dnf.config":                  reflect.TypeFor[DNFConfigStageOptions](),
+	"org.osbuild.dnf.module-config":           reflect.TypeFor[DNFModuleConfigStageOptions](),
+	"org.osbuild.dnf4.versionlock":            reflect.TypeFor[DNF4VersionlockOptions](),
+	"org.osbuild.dracut":                      reflect.TypeFor[DracutStageOptions](),
+	"org.osbuild.dracut.conf":                 reflect.TypeFor[DracutConfStageOptions](),
+	"org.osbuild.erofs":                       reflect.TypeFor[ErofsStageOptions](),
+	"org.osbuild.firewall":                    reflect.TypeFor[FirewallStageOptions](),
+	"org.osbuild.first-boot":                  reflect.TypeFor[FirstBootStageOptions](),
+	"org.osbuild.fix-bls":                     reflect.TypeFor[FixBLSStageOptions](),
+	"org.osbuild.fstab":                       reflect.TypeFor[FSTabStageOptions](),
+	"org.osbuild.gcp.guest-agent.conf":        reflect.TypeFor[GcpGuestAgentConfigOptions](),
+	"org.osbuild.groups":                      reflect.TypeFor[GroupsStageOptions](),
+	"org.osbuild.grub2":                       reflect.TypeFor[GRUB2StageOptions](),
+	"org.osbuild.grub2.inst":                  reflect.TypeFor[Grub2InstStageOptions](),
+	"org.osbuild.grub2.iso":                   reflect.TypeFor[GrubISOStageOptions](),
+	"org.osbuild.grub2.iso.legacy":            reflect.TypeFor[Grub2ISOLegacyStageOptions](),
+	"org.osbuild.grub2.legacy":                reflect.TypeFor[GRUB2LegacyStageOptions](),
+	"org.osbuild.gzip":                        reflect.TypeFor[GzipStageOptions](),
+	"org.osbuild.hmac":                        reflect.TypeFor[HMACStageOptions](),
+	"org.osbuild.hostname":                    reflect.TypeFor[HostnameStageOptions](),
+	"org.osbuild.ignition":                    reflect.TypeFor[IgnitionStageOptions](),
+	"org.osbuild.implantisomd5":               reflect.TypeFor[Implantisomd5StageOptions](),
+	"org.osbuild.insights-client.config":      reflect.TypeFor[InsightsClientConfigStageOptions](),
+	"org.osbuild.isolinux":                    reflect.TypeFor[ISOLinuxStageOptions](),
+	"org.osbuild.kernel-cmdline":              reflect.TypeFor[KernelCmdlineStageOptions](),
+	"org.osbuild.keymap":                      reflect.TypeFor[KeymapStageOptions](),
+	"org.osbuild.kickstart":                   reflect.TypeFor[KickstartStageOptions](),
+	"org.osbuild.locale":                      reflect.TypeFor[LocaleStageOptions](),
+	"org.osbuild.lorax-script":                reflect.TypeFor[LoraxScriptStageOptions](),
+	"org.osbuild.luks2.format":                reflect.TypeFor[LUKS2CreateStageOptions](),
+	"org.osbuild.luks2.remove-key":            reflect.TypeFor[LUKS2RemoveKeyStageOptions](),
+	"org.osbuild.lvm2.create":                 reflect.TypeFor[LVM2CreateStageOptions](),
+	"org.osbuild.lvm2.metadata":               reflect.TypeFor[LVM2MetadataStageOptions](),
+	"org.osbuild.machine-id":                  reflect.TypeFor[MachineIdStageOptions](),
+	"org.osbuild.mkdir":                       reflect.TypeFor[MkdirStageOptions](),
+	"org.osbuild.mkfs.btrfs":                  reflect.TypeFor[MkfsBtrfsStageOptions](),
+	"org.osbuild.mkfs.ext4":                   reflect.TypeFor[MkfsExt4StageOptions](),
+	"org.osbuild.mkfs.f2fs":                   reflect.TypeFor[MkfsF2fsStageOptions](),
+	"org.osbuild.mkfs.fat":                    reflect.TypeFor[MkfsFATStageOptions](),
+	"org.osbuild.mkfs.xfs":                    reflect.TypeFor[MkfsXfsStageOptions](),
+	"org.osbuild.mkswap":                      reflect.TypeFor[MkswapStageOptions](),
+	"org.osbuild.modprobe":                    reflect.TypeFor[ModprobeStageOptions](),
+	"org.osbuild.nginx.conf":                  reflect.TypeFor[NginxConfigStageOptions](),
+	"org.osbuild.nm.conf":                     reflect.TypeFor[NMConfStageOptions](),
+	"org.osbuild.oci-archive":                 reflect.TypeFor[OCIArchiveStageOptions](),
+	"org.osbuild.oscap.autotailor":            reflect.TypeFor[OscapAutotailorStageOptions](),
+	"org.osbuild.oscap.remediation":           reflect.TypeFor[OscapRemediationStageOptions](),
+	"org.osbuild.ostree.commit":               reflect.TypeFor[OSTreeCommitStageOptions](),
+	"org.osbuild.ostree.config":               reflect.TypeFor[OSTreeConfigStageOptions](),
+	"org.osbuild.ostree.deploy":               reflect.TypeFor[OSTreeDeployStageOptions](),
+	"org.osbuild.ostree.deploy.container":     reflect.TypeFor[OSTreeDeployContainerStageOptions](),
+	"org.osbuild.ostree.encapsulate":          reflect.TypeFor[OSTreeEncapsulateStageOptions](),
+	"org.osbuild.ostree.fillvar":              reflect.TypeFor[OSTreeFillvarStageOptions](),
+	"org.osbuild.ostree.init":                 reflect.TypeFor[OSTreeInitStageOptions](),
+	"org.osbuild.ostree.os-init":              reflect.TypeFor[OSTreeOsInitStageOptions](),
+	"org.osbuild.ostree.passwd":               reflect.TypeFor[OSTreePasswdStageOptions](),
+	"org.osbuild.ostree.preptree":             reflect.TypeFor[OSTreePrepTreeStageOptions](),
+	"org.osbuild.ostree.pull":                 reflect.TypeFor[OSTreePullStageOptions](),
+	"org.osbuild.ostree.remotes":              reflect.TypeFor[OSTreeRemotesStageOptions](),
+	"org.osbuild.ostree.selinux":              reflect.TypeFor[OSTreeSelinuxStageOptions](),
+	"org.osbuild.ovf":                         reflect.TypeFor[OVFStageOptions](),
+	"org.osbuild.pam.limits.conf":             reflect.TypeFor[PamLimitsConfStageOptions](),
+	"org.osbuild.pwquality.conf":              reflect.TypeFor[PwqualityConfStageOptions](),
+	"org.osbuild.qemu":                        reflect.TypeFor[QEMUStageOptions](),
+	"org.osbuild.rhsm":                        reflect.TypeFor[RHSMStageOptions](),
+	"org.osbuild.rhsm.facts":                  reflect.TypeFor[RHSMFactsStageOptions](),
+	"org.osbuild.rpm":                         reflect.TypeFor[RPMStageOptions](),
+	"org.osbuild.selinux":                     reflect.TypeFor[SELinuxStageOptions](),
+	"org.osbuild.selinux.config":              reflect.TypeFor[SELinuxConfigStageOptions](),
+	"org.osbuild.sfdisk":                      reflect.TypeFor[SfdiskStageOptions](),
+	"org.osbuild.sgdisk":                      reflect.TypeFor[SgdiskStageOptions](),
+	"org.osbuild.shell.init":                  reflect.TypeFor[ShellInitStageOptions](),
+	"org.osbuild.skopeo":                      reflect.TypeFor[SkopeoStageOptions](),
+	"org.osbuild.squashfs":                    reflect.TypeFor[SquashfsStageOptions](),
+	"org.osbuild.sshd.config":                 reflect.TypeFor[SshdConfigStageOptions](),
+	"org.osbuild.sysconfig":                   reflect.TypeFor[SysconfigStageOptions](),
+	"org.osbuild.sysctld":                     reflect.TypeFor[SysctldStageOptions](),
+	"org.osbuild.systemd":                     reflect.TypeFor[SystemdStageOptions](),
+	"org.osbuild.systemd-journald":            reflect.TypeFor[SystemdJournaldStageOptions](),
+	"org.osbuild.systemd-logind":              reflect.TypeFor[SystemdLogindStageOptions](),
+	"org.osbuild.systemd.preset":              reflect.TypeFor[SystemdPresetStageOptions](),
+	"org.osbuild.systemd.unit":                reflect.TypeFor[SystemdUnitStageOptions](),
+	"org.osbuild.systemd.unit.create":         reflect.TypeFor[SystemdUnitCreateStageOptions](),
+	"org.osbuild.tar":                         reflect.TypeFor[TarStageOptions](),
+	"org.osbuild.timezone":                    reflect.TypeFor[TimezoneStageOptions](),
+	"org.osbuild.tmpfilesd":                   reflect.TypeFor[TmpfilesdStageOptions](),
+	"org.osbuild.truncate":                    reflect.TypeFor[TruncateStageOptions](),
+	"org.osbuild.tuned":                       reflect.TypeFor[TunedStageOptions](),
+	"org.osbuild.udev.rules":                  reflect.TypeFor[UdevRulesStageOptions](),
+	"org.osbuild.update-crypto-policies":      reflect.TypeFor[UpdateCryptoPoliciesStageOptions](),
+	"org.osbuild.users":                       reflect.TypeFor[UsersStageOptions](),
+	"org.osbuild.vagrant":                     reflect.TypeFor[VagrantStageOptions](),
+	"org.osbuild.waagent.conf":                reflect.TypeFor[WAAgentConfStageOptions](),
+	"org.osbuild.write-device":                reflect.TypeFor[WriteDeviceStageOptions](),
+	"org.osbuild.wsl-distribution.conf":       reflect.TypeFor[WSLDistributionConfStageOptions](),
+	"org.osbuild.wsl.conf":                    reflect.TypeFor[WSLConfStageOptions](),
+	"org.osbuild.xorrisofs":                   reflect.TypeFor[XorrisofsStageOptions](),
+	"org.osbuild.xz":                          reflect.TypeFor[XzStageOptions](),
+	"org.osbuild.yum.config":                  reflect.TypeFor[YumConfigStageOptions](),
+	"org.osbuild.yum.repos":                   reflect.TypeFor[YumReposStageOptions](),
+	"org.osbuild.zipl":                        reflect.TypeFor[ZiplStageOptions](),
+	"org.osbuild.zipl.inst":                   reflect.TypeFor[ZiplInstStageOptions](),
+	"org.osbuild.zstd":                        reflect.TypeFor[ZstdStageOptions](),
+}
+
+// NewStageOptionsFor returns new (empty) options for the given stage type
+// that serialized options can be decoded into, or nil if the stage type
+// is not known.
+func NewStageOptionsFor(stageType string) StageOptions {
+	t, ok := stageOptionsTypes[stageType]
+	if !ok {
+		return nil
+	}
+	return reflect.New(t).Interface().(StageOptions)
+}
+
+// KnownStageTypes returns the sorted stage types that have typed options
+func KnownStageTypes() []string {
+	types := make([]string, 0, len(stageOptionsTypes))
+	for t := range stageOptionsTypes {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// RawStageOptions are the options of a stage whose type is not known,
+// they are kept as they are serialized.
+type RawStageOptions json.RawMessage
+
+func (RawStageOptions) isStageOptions() {}
+
+func (o RawStageOptions) MarshalJSON() ([]byte, error) {
+	return json.RawMessage(o).MarshalJSON()
+}
@@ -0,0 +1,169 @@
+package osbuild
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/common"
+)
+
+func TestNewStageOptionsFor(t *testing.T) {
+	types := KnownStageTypes()
+	assert.Contains(t, types, "org.osbuild.rpm")
+	for _, stageType := range types {
+		assert.NotNil(t, NewStageOptionsFor(stageType), stageType)
+	}
+
+	assert.IsType(t, &UsersStageOptions{}, NewStageOptionsFor("org.osbuild.users"))
+	// options with pointer receivers
+	assert.IsType(t, &HMACStageOptions{}, NewStageOptionsFor("org.osbuild.hmac"))
+	assert.Nil(t, NewStageOptionsFor("org.osbuild.unknown"))
+}
+
+func TestStageUnmarshalJSON(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		data     string
+		expected *Stage
+	}{
+		{
+			name: "typed",
+			data: `{"type":"org.osbuild.users","options":{"users":{"alice":{"uid":1000,"groups":["wheel"]}}}}`,
+			expected: &Stage{
+				Type: "org.osbuild.users",
+				Options: &UsersStageOptions{
+					Users: map[string]UsersStageOptionsUser{
+						"alice": {UID: common.ToPtr(1000), Groups: []string{"wheel"}},
+					},
+				},
+			},
+		},
+		{
+			name: "unknown",
+			data: `{"type":"org.osbuild.unknown","options":{"foo":[1,2]}}`,
+			expected: &Stage{
+				Type:    "org.osbuild.unknown",
+				Options: RawStageOptions(`{"foo":[1,2]}`),
+			},
+		},
+		{
+			name: "no-options",
+			data: `{"type":"org.osbuild.mkdir","id":"1234"}`,
+			expected: &Stage{
+				Type: "org.osbuild.mkdir",
+				ID:   "1234",
+			},
+		},
+		{
+			name: "interface-options",
+			data: `{"type":"org.osbuild.qemu","options":{"filename":"disk.vmdk","format":{"type":"vmdk","subformat":"streamOptimized"}}}`,
+			expected: &Stage{
+				Type: "org.osbuild.qemu",
+				Options: &QEMUStageOptions{
+					Filename: "disk.vmdk",
+					Format:   &VMDKOptions{Type: QEMUFormatVMDK, Subformat: VMDKSubformatStreamOptimized},
+				},
+			},
+		},
+		{
+			name: "skopeo",
+			data: `{"type":"org.osbuild.skopeo","options":{"destination":{"type":"oci","path":"/image"}}}`,
+			expected: &Stage{
+				Type: "org.osbuild.skopeo",
+				Options: &SkopeoStageOptions{
+					Destination: &SkopeoDestinationOCI{Type: "oci", Path: "/image"},
+				},
+			},
+		},
+		{
+			name: "oscap-autotailor",
+			data: `{"type":"org.osbuild.oscap.autotailor","options":{"filepath":"tailoring.xml","config":{"tailored_profile_id":"p1","datastream":"ds.xml","tailoring_file":"t.json"}}}`,
+			expected: &Stage{
+				Type: "org.osbuild.oscap.autotailor",
+				Options: &OscapAutotailorStageOptions{
+					Filepath: "tailoring.xml",
+					Config: &AutotailorJSONConfig{
+						TailoredProfileID: "p1",
+						Datastream:        "ds.xml",
+						TailoringFile:     "t.json",
+					},
+				},
+			},
+		},
+		{
+			name: "inputs-devices-mounts",
+			data: `{"type":"org.osbuild.mkfs.ext4","inputs":{"tree":{"type":"org.osbuild.tree"}},"options":{"uuid":"6e4ff95f-f662-45ee-a82a-bdf44a2d0b75"},"devices":{"device":{"type":"org.osbuild.loopback","options":{"filename":"disk.raw"}}},"mounts":[{"name":"root","type":"org.osbuild.ext4","source":"device","target":"/","options":{"readonly":true}}]}`,
+			expected: &Stage{
+				Type:    "org.osbuild.mkfs.ext4",
+				Inputs:  RawInputs(`{"tree":{"type":"org.osbuild.tree"}}`),
+				Options: &MkfsExt4StageOptions{UUID: "6e4ff95f-f662-45ee-a82a-bdf44a2d0b75"},
+				Devices: map[string]Device{
+					"device": {Type: "org.osbuild.loopback", Options: RawDeviceOptions(`{"filename":"disk.raw"}`)},
+				},
+				Mounts: []Mount{
+					{Name: "root", Type: "org.osbuild.ext4", Source: "device", Target: "/", Options: RawMountOptions(`{"readonly":true}`)},
+				},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var stage Stage
+			require.NoError(t, json.Unmarshal([]byte(tc.data), &stage))
+			assert.Equal(t, tc.expected, &stage)
+
+			// and back
+			data, err := json.Marshal(&stage)
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.data, string(data))
+		})
+	}
+}
+
+func TestStageUnmarshalJSONBadOptions(t *testing.T) {
+	var stage Stage
+	err := json.Unmarshal([]byte(`{"type":"org.osbuild.locale","options":{"language":1}}`), &stage)
+	assert.EqualError(t, err, `cannot decode options of stage "org.osbuild.locale": json: cannot unmarshal number into Go struct field LocaleStageOptions.language of type string`)
+}
+
+func TestFindStageOptions(t *testing.T) {
+	manifest, err := NewManifestFromBytes([]byte(`{
+  "version": "2",
+  "pipelines": [
+    {
+      "name": "build",
+      "stages": [
+        {"type": "org.osbuild.users", "options": {"users": {"builder": {}}}}
+      ]
+    },
+    {
+      "name": "os",
+      "stages": [
+        {"type": "org.osbuild.kernel-cmdline", "options": {"root_fs_uuid": "6e4ff95f-f662-45ee-a82a-bdf44a2d0b75", "kernel_opts": "ro console=ttyS0"}},
+        {"type": "org.osbuild.users", "options": {"users": {"alice": {"uid": 1000}}}},
+        {"type": "org.osbuild.users", "options": {"users": {"bob": {}}}}
+      ]
+    }
+  ],
+  "sources": {}
+}`))
+	require.NoError(t, err)
+
+	assert.Nil(t, manifest.GetPipeline("image"))
+	os := manifest.GetPipeline("os")
+	require.NotNil(t, os)
+
+	users := FindStageOptions[*UsersStageOptions](os)
+	require.Len(t, users, 2)
+	assert.Equal(t, common.ToPtr(1000), users[0].Users["alice"].UID)
+	assert.Contains(t, users[1].Users, "bob")
+
+	cmdlines := FindStageOptions[*KernelCmdlineStageOptions](os)
+	require.Len(t, cmdlines, 1)
+	assert.Equal(t, "ro console=ttyS0", cmdlines[0].KernelOpts)
+
+	assert.Empty(t, FindStageOptions[*LocaleStageOptions](os))
+	assert.Empty(t, FindStageOptions[*UsersStageOptions](nil))
+}
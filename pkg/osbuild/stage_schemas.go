@@ -0,0 +1,241 @@
+package osbuild
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/osbuild/images/data/stageschemas"
+	"github.com/osbuild/images/internal/jsonschema"
+)
+
+// StageSchemas are the JSON schemas of the options of osbuild stages,
+// as shipped by osbuild in its "stages/<type>.meta.json" files. They are
+// used to validate stage options without running osbuild.
+type StageSchemas struct {
+	schemas map[string]*jsonschema.Schema
+	// droppedPatterns are the patterns of the schemas that are not
+	// validated, see DroppedPatterns()
+	droppedPatterns map[string][]string
+}
+
+// stageMeta is the part of the "<type>.meta.json" file of a stage that
+// contains the schemas, "schema" for stages of manifest version 1 and
+// "schema_2" for version 2.
+type stageMeta struct {
+	Schema  *jsonschema.Schema `json:"schema"`
+	Schema2 *struct {
+		Options     *jsonschema.Schema            `json:"options"`
+		Definitions map[string]*jsonschema.Schema `json:"definitions"`
+	} `json:"schema_2"`
+}
+
+// optionsSchema returns the schema of the options of the stage, stages
+// without a schema for their options do not take any options. The
+// patterns that were dropped from the schema are returned as well.
+func (m *stageMeta) optionsSchema() (*jsonschema.Schema, []string) {
+	var s *jsonschema.Schema
+	switch {
+	case m.Schema2 != nil && m.Schema2.Options != nil:
+		s = m.Schema2.Options
+		if len(m.Schema2.Definitions) > 0 && s.Definitions == nil {
+			s.Definitions = m.Schema2.Definitions
+		}
+	case m.Schema != nil:
+		s = m.Schema
+	default:
+		s = &jsonschema.Schema{AdditionalProperties: false}
+	}
+	if s.Type == "" && len(s.Types) == 0 {
+		s.Type = "object"
+	}
+
+	// the schemas are written for python, drop the patterns that
+	// are not supported by jsonschema.CompilePattern() (e.g.
+	// lookaheads) instead of failing the validation of all options
+	var dropped []string
+	s.Walk(func(sub *jsonschema.Schema) {
+		if sub.Pattern != "" {
+			if _, err := jsonschema.CompilePattern(sub.Pattern); err != nil {
+				dropped = append(dropped, sub.Pattern)
+				sub.Pattern = ""
+			}
+		}
+	})
+	return s, dropped
+}
+
+// NewStageSchemasFromFS loads the stage schemas from the
+// "<type>.meta.json" files in the root of fsys.
+func NewStageSchemasFromFS(fsys fs.FS) (*StageSchemas, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	schemas := make(map[string]*jsonschema.Schema)
+	droppedPatterns := make(map[string][]string)
+	for _, entry := range entries {
+		stageType, ok := strings.CutSuffix(entry.Name(), ".meta.json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		var meta stageMeta
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return nil, fmt.Errorf("cannot load schema of stage %q: %w", stageType, err)
+		}
+		schema, dropped := meta.optionsSchema()
+		schemas[stageType] = schema
+		if len(dropped) > 0 {
+			droppedPatterns[stageType] = dropped
+		}
+	}
+	return &StageSchemas{schemas: schemas, droppedPatterns: droppedPatterns}, nil
+}
+
+// NewStageSchemasFromDir loads the stage schemas from a directory with
+// "<type>.meta.json" files, e.g. the stages directory of an osbuild
+// installation ("/usr/lib/osbuild/stages").
+func NewStageSchemasFromDir(dir string) (*StageSchemas, error) {
+	return NewStageSchemasFromFS(os.DirFS(dir))
+}
+
+// DefaultStageSchemas returns the stage schemas of the snapshot that is
+// embedded in the library, it only contains the schemas of some stages.
+func DefaultStageSchemas() (*StageSchemas, error) {
+	return NewStageSchemasFromFS(stageschemas.Data)
+}
+
+// Types returns the sorted stage types that have a schema
+func (s *StageSchemas) Types() []string {
+	types := make([]string, 0, len(s.schemas))
+	for t := range s.schemas {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// DroppedPatterns returns the patterns of the schema of the stage type
+// that are not supported by jsonschema.CompilePattern(), e.g. because
+// they use lookaheads. The values of these patterns are not validated.
+func (s *StageSchemas) DroppedPatterns(stageType string) []string {
+	return s.droppedPatterns[stageType]
+}
+
+// StageOptionsError is a single problem in the options of a stage.
+type StageOptionsError struct {
+	// Pipeline and Index locate the stage in a manifest, the
+	// pipeline is empty if a single stage was validated
+	Pipeline string
+	Index    int
+	Type     string
+
+	// Path is the location in the options, e.g. "/users/alice/uid"
+	Path string
+	Msg  string
+}
+
+func (e *StageOptionsError) Error() string {
+	p := e.Path
+	if p == "" {
+		p = "/"
+	}
+	if e.Pipeline == "" {
+		return fmt.Sprintf("%s: options %s: %s", e.Type, p, e.Msg)
+	}
+	return fmt.Sprintf("pipeline %q, stage %d (%s): options %s: %s", e.Pipeline, e.Index, e.Type, p, e.Msg)
+}
+
+// validate validates the decoded options of a stage, stages without a
+// schema are not validated
+func (s *StageSchemas) validate(pipeline string, index int, stageType string, options any) []error {
+	schema, ok := s.schemas[stageType]
+	if !ok {
+		return nil
+	}
+	if options == nil {
+		options = map[string]any{}
+	}
+	err := schema.Validate(options)
+	if err == nil {
+		return nil
+	}
+	var errs []error
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var verr *jsonschema.ValidationError
+		if !errors.As(e, &verr) {
+			errs = append(errs, e)
+			continue
+		}
+		errs = append(errs, &StageOptionsError{
+			Pipeline: pipeline,
+			Index:    index,
+			Type:     stageType,
+			Path:     verr.Path,
+			Msg:      verr.Msg,
+		})
+	}
+	return errs
+}
+
+// ValidateStage validates the options of a stage against the schema of
+// its type. All errors are returned joined, each one is a
+// *StageOptionsError.
+func (s *StageSchemas) ValidateStage(stage *Stage) error {
+	options, err := decodeOptions(stage.Options)
+	if err != nil {
+		return fmt.Errorf("cannot encode options of stage %q: %w", stage.Type, err)
+	}
+	return errors.Join(s.validate("", 0, stage.Type, options)...)
+}
+
+// ValidateManifest validates the options of all stages of a serialized
+// manifest. All errors are returned joined, each one is a
+// *StageOptionsError.
+func (s *StageSchemas) ValidateManifest(data []byte) error {
+	var manifest struct {
+		Pipelines []struct {
+			Name   string `json:"name"`
+			Stages []struct {
+				Type    string `json:"type"`
+				Options any    `json:"options"`
+			} `json:"stages"`
+		} `json:"pipelines"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("cannot decode manifest: %w", err)
+	}
+
+	var errs []error
+	for _, p := range manifest.Pipelines {
+		for idx, stage := range p.Stages {
+			errs = append(errs, s.validate(p.Name, idx, stage.Type, stage.Options)...)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// decodeOptions returns the options as they are serialized, as a tree
+// of maps, slices and scalars
+func decodeOptions(options StageOptions) (any, error) {
+	if options == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(options)
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
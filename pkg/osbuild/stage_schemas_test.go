@@ -0,0 +1,306 @@
+package osbuild
+
+import (
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/common"
+)
+
+func TestDefaultStageSchemas(t *testing.T) {
+	schemas, err := DefaultStageSchemas()
+	require.NoError(t, err)
+	assert.Contains(t, schemas.Types(), "org.osbuild.locale")
+	assert.Contains(t, schemas.Types(), "org.osbuild.mkfs.fat")
+}
+
+func TestStageSchemasValidateStage(t *testing.T) {
+	schemas, err := DefaultStageSchemas()
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name        string
+		stage       *Stage
+		expectedErr string
+	}{
+		{
+			name:  "locale",
+			stage: NewLocaleStage(&LocaleStageOptions{Language: "en_US.UTF-8"}),
+		},
+		{
+			name: "users",
+			stage: NewUsersStage(&UsersStageOptions{
+				Users: map[string]UsersStageOptionsUser{
+					"alice": {UID: common.ToPtr(1000), Groups: []string{"wheel"}},
+				},
+			}),
+		},
+		{
+			name: "users-bad-name",
+			stage: NewUsersStage(&UsersStageOptions{
+				Users: map[string]UsersStageOptionsUser{
+					"-alice": {},
+				},
+			}),
+			expectedErr: `org.osbuild.users: options /users: unknown property "-alice"`,
+		},
+		{
+			name: "chrony-refclock",
+			stage: NewChronyStage(&ChronyStageOptions{
+				Refclocks: []ChronyConfigRefclock{
+					{Driver: NewChronyDriverSHM(0)},
+				},
+			}),
+		},
+		{
+			name: "chrony-bad-minpoll",
+			// the constructors validate the options too, create the
+			// invalid stages directly
+			stage: &Stage{
+				Type: "org.osbuild.chrony",
+				Options: &ChronyStageOptions{
+					Servers: []ChronyConfigServer{
+						{Hostname: "ntp.example.com", Minpoll: common.ToPtr(42)},
+					},
+				},
+			},
+			expectedErr: "org.osbuild.chrony: options /servers/0/minpoll: value 42 is bigger than 24",
+		},
+		{
+			name: "fat-enum",
+			stage: &Stage{
+				Type: "org.osbuild.mkfs.fat",
+				Options: &MkfsFATStageOptions{
+					VolID:   "7b7795e7",
+					FATSize: common.ToPtr(24),
+				},
+			},
+			expectedErr: "org.osbuild.mkfs.fat: options /fat-size: value 24 is not one of [12 16 32]",
+		},
+		{
+			name:        "missing-required",
+			stage:       NewLocaleStage(nil),
+			expectedErr: `org.osbuild.locale: options /: missing required property "language"`,
+		},
+		{
+			name: "no-schema",
+			stage: &Stage{
+				Type:    "org.osbuild.not-in-the-snapshot",
+				Options: &LocaleStageOptions{},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := schemas.ValidateStage(tc.stage)
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+func TestStageSchemasValidateManifest(t *testing.T) {
+	fsys := fstest.MapFS{
+		"org.osbuild.test.meta.json": &fstest.MapFile{Data: []byte(`{
+  "summary": "A test stage",
+  "schema_2": {
+    "definitions": {
+      "mode": {"enum": ["a", "b"]}
+    },
+    "options": {
+      "additionalProperties": false,
+      "required": ["mode"],
+      "properties": {
+        "mode": {"$ref": "#/definitions/mode"},
+        "name": {"type": "string", "pattern": "^(?!foo)"}
+      }
+    }
+  }
+}`)},
+		"org.osbuild.nooptions.meta.json": &fstest.MapFile{Data: []byte(`{"summary": "No options"}`)},
+		"README.md":                       &fstest.MapFile{Data: []byte("not a schema")},
+	}
+	schemas, err := NewStageSchemasFromFS(fsys)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"org.osbuild.nooptions", "org.osbuild.test"}, schemas.Types())
+	// the lookahead cannot be validated
+	assert.Equal(t, []string{"^(?!foo)"}, schemas.DroppedPatterns("org.osbuild.test"))
+	assert.Empty(t, schemas.DroppedPatterns("org.osbuild.nooptions"))
+
+	manifest := []byte(`{
+  "version": "2",
+  "pipelines": [
+    {
+      "name": "os",
+      "stages": [
+        {"type": "org.osbuild.test", "options": {"mode": "a", "name": "foo"}},
+        {"type": "org.osbuild.test", "options": {"mode": "c"}},
+        {"type": "org.osbuild.nooptions"},
+        {"type": "org.osbuild.nooptions", "options": {"foo": 1}},
+        {"type": "org.osbuild.unknown", "options": {"foo": 1}}
+      ]
+    },
+    {
+      "name": "image",
+      "stages": [
+        {"type": "org.osbuild.test"}
+      ]
+    }
+  ]
+}`)
+	err = schemas.ValidateManifest(manifest)
+	assert.EqualError(t, err, `pipeline "os", stage 1 (org.osbuild.test): options /mode: value "c" is not one of ["a" "b"]
+pipeline "os", stage 3 (org.osbuild.nooptions): options /: unknown property "foo"
+pipeline "image", stage 0 (org.osbuild.test): options /: missing required property "mode"`)
+
+	var optsErr *StageOptionsError
+	require.ErrorAs(t, err, &optsErr)
+	assert.Equal(t, &StageOptionsError{
+		Pipeline: "os",
+		Index:    1,
+		Type:     "org.osbuild.test",
+		Path:     "/mode",
+		Msg:      `value "c" is not one of ["a" "b"]`,
+	}, optsErr)
+
+	assert.EqualError(t, schemas.ValidateManifest([]byte("{")), "cannot decode manifest: unexpected end of JSON input")
+}
+
+// stagesWithoutSchemaSnapshot are the stage types that are generated by
+// the library but have no schema in the snapshot yet (see
+// data/stageschemas/README.md). Copy the schema of a stage from osbuild
+// to remove it from the list.
+var stagesWithoutSchemaSnapshot = []string{
+	"org.osbuild.anaconda",
+	"org.osbuild.authconfig",
+	"org.osbuild.authselect",
+	"org.osbuild.bootc.install-to-filesystem",
+	"org.osbuild.bootc.install.config",
+	"org.osbuild.bootiso.mono",
+	"org.osbuild.bootupd",
+	"org.osbuild.btrfs.subvol",
+	"org.osbuild.buildstamp",
+	"org.osbuild.chmod",
+	"org.osbuild.chown",
+	"org.osbuild.clevis.luks-bind",
+	"org.osbuild.cloud-init",
+	"org.osbuild.container-deploy",
+	"org.osbuild.containers.storage.conf",
+	"org.osbuild.copy",
+	"org.osbuild.discinfo",
+	"org.osbuild.dnf-automatic.config",
+	"org.osbuild.dnf.config",
+	"org.osbuild.dnf.module-config",
+	"org.osbuild.dnf4.versionlock",
+	"org.osbuild.dracut",
+	"org.osbuild.dracut.conf",
+	"org.osbuild.erofs",
+	"org.osbuild.first-boot",
+	"org.osbuild.fstab",
+	"org.osbuild.gcp.guest-agent.conf",
+	"org.osbuild.grub2",
+	"org.osbuild.grub2.inst",
+	"org.osbuild.grub2.iso",
+	"org.osbuild.grub2.iso.legacy",
+	"org.osbuild.grub2.legacy",
+	"org.osbuild.gzip",
+	"org.osbuild.hmac",
+	"org.osbuild.ignition",
+	"org.osbuild.implantisomd5",
+	"org.osbuild.insights-client.config",
+	"org.osbuild.isolinux",
+	"org.osbuild.kickstart",
+	"org.osbuild.lorax-script",
+	"org.osbuild.luks2.format",
+	"org.osbuild.luks2.remove-key",
+	"org.osbuild.lvm2.metadata",
+	"org.osbuild.machine-id",
+	"org.osbuild.mkfs.btrfs",
+	"org.osbuild.mkfs.f2fs",
+	"org.osbuild.mkswap",
+	"org.osbuild.nginx.conf",
+	"org.osbuild.nm.conf",
+	"org.osbuild.oci-archive",
+	"org.osbuild.oscap.autotailor",
+	"org.osbuild.oscap.remediation",
+	"org.osbuild.ostree.commit",
+	"org.osbuild.ostree.config",
+	"org.osbuild.ostree.deploy",
+	"org.osbuild.ostree.deploy.container",
+	"org.osbuild.ostree.encapsulate",
+	"org.osbuild.ostree.fillvar",
+	"org.osbuild.ostree.init",
+	"org.osbuild.ostree.os-init",
+	"org.osbuild.ostree.passwd",
+	"org.osbuild.ostree.preptree",
+	"org.osbuild.ostree.pull",
+	"org.osbuild.ostree.remotes",
+	"org.osbuild.ostree.selinux",
+	"org.osbuild.ovf",
+	"org.osbuild.pam.limits.conf",
+	"org.osbuild.pwquality.conf",
+	"org.osbuild.qemu",
+	"org.osbuild.rhsm",
+	"org.osbuild.rhsm.facts",
+	"org.osbuild.rpm",
+	"org.osbuild.selinux.config",
+	"org.osbuild.sfdisk",
+	"org.osbuild.sgdisk",
+	"org.osbuild.shell.init",
+	"org.osbuild.skopeo",
+	"org.osbuild.squashfs",
+	"org.osbuild.sshd.config",
+	"org.osbuild.sysconfig",
+	"org.osbuild.sysctld",
+	"org.osbuild.systemd-journald",
+	"org.osbuild.systemd-logind",
+	"org.osbuild.systemd.preset",
+	"org.osbuild.systemd.unit",
+	"org.osbuild.systemd.unit.create",
+	"org.osbuild.tar",
+	"org.osbuild.tmpfilesd",
+	"org.osbuild.tuned",
+	"org.osbuild.udev.rules",
+	"org.osbuild.update-crypto-policies",
+	"org.osbuild.vagrant",
+	"org.osbuild.waagent.conf",
+	"org.osbuild.write-device",
+	"org.osbuild.wsl-distribution.conf",
+	"org.osbuild.wsl.conf",
+	"org.osbuild.xorrisofs",
+	"org.osbuild.xz",
+	"org.osbuild.yum.config",
+	"org.osbuild.yum.repos",
+	"org.osbuild.zipl",
+	"org.osbuild.zipl.inst",
+	"org.osbuild.zstd",
+}
+
+func TestDefaultStageSchemasCoverage(t *testing.T) {
+	schemas, err := DefaultStageSchemas()
+	require.NoError(t, err)
+
+	for _, stageType := range KnownStageTypes() {
+		hasSchema := slices.Contains(schemas.Types(), stageType)
+		missing := slices.Contains(stagesWithoutSchemaSnapshot, stageType)
+		switch {
+		case !hasSchema && !missing:
+			t.Errorf("stage %q has no schema in the snapshot, add it to data/stageschemas", stageType)
+		case hasSchema && missing:
+			t.Errorf("stage %q has a schema in the snapshot, remove it from stagesWithoutSchemaSnapshot", stageType)
+		}
+		assert.Empty(t, schemas.DroppedPatterns(stageType), "schema of %q has patterns that cannot be validated", stageType)
+	}
+	for _, stageType := range schemas.Types() {
+		assert.Contains(t, KnownStageTypes(), stageType, "schema snapshot of unknown stage")
+	}
+	for _, stageType := range stagesWithoutSchemaSnapshot {
+		assert.Contains(t, KnownStageTypes(), stageType, "stagesWithoutSchemaSnapshot lists an unknown stage")
+	}
+}
@@ -246,8 +246,9 @@ func NewSystemdUnitCreateStage(options *SystemdUnitCreateStageOptions) *Stage {
 
 // GenSystemdMountStages generates a collection of
 // org.osbuild.systemd.unit.create stages with options to create systemd mount
-// units, one for each mountpoint in the partition table.
-func GenSystemdMountStages(pt *disk.PartitionTable) ([]*Stage, error) {
+// units, one for each mountpoint in the partition table and the partition
+// tables of the data disks of the image (if any).
+func GenSystemdMountStages(pt *disk.PartitionTable, dataPTs ...*disk.PartitionTable) ([]*Stage, error) {
 	mountStages := make([]*Stage, 0)
 	unitNames := make([]string, 0)
 
@@ -283,6 +284,12 @@ func GenSystemdMountStages(pt *disk.PartitionTable) ([]*Stage, error) {
 			// vfat IDs aren't lowercased
 			device = filepath.Join("/dev/disk/by-uuid", fsSpec.UUID)
 		}
+		if fs, ok := ent.(*disk.Filesystem); ok && fs.ReadOnly() {
+			device, err = readOnlyFSDevice(ent, path)
+			if err != nil {
+				return err
+			}
+		}
 
 		switch ent.GetFSType() {
 		case "swap":
@@ -306,9 +313,17 @@ func GenSystemdMountStages(pt *disk.PartitionTable) ([]*Stage, error) {
 		return nil
 	}
 
-	err := pt.ForEachFSTabEntity(genOption)
-	if err != nil {
-		return nil, err
+	for _, pt := range append([]*disk.PartitionTable{pt}, dataPTs...) {
+		err := pt.ForEachFSTabEntity(func(ent disk.FSTabEntity, path []disk.Entity) error {
+			// mounted by systemd-gpt-auto-generator
+			if pt.DPSAutoMounted(ent, path) {
+				return nil
+			}
+			return genOption(ent, path)
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// sort the entries by filename for stable ordering
@@ -137,14 +137,29 @@ type UdevRule interface {
 	isUdevRule()
 }
 
+// UnmarshalJSON decodes the rules from the format of the distro
+// definitions (see UdevKV) or from the format they are serialized in.
 func (u *UdevRules) UnmarshalJSON(data []byte) error {
-	var rawRules []map[string]interface{}
-	if err := json.Unmarshal(data, &rawRules); err != nil {
+	var rawRulesList []json.RawMessage
+	if err := json.Unmarshal(data, &rawRulesList); err != nil {
 		return err
 	}
 
 	var newRules []UdevRule
-	for _, rawRule := range rawRules {
+	for _, rawRuleData := range rawRulesList {
+		// serialized rules are lists of ops
+		if len(rawRuleData) > 0 && rawRuleData[0] == '[' {
+			ops, err := unmarshalUdevOps(rawRuleData)
+			if err != nil {
+				return err
+			}
+			newRules = append(newRules, ops)
+			continue
+		}
+		var rawRule map[string]interface{}
+		if err := json.Unmarshal(rawRuleData, &rawRule); err != nil {
+			return err
+		}
 		if v, ok := rawRule["comment"].([]interface{}); ok {
 			var vs []string
 			for _, vv := range v {
@@ -177,6 +192,31 @@ func (u *UdevRules) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+func unmarshalUdevOps(data []byte) (UdevOps, error) {
+	var rawOps []struct {
+		Key   json.RawMessage `json:"key"`
+		Op    string          `json:"op"`
+		Value string          `json:"val"`
+	}
+	if err := json.Unmarshal(data, &rawOps); err != nil {
+		return nil, err
+	}
+	ops := make(UdevOps, 0, len(rawOps))
+	for _, rawOp := range rawOps {
+		var key string
+		if err := json.Unmarshal(rawOp.Key, &key); err == nil {
+			ops = append(ops, UdevOpSimple{Key: key, Op: rawOp.Op, Value: rawOp.Value})
+			continue
+		}
+		var argKey UdevRuleKeyArg
+		if err := json.Unmarshal(rawOp.Key, &argKey); err != nil {
+			return nil, fmt.Errorf("invalid udev rule key: %w", err)
+		}
+		ops = append(ops, UdevOpArg{Key: argKey, Op: rawOp.Op, Value: rawOp.Value})
+	}
+	return ops, nil
+}
+
 func (u *UdevRules) UnmarshalYAML(unmarshal func(any) error) error {
 	return common.UnmarshalYAMLviaJSON(u, unmarshal)
 }
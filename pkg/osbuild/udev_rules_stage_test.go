@@ -1,6 +1,7 @@
 package osbuild
 
 import (
+	"encoding/json"
 	"testing"
 
 	"gopkg.in/yaml.v3"
@@ -246,3 +247,22 @@ rules:
 	}
 	assert.Equal(t, expected, options)
 }
+
+func TestUdevRuleUnmarshalSerialized(t *testing.T) {
+	options := UdevRulesStageOptions{
+		Filename: "/etc/udev/rules.d/68-azure-sriov-nm-unmanaged.rules",
+		Rules: UdevRules{
+			NewUdevRuleComment([]string{"comment1"}),
+			NewUdevRule([]UdevKV{
+				{K: "SUBSYSTEM", O: "==", V: "net"},
+				{K: "ENV", A: "NM_UNMANAGED", O: "=", V: "1"},
+			}),
+		},
+	}
+	data, err := json.Marshal(options)
+	require.NoError(t, err)
+
+	var decoded UdevRulesStageOptions
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, options, decoded)
+}
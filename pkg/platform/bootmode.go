@@ -23,3 +23,15 @@ func (m BootMode) String() string {
 		panic("invalid boot mode")
 	}
 }
+
+// BootModeFor returns the boot mode that the given platform supports
+func BootModeFor(p Platform) BootMode {
+	if p.GetUEFIVendor() != "" && p.GetBIOSPlatform() != "" {
+		return BOOT_HYBRID
+	} else if p.GetUEFIVendor() != "" {
+		return BOOT_UEFI
+	} else if p.GetBIOSPlatform() != "" || p.GetZiplSupport() {
+		return BOOT_LEGACY
+	}
+	return BOOT_NONE
+}
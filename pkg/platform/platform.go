@@ -33,6 +33,25 @@ const ( // bootloader enum
 	BOOTLOADER_UKI
 )
 
+func (b Bootloader) String() string {
+	switch b {
+	case BOOTLOADER_NONE:
+		return "none"
+	case BOOTLOADER_GRUB2:
+		return "grub2"
+	case BOOTLOADER_ZIPL:
+		return "zipl"
+	case BOOTLOADER_UKI:
+		return "uki"
+	default:
+		panic(fmt.Errorf("unknown bootloader %d", b))
+	}
+}
+
+func (b Bootloader) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.String())
+}
+
 func (b *Bootloader) UnmarshalJSON(data []byte) (err error) {
 	var s string
 	if err := json.Unmarshal(data, &s); err != nil {
@@ -89,6 +108,10 @@ func (f ImageFormat) String() string {
 	}
 }
 
+func (f ImageFormat) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.String())
+}
+
 func (f *ImageFormat) UnmarshalJSON(data []byte) error {
 	var s string
 	if err := json.Unmarshal(data, &s); err != nil {
@@ -47,3 +47,29 @@ func TestImageFormatUnmarshal(t *testing.T) {
 		assert.Equal(t, ifmt, f)
 	}
 }
+
+func TestImageFormatMarshalJSON(t *testing.T) {
+	b, err := json.Marshal(platform.FORMAT_QCOW2)
+	assert.NoError(t, err)
+	assert.Equal(t, `"qcow2"`, string(b))
+}
+
+func TestBootloaderString(t *testing.T) {
+	for _, bl := range []platform.Bootloader{
+		platform.BOOTLOADER_NONE,
+		platform.BOOTLOADER_GRUB2,
+		platform.BOOTLOADER_ZIPL,
+		platform.BOOTLOADER_UKI,
+	} {
+		parsed, err := platform.FromString(bl.String())
+		assert.NoError(t, err)
+		assert.Equal(t, bl, parsed)
+
+		b, err := json.Marshal(bl)
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("%q", bl.String()), string(b))
+	}
+	assert.PanicsWithError(t, "unknown bootloader 999", func() {
+		_ = platform.Bootloader(999).String()
+	})
+}